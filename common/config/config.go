@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -87,8 +88,36 @@ func GetJwtSecret() string {
 	return os.Getenv("JWT_SECRET")
 }
 
-func GetPasswordResetKey() string {
-	return os.Getenv("PASSWORD_RESET_KEY")
+// GetCursorSecret retorna a chave que assina os cursores de paginacao
+// (CURSOR_SECRET); sem ela usa JWT_SECRET
+func GetCursorSecret() string {
+	if secret := os.Getenv("CURSOR_SECRET"); secret != "" {
+		return secret
+	}
+	return GetJwtSecret()
+}
+
+// GetPixWebhookSecret retorna o segredo compartilhado do webhook Pix da EFI
+// (PIX_WEBHOOK_SECRET), enviado em ?hmac= na URL cadastrada
+func GetPixWebhookSecret() string {
+	return os.Getenv("PIX_WEBHOOK_SECRET")
+}
+
+// GetPixMerchantName retorna o nome do recebedor no QR Code das cobrancas da
+// plataforma (PIX_MERCHANT_NAME)
+func GetPixMerchantName() string {
+	if name := os.Getenv("PIX_MERCHANT_NAME"); name != "" {
+		return name
+	}
+	return "BACK SORTE"
+}
+
+// GetPixMerchantCity retorna a cidade do recebedor no QR Code (PIX_MERCHANT_CITY)
+func GetPixMerchantCity() string {
+	if city := os.Getenv("PIX_MERCHANT_CITY"); city != "" {
+		return city
+	}
+	return "SAO PAULO"
 }
 
 func GetawsBucketNameImgDoacao() string {
@@ -99,3 +128,34 @@ func GetDynamoTableName() string {
 	return os.Getenv("DYNAMODB_TABLE")
 }
 
+func GetJwtIssuer() string {
+	return os.Getenv("JWT_ISSUER")
+}
+
+func GetJwtAudience() string {
+	return os.Getenv("JWT_AUDIENCE")
+}
+
+// GetJwtAccessTTL retorna a validade do token de acesso (JWT_ACCESS_TTL, ex: "15m", "1h")
+func GetJwtAccessTTL() time.Duration {
+	return durationFromEnv("JWT_ACCESS_TTL", 15*time.Minute)
+}
+
+// GetJwtRefreshTTL retorna a validade da sessao de refresh token (JWT_REFRESH_TTL, ex: "720h")
+func GetJwtRefreshTTL() time.Duration {
+	return durationFromEnv("JWT_REFRESH_TTL", 30*24*time.Hour)
+}
+
+func durationFromEnv(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("%s invalido (%q). Usando %s como padrao.", key, value, fallback)
+		return fallback
+	}
+	return d
+}
+
@@ -0,0 +1,230 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"BACK_SORTE_GO/common/config"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/gorilla/mux"
+)
+
+// Principal identifica quem fez a requisicao: um usuario ou, em tokens
+// client_credentials, um cliente de maquina (UserID vazio).
+type Principal struct {
+	UserID   string
+	ClientID string
+	Roles    []string
+	Scopes   []string
+	TokenID  string
+	MFAAt    time.Time // ultima verificacao do segundo fator; zero quando nao houve
+}
+
+// AccessClaims sao as claims do token de acesso emitido pelo login.
+// Scope segue o formato OAuth: escopos separados por espaco; MFAAt e o unix
+// time da ultima verificacao TOTP.
+type AccessClaims struct {
+	Roles    []string `json:"roles,omitempty"`
+	ClientID string   `json:"client_id,omitempty"`
+	Scope    string   `json:"scope,omitempty"`
+	MFAAt    int64    `json:"mfa_at,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// ClientSubjectPrefix marca o sub de tokens emitidos para clientes de maquina.
+const ClientSubjectPrefix = "client:"
+
+// RoleAdmin e o papel da equipe de suporte/administracao (campo roles do PROFILE).
+const RoleAdmin = "ADMIN"
+
+type AuthConfig struct {
+	Secret   []byte
+	Issuer   string
+	Audience string
+}
+
+var (
+	ErrAuthNotConfigured = errors.New("JWT_SECRET nao definido")
+	ErrTokenInvalid      = errors.New("token invalido")
+)
+
+type principalKey struct{}
+
+func AuthConfigFromEnv() AuthConfig {
+	return AuthConfig{
+		Secret:   []byte(config.GetJwtSecret()),
+		Issuer:   config.GetJwtIssuer(),
+		Audience: config.GetJwtAudience(),
+	}
+}
+
+// ParseAccessToken valida assinatura (somente HS256), expiracao, issuer e audience.
+func ParseAccessToken(cfg AuthConfig, tokenString string) (Principal, error) {
+	if len(cfg.Secret) == 0 {
+		return Principal{}, ErrAuthNotConfigured
+	}
+
+	claims := &AccessClaims{}
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	token, err := parser.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return cfg.Secret, nil
+	})
+	if err != nil || !token.Valid {
+		return Principal{}, ErrTokenInvalid
+	}
+	if claims.ExpiresAt == nil || claims.Subject == "" {
+		return Principal{}, ErrTokenInvalid
+	}
+	if cfg.Issuer != "" && !claims.VerifyIssuer(cfg.Issuer, true) {
+		return Principal{}, ErrTokenInvalid
+	}
+	if cfg.Audience != "" && !claims.VerifyAudience(cfg.Audience, true) {
+		return Principal{}, ErrTokenInvalid
+	}
+
+	principal := Principal{
+		ClientID: claims.ClientID,
+		Roles:    claims.Roles,
+		Scopes:   strings.Fields(claims.Scope),
+		TokenID:  claims.ID,
+	}
+	if claims.MFAAt > 0 {
+		principal.MFAAt = time.Unix(claims.MFAAt, 0)
+	}
+	if clientID, ok := strings.CutPrefix(claims.Subject, ClientSubjectPrefix); ok {
+		principal.ClientID = clientID
+	} else {
+		principal.UserID = claims.Subject
+	}
+	return principal, nil
+}
+
+func (p Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (p Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func bearerToken(r *http.Request) string {
+	authHeader := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authHeader, "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimPrefix(authHeader, "Bearer "))
+}
+
+// RequireAuth rejeita a requisicao sem um Bearer token valido.
+func RequireAuth(cfg AuthConfig) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tokenString := bearerToken(r)
+			if tokenString == "" {
+				http.Error(w, "Token nao fornecido", http.StatusUnauthorized)
+				return
+			}
+
+			principal, err := ParseAccessToken(cfg, tokenString)
+			if errors.Is(err, ErrAuthNotConfigured) {
+				http.Error(w, "Autenticacao nao configurada", http.StatusInternalServerError)
+				return
+			}
+			if err != nil {
+				http.Error(w, "Token invalido", http.StatusUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+// OptionalAuth preenche o Principal quando ha um token valido, sem bloquear a requisicao.
+func OptionalAuth(cfg AuthConfig) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if tokenString := bearerToken(r); tokenString != "" {
+				if principal, err := ParseAccessToken(cfg, tokenString); err == nil {
+					r = r.WithContext(WithPrincipal(r.Context(), principal))
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireScope exige que o token (ja validado por RequireAuth) tenha o escopo informado.
+func RequireScope(scope string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := PrincipalFromContext(r.Context())
+			if !ok || !principal.HasScope(scope) {
+				http.Error(w, "Acesso negado", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireRole exige que o usuario do token (ja validado por RequireAuth) tenha um dos papeis informados.
+func RequireRole(roles ...string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := PrincipalFromContext(r.Context())
+			if ok && principal.UserID != "" {
+				for _, role := range roles {
+					if principal.HasRole(role) {
+						next.ServeHTTP(w, r)
+						return
+					}
+				}
+			}
+			http.Error(w, "Acesso negado", http.StatusForbidden)
+		})
+	}
+}
+
+// RequireRoleOrScope libera a rota para usuarios com o papel ou clientes de maquina com o escopo.
+func RequireRoleOrScope(role, scope string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := PrincipalFromContext(r.Context())
+			if !ok || !(principal.UserID != "" && principal.HasRole(role)) && !principal.HasScope(scope) {
+				http.Error(w, "Acesso negado", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+	return principal, ok
+}
+
+// UserIDFromContext retorna o id do usuario autenticado ou "" quando nao houver.
+func UserIDFromContext(ctx context.Context) string {
+	principal, _ := PrincipalFromContext(ctx)
+	return principal.UserID
+}
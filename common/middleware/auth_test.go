@@ -0,0 +1,147 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var testAuth = AuthConfig{Secret: []byte("segredo"), Issuer: "sorte-login", Audience: "sorte-api"}
+
+func signToken(t *testing.T, method jwt.SigningMethod, key interface{}, claims AccessClaims) string {
+	t.Helper()
+	s, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func validClaims(sub string) AccessClaims {
+	return AccessClaims{
+		Roles: []string{RoleAdmin},
+		Scope: "donations:read pix:write",
+		MFAAt: 1700000000,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   sub,
+			Issuer:    testAuth.Issuer,
+			Audience:  jwt.ClaimStrings{testAuth.Audience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			ID:        "jti-1",
+		},
+	}
+}
+
+func TestParseAccessToken(t *testing.T) {
+	hs256 := func(edit func(*AccessClaims)) string {
+		c := validClaims("u-1")
+		if edit != nil {
+			edit(&c)
+		}
+		return signToken(t, jwt.SigningMethodHS256, testAuth.Secret, c)
+	}
+
+	p, err := ParseAccessToken(testAuth, hs256(nil))
+	if err != nil || p.UserID != "u-1" || p.ClientID != "" || !p.HasRole(RoleAdmin) || !p.HasScope("pix:write") || p.TokenID != "jti-1" || p.MFAAt.Unix() != 1700000000 {
+		t.Fatalf("token valido = %+v, %v", p, err)
+	}
+	p, err = ParseAccessToken(testAuth, signToken(t, jwt.SigningMethodHS256, testAuth.Secret, validClaims(ClientSubjectPrefix+"c-1")))
+	if err != nil || p.UserID != "" || p.ClientID != "c-1" {
+		t.Fatalf("token de cliente = %+v, %v", p, err)
+	}
+
+	for name, tc := range map[string]struct {
+		cfg   AuthConfig
+		token string
+		want  error
+	}{
+		"alg none":        {testAuth, signToken(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, validClaims("u-1")), ErrTokenInvalid},
+		"alg HS512":       {testAuth, signToken(t, jwt.SigningMethodHS512, testAuth.Secret, validClaims("u-1")), ErrTokenInvalid},
+		"segredo errado":  {testAuth, signToken(t, jwt.SigningMethodHS256, []byte("outro"), validClaims("u-1")), ErrTokenInvalid},
+		"expirado":        {testAuth, hs256(func(c *AccessClaims) { c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute)) }), ErrTokenInvalid},
+		"sem exp":         {testAuth, hs256(func(c *AccessClaims) { c.ExpiresAt = nil }), ErrTokenInvalid},
+		"sem sub":         {testAuth, hs256(func(c *AccessClaims) { c.Subject = "" }), ErrTokenInvalid},
+		"issuer errado":   {testAuth, hs256(func(c *AccessClaims) { c.Issuer = "outro" }), ErrTokenInvalid},
+		"audience errada": {testAuth, hs256(func(c *AccessClaims) { c.Audience = jwt.ClaimStrings{"outra"} }), ErrTokenInvalid},
+		"malformado":      {testAuth, "abc.def.ghi", ErrTokenInvalid},
+		"sem segredo":     {AuthConfig{}, hs256(nil), ErrAuthNotConfigured},
+	} {
+		if _, err := ParseAccessToken(tc.cfg, tc.token); !errors.Is(err, tc.want) {
+			t.Errorf("%s: err = %v; want %v", name, err, tc.want)
+		}
+	}
+}
+
+func TestRequireAuth(t *testing.T) {
+	var got Principal
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = PrincipalFromContext(r.Context())
+	})
+	token := signToken(t, jwt.SigningMethodHS256, testAuth.Secret, validClaims("u-1"))
+
+	for name, tc := range map[string]struct {
+		cfg    AuthConfig
+		header string
+		want   int
+	}{
+		"valido":         {testAuth, "Bearer " + token, http.StatusOK},
+		"sem header":     {testAuth, "", http.StatusUnauthorized},
+		"sem bearer":     {testAuth, token, http.StatusUnauthorized},
+		"bearer vazio":   {testAuth, "Bearer ", http.StatusUnauthorized},
+		"token invalido": {testAuth, "Bearer abc", http.StatusUnauthorized},
+		"sem segredo":    {AuthConfig{}, "Bearer " + token, http.StatusInternalServerError},
+	} {
+		got = Principal{}
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tc.header != "" {
+			r.Header.Set("Authorization", tc.header)
+		}
+		w := httptest.NewRecorder()
+		RequireAuth(tc.cfg)(next).ServeHTTP(w, r)
+		if w.Code != tc.want {
+			t.Errorf("%s: status %d; want %d", name, w.Code, tc.want)
+		}
+		if tc.want == http.StatusOK && got.UserID != "u-1" {
+			t.Errorf("%s: principal = %+v", name, got)
+		}
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	admin := RequireRole(RoleAdmin, "SUPORTE")
+	adminOrScope := RequireRoleOrScope(RoleAdmin, "pix:monitor")
+
+	for name, tc := range map[string]struct {
+		mw        func(http.Handler) http.Handler
+		principal *Principal
+		want      int
+	}{
+		"papel":                        {admin, &Principal{UserID: "u-1", Roles: []string{RoleAdmin}}, http.StatusOK},
+		"outro papel da lista":         {admin, &Principal{UserID: "u-1", Roles: []string{"SUPORTE"}}, http.StatusOK},
+		"sem papel":                    {admin, &Principal{UserID: "u-1", Roles: []string{"DOADOR"}}, http.StatusForbidden},
+		"papel sem usuario":            {admin, &Principal{ClientID: "c-1", Roles: []string{RoleAdmin}}, http.StatusForbidden},
+		"escopo nao substitui":         {admin, &Principal{ClientID: "c-1", Scopes: []string{"pix:monitor"}}, http.StatusForbidden},
+		"sem principal":                {admin, nil, http.StatusForbidden},
+		"ou escopo: papel":             {adminOrScope, &Principal{UserID: "u-1", Roles: []string{RoleAdmin}}, http.StatusOK},
+		"ou escopo: escopo":            {adminOrScope, &Principal{ClientID: "c-1", Scopes: []string{"pix:monitor"}}, http.StatusOK},
+		"ou escopo: escopo errado":     {adminOrScope, &Principal{ClientID: "c-1", Scopes: []string{"donations:read"}}, http.StatusForbidden},
+		"ou escopo: sem papel":         {adminOrScope, &Principal{UserID: "u-1", Roles: []string{"DOADOR"}}, http.StatusForbidden},
+		"ou escopo: papel sem usuario": {adminOrScope, &Principal{ClientID: "c-1", Roles: []string{RoleAdmin}}, http.StatusForbidden},
+		"ou escopo: sem principal":     {adminOrScope, nil, http.StatusForbidden},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tc.principal != nil {
+			r = r.WithContext(WithPrincipal(r.Context(), *tc.principal))
+		}
+		w := httptest.NewRecorder()
+		tc.mw(next).ServeHTTP(w, r)
+		if w.Code != tc.want {
+			t.Errorf("%s: status %d; want %d", name, w.Code, tc.want)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"BACK_SORTE_GO/common/store"
+	"BACK_SORTE_GO/common/store/dynamo"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/gorilla/mux"
+)
+
+const (
+	// MFARecentWindow e por quanto tempo uma verificacao TOTP libera operacoes sensiveis.
+	MFARecentWindow = 10 * time.Minute
+	// MFASK e o SK do segundo fator do usuario (USER#{id} / MFA#TOTP).
+	MFASK = "MFA#TOTP"
+)
+
+// RequireRecentMFA deve vir depois de RequireAuth. Para usuarios com segundo fator
+// ativo exige um token com verificacao TOTP nos ultimos MFARecentWindow; quem nao
+// ativou o segundo fator segue normalmente.
+func RequireRecentMFA(storeDDB dynamo.Store) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := PrincipalFromContext(r.Context())
+			if !ok || principal.UserID == "" {
+				http.Error(w, "Token invalido", http.StatusUnauthorized)
+				return
+			}
+			if !principal.MFAAt.IsZero() && time.Since(principal.MFAAt) <= MFARecentWindow {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			item, err := storeDDB.GetItem(r.Context(), store.UserPK(principal.UserID), MFASK)
+			if err != nil {
+				http.Error(w, "Erro ao verificar segundo fator", http.StatusInternalServerError)
+				return
+			}
+			if enabled, ok := item["enabled"].(*types.AttributeValueMemberBOOL); ok && enabled.Value {
+				http.Error(w, "Verificacao do segundo fator necessaria", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
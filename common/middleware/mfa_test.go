@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"BACK_SORTE_GO/common/store"
+	"BACK_SORTE_GO/common/store/dynamo"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestRequireRecentMFA(t *testing.T) {
+	ctx := context.Background()
+	storeDDB := dynamo.NewMemory("core")
+	for id, enabled := range map[string]bool{"u-mfa": true, "u-pendente": false} {
+		err := storeDDB.PutItem(ctx, map[string]types.AttributeValue{
+			"PK":      dynamo.S(store.UserPK(id)),
+			"SK":      dynamo.S(MFASK),
+			"enabled": dynamo.B(enabled),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	for name, tc := range map[string]struct {
+		principal *Principal
+		want      int
+	}{
+		"mfa recente":           {&Principal{UserID: "u-mfa", MFAAt: time.Now().Add(-time.Minute)}, http.StatusOK},
+		"mfa antigo":            {&Principal{UserID: "u-mfa", MFAAt: time.Now().Add(-MFARecentWindow - time.Minute)}, http.StatusForbidden},
+		"sem mfa_at":            {&Principal{UserID: "u-mfa"}, http.StatusForbidden},
+		"sem segundo fator":     {&Principal{UserID: "u-sem-mfa"}, http.StatusOK},
+		"segundo fator inativo": {&Principal{UserID: "u-pendente"}, http.StatusOK},
+		"cliente de maquina":    {&Principal{ClientID: "batch"}, http.StatusUnauthorized},
+		"sem principal":         {nil, http.StatusUnauthorized},
+	} {
+		r := httptest.NewRequest(http.MethodPut, "/", nil)
+		if tc.principal != nil {
+			r = r.WithContext(WithPrincipal(r.Context(), *tc.principal))
+		}
+		w := httptest.NewRecorder()
+		RequireRecentMFA(storeDDB)(next).ServeHTTP(w, r)
+		if w.Code != tc.want {
+			t.Errorf("%s: status %d; want %d", name, w.Code, tc.want)
+		}
+	}
+}
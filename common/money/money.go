@@ -0,0 +1,348 @@
+// Package money representa valores monetarios em centavos inteiros, para que
+// somas de milhares de pagamentos nao acumulem erro de ponto flutuante.
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// BRL e a moeda padrao: Money sem moeda e tratado como real.
+const BRL = "BRL"
+
+// ErrCurrencyMismatch e o erro de Add, Sub, Cmp e Min entre moedas diferentes.
+var ErrCurrencyMismatch = errors.New("money: moedas diferentes")
+
+// Money e um valor em centavos com a moeda (ISO 4217, vazia e BRL). Na tabela
+// e gravado como numero em reais com duas casas ("10.50"), o mesmo formato
+// dos itens antigos, o que mantem os ADD atomicos dos agregados; a moeda nao
+// vai para a tabela, cujos valores sao todos em reais. Em JSON vira o numero
+// 10.50.
+type Money struct {
+	Centavos int64
+	Moeda    string
+}
+
+// Cents devolve o valor em reais com os centavos informados.
+func Cents(centavos int64) Money {
+	return Money{Centavos: centavos}
+}
+
+// New devolve o valor em centavos na moeda informada.
+func New(centavos int64, moeda string) Money {
+	return Money{Centavos: centavos, Moeda: strings.ToUpper(strings.TrimSpace(moeda))}
+}
+
+// Parse le reais com virgula ou ponto decimal ("10,50", "10.50", "10") e
+// aceita separador de milhar no formato brasileiro ("1.234,56"). Mais de
+// duas casas decimais e erro.
+func Parse(s string) (Money, error) {
+	s = strings.TrimSpace(s)
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	if s == "" {
+		return Money{}, errors.New("valor vazio")
+	}
+
+	intPart, fracPart := s, ""
+	if strings.Contains(s, ",") {
+		var ok bool
+		intPart, fracPart, ok = strings.Cut(s, ",")
+		if !ok || strings.Contains(fracPart, ",") {
+			return Money{}, errors.New("formato decimal invalido")
+		}
+		if strings.Contains(intPart, ".") {
+			if !validGrouping(intPart) {
+				return Money{}, errors.New("separador de milhar invalido")
+			}
+			intPart = strings.ReplaceAll(intPart, ".", "")
+		}
+	} else if strings.Contains(s, ".") {
+		var ok bool
+		intPart, fracPart, ok = strings.Cut(s, ".")
+		if !ok || strings.Contains(fracPart, ".") {
+			return Money{}, errors.New("formato decimal invalido")
+		}
+	}
+	if intPart == "" {
+		intPart = "0"
+	}
+	if !isDigits(intPart) || !isDigits(fracPart) {
+		return Money{}, errors.New("valor deve conter apenas digitos")
+	}
+	if len(fracPart) > 2 {
+		return Money{}, errors.New("use no maximo 2 casas decimais")
+	}
+	fracPart = (fracPart + "00")[:2]
+
+	reais, err := strconv.ParseInt(intPart, 10, 64)
+	if err != nil || reais > math.MaxInt64/100-1 {
+		return Money{}, errors.New("valor fora do limite")
+	}
+	centavos, _ := strconv.ParseInt(fracPart, 10, 64)
+	m := Cents(reais*100 + centavos)
+	if neg {
+		m = m.Neg()
+	}
+	return m, nil
+}
+
+// parseNumber le um numero ja gravado na tabela. Itens antigos guardaram
+// float64 sem arredondar ("0.30000000000000004"): o valor e arredondado ao
+// centavo mais proximo.
+func parseNumber(s string) (Money, error) {
+	if m, err := Parse(s); err == nil {
+		return m, nil
+	}
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok {
+		return Money{}, fmt.Errorf("numero invalido: %q", s)
+	}
+	return Cents(roundRat(r.Mul(r, big.NewRat(100, 1)))), nil
+}
+
+// roundRat arredonda ao inteiro mais proximo, metade para longe do zero.
+func roundRat(r *big.Rat) int64 {
+	q, rem := new(big.Int).QuoRem(r.Num(), r.Denom(), new(big.Int))
+	if rem.Sign() != 0 && new(big.Int).Mul(new(big.Int).Abs(rem), big.NewInt(2)).Cmp(r.Denom()) >= 0 {
+		q.Add(q, big.NewInt(int64(r.Num().Sign())))
+	}
+	return q.Int64()
+}
+
+func validGrouping(s string) bool {
+	groups := strings.Split(s, ".")
+	if len(groups[0]) == 0 || len(groups[0]) > 3 {
+		return false
+	}
+	for _, g := range groups[1:] {
+		if len(g) != 3 {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Currency devolve a moeda, BRL quando nao informada.
+func (m Money) Currency() string {
+	if m.Moeda == "" {
+		return BRL
+	}
+	return m.Moeda
+}
+
+// match confere que os valores sao da mesma moeda; zero combina com qualquer
+// moeda, para que o valor zero sirva de ponto de partida das somas.
+func (m Money) match(o Money) error {
+	if m.Currency() != o.Currency() && m.Centavos != 0 && o.Centavos != 0 {
+		return fmt.Errorf("%w: %s e %s", ErrCurrencyMismatch, m.Currency(), o.Currency())
+	}
+	return nil
+}
+
+// with devolve centavos na moeda do resultado de m e o.
+func (m Money) with(centavos int64, o Money) Money {
+	moeda := m.Moeda
+	if m.Centavos == 0 && moeda == "" {
+		moeda = o.Moeda
+	}
+	return Money{Centavos: centavos, Moeda: moeda}
+}
+
+// Add soma valores da mesma moeda.
+func (m Money) Add(o Money) (Money, error) {
+	if err := m.match(o); err != nil {
+		return Money{}, err
+	}
+	return m.with(m.Centavos+o.Centavos, o), nil
+}
+
+// Sub subtrai valores da mesma moeda.
+func (m Money) Sub(o Money) (Money, error) {
+	if err := m.match(o); err != nil {
+		return Money{}, err
+	}
+	return m.with(m.Centavos-o.Centavos, o), nil
+}
+
+// Neg troca o sinal.
+func (m Money) Neg() Money {
+	m.Centavos = -m.Centavos
+	return m
+}
+
+// Cmp compara com o valor da mesma moeda: -1, 0 ou 1.
+func (m Money) Cmp(o Money) (int, error) {
+	if err := m.match(o); err != nil {
+		return 0, err
+	}
+	switch {
+	case m.Centavos < o.Centavos:
+		return -1, nil
+	case m.Centavos > o.Centavos:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (m Money) IsZero() bool {
+	return m.Centavos == 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.Centavos < 0
+}
+
+// Percent devolve p por cento do valor, arredondado ao centavo (metade para
+// longe do zero).
+func (m Money) Percent(p float64) Money {
+	m.Centavos = int64(math.Round(float64(m.Centavos) * p / 100))
+	return m
+}
+
+// Prorate devolve a parte do valor proporcional a part/total, arredondada ao
+// centavo; total zero devolve zero.
+func (m Money) Prorate(part, total Money) Money {
+	if total.Centavos == 0 {
+		return Money{Moeda: m.Moeda}
+	}
+	num := new(big.Int).Mul(big.NewInt(m.Centavos), big.NewInt(part.Centavos))
+	m.Centavos = roundRat(new(big.Rat).SetFrac(num, big.NewInt(total.Centavos)))
+	return m
+}
+
+// Min devolve o menor dos dois valores da mesma moeda.
+func (m Money) Min(o Money) (Money, error) {
+	c, err := m.Cmp(o)
+	if err != nil {
+		return Money{}, err
+	}
+	if c <= 0 {
+		return m, nil
+	}
+	return o, nil
+}
+
+// Float64 devolve o valor na moeda; so para razoes (percentual da meta),
+// nunca para somar.
+func (m Money) Float64() float64 {
+	return float64(m.Centavos) / 100
+}
+
+// String devolve o valor com ponto e duas casas ("1234.56"), formato da EFI,
+// da tabela e do JSON.
+func (m Money) String() string {
+	c := m.Centavos
+	sign := ""
+	if c < 0 {
+		sign, c = "-", -c
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, c/100, c%100)
+}
+
+// Format devolve o valor para exibicao no padrao brasileiro, com o simbolo do
+// real ("R$ 1.234,56") ou o codigo das outras moedas ("USD 1.234,56").
+func (m Money) Format() string {
+	c := m.Centavos
+	sign := ""
+	if c < 0 {
+		sign, c = "-", -c
+	}
+	digits := strconv.FormatInt(c/100, 10)
+	var b strings.Builder
+	for i, r := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte('.')
+		}
+		b.WriteRune(r)
+	}
+	symbol := m.Currency()
+	if symbol == BRL {
+		symbol = "R$"
+	}
+	return fmt.Sprintf("%s%s %s,%02d", sign, symbol, b.String(), c%100)
+}
+
+// MarshalJSON grava o numero em reais com duas casas.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON aceita numero (10.5) ou texto ("10,50").
+func (m *Money) UnmarshalJSON(data []byte) error {
+	raw := strings.TrimSpace(string(data))
+	if raw == "null" {
+		*m = Money{}
+		return nil
+	}
+	if strings.HasPrefix(raw, `"`) {
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return err
+		}
+	}
+	v, err := Parse(raw)
+	if err != nil {
+		return err
+	}
+	m.Centavos = v.Centavos
+	return nil
+}
+
+// MarshalDynamoDBAttributeValue grava o numero em reais com duas casas. A
+// tabela nao guarda a moeda, entao valor em outra moeda e erro.
+func (m Money) MarshalDynamoDBAttributeValue() (types.AttributeValue, error) {
+	if m.Currency() != BRL {
+		return nil, fmt.Errorf("money: a tabela guarda valores em %s, nao em %s", BRL, m.Currency())
+	}
+	return &types.AttributeValueMemberN{Value: m.String()}, nil
+}
+
+// UnmarshalDynamoDBAttributeValue le numeros e, de itens antigos, textos; os
+// dois com a mesma leitura tolerante dos valores ja gravados.
+func (m *Money) UnmarshalDynamoDBAttributeValue(av types.AttributeValue) error {
+	var v Money
+	var err error
+	switch x := av.(type) {
+	case *types.AttributeValueMemberN:
+		v, err = parseNumber(x.Value)
+	case *types.AttributeValueMemberS:
+		if strings.TrimSpace(x.Value) != "" {
+			v, err = parseNumber(x.Value)
+		}
+	case *types.AttributeValueMemberNULL:
+	default:
+		return fmt.Errorf("money: tipo de atributo nao suportado %T", av)
+	}
+	if err != nil {
+		return err
+	}
+	m.Centavos = v.Centavos
+	return nil
+}
+
+// Text e um Money que em JSON vai como texto ("10.50"), para campos que a API
+// sempre devolveu como string. Na tabela e gravado como Money.
+type Text struct {
+	Money
+}
+
+// MarshalJSON grava o valor como texto com duas casas.
+func (t Text) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
@@ -0,0 +1,166 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestParse(t *testing.T) {
+	for in, want := range map[string]int64{
+		"10,50":        1050,
+		"10.50":        1050,
+		"10.5":         1050,
+		"10":           1000,
+		",99":          99,
+		"0,01":         1,
+		"1.234,56":     123456,
+		"12.345.678,9": 1234567890,
+		" -3,20 ":      -320,
+	} {
+		if got, err := Parse(in); err != nil || got.Centavos != want {
+			t.Errorf("Parse(%q) = %d, %v; want %d", in, got.Centavos, err, want)
+		}
+	}
+	for _, in := range []string{"", "abc", "10,505", "1.234", "1,2,3", "12.34,56", "1.234.5", "1e3", "99999999999999999999"} {
+		if _, err := Parse(in); err == nil {
+			t.Errorf("Parse(%q) deveria falhar", in)
+		}
+	}
+}
+
+func TestArithmeticAndFormat(t *testing.T) {
+	// 0.10 + 0.20 em float64 da 0.30000000000000004; em centavos e exato.
+	var total Money
+	var err error
+	for i := 0; i < 10000; i++ {
+		if total, err = total.Add(Cents(10)); err != nil {
+			t.Fatal(err)
+		}
+		if total, err = total.Add(Cents(20)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if total.String() != "3000.00" || total.Currency() != BRL {
+		t.Fatalf("total = %s %s", total, total.Currency())
+	}
+	if got := Cents(123456789).Format(); got != "R$ 1.234.567,89" {
+		t.Fatalf("Format = %s", got)
+	}
+	if got := Cents(-5).String(); got != "-0.05" {
+		t.Fatalf("String = %s", got)
+	}
+	if got := Cents(1005).Percent(10); got.Centavos != 101 {
+		t.Fatalf("Percent = %d", got.Centavos)
+	}
+	if got := Cents(1000).Prorate(Cents(1), Cents(3)); got.Centavos != 333 {
+		t.Fatalf("Prorate = %d", got.Centavos)
+	}
+	if got := Cents(4500).Prorate(Cents(2500), Cents(5000)); got.Centavos != 2250 {
+		t.Fatalf("Prorate = %d", got.Centavos)
+	}
+}
+
+func TestCurrency(t *testing.T) {
+	usd := New(1050, "usd")
+	if usd.Currency() != "USD" || Cents(1).Currency() != BRL || (Money{}).Currency() != BRL {
+		t.Fatalf("Currency = %s", usd.Currency())
+	}
+	if got := usd.Format(); got != "USD 10,50" {
+		t.Fatalf("Format = %s", got)
+	}
+
+	// Moedas diferentes sao erro, sem panic; zero combina com qualquer moeda.
+	if _, err := usd.Add(Cents(100)); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Fatalf("Add USD+BRL = %v", err)
+	}
+	if _, err := Cents(100).Sub(usd); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Fatalf("Sub BRL-USD = %v", err)
+	}
+	if _, err := usd.Cmp(Cents(100)); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Fatalf("Cmp USD/BRL = %v", err)
+	}
+	if _, err := usd.Min(Cents(100)); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Fatalf("Min USD/BRL = %v", err)
+	}
+	if got, err := (Money{}).Add(usd); err != nil || got != usd {
+		t.Fatalf("zero + USD = %+v, %v", got, err)
+	}
+	if got, err := usd.Sub(New(1050, "USD")); err != nil || !got.IsZero() || got.Currency() != "USD" {
+		t.Fatalf("USD - USD = %+v, %v", got, err)
+	}
+	if c, err := usd.Cmp(New(2000, "USD")); err != nil || c != -1 {
+		t.Fatalf("Cmp USD = %d, %v", c, err)
+	}
+
+	// A moeda nao vai para a tabela, cujos valores sao em reais.
+	if _, err := attributevalue.Marshal(usd); err == nil {
+		t.Fatal("valor em USD gravado na tabela")
+	}
+	if av, err := attributevalue.Marshal(New(1050, BRL)); err != nil || av.(*types.AttributeValueMemberN).Value != "10.50" {
+		t.Fatalf("dynamo BRL = %#v, %v", av, err)
+	}
+}
+
+func TestCodecs(t *testing.T) {
+	type item struct {
+		Valor Money `dynamodbav:"valor" json:"valor"`
+	}
+
+	av, err := attributevalue.MarshalMap(item{Valor: Cents(2550)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, ok := av["valor"].(*types.AttributeValueMemberN); !ok || n.Value != "25.50" {
+		t.Fatalf("dynamo = %#v", av["valor"])
+	}
+	for raw, want := range map[types.AttributeValue]int64{
+		&types.AttributeValueMemberN{Value: "25.5"}:                2550,
+		&types.AttributeValueMemberN{Value: "0.30000000000000004"}: 30,
+		&types.AttributeValueMemberN{Value: "44.995"}:              4500,
+		&types.AttributeValueMemberS{Value: "10,00"}:               1000,
+		&types.AttributeValueMemberS{Value: "19.999"}:              2000,
+		&types.AttributeValueMemberNULL{Value: true}:               0,
+	} {
+		var got item
+		if err := attributevalue.UnmarshalMap(map[string]types.AttributeValue{"valor": raw}, &got); err != nil || got.Valor.Centavos != want {
+			t.Errorf("unmarshal %#v = %d, %v", raw, got.Valor.Centavos, err)
+		}
+	}
+
+	b, _ := json.Marshal(item{Valor: Cents(1000)})
+	if string(b) != `{"valor":10.00}` {
+		t.Fatalf("json = %s", b)
+	}
+	for raw, want := range map[string]int64{`{"valor":10.5}`: 1050, `{"valor":"10,50"}`: 1050, `{"valor":null}`: 0} {
+		var got item
+		if err := json.Unmarshal([]byte(raw), &got); err != nil || got.Valor.Centavos != want {
+			t.Errorf("json %s = %d, %v", raw, got.Valor.Centavos, err)
+		}
+	}
+}
+
+func TestText(t *testing.T) {
+	type item struct {
+		Valor Text `dynamodbav:"valor" json:"valor"`
+	}
+	b, _ := json.Marshal(item{Valor: Text{Cents(990)}})
+	if string(b) != `{"valor":"9.90"}` {
+		t.Fatalf("json = %s", b)
+	}
+	var got item
+	if err := json.Unmarshal([]byte(`{"valor":"9,90"}`), &got); err != nil || got.Valor.Centavos != 990 {
+		t.Fatalf("json = %+v, %v", got, err)
+	}
+	av, err := attributevalue.MarshalMap(item{Valor: Text{Cents(990)}})
+	if n, ok := av["valor"].(*types.AttributeValueMemberN); err != nil || !ok || n.Value != "9.90" {
+		t.Fatalf("dynamo = %#v, %v", av["valor"], err)
+	}
+	got = item{}
+	if err := attributevalue.UnmarshalMap(map[string]types.AttributeValue{"valor": &types.AttributeValueMemberS{Value: "0"}}, &got); err != nil || !got.Valor.IsZero() {
+		t.Fatalf("unmarshal texto = %+v, %v", got, err)
+	}
+}
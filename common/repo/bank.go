@@ -0,0 +1,86 @@
+package repo
+
+import (
+	"BACK_SORTE_GO/common/store"
+	"BACK_SORTE_GO/common/store/dynamo"
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// BankRepo le e grava as contas de saque (BANK#) e o lookup por id.
+type BankRepo struct {
+	store dynamo.Store
+}
+
+func NewBankRepo(storeDDB dynamo.Store) BankRepo {
+	return BankRepo{store: storeDDB}
+}
+
+// Create grava a conta e o lookup BANK#{id} / USER#{id} em uma transacao.
+func (r BankRepo) Create(ctx context.Context, acc BankAccount) error {
+	accItem, err := marshalItem(acc, map[string]string{"PK": store.UserPK(acc.IDUser), "SK": store.BankPK(acc.ID)})
+	if err != nil {
+		return err
+	}
+	lookupItem, err := marshalItem(BankLookup{
+		ID:     acc.ID,
+		IDUser: acc.IDUser,
+		Active: acc.Active,
+		Dell:   acc.Dell,
+	}, map[string]string{"PK": store.BankPK(acc.ID), "SK": store.UserPK(acc.IDUser)})
+	if err != nil {
+		return err
+	}
+	return Transact(ctx, r.store, putItems(r.store, accItem, lookupItem))
+}
+
+// Get devolve uma conta do usuario.
+func (r BankRepo) Get(ctx context.Context, userID, bankID string) (BankAccount, error) {
+	var acc BankAccount
+	err := getItem(ctx, r.store, store.UserPK(userID), store.BankPK(bankID), &acc)
+	return acc, err
+}
+
+// ListByUser devolve todas as contas do usuario, ativas ou nao.
+func (r BankRepo) ListByUser(ctx context.Context, userID string) ([]BankAccount, error) {
+	out, err := r.store.Query(ctx, &dynamodb.QueryInput{
+		KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :sk)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": dynamo.S(store.UserPK(userID)),
+			":sk": dynamo.S(store.PrefixBank),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var accounts []BankAccount
+	err = attributevalue.UnmarshalListOfMaps(out.Items, &accounts)
+	return accounts, err
+}
+
+// Deactivate desativa a conta e o lookup dela.
+func (r BankRepo) Deactivate(ctx context.Context, userID, bankID string) error {
+	values := map[string]types.AttributeValue{
+		":a": dynamo.B(false),
+		":d": dynamo.B(true),
+		":u": dynamo.S(now()),
+	}
+	return r.store.TransactWrite(ctx, []types.TransactWriteItem{
+		{Update: &types.Update{
+			TableName:                 aws.String(r.store.TableName()),
+			Key:                       itemKey(store.UserPK(userID), store.BankPK(bankID)),
+			UpdateExpression:          aws.String("SET active = :a, dell = :d, date_update = :u"),
+			ExpressionAttributeValues: values,
+		}},
+		{Update: &types.Update{
+			TableName:                 aws.String(r.store.TableName()),
+			Key:                       itemKey(store.BankPK(bankID), store.UserPK(userID)),
+			UpdateExpression:          aws.String("SET active = :a, dell = :d"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{":a": values[":a"], ":d": values[":d"]},
+		}},
+	})
+}
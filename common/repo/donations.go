@@ -0,0 +1,817 @@
+package repo
+
+import (
+	"BACK_SORTE_GO/common/money"
+	"BACK_SORTE_GO/common/store"
+	"BACK_SORTE_GO/common/store/dynamo"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	skPayment = "PAYMENT"
+	// skLink e fixo para que o put condicional do LINK# garanta um dono por nome.
+	skLink = "LINK"
+)
+
+// DonationRepo le e grava a campanha: PROFILE, DETAILS, PAYMENT e LINK.
+type DonationRepo struct {
+	store dynamo.Store
+}
+
+func NewDonationRepo(storeDDB dynamo.Store) DonationRepo {
+	return DonationRepo{store: storeDDB}
+}
+
+// NewDonation reune os itens gravados na criacao de uma campanha.
+type NewDonation struct {
+	Profile Donation
+	Details DonationDetails
+	Link    DonationLink
+	Payment DonationPayment
+}
+
+// CreateItems monta as escritas da campanha; o perfil entra no GSI1 do dono
+// ordenado pela data de criacao e, se aberta, no feed publico (GSI3/GSI4) e
+// nos rankings (GSI6/GSI7). Os termos do titulo e do texto vao para o indice
+// da busca (TERM#) e o agregado AGG comeca zerado com a meta.
+func (r DonationRepo) CreateItems(d NewDonation) ([]types.TransactWriteItem, error) {
+	pk := store.DonationPK(d.Profile.ID)
+	d.Profile.Terms = donationTerms(d.Profile.Name, d.Details.Texto)
+	keys := map[string]string{
+		"PK":     pk,
+		"SK":     skProfile,
+		"GSI1PK": store.UserPK(d.Profile.IDUser),
+		"GSI1SK": store.PrefixDonation + d.Profile.DateCreate + "#" + d.Profile.ID,
+	}
+	if listed(d.Profile) {
+		for name, value := range exploreKeys(d.Profile, d.Details.Area) {
+			keys[name] = value
+		}
+	}
+	profile, err := marshalItem(d.Profile, keys)
+	if err != nil {
+		return nil, err
+	}
+	if listed(d.Profile) {
+		ranking, err := rankingKeys(d.Profile)
+		if err != nil {
+			return nil, err
+		}
+		for name, value := range ranking {
+			profile[name] = value
+		}
+	}
+	details, err := marshalItem(d.Details, map[string]string{"PK": pk, "SK": skDetails})
+	if err != nil {
+		return nil, err
+	}
+	link, err := marshalItem(d.Link, map[string]string{"PK": store.LinkPK(d.Link.NomeLink), "SK": skLink})
+	if err != nil {
+		return nil, err
+	}
+	payment, err := marshalItem(d.Payment, map[string]string{"PK": pk, "SK": skPayment})
+	if err != nil {
+		return nil, err
+	}
+	progress, err := progressItem(d.Profile.ID, d.Profile.Valor)
+	if err != nil {
+		return nil, err
+	}
+	items := putItems(r.store, profile, details, link, payment, progress)
+	items[2].Put.ConditionExpression = aws.String("attribute_not_exists(PK)")
+	terms, err := r.termPuts(d.Profile, d.Profile.Terms)
+	if err != nil {
+		return nil, err
+	}
+	return append(items, terms...), nil
+}
+
+// Create grava a campanha montada por CreateItems em uma transacao; devolve
+// ErrLinkTaken se o nome_link foi reservado por outra campanha.
+func (r DonationRepo) Create(ctx context.Context, d NewDonation) error {
+	items, err := r.CreateItems(d)
+	if err != nil {
+		return err
+	}
+	err = Transact(ctx, r.store, items)
+	if dynamo.IsConditionFailed(err) {
+		return ErrLinkTaken
+	}
+	return err
+}
+
+// Get devolve o perfil da campanha.
+func (r DonationRepo) Get(ctx context.Context, id string) (Donation, error) {
+	var d Donation
+	err := getItem(ctx, r.store, store.DonationPK(id), skProfile, &d)
+	return d, err
+}
+
+// GetDetails devolve texto, imagem e area da campanha.
+func (r DonationRepo) GetDetails(ctx context.Context, id string) (DonationDetails, error) {
+	var d DonationDetails
+	err := getItem(ctx, r.store, store.DonationPK(id), skDetails, &d)
+	return d, err
+}
+
+// GetPayment devolve o saldo da campanha.
+func (r DonationRepo) GetPayment(ctx context.Context, id string) (DonationPayment, error) {
+	var p DonationPayment
+	err := getItem(ctx, r.store, store.DonationPK(id), skPayment, &p)
+	return p, err
+}
+
+// FindLink resolve o nome publico (@nome) para o item de link, atual ou alias.
+func (r DonationRepo) FindLink(ctx context.Context, nomeLink string) (DonationLink, error) {
+	l, _, err := r.findLinkItem(ctx, nomeLink)
+	return l, err
+}
+
+// findLinkItem devolve o link e a chave do item, que varia entre o formato
+// atual (SK LINK) e o antigo (SK DONATION#{id}).
+func (r DonationRepo) findLinkItem(ctx context.Context, nomeLink string) (DonationLink, map[string]types.AttributeValue, error) {
+	out, err := r.store.Query(ctx, &dynamodb.QueryInput{
+		KeyConditionExpression: aws.String("PK = :pk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": dynamo.S(store.LinkPK(nomeLink)),
+		},
+		Limit: aws.Int32(1),
+	})
+	if err != nil {
+		return DonationLink{}, nil, err
+	}
+	if len(out.Items) == 0 {
+		return DonationLink{}, nil, ErrNotFound
+	}
+	var l DonationLink
+	if err := attributevalue.UnmarshalMap(out.Items[0], &l); err != nil {
+		return DonationLink{}, nil, err
+	}
+	return l, map[string]types.AttributeValue{"PK": out.Items[0]["PK"], "SK": out.Items[0]["SK"]}, nil
+}
+
+// RenameLink troca o nome publico da campanha. O novo LINK# e reservado com put
+// condicional (ou reativado, se ja era um alias da propria campanha), o PROFILE
+// so muda se ainda estiver com o link lido e o link anterior vira alias.
+func (r DonationRepo) RenameLink(ctx context.Context, d Donation, link DonationLink) error {
+	if link.NomeLink == d.NomeLink {
+		return nil
+	}
+	table := aws.String(r.store.TableName())
+
+	var claim types.TransactWriteItem
+	existing, existingKey, err := r.findLinkItem(ctx, link.NomeLink)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		item, err := marshalItem(link, map[string]string{"PK": store.LinkPK(link.NomeLink), "SK": skLink})
+		if err != nil {
+			return err
+		}
+		claim = types.TransactWriteItem{Put: &types.Put{TableName: table, Item: item, ConditionExpression: aws.String("attribute_not_exists(PK)")}}
+	case err != nil:
+		return err
+	case existing.IDDoacao == d.ID:
+		claim = types.TransactWriteItem{Update: &types.Update{
+			TableName:                 table,
+			Key:                       existingKey,
+			UpdateExpression:          aws.String("REMOVE alias"),
+			ConditionExpression:       aws.String("id_doacao = :id"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{":id": dynamo.S(d.ID)},
+		}}
+	default:
+		return ErrLinkTaken
+	}
+
+	items := []types.TransactWriteItem{claim, {Update: &types.Update{
+		TableName:           table,
+		Key:                 itemKey(store.DonationPK(d.ID), skProfile),
+		UpdateExpression:    aws.String("SET nome_link = :new, date_update = :d"),
+		ConditionExpression: aws.String("nome_link = :old"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":new": dynamo.S(link.NomeLink),
+			":old": dynamo.S(d.NomeLink),
+			":d":   dynamo.S(now()),
+		},
+	}}}
+	if _, oldKey, err := r.findLinkItem(ctx, d.NomeLink); err == nil {
+		items = append(items, types.TransactWriteItem{Update: &types.Update{
+			TableName:                 table,
+			Key:                       oldKey,
+			UpdateExpression:          aws.String("SET alias = :t"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{":t": dynamo.B(true)},
+		}})
+	} else if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	err = r.store.TransactWrite(ctx, items)
+	if dynamo.IsConditionFailed(err) {
+		return ErrLinkTaken
+	}
+	return err
+}
+
+// ListByUser devolve todas as campanhas do usuario pelo GSI1, da mais recente
+// para a mais antiga.
+func (r DonationRepo) ListByUser(ctx context.Context, userID string) ([]Donation, error) {
+	items, err := queryAll(ctx, r.store, byUserQuery(userID))
+	if err != nil {
+		return nil, err
+	}
+	var donations []Donation
+	err = attributevalue.UnmarshalListOfMaps(items, &donations)
+	return donations, err
+}
+
+// ListByUserPage devolve uma pagina das campanhas do usuario a partir do
+// cursor e o cursor da pagina seguinte (vazio na ultima).
+func (r DonationRepo) ListByUserPage(ctx context.Context, userID, cursor string, limit int) ([]Donation, string, error) {
+	items, next, err := queryPage(ctx, r.store, byUserQuery(userID), "GSI1#"+store.UserPK(userID), cursor, limit)
+	if err != nil {
+		return nil, "", err
+	}
+	donations := []Donation{}
+	err = attributevalue.UnmarshalListOfMaps(items, &donations)
+	return donations, next, err
+}
+
+// CountByUser conta as campanhas do usuario sem trazer os itens.
+func (r DonationRepo) CountByUser(ctx context.Context, userID string) (int, error) {
+	return countAll(ctx, r.store, byUserQuery(userID))
+}
+
+func byUserQuery(userID string) *dynamodb.QueryInput {
+	return &dynamodb.QueryInput{
+		IndexName:              aws.String("GSI1"),
+		KeyConditionExpression: aws.String("GSI1PK = :pk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": dynamo.S(store.UserPK(userID)),
+		},
+		ScanIndexForward: aws.Bool(false),
+	}
+}
+
+// listed diz se a campanha aparece no feed publico.
+func listed(d Donation) bool {
+	return d.Active && !d.Closed && !d.Dell
+}
+
+// Ended diz se a campanha ja passou da date_end, mesmo antes do job de
+// encerramento rodar.
+func (d Donation) Ended(at time.Time) bool {
+	return d.DateEnd != "" && d.DateEnd <= at.UTC().Format(time.RFC3339)
+}
+
+// AcceptsPayments diz se a campanha pode receber novas doacoes.
+func (d Donation) AcceptsPayments(at time.Time) bool {
+	return listed(d) && !d.Ended(at)
+}
+
+// exploreKeys sao os atributos dos indices esparsos do feed: GSI3 por area e
+// GSI4 com todas as campanhas abertas, ambos pela data de criacao, e GSI8 com
+// as abertas que tem date_end, pela data de encerramento. Encerrar ou excluir
+// a campanha remove os atributos (removeExplore) e ela sai do feed.
+func exploreKeys(d Donation, area string) map[string]string {
+	sk := d.DateCreate + "#" + d.ID
+	keys := map[string]string{
+		"GSI3PK": store.AreaPK(area),
+		"GSI3SK": sk,
+		"GSI4PK": store.ExplorePK,
+		"GSI4SK": sk,
+	}
+	if d.DateEnd != "" {
+		keys["GSI8PK"] = store.ExplorePK
+		keys["GSI8SK"] = d.DateEnd
+	}
+	return keys
+}
+
+// rankingKeys sao os atributos dos rankings do feed, esparsos como o GSI4 e
+// com chave numerica: GSI6 pelo arrecadado e GSI7 pelo que falta para a meta.
+// Os pagamentos e devolucoes mantem as chaves com ADD (ProgressWrites e
+// ProgressReversal).
+func rankingKeys(d Donation) (map[string]types.AttributeValue, error) {
+	falta, err := d.Valor.Sub(d.Arrecadado)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]types.AttributeValue{
+		"GSI6PK": dynamo.S(store.ExplorePK),
+		"GSI6SK": dynamo.N(d.Arrecadado.String()),
+		"GSI7PK": dynamo.S(store.ExplorePK),
+		"GSI7SK": dynamo.N(falta.String()),
+	}, nil
+}
+
+// setRanking regrava os rankings a partir do arrecadado gravado no PROFILE,
+// sem corrida com o ADD dos pagamentos; :v e a meta e :explore o ExplorePK.
+const setRanking = ", GSI6PK = :explore, GSI6SK = if_not_exists(arrecadado, :zero), GSI7PK = :explore, GSI7SK = :v - if_not_exists(arrecadado, :zero)"
+
+const removeExplore = " REMOVE GSI3PK, GSI3SK, GSI4PK, GSI4SK, GSI6PK, GSI6SK, GSI7PK, GSI7SK, GSI8PK, GSI8SK"
+
+// reachedCursor abre a segunda parte de ExploreByGoal, com as campanhas que ja
+// bateram a meta; os cursores dessa parte levam o mesmo prefixo.
+const reachedCursor = "meta."
+
+// Explore devolve uma pagina das campanhas abertas, mais recentes primeiro,
+// de uma area ou de todas (area vazia).
+func (r DonationRepo) Explore(ctx context.Context, area, cursor string, limit int) ([]Donation, string, error) {
+	input := &dynamodb.QueryInput{
+		IndexName:              aws.String("GSI4"),
+		KeyConditionExpression: aws.String("GSI4PK = :pk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": dynamo.S(store.ExplorePK),
+		},
+		ScanIndexForward: aws.Bool(false),
+	}
+	scope := "GSI4#" + store.ExplorePK
+	if area != "" {
+		input.IndexName = aws.String("GSI3")
+		input.KeyConditionExpression = aws.String("GSI3PK = :pk")
+		input.ExpressionAttributeValues[":pk"] = dynamo.S(store.AreaPK(area))
+		scope = "GSI3#" + store.AreaPK(area)
+	}
+	return r.explorePage(ctx, input, scope, cursor, limit)
+}
+
+// ExploreByRaised devolve uma pagina das campanhas abertas, da que mais
+// arrecadou para a que menos (GSI6). A area filtra pelo GSI3PK.
+func (r DonationRepo) ExploreByRaised(ctx context.Context, area, cursor string, limit int) ([]Donation, string, error) {
+	input := &dynamodb.QueryInput{
+		IndexName:              aws.String("GSI6"),
+		KeyConditionExpression: aws.String("GSI6PK = :pk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": dynamo.S(store.ExplorePK),
+		},
+		ScanIndexForward: aws.Bool(false),
+	}
+	return r.explorePage(ctx, withArea(input, area), "GSI6#"+store.ExplorePK+"#"+area, cursor, limit)
+}
+
+// ExploreByGoal devolve uma pagina das campanhas abertas pelo valor que falta
+// para a meta (GSI7): primeiro as que ainda nao bateram, da mais perto para a
+// mais longe, depois as que ja bateram, da que mais passou da meta.
+func (r DonationRepo) ExploreByGoal(ctx context.Context, area, cursor string, limit int) ([]Donation, string, error) {
+	var selected []Donation
+	if !strings.HasPrefix(cursor, reachedCursor) {
+		page, next, err := r.explorePage(ctx, goalQuery(area, "GSI7SK > :zero"), "GSI7#falta#"+area, cursor, limit)
+		if err != nil || next != "" {
+			return page, next, err
+		}
+		if len(page) == limit {
+			return page, reachedCursor, nil
+		}
+		selected, cursor, limit = page, reachedCursor, limit-len(page)
+	}
+	page, next, err := r.explorePage(ctx, goalQuery(area, "GSI7SK <= :zero"), "GSI7#batida#"+area, strings.TrimPrefix(cursor, reachedCursor), limit)
+	if err != nil {
+		return nil, "", err
+	}
+	if next != "" {
+		next = reachedCursor + next
+	}
+	return append(selected, page...), next, nil
+}
+
+func goalQuery(area, condition string) *dynamodb.QueryInput {
+	return withArea(&dynamodb.QueryInput{
+		IndexName:              aws.String("GSI7"),
+		KeyConditionExpression: aws.String("GSI7PK = :pk AND " + condition),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk":   dynamo.S(store.ExplorePK),
+			":zero": dynamo.N("0"),
+		},
+	}, area)
+}
+
+// withArea restringe um ranking a uma area pelo GSI3PK, que so existe nas
+// campanhas abertas.
+func withArea(input *dynamodb.QueryInput, area string) *dynamodb.QueryInput {
+	if area != "" {
+		input.FilterExpression = aws.String("GSI3PK = :area")
+		input.ExpressionAttributeValues[":area"] = dynamo.S(store.AreaPK(area))
+	}
+	return input
+}
+
+func (r DonationRepo) explorePage(ctx context.Context, input *dynamodb.QueryInput, scope, cursor string, limit int) ([]Donation, string, error) {
+	items, next, err := queryPage(ctx, r.store, input, scope, cursor, limit)
+	if err != nil {
+		return nil, "", err
+	}
+	donations := []Donation{}
+	err = attributevalue.UnmarshalListOfMaps(items, &donations)
+	return donations, next, err
+}
+
+// ScanProfiles percorre os PROFILE de todas as campanhas. Faz Scan na tabela
+// inteira: so para migracoes pontuais, nunca em rotas.
+func (r DonationRepo) ScanProfiles(ctx context.Context, fn func(Donation) error) error {
+	input := &dynamodb.ScanInput{
+		TableName:        aws.String(r.store.TableName()),
+		FilterExpression: aws.String("SK = :sk AND begins_with(PK, :pk)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":sk": dynamo.S(skProfile),
+			":pk": dynamo.S(store.PrefixDonation),
+		},
+	}
+	for {
+		out, err := r.store.Scan(ctx, input)
+		if err != nil {
+			return err
+		}
+		for _, item := range out.Items {
+			var d Donation
+			if err := attributevalue.UnmarshalMap(item, &d); err != nil {
+				return err
+			}
+			if err := fn(d); err != nil {
+				return err
+			}
+		}
+		if len(out.LastEvaluatedKey) == 0 {
+			return nil
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
+
+// IndexFeed grava as chaves do feed, dos rankings e do encerramento de uma
+// campanha aberta criada antes deles. A condicao confere que ela continua
+// aberta; encerrada ou excluida nesse meio tempo devolve ErrConflict.
+func (r DonationRepo) IndexFeed(ctx context.Context, d Donation) error {
+	details, err := r.GetDetails(ctx, d.ID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	var sets []string
+	values := map[string]types.AttributeValue{
+		":explore": dynamo.S(store.ExplorePK),
+		":zero":    dynamo.N("0"),
+		":v":       dynamo.N(d.Valor.String()),
+		":t":       dynamo.B(true),
+		":f":       dynamo.B(false),
+	}
+	for name, value := range exploreKeys(d, details.Area) {
+		sets = append(sets, fmt.Sprintf("%s = :%s", name, strings.ToLower(name)))
+		values[":"+strings.ToLower(name)] = dynamo.S(value)
+	}
+	sort.Strings(sets)
+	err = r.store.TransactWrite(ctx, []types.TransactWriteItem{{Update: &types.Update{
+		TableName:                 aws.String(r.store.TableName()),
+		Key:                       itemKey(store.DonationPK(d.ID), skProfile),
+		UpdateExpression:          aws.String("SET " + strings.Join(sets, ", ") + setRanking),
+		ConditionExpression:       aws.String(openCondition),
+		ExpressionAttributeValues: values,
+	}}})
+	if dynamo.IsConditionFailed(err) {
+		return ErrConflict
+	}
+	return err
+}
+
+// openCondition confere no PROFILE que a campanha continua aberta (:t true e
+// :f false), como listed.
+const openCondition = "active = :t AND (attribute_not_exists(closed) OR closed = :f) AND (attribute_not_exists(dell) OR dell = :f)"
+
+// ListEnded devolve as campanhas abertas com date_end ate at, lendo so esse
+// trecho do indice esparso de encerramento (GSI8).
+func (r DonationRepo) ListEnded(ctx context.Context, at time.Time) ([]Donation, error) {
+	items, err := queryAll(ctx, r.store, &dynamodb.QueryInput{
+		IndexName:              aws.String("GSI8"),
+		KeyConditionExpression: aws.String("GSI8PK = :pk AND GSI8SK <= :at"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": dynamo.S(store.ExplorePK),
+			":at": dynamo.S(at.UTC().Format(time.RFC3339)),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	ended := []Donation{}
+	err = attributevalue.UnmarshalListOfMaps(items, &ended)
+	return ended, err
+}
+
+// DetailsAndPayments busca em lote DETAILS e PAYMENT das campanhas, indexados
+// pelo id. Campanhas sem o item simplesmente ficam fora do mapa.
+func (r DonationRepo) DetailsAndPayments(ctx context.Context, ids []string) (map[string]DonationDetails, map[string]DonationPayment, error) {
+	details := map[string]DonationDetails{}
+	payments := map[string]DonationPayment{}
+	if len(ids) == 0 {
+		return details, payments, nil
+	}
+
+	keys := make([]map[string]types.AttributeValue, 0, len(ids)*2)
+	for _, id := range ids {
+		keys = append(keys, itemKey(store.DonationPK(id), skDetails), itemKey(store.DonationPK(id), skPayment))
+	}
+	batch, err := r.store.BatchGet(ctx, keys)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, id := range ids {
+		pk := store.DonationPK(id)
+		if item := batch[pk+"|"+skDetails]; item != nil {
+			var d DonationDetails
+			if err := attributevalue.UnmarshalMap(item, &d); err != nil {
+				return nil, nil, err
+			}
+			details[id] = d
+		}
+		if item := batch[pk+"|"+skPayment]; item != nil {
+			var p DonationPayment
+			if err := attributevalue.UnmarshalMap(item, &p); err != nil {
+				return nil, nil, err
+			}
+			payments[id] = p
+		}
+	}
+	return details, payments, nil
+}
+
+// DonationChanges traz os campos editaveis da campanha; nil mantem o valor atual.
+type DonationChanges struct {
+	Name       *string
+	Valor      *money.Money
+	Texto      *string
+	Area       *string
+	ImgCaminho *string
+	// DateEnd vazio remove a data de encerramento.
+	DateEnd *string
+}
+
+// Edit aplica as alteracoes em PROFILE e DETAILS e grava o historico EDIT# em
+// uma transacao condicionada a versao lida pelo cliente; devolve ErrConflict se
+// a campanha mudou desde entao. Sem diferenca nada e gravado e a edicao volta
+// sem Changes.
+func (r DonationRepo) Edit(ctx context.Context, id string, version int64, c DonationChanges, updatedBy string) (DonationEdit, error) {
+	profile, err := r.Get(ctx, id)
+	if err != nil {
+		return DonationEdit{}, err
+	}
+	if profile.Version != version {
+		return DonationEdit{}, ErrConflict
+	}
+	details, err := r.GetDetails(ctx, id)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return DonationEdit{}, err
+	}
+
+	ts := now()
+	edit := DonationEdit{IDDoacao: id, Version: version, UpdatedBy: updatedBy, DateEdit: ts}
+	changes := map[string]FieldChange{}
+	apply := func(field string, current *string, next *string) {
+		if next != nil && *next != *current {
+			changes[field] = FieldChange{From: *current, To: *next}
+			*current = *next
+		}
+	}
+	apply("name", &profile.Name, c.Name)
+	apply("texto", &details.Texto, c.Texto)
+	apply("area", &details.Area, c.Area)
+	apply("img_caminho", &details.ImgCaminho, c.ImgCaminho)
+	if c.Valor != nil {
+		cmp, err := c.Valor.Cmp(profile.Valor)
+		if err != nil {
+			return DonationEdit{}, err
+		}
+		if cmp != 0 {
+			changes["valor"] = FieldChange{From: profile.Valor.String(), To: c.Valor.String()}
+			profile.Valor = *c.Valor
+		}
+	}
+	apply("date_end", &profile.DateEnd, c.DateEnd)
+	if len(changes) == 0 {
+		return edit, nil
+	}
+	edit.Version = version + 1
+	edit.Changes = changes
+
+	condition := "version = :cv"
+	if version == 0 {
+		condition = "attribute_not_exists(version) OR version = :cv"
+	}
+	pk := store.DonationPK(id)
+	profileUpdate := "SET #n = :n, valor = :v, version = :nv, date_update = :d, updated_by = :by"
+	profileValues := map[string]types.AttributeValue{
+		":n":  dynamo.S(profile.Name),
+		":v":  dynamo.N(profile.Valor.String()),
+		":nv": dynamo.N(fmt.Sprint(edit.Version)),
+		":cv": dynamo.N(fmt.Sprint(version)),
+		":d":  dynamo.S(ts),
+		":by": dynamo.S(updatedBy),
+	}
+	// Titulo ou texto novos trocam os termos da busca de campanhas abertas;
+	// campanhas anteriores a busca sao indexadas na primeira edicao.
+	var termWrites []types.TransactWriteItem
+	_, nameChanged := changes["name"]
+	_, textoChanged := changes["texto"]
+	if listed(profile) && (nameChanged || textoChanged || profile.Terms == nil) {
+		terms := donationTerms(profile.Name, details.Texto)
+		added, removed := diffTerms(profile.Terms, terms)
+		puts, err := r.termPuts(profile, added)
+		if err != nil {
+			return DonationEdit{}, err
+		}
+		termWrites = append(puts, r.termDeletes(profile, removed)...)
+		termsAttr, err := attributevalue.Marshal(terms)
+		if err != nil {
+			return DonationEdit{}, err
+		}
+		profileUpdate += ", terms = :terms"
+		profileValues[":terms"] = termsAttr
+	}
+	// Regrava as chaves do feed e dos rankings: acompanha a troca de area e de
+	// meta e inclui campanhas criadas antes do feed existir.
+	if listed(profile) {
+		for name, value := range exploreKeys(profile, details.Area) {
+			profileUpdate += fmt.Sprintf(", %s = :%s", name, strings.ToLower(name))
+			profileValues[":"+strings.ToLower(name)] = dynamo.S(value)
+		}
+		profileUpdate += setRanking
+		profileValues[":explore"] = dynamo.S(store.ExplorePK)
+		profileValues[":zero"] = dynamo.N("0")
+	}
+	if _, ok := changes["date_end"]; ok {
+		if profile.DateEnd == "" {
+			profileUpdate += " REMOVE date_end, GSI8PK, GSI8SK"
+		} else {
+			profileUpdate += ", date_end = :de"
+			profileValues[":de"] = dynamo.S(profile.DateEnd)
+		}
+	}
+	// A meta do agregado acompanha o valor; o AGG de campanhas antigas e
+	// reconstruido antes para o update nao criar um item so com a meta.
+	var metaWrites []types.TransactWriteItem
+	if _, ok := changes["valor"]; ok {
+		if _, err := r.EnsureProgress(ctx, id); err != nil {
+			return DonationEdit{}, err
+		}
+		metaWrites = append(metaWrites, types.TransactWriteItem{Update: &types.Update{
+			TableName:                 aws.String(r.store.TableName()),
+			Key:                       itemKey(pk, skProgress),
+			UpdateExpression:          aws.String("SET meta = :v"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{":v": profileValues[":v"]},
+		}})
+	}
+	editItem, err := marshalItem(edit, map[string]string{"PK": pk, "SK": fmt.Sprintf("%s%s#%06d", store.PrefixEdit, ts, edit.Version)})
+	if err != nil {
+		return DonationEdit{}, err
+	}
+	err = r.store.TransactWrite(ctx, append([]types.TransactWriteItem{
+		{Update: &types.Update{
+			TableName:           aws.String(r.store.TableName()),
+			Key:                 itemKey(pk, skProfile),
+			UpdateExpression:    aws.String(profileUpdate),
+			ConditionExpression: aws.String(condition),
+			ExpressionAttributeNames: map[string]string{
+				"#n": "name",
+			},
+			ExpressionAttributeValues: profileValues,
+		}},
+		{Update: &types.Update{
+			TableName:        aws.String(r.store.TableName()),
+			Key:              itemKey(pk, skDetails),
+			UpdateExpression: aws.String("SET id = if_not_exists(id, :id), id_doacao = :idd, texto = :t, area = :a, img_caminho = :img"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":id":  dynamo.S(id),
+				":idd": dynamo.S(id),
+				":t":   dynamo.S(details.Texto),
+				":a":   dynamo.S(details.Area),
+				":img": dynamo.S(details.ImgCaminho),
+			},
+		}},
+		{Put: &types.Put{
+			TableName:           aws.String(r.store.TableName()),
+			Item:                editItem,
+			ConditionExpression: aws.String("attribute_not_exists(PK)"),
+		}},
+	}, append(termWrites, metaWrites...)...))
+	if dynamo.IsConditionFailed(err) {
+		return DonationEdit{}, ErrConflict
+	}
+	if err != nil {
+		return DonationEdit{}, err
+	}
+	return edit, nil
+}
+
+// ListEdits devolve o historico de edicoes da campanha, da mais recente para a
+// mais antiga.
+func (r DonationRepo) ListEdits(ctx context.Context, id string) ([]DonationEdit, error) {
+	items, err := queryAll(ctx, r.store, &dynamodb.QueryInput{
+		KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :sk)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": dynamo.S(store.DonationPK(id)),
+			":sk": dynamo.S(store.PrefixEdit),
+		},
+		ScanIndexForward: aws.Bool(false),
+	})
+	if err != nil {
+		return nil, err
+	}
+	edits := []DonationEdit{}
+	err = attributevalue.UnmarshalListOfMaps(items, &edits)
+	return edits, err
+}
+
+// Close encerra a campanha e a retira do feed e da busca.
+func (r DonationRepo) Close(ctx context.Context, id, updatedBy string) error {
+	return r.unlist(ctx, id, "SET active = :a, closed = :c, date_update = :d, updated_by = :by", map[string]types.AttributeValue{
+		":a":  dynamo.B(false),
+		":c":  dynamo.B(true),
+		":d":  dynamo.S(now()),
+		":by": dynamo.S(updatedBy),
+	})
+}
+
+// MarkDeleted faz a exclusao logica da campanha e a retira do feed e da busca.
+func (r DonationRepo) MarkDeleted(ctx context.Context, id, updatedBy string) error {
+	return r.unlist(ctx, id, "SET dell = :d, date_update = :u, updated_by = :by", map[string]types.AttributeValue{
+		":d":  dynamo.B(true),
+		":u":  dynamo.S(now()),
+		":by": dynamo.S(updatedBy),
+	})
+}
+
+// unlist aplica o encerramento ou a exclusao no PROFILE, tira a campanha do
+// feed e apaga os TERM# da busca na mesma transacao. A versao sobe para que
+// uma edicao lida antes nao devolva a campanha ao feed nem regrave termos; se
+// a campanha mudar entre a leitura e a escrita, le de novo.
+func (r DonationRepo) unlist(ctx context.Context, id, set string, values map[string]types.AttributeValue) error {
+	for attempt := 0; attempt < 3; attempt++ {
+		d, err := r.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		condition, vals := versionCondition(d.Version)
+		for k, v := range values {
+			vals[k] = v
+		}
+		err = r.store.TransactWrite(ctx, append([]types.TransactWriteItem{{Update: &types.Update{
+			TableName:                 aws.String(r.store.TableName()),
+			Key:                       itemKey(store.DonationPK(id), skProfile),
+			UpdateExpression:          aws.String(set + ", version = :nv" + removeExplore),
+			ConditionExpression:       aws.String(condition),
+			ExpressionAttributeValues: vals,
+		}}}, r.termDeletes(d, d.Terms)...))
+		if dynamo.IsConditionFailed(err) {
+			continue
+		}
+		return err
+	}
+	return ErrConflict
+}
+
+// versionCondition condiciona a escrita a versao lida (:cv) e traz a seguinte
+// em :nv; campanhas antigas nao tem o atributo.
+func versionCondition(version int64) (string, map[string]types.AttributeValue) {
+	condition := "version = :cv"
+	if version == 0 {
+		condition = "(attribute_not_exists(version) OR version = :cv)"
+	}
+	return condition, map[string]types.AttributeValue{
+		":cv": dynamo.N(fmt.Sprint(version)),
+		":nv": dynamo.N(fmt.Sprint(version + 1)),
+	}
+}
+
+// RequestRescue lanca o resgate de valor (RESGATE, da campanha para a conta de
+// resgate) e marca o pedido em PROCESS na mesma transacao. O debito e
+// condicionado ao saldo disponivel em vez de sobrescreve-lo: creditos que
+// chegam no meio tempo continuam no saldo. Resgate acima do saldo devolve
+// ErrInsufficientBalance.
+func (r DonationRepo) RequestRescue(ctx context.Context, id string, valor money.Money, userID string) (LedgerEntry, error) {
+	ts := now()
+	ref := strconv.FormatInt(time.Now().UnixNano(), 36)
+	entry := LedgerEntry{
+		ID: LedgerResgate + "-" + ref, Tipo: LedgerResgate, Referencia: ref,
+		Origem: AccountCampanha, Destino: AccountResgate, Valor: valor,
+		IDUser: userID, DataCriacao: ts,
+	}
+	ledger := NewLedgerRepo(r.store)
+	items, update, debito, err := ledger.writes(id, []LedgerEntry{entry})
+	if err != nil {
+		return LedgerEntry{}, err
+	}
+	update.UpdateExpression = aws.String(*update.UpdateExpression + ", data_solicitado = :d, #s = :s, solicitado = :b")
+	update.ExpressionAttributeNames = map[string]string{"#s": "status"}
+	update.ExpressionAttributeValues[":s"] = dynamo.S("PROCESS")
+	update.ExpressionAttributeValues[":b"] = dynamo.B(true)
+	err = ledger.transact(ctx, id, append(items, types.TransactWriteItem{Update: update}), debito)
+	entry.IDDoacao = id
+	return entry, err
+}
@@ -0,0 +1,530 @@
+package repo
+
+import "BACK_SORTE_GO/common/money"
+
+// Entidades da tabela unica (ver dynamodb/single_table_model.md). As tags
+// dynamodbav sao os nomes gravados na tabela; PK, SK e atributos de GSI ficam
+// a cargo dos repositorios. Os itens de sessao, cliente, desafio MFA e falhas
+// de login sao do lambda de login e tem seus tipos la.
+
+// User e o perfil do usuario (USER#{id} / PROFILE).
+type User struct {
+	ID             string   `dynamodbav:"id" json:"id"`
+	Name           string   `dynamodbav:"name" json:"name"`
+	Email          string   `dynamodbav:"email" json:"email"`
+	PasswordHash   string   `dynamodbav:"password" json:"-"`
+	CPF            string   `dynamodbav:"cpf" json:"cpf"`
+	Active         bool     `dynamodbav:"active" json:"active"`
+	Inicial        bool     `dynamodbav:"inicial" json:"inicial"`
+	Dell           bool     `dynamodbav:"dell" json:"dell"`
+	EmailValid     bool     `dynamodbav:"email_valid,omitempty" json:"email_valid,omitempty"`
+	Roles          []string `dynamodbav:"roles,omitempty" json:"roles,omitempty"`
+	RolesUpdatedBy string   `dynamodbav:"roles_updated_by,omitempty" json:"-"`
+	DateCreate     string   `dynamodbav:"date_create" json:"date_create"`
+	DateUpdate     string   `dynamodbav:"date_update" json:"date_update"`
+}
+
+// UserDetails guarda os dados complementares (USER#{id} / DETAILS).
+type UserDetails struct {
+	ID         string `dynamodbav:"id" json:"id"`
+	IDUser     string `dynamodbav:"id_user" json:"id_user"`
+	CPFValid   bool   `dynamodbav:"cpf_valid,omitempty" json:"cpf_valid,omitempty"`
+	EmailValid bool   `dynamodbav:"email_valid,omitempty" json:"email_valid,omitempty"`
+	CEP        string `dynamodbav:"cep,omitempty" json:"cep,omitempty"`
+	Telefone   string `dynamodbav:"telefone,omitempty" json:"telefone,omitempty"`
+	Apelido    string `dynamodbav:"apelido,omitempty" json:"apelido,omitempty"`
+	ImgPerfil  string `dynamodbav:"img_perfil,omitempty" json:"img_perfil,omitempty"`
+	DateCreate string `dynamodbav:"date_create,omitempty" json:"date_create,omitempty"`
+	DateUpdate string `dynamodbav:"date_update" json:"date_update"`
+}
+
+// AccountLevel e o nivel da conta (USER#{id} / ACCOUNT#LEVEL).
+type AccountLevel struct {
+	ID            string `dynamodbav:"id" json:"id"`
+	IDUser        string `dynamodbav:"id_user" json:"id_user"`
+	Nivel         string `dynamodbav:"nivel" json:"nivel"`
+	Ativo         bool   `dynamodbav:"ativo" json:"ativo"`
+	Status        string `dynamodbav:"status" json:"status"`
+	DataPagamento string `dynamodbav:"data_pagamento" json:"data_pagamento"`
+	TipoPagamento string `dynamodbav:"tipo_pagamento" json:"tipo_pagamento"`
+	DataUpdate    string `dynamodbav:"data_update" json:"data_update"`
+}
+
+// Niveis de conta. Contas novas comecam em BASICO; PREMIUM e o nivel pago.
+const (
+	AccountLevelBasico  = "BASICO"
+	AccountLevelPremium = "PREMIUM"
+)
+
+var accountLevels = map[string]bool{AccountLevelBasico: true, AccountLevelPremium: true}
+
+// AccountPayment e uma cobranca do nivel da conta (USER#{id} / ACCOUNT#PAYMENT#{id}).
+type AccountPayment struct {
+	ID            string     `dynamodbav:"id" json:"id"`
+	IDUser        string     `dynamodbav:"id_user" json:"id_user"`
+	PagoData      string     `dynamodbav:"pago_data" json:"pago_data"`
+	Pago          bool       `dynamodbav:"pago" json:"pago"`
+	Valor         money.Text `dynamodbav:"valor" json:"valor"`
+	Status        string     `dynamodbav:"status" json:"status"`
+	Codigo        string     `dynamodbav:"codigo" json:"codigo"`
+	DataCreate    string     `dynamodbav:"data_create" json:"data_create"`
+	Referente     string     `dynamodbav:"referente" json:"referente"`
+	Valido        bool       `dynamodbav:"valido" json:"valido"`
+	TxID          string     `dynamodbav:"txid" json:"txid"`
+	PgStatus      string     `dynamodbav:"pg_status" json:"pg_status"`
+	CPF           string     `dynamodbav:"cpf" json:"cpf"`
+	Chave         string     `dynamodbav:"chave" json:"chave"`
+	PixCopiaECola string     `dynamodbav:"pixCopiaECola" json:"pixCopiaECola"`
+	Expiracao     string     `dynamodbav:"expiracao" json:"expiracao"`
+}
+
+// PasswordRecover e um pedido de recuperacao de senha (PWDREC#{email} / TS#{data}#{id}).
+type PasswordRecover struct {
+	ID         string `dynamodbav:"id" json:"id"`
+	IDUser     string `dynamodbav:"id_user" json:"id_user"`
+	Email      string `dynamodbav:"email" json:"email"`
+	Token      string `dynamodbav:"token" json:"-"`
+	Validated  bool   `dynamodbav:"validated" json:"validated"`
+	ToSend     bool   `dynamodbav:"to_send" json:"to_send"`
+	Attempt    int64  `dynamodbav:"attempt" json:"attempt"`
+	Blocked    bool   `dynamodbav:"blocked" json:"blocked"`
+	DateValid  string `dynamodbav:"date_valid" json:"date_valid"`
+	DataCreate string `dynamodbav:"data_create" json:"data_create"`
+}
+
+// BankAccount e a conta de saque do usuario (USER#{id} / BANK#{id}); o
+// repositorio grava junto o lookup BANK#{id} / USER#{id}.
+type BankAccount struct {
+	ID         string `dynamodbav:"id" json:"id"`
+	IDUser     string `dynamodbav:"id_user" json:"id_user"`
+	Banco      string `dynamodbav:"banco" json:"banco"`
+	BancoNome  string `dynamodbav:"banco_nome" json:"banco_nome"`
+	Conta      string `dynamodbav:"conta" json:"conta"`
+	Agencia    string `dynamodbav:"agencia" json:"agencia"`
+	Digito     string `dynamodbav:"digito" json:"digito"`
+	CPF        string `dynamodbav:"cpf" json:"cpf"`
+	Telefone   string `dynamodbav:"telefone" json:"telefone"`
+	Pix        string `dynamodbav:"pix" json:"pix"`
+	Active     bool   `dynamodbav:"active" json:"active"`
+	Dell       bool   `dynamodbav:"dell" json:"dell"`
+	DateCreate string `dynamodbav:"date_create" json:"date_create"`
+	DateUpdate string `dynamodbav:"date_update" json:"date_update"`
+}
+
+// BankLookup localiza o dono de uma conta pelo id (BANK#{id} / USER#{id}).
+type BankLookup struct {
+	ID     string `dynamodbav:"id" json:"id"`
+	IDUser string `dynamodbav:"id_user" json:"id_user"`
+	Active bool   `dynamodbav:"active" json:"active"`
+	Dell   bool   `dynamodbav:"dell" json:"dell"`
+}
+
+// Withdraw e um saque feito para uma conta (BANK#{id} / WITHDRAW#{id}).
+type Withdraw struct {
+	ID         string      `dynamodbav:"id" json:"id"`
+	Valor      money.Money `dynamodbav:"valor" json:"valor"`
+	Realizado  bool        `dynamodbav:"realizado" json:"realizado"`
+	Error      string      `dynamodbav:"error,omitempty" json:"error,omitempty"`
+	DateCreate string      `dynamodbav:"date_create" json:"date_create"`
+	DateUpdate string      `dynamodbav:"date_update" json:"date_update"`
+}
+
+// Donation e o perfil da campanha (DONATION#{id} / PROFILE), indexado no GSI1
+// pelo dono.
+type Donation struct {
+	ID         string      `dynamodbav:"id" json:"id"`
+	IDUser     string      `dynamodbav:"id_user" json:"id_user"`
+	Name       string      `dynamodbav:"name" json:"name"`
+	Valor      money.Money `dynamodbav:"valor" json:"valor"`
+	Active     bool        `dynamodbav:"active" json:"active"`
+	Dell       bool        `dynamodbav:"dell" json:"dell"`
+	Closed     bool        `dynamodbav:"closed" json:"closed"`
+	DateStart  string      `dynamodbav:"date_start" json:"date_start"`
+	DateCreate string      `dynamodbav:"date_create" json:"date_create"`
+	DateUpdate string      `dynamodbav:"date_update" json:"date_update"`
+	NomeLink   string      `dynamodbav:"nome_link" json:"nome_link"`
+	UpdatedBy  string      `dynamodbav:"updated_by,omitempty" json:"updated_by,omitempty"`
+	// Arrecadado e o total bruto dos pagamentos confirmados (Pix e Stripe),
+	// usado na ordenacao do feed.
+	Arrecadado money.Money `dynamodbav:"arrecadado" json:"arrecadado"`
+	// Terms sao os termos indexados em TERM#, guardados para a edicao saber
+	// quais itens do indice apagar.
+	Terms []string `dynamodbav:"terms,omitempty" json:"-"`
+	// Version e incrementada a cada edicao (controle otimista); campanhas
+	// antigas sem o atributo estao na versao 0.
+	Version int64 `dynamodbav:"version" json:"version"`
+	// DateEnd e a data de encerramento automatico (RFC3339 em UTC), opcional.
+	DateEnd string `dynamodbav:"date_end,omitempty" json:"date_end,omitempty"`
+}
+
+// DonationEdit registra uma edicao da campanha (DONATION#{id} / EDIT#{data}#{versao}).
+type DonationEdit struct {
+	IDDoacao  string                 `dynamodbav:"id_doacao" json:"id_doacao"`
+	Version   int64                  `dynamodbav:"version" json:"version"`
+	Changes   map[string]FieldChange `dynamodbav:"changes" json:"changes"`
+	UpdatedBy string                 `dynamodbav:"updated_by" json:"updated_by"`
+	DateEdit  string                 `dynamodbav:"date_edit" json:"date_edit"`
+}
+
+// FieldChange guarda o valor anterior e o novo de um campo editado.
+type FieldChange struct {
+	From string `dynamodbav:"de" json:"de"`
+	To   string `dynamodbav:"para" json:"para"`
+}
+
+// DonationProgress e o agregado da campanha (DONATION#{id} / AGG), atualizado
+// na mesma transacao que confirma cada pagamento.
+type DonationProgress struct {
+	IDDoacao        string      `dynamodbav:"id_doacao" json:"id_doacao"`
+	Meta            money.Money `dynamodbav:"meta" json:"meta"`
+	TotalArrecadado money.Money `dynamodbav:"total_arrecadado" json:"total_arrecadado"`
+	TotalDoadores   int         `dynamodbav:"total_doadores" json:"total_doadores"`
+	TotalDoacoes    int         `dynamodbav:"total_doacoes" json:"total_doacoes"`
+	UltimaDoacao    string      `dynamodbav:"ultima_doacao,omitempty" json:"ultima_doacao,omitempty"`
+}
+
+// DonorMarker marca que o doador ja contribuiu com a campanha
+// (DONATION#{id} / CPF#{cpf} ou EMAIL#{email}).
+type DonorMarker struct {
+	IDDoacao     string `dynamodbav:"id_doacao" json:"id_doacao"`
+	DataPrimeira string `dynamodbav:"data_primeira" json:"data_primeira"`
+}
+
+// DonationPost e uma novidade publicada pelo dono na campanha
+// (DONATION#{id} / POST#{postId}, com o id ordenado pelo tempo).
+type DonationPost struct {
+	ID         string `dynamodbav:"id" json:"id"`
+	IDDoacao   string `dynamodbav:"id_doacao" json:"id_doacao"`
+	IDUser     string `dynamodbav:"id_user" json:"id_user"`
+	Texto      string `dynamodbav:"texto" json:"texto"`
+	ImgCaminho string `dynamodbav:"img_caminho,omitempty" json:"img_caminho,omitempty"`
+	DateCreate string `dynamodbav:"date_create" json:"date_create"`
+	DateUpdate string `dynamodbav:"date_update,omitempty" json:"date_update,omitempty"`
+}
+
+// SK devolve a sort key da novidade dentro da campanha.
+func (p DonationPost) SK() string {
+	return postSK(p.ID)
+}
+
+// DonationSubscriber e o doador que pediu para receber as novidades da
+// campanha por e-mail (DONATION#{id} / SUB#{email}).
+type DonationSubscriber struct {
+	IDDoacao   string `dynamodbav:"id_doacao" json:"id_doacao"`
+	Email      string `dynamodbav:"email" json:"email"`
+	Nome       string `dynamodbav:"nome" json:"nome"`
+	DateCreate string `dynamodbav:"date_create" json:"date_create"`
+}
+
+// SearchTerm e a entrada do indice invertido da busca
+// (TERM#{termo} / DONATION#{date_create}#{id}).
+type SearchTerm struct {
+	Term       string `dynamodbav:"term" json:"term"`
+	IDDoacao   string `dynamodbav:"id_doacao" json:"id_doacao"`
+	DateCreate string `dynamodbav:"date_create" json:"date_create"`
+}
+
+// DonationDetails guarda o texto e a imagem da campanha (DONATION#{id} / DETAILS).
+type DonationDetails struct {
+	ID         string `dynamodbav:"id" json:"id"`
+	IDDoacao   string `dynamodbav:"id_doacao" json:"id_doacao"`
+	Texto      string `dynamodbav:"texto" json:"texto"`
+	ImgCaminho string `dynamodbav:"img_caminho" json:"img_caminho"`
+	Area       string `dynamodbav:"area" json:"area"`
+}
+
+// DonationLink resolve o nome publico da campanha (LINK#{nome_link} / LINK; links
+// antigos usam SK DONATION#{id}).
+type DonationLink struct {
+	ID       string `dynamodbav:"id" json:"id"`
+	IDDoacao string `dynamodbav:"id_doacao" json:"id_doacao"`
+	NomeLink string `dynamodbav:"nome_link" json:"nome_link"`
+	IDUser   string `dynamodbav:"id_user,omitempty" json:"id_user,omitempty"`
+	// Alias marca um link antigo da campanha, mantido para redirecionar ao atual.
+	Alias bool `dynamodbav:"alias,omitempty" json:"alias,omitempty"`
+}
+
+// DonationPayment e o saldo da campanha (DONATION#{id} / PAYMENT). O atributo
+// valor_tranferido mantem a grafia ja gravada na tabela.
+type DonationPayment struct {
+	ID               string      `dynamodbav:"id" json:"id"`
+	IDDoacao         string      `dynamodbav:"id_doacao" json:"id_doacao"`
+	ValorDisponivel  money.Money `dynamodbav:"valor_disponivel" json:"valor_disponivel"`
+	ValorTransferido money.Money `dynamodbav:"valor_tranferido" json:"valor_tranferido"`
+	ValorTaxas       money.Money `dynamodbav:"valor_taxas" json:"valor_taxas"`
+	DataTransferido  string      `dynamodbav:"data_tranferido,omitempty" json:"data_tranferido,omitempty"`
+	Solicitado       bool        `dynamodbav:"solicitado" json:"solicitado"`
+	DataSolicitado   string      `dynamodbav:"data_solicitado,omitempty" json:"data_solicitado,omitempty"`
+	Status           string      `dynamodbav:"status" json:"status"`
+	Img              string      `dynamodbav:"img,omitempty" json:"img,omitempty"`
+	Pdf              string      `dynamodbav:"pdf,omitempty" json:"pdf,omitempty"`
+	Banco            string      `dynamodbav:"banco,omitempty" json:"banco,omitempty"`
+	Conta            string      `dynamodbav:"conta,omitempty" json:"conta,omitempty"`
+	Agencia          string      `dynamodbav:"agencia,omitempty" json:"agencia,omitempty"`
+	Digito           string      `dynamodbav:"digito,omitempty" json:"digito,omitempty"`
+	Pix              string      `dynamodbav:"pix,omitempty" json:"pix,omitempty"`
+	DataUpdate       string      `dynamodbav:"data_update" json:"data_update"`
+}
+
+// Pix e uma cobranca Pix feita para a campanha (DONATION#{id} / PIX#{data}#{id});
+// vira mensagem publica quando visivel.
+type Pix struct {
+	ID       string      `dynamodbav:"id" json:"id"`
+	IDDoacao string      `dynamodbav:"id_doacao" json:"id_doacao"`
+	Valor    money.Money `dynamodbav:"valor" json:"valor"`
+	CPF      string      `dynamodbav:"cpf" json:"cpf"`
+	Nome     string      `dynamodbav:"nome" json:"nome"`
+	Mensagem string      `dynamodbav:"mensagem" json:"mensagem"`
+	Anonimo  bool        `dynamodbav:"anonimo" json:"anonimo"`
+	// Novidades e o pedido do doador para ser inscrito (SUB#) na confirmacao;
+	// o e-mail nao sai nas respostas publicas.
+	Novidades   bool   `dynamodbav:"novidades,omitempty" json:"-"`
+	Email       string `dynamodbav:"email,omitempty" json:"-"`
+	Visivel     bool   `dynamodbav:"visivel" json:"visivel"`
+	DataCriacao string `dynamodbav:"data_criacao" json:"data_criacao"`
+	Status      string `dynamodbav:"status" json:"status"`
+	TxID        string `dynamodbav:"txid" json:"txid"`
+	// ValorDevolvido soma as devolucoes (REFUND#) ja reservadas da cobranca.
+	ValorDevolvido money.Money `dynamodbav:"valor_devolvido,omitempty" json:"valor_devolvido,omitempty"`
+	// Taxa e a taxa da plataforma gravada na confirmacao; cobrancas antigas nao tem.
+	Taxa *AppliedFee `dynamodbav:"taxa,omitempty" json:"taxa,omitempty"`
+}
+
+// SK devolve a sort key do item Pix dentro da campanha.
+func (p Pix) SK() string {
+	return pixSK(p.DataCriacao, p.ID)
+}
+
+// PixStatus e o lookup da cobranca pelo txid (TX#{txid} / STATUS).
+type PixStatus struct {
+	IDPixQRCode   string      `dynamodbav:"id_pix_qrcode" json:"id_pix_qrcode"`
+	IDDoacao      string      `dynamodbav:"id_doacao" json:"id_doacao"`
+	PixSK         string      `dynamodbav:"pix_sk" json:"pix_sk"`
+	Status        string      `dynamodbav:"status" json:"status"`
+	Buscar        bool        `dynamodbav:"buscar" json:"buscar"`
+	Finalizado    bool        `dynamodbav:"finalizado" json:"finalizado"`
+	DataPago      string      `dynamodbav:"data_pago" json:"data_pago"`
+	Expiracao     int64       `dynamodbav:"expiracao" json:"expiracao"`
+	TipoPagamento string      `dynamodbav:"tipo_pagamento" json:"tipo_pagamento"`
+	LocID         int64       `dynamodbav:"loc_id" json:"loc_id"`
+	LocTipoCob    string      `dynamodbav:"loc_tipo_cob" json:"loc_tipo_cob"`
+	LocCriacao    string      `dynamodbav:"loc_criacao" json:"loc_criacao"`
+	Location      string      `dynamodbav:"location" json:"location"`
+	PixCopiaECola string      `dynamodbav:"pix_copia_e_cola" json:"pix_copia_e_cola"`
+	Chave         string      `dynamodbav:"chave" json:"chave"`
+	IDPix         string      `dynamodbav:"id_pix" json:"id_pix"`
+	Valor         money.Money `dynamodbav:"valor" json:"valor"`
+	DataCriacao   string      `dynamodbav:"data_criacao" json:"data_criacao"`
+}
+
+// PixRefund e uma devolucao de Pix pago (DONATION#{id} / REFUND#{txid}#{id}).
+// O id e o identificador da devolucao na EFI; repetir o pedido com o mesmo id
+// nao devolve de novo. Status comeca PENDENTE, fica EM_PROCESSAMENTO enquanto
+// a EFI processa e termina em DEVOLVIDO ou NAO_REALIZADO.
+type PixRefund struct {
+	ID          string      `dynamodbav:"id" json:"id"`
+	IDDoacao    string      `dynamodbav:"id_doacao" json:"id_doacao"`
+	TxID        string      `dynamodbav:"txid" json:"txid"`
+	E2EID       string      `dynamodbav:"e2e_id" json:"e2e_id"`
+	PixSK       string      `dynamodbav:"pix_sk" json:"pix_sk"`
+	Valor       money.Money `dynamodbav:"valor" json:"valor"`
+	Motivo      string      `dynamodbav:"motivo,omitempty" json:"motivo,omitempty"`
+	IDUser      string      `dynamodbav:"id_user" json:"id_user"`
+	Status      string      `dynamodbav:"status" json:"status"`
+	RtrID       string      `dynamodbav:"rtr_id,omitempty" json:"rtr_id,omitempty"`
+	DataCriacao string      `dynamodbav:"data_criacao" json:"data_criacao"`
+	DataUpdate  string      `dynamodbav:"data_update,omitempty" json:"data_update,omitempty"`
+}
+
+// SK devolve a sort key da devolucao dentro da campanha.
+func (rf PixRefund) SK() string {
+	return refundSK(rf.TxID, rf.ID)
+}
+
+// Open diz se a devolucao ainda espera a confirmacao da EFI; a reserva so e
+// concluida em DEVOLVIDO ou desfeita em NAO_REALIZADO.
+func (rf PixRefund) Open() bool {
+	return rf.Status == "PENDENTE" || rf.Status == "EM_PROCESSAMENTO"
+}
+
+// LedgerEntry e um lancamento do livro razao da campanha
+// (DONATION#{id} / LEDGER#{data}#{id}). Cada lancamento move Valor da conta
+// Origem para a conta Destino, entao os saldos de todas as contas somam zero.
+// Lancamentos nao sao alterados: correcoes entram como novos lancamentos.
+type LedgerEntry struct {
+	ID          string      `dynamodbav:"id" json:"id"`
+	IDDoacao    string      `dynamodbav:"id_doacao" json:"id_doacao"`
+	Tipo        string      `dynamodbav:"tipo" json:"tipo"`
+	Origem      string      `dynamodbav:"origem" json:"origem"`
+	Destino     string      `dynamodbav:"destino" json:"destino"`
+	Valor       money.Money `dynamodbav:"valor" json:"valor"`
+	Referencia  string      `dynamodbav:"referencia,omitempty" json:"referencia,omitempty"`
+	Descricao   string      `dynamodbav:"descricao,omitempty" json:"descricao,omitempty"`
+	IDUser      string      `dynamodbav:"id_user,omitempty" json:"id_user,omitempty"`
+	DataCriacao string      `dynamodbav:"data_criacao" json:"data_criacao"`
+}
+
+// SK devolve a sort key do lancamento dentro da campanha.
+func (e LedgerEntry) SK() string {
+	return ledgerSK(e.DataCriacao, e.ID)
+}
+
+// RefSK devolve a sort key da marca que impede lancar o mesmo fato duas vezes
+// (pela referencia, ou o id quando nao ha referencia).
+func (e LedgerEntry) RefSK() string {
+	ref := e.Referencia
+	if ref == "" {
+		ref = e.ID
+	}
+	return ledgerRefSK(ref, e.Tipo)
+}
+
+// PixReconcileRun e o relatorio de uma execucao da conciliacao das cobrancas
+// Pix (RECONCILE#PIX / {data_inicio}#{id}).
+type PixReconcileRun struct {
+	ID          string   `dynamodbav:"id" json:"id"`
+	Origem      string   `dynamodbav:"origem" json:"origem"`
+	DataInicio  string   `dynamodbav:"data_inicio" json:"data_inicio"`
+	DataFim     string   `dynamodbav:"data_fim" json:"data_fim"`
+	Verificadas int      `dynamodbav:"verificadas" json:"verificadas"`
+	Concluidas  int      `dynamodbav:"concluidas" json:"concluidas"`
+	Vencidas    int      `dynamodbav:"vencidas" json:"vencidas"`
+	Removidas   int      `dynamodbav:"removidas" json:"removidas"`
+	Pendentes   int      `dynamodbav:"pendentes" json:"pendentes"`
+	Erros       int      `dynamodbav:"erros" json:"erros"`
+	Falhas      []string `dynamodbav:"falhas,omitempty" json:"falhas,omitempty"`
+}
+
+// FeePolicy e uma versao da politica de taxas da plataforma
+// (FEEPOLICY / V#{versao}). Versoes nao mudam depois de gravadas: a mais alta
+// vale para os pagamentos novos e as antigas explicam os extratos.
+type FeePolicy struct {
+	Versao     int        `dynamodbav:"versao" json:"versao"`
+	Descricao  string     `dynamodbav:"descricao,omitempty" json:"descricao,omitempty"`
+	Regras     []FeeRule  `dynamodbav:"regras" json:"regras"`
+	Promocoes  []FeePromo `dynamodbav:"promocoes,omitempty" json:"promocoes,omitempty"`
+	IDUser     string     `dynamodbav:"id_user,omitempty" json:"id_user,omitempty"`
+	DateCreate string     `dynamodbav:"date_create,omitempty" json:"date_create,omitempty"`
+}
+
+// FeeRule e a taxa de um meio de pagamento: percentual sobre o valor mais um
+// fixo em reais. Sem nivel vale para qualquer nivel de conta.
+type FeeRule struct {
+	Metodo     string      `dynamodbav:"metodo" json:"metodo"`
+	Nivel      string      `dynamodbav:"nivel,omitempty" json:"nivel,omitempty"`
+	Percentual float64     `dynamodbav:"percentual" json:"percentual"`
+	Fixo       money.Money `dynamodbav:"fixo" json:"fixo"`
+}
+
+// FeePromo zera a taxa entre inicio (inclusivo) e fim (exclusivo), ambos em
+// RFC3339. Metodo e nivel vazios valem para todos.
+type FeePromo struct {
+	Nome   string `dynamodbav:"nome" json:"nome"`
+	Inicio string `dynamodbav:"inicio" json:"inicio"`
+	Fim    string `dynamodbav:"fim" json:"fim"`
+	Metodo string `dynamodbav:"metodo,omitempty" json:"metodo,omitempty"`
+	Nivel  string `dynamodbav:"nivel,omitempty" json:"nivel,omitempty"`
+}
+
+// AppliedFee e a taxa cobrada de um pagamento creditado, com a versao da
+// politica e a regra ou promocao usadas no calculo.
+type AppliedFee struct {
+	Versao     int         `dynamodbav:"versao" json:"versao"`
+	Metodo     string      `dynamodbav:"metodo" json:"metodo"`
+	Nivel      string      `dynamodbav:"nivel,omitempty" json:"nivel,omitempty"`
+	Percentual float64     `dynamodbav:"percentual" json:"percentual"`
+	Fixo       money.Money `dynamodbav:"fixo" json:"fixo"`
+	Promocao   string      `dynamodbav:"promocao,omitempty" json:"promocao,omitempty"`
+	Valor      money.Money `dynamodbav:"valor" json:"valor"`
+	Liquido    money.Money `dynamodbav:"liquido" json:"liquido"`
+}
+
+// Visualization e o agregado de interacoes da campanha (DONATION#{id} / VISUALIZATION).
+type Visualization struct {
+	Visualization  int64  `dynamodbav:"visualization" json:"visualization"`
+	DonationLike   int64  `dynamodbav:"donation_like" json:"donation_like"`
+	Love           int64  `dynamodbav:"love" json:"love"`
+	Shared         int64  `dynamodbav:"shared" json:"shared"`
+	AcesseDonation int64  `dynamodbav:"acesse_donation" json:"acesse_donation"`
+	CreatePix      int64  `dynamodbav:"create_pix" json:"create_pix"`
+	CreateCartao   int64  `dynamodbav:"create_cartao" json:"create_cartao"`
+	CreatePayPal   int64  `dynamodbav:"create_paypal" json:"create_paypal"`
+	CreateGoogle   int64  `dynamodbav:"create_google" json:"create_google"`
+	CreatePag1     int64  `dynamodbav:"create_pag1" json:"create_pag1"`
+	CreatePag2     int64  `dynamodbav:"create_pag2" json:"create_pag2"`
+	CreatePag3     int64  `dynamodbav:"create_pag3" json:"create_pag3"`
+	DateCreate     string `dynamodbav:"date_create,omitempty" json:"date_create,omitempty"`
+	DateUpdate     string `dynamodbav:"date_update" json:"date_update"`
+}
+
+// VisualizationEvent e uma interacao individual (DONATION#{id} / VIS#{data}#{id}).
+type VisualizationEvent struct {
+	ID              string `dynamodbav:"id" json:"id"`
+	IDVisualization string `dynamodbav:"id_visualization" json:"id_visualization"`
+	IP              string `dynamodbav:"ip" json:"ip"`
+	IDUser          string `dynamodbav:"id_user" json:"id_user"`
+	Idioma          string `dynamodbav:"idioma" json:"idioma"`
+	Tema            string `dynamodbav:"tema" json:"tema"`
+	Form            string `dynamodbav:"form" json:"form"`
+	Google          string `dynamodbav:"google" json:"google"`
+	GoogleMaps      string `dynamodbav:"google_maps" json:"google_maps"`
+	GoogleAds       string `dynamodbav:"google_ads" json:"google_ads"`
+	MetaPixel       string `dynamodbav:"meta_pixel" json:"meta_pixel"`
+	CookiesStripe   string `dynamodbav:"Cookies_Stripe" json:"Cookies_Stripe"`
+	CookiesPayPal   string `dynamodbav:"Cookies_PayPal" json:"Cookies_PayPal"`
+	VisitorInfo     string `dynamodbav:"visitor_info1_live" json:"visitor_info1_live"`
+	DonationLike    bool   `dynamodbav:"donation_like" json:"donation_like"`
+	Love            bool   `dynamodbav:"love" json:"love"`
+	Shared          bool   `dynamodbav:"shared" json:"shared"`
+	AcesseDonation  bool   `dynamodbav:"acesse_donation" json:"acesse_donation"`
+	CreatePix       bool   `dynamodbav:"create_pix" json:"create_pix"`
+	CreateCartao    bool   `dynamodbav:"create_cartao" json:"create_cartao"`
+	CreatePayPal    bool   `dynamodbav:"create_paypal" json:"create_paypal"`
+	CreateGoogle    bool   `dynamodbav:"create_google" json:"create_google"`
+	CreatePag1      bool   `dynamodbav:"create_pag1" json:"create_pag1"`
+	CreatePag2      bool   `dynamodbav:"create_pag2" json:"create_pag2"`
+	CreatePag3      bool   `dynamodbav:"create_pag3" json:"create_pag3"`
+	DateCreate      string `dynamodbav:"date_create" json:"date_create"`
+}
+
+// Contact e uma mensagem do formulario de contato (CONTACT#{id} / DETAIL).
+type Contact struct {
+	ID         string `dynamodbav:"id" json:"id"`
+	Nome       string `dynamodbav:"nome" json:"nome"`
+	Email      string `dynamodbav:"email" json:"email"`
+	Mensagem   string `dynamodbav:"mensagem" json:"mensagem"`
+	IP         string `dynamodbav:"ip" json:"ip"`
+	Location   string `dynamodbav:"location" json:"location"`
+	Token      string `dynamodbav:"token" json:"-"`
+	View       bool   `dynamodbav:"view" json:"view"`
+	DataCreate string `dynamodbav:"data_create" json:"data_create"`
+}
+
+// EmailVerify e o token de confirmacao de email (EMAIL#VERIFY#{token} / USER#{id}).
+type EmailVerify struct {
+	UserID     string `dynamodbav:"user_id" json:"user_id"`
+	Email      string `dynamodbav:"email" json:"email"`
+	DonationID string `dynamodbav:"donation_id,omitempty" json:"donation_id,omitempty"`
+	Used       bool   `dynamodbav:"used" json:"used"`
+	Attempts   int64  `dynamodbav:"attempts" json:"attempts"`
+	Blocked    bool   `dynamodbav:"blocked" json:"blocked"`
+	DateCreate string `dynamodbav:"date_create" json:"date_create"`
+	DateUpdate string `dynamodbav:"date_update,omitempty" json:"date_update,omitempty"`
+	ExpiresAt  string `dynamodbav:"expires_at" json:"expires_at"`
+}
+
+// EmailQuota conta os envios do dia (EMAIL#QUOTA#{data} / COUNTER).
+type EmailQuota struct {
+	SendCount  int64  `dynamodbav:"send_count" json:"send_count"`
+	DateUpdate string `dynamodbav:"date_update" json:"date_update"`
+}
+
+// EmailPending e um envio adiado pela cota diaria (EMAIL#PENDING / TS#{epochMs}#{id}).
+type EmailPending struct {
+	Status        string `dynamodbav:"status" json:"status"`
+	Payload       string `dynamodbav:"payload" json:"payload"`
+	Attempts      int64  `dynamodbav:"attempts" json:"attempts"`
+	NextAttemptAt string `dynamodbav:"next_attempt_at" json:"next_attempt_at"`
+	DateCreate    string `dynamodbav:"date_create" json:"date_create"`
+	DateUpdate    string `dynamodbav:"date_update" json:"date_update"`
+}
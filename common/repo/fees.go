@@ -0,0 +1,262 @@
+package repo
+
+import (
+	"BACK_SORTE_GO/common/money"
+	"BACK_SORTE_GO/common/store"
+	"BACK_SORTE_GO/common/store/dynamo"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Meios de pagamento das regras de taxa.
+const (
+	FeeMethodPix    = "PIX"
+	FeeMethodCartao = "CARTAO"
+)
+
+const prefixFeeVersion = "V#"
+
+// DefaultFeePolicy vale enquanto nenhuma versao foi publicada: os 10% que a
+// plataforma sempre cobrou.
+var DefaultFeePolicy = FeePolicy{
+	Versao:    0,
+	Descricao: "Taxa padrao",
+	Regras: []FeeRule{
+		{Metodo: FeeMethodPix, Percentual: 10},
+		{Metodo: FeeMethodCartao, Percentual: 10},
+	},
+}
+
+var feeMethods = map[string]bool{FeeMethodPix: true, FeeMethodCartao: true}
+
+// FeeRepo le e publica as versoes da politica de taxas.
+type FeeRepo struct {
+	store dynamo.Store
+}
+
+func NewFeeRepo(storeDDB dynamo.Store) FeeRepo {
+	return FeeRepo{store: storeDDB}
+}
+
+func feeVersionSK(versao int) string {
+	return fmt.Sprintf("%s%06d", prefixFeeVersion, versao)
+}
+
+// Current devolve a versao mais recente; sem nenhuma publicada, DefaultFeePolicy.
+func (r FeeRepo) Current(ctx context.Context) (FeePolicy, error) {
+	out, err := r.store.Query(ctx, &dynamodb.QueryInput{
+		KeyConditionExpression: aws.String("PK = :pk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": dynamo.S(store.FeePolicyPK),
+		},
+		ScanIndexForward: aws.Bool(false),
+		Limit:            aws.Int32(1),
+	})
+	if err != nil {
+		return FeePolicy{}, err
+	}
+	if len(out.Items) == 0 {
+		return DefaultFeePolicy, nil
+	}
+	var p FeePolicy
+	err = attributevalue.UnmarshalMap(out.Items[0], &p)
+	return p, err
+}
+
+// Get devolve uma versao publicada; a versao 0 e DefaultFeePolicy.
+func (r FeeRepo) Get(ctx context.Context, versao int) (FeePolicy, error) {
+	if versao == 0 {
+		return DefaultFeePolicy, nil
+	}
+	var p FeePolicy
+	err := getItem(ctx, r.store, store.FeePolicyPK, feeVersionSK(versao), &p)
+	return p, err
+}
+
+// List devolve as versoes publicadas, da mais recente para a mais antiga.
+func (r FeeRepo) List(ctx context.Context) ([]FeePolicy, error) {
+	items, err := queryAll(ctx, r.store, &dynamodb.QueryInput{
+		KeyConditionExpression: aws.String("PK = :pk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": dynamo.S(store.FeePolicyPK),
+		},
+		ScanIndexForward: aws.Bool(false),
+	})
+	if err != nil {
+		return nil, err
+	}
+	policies := []FeePolicy{}
+	err = attributevalue.UnmarshalListOfMaps(items, &policies)
+	return policies, err
+}
+
+// Publish grava p como a versao seguinte a atual. Duas publicacoes
+// concorrentes disputam o mesmo numero e a segunda recebe ErrConflict.
+func (r FeeRepo) Publish(ctx context.Context, p FeePolicy) (FeePolicy, error) {
+	if err := p.Validate(); err != nil {
+		return FeePolicy{}, err
+	}
+	current, err := r.Current(ctx)
+	if err != nil {
+		return FeePolicy{}, err
+	}
+	p.Versao = current.Versao + 1
+	p.DateCreate = now()
+	item, err := marshalItem(p, map[string]string{"PK": store.FeePolicyPK, "SK": feeVersionSK(p.Versao)})
+	if err != nil {
+		return FeePolicy{}, err
+	}
+	err = r.store.TransactWrite(ctx, []types.TransactWriteItem{{Put: &types.Put{
+		TableName:           aws.String(r.store.TableName()),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(PK)"),
+	}}})
+	if dynamo.IsConditionFailed(err) {
+		return FeePolicy{}, ErrConflict
+	}
+	return p, err
+}
+
+// Quote calcula a taxa de um pagamento da campanha com a politica atual e o
+// nivel da conta do dono (ACCOUNT#LEVEL).
+func (r FeeRepo) Quote(ctx context.Context, metodo, donationID string, valor money.Money, at time.Time) (AppliedFee, error) {
+	policy, err := r.Current(ctx)
+	if err != nil {
+		return AppliedFee{}, err
+	}
+	nivel, err := r.ownerLevel(ctx, donationID)
+	if err != nil {
+		return AppliedFee{}, err
+	}
+	return policy.Apply(metodo, nivel, valor, at)
+}
+
+// ownerLevel devolve o nivel da conta do dono da campanha; campanha ou nivel
+// ausentes caem nas regras sem nivel.
+func (r FeeRepo) ownerLevel(ctx context.Context, donationID string) (string, error) {
+	donation, err := NewDonationRepo(r.store).Get(ctx, donationID)
+	if errors.Is(err, ErrNotFound) || donation.IDUser == "" {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	level, err := NewUserRepo(r.store).GetAccountLevel(ctx, donation.IDUser)
+	if errors.Is(err, ErrNotFound) {
+		return "", nil
+	}
+	return level.Nivel, err
+}
+
+// Validate confere metodos, niveis de conta, percentuais, valores fixos e
+// periodos das promocoes.
+func (p FeePolicy) Validate() error {
+	if len(p.Regras) == 0 {
+		return errors.New("politica sem regras")
+	}
+	seen := map[string]bool{}
+	for _, rule := range p.Regras {
+		if !feeMethods[rule.Metodo] {
+			return fmt.Errorf("metodo invalido: %q", rule.Metodo)
+		}
+		if rule.Nivel != "" && !accountLevels[rule.Nivel] {
+			return fmt.Errorf("nivel invalido: %q", rule.Nivel)
+		}
+		if rule.Percentual < 0 || rule.Percentual > 100 || rule.Fixo.IsNegative() {
+			return fmt.Errorf("taxa invalida para %s %s", rule.Metodo, rule.Nivel)
+		}
+		key := rule.Metodo + "#" + rule.Nivel
+		if seen[key] {
+			return fmt.Errorf("regra repetida para %s %s", rule.Metodo, rule.Nivel)
+		}
+		seen[key] = true
+	}
+	for _, promo := range p.Promocoes {
+		if strings.TrimSpace(promo.Nome) == "" {
+			return errors.New("promocao sem nome")
+		}
+		if promo.Metodo != "" && !feeMethods[promo.Metodo] {
+			return fmt.Errorf("metodo invalido na promocao %s: %q", promo.Nome, promo.Metodo)
+		}
+		if promo.Nivel != "" && !accountLevels[promo.Nivel] {
+			return fmt.Errorf("nivel invalido na promocao %s: %q", promo.Nome, promo.Nivel)
+		}
+		inicio, err1 := time.Parse(time.RFC3339, promo.Inicio)
+		fim, err2 := time.Parse(time.RFC3339, promo.Fim)
+		if err1 != nil || err2 != nil || !fim.After(inicio) {
+			return fmt.Errorf("periodo invalido na promocao %s", promo.Nome)
+		}
+	}
+	return nil
+}
+
+// Apply calcula a taxa de valor: promocao ativa zera a taxa; senao vale a regra
+// do metodo para o nivel ou, sem ela, a regra do metodo sem nivel. A taxa nunca
+// passa do valor pago. Valor em outra moeda que nao a da regra e erro.
+func (p FeePolicy) Apply(metodo, nivel string, valor money.Money, at time.Time) (AppliedFee, error) {
+	fee := AppliedFee{Versao: p.Versao, Metodo: metodo, Nivel: nivel, Liquido: valor}
+	for _, promo := range p.Promocoes {
+		if promo.active(metodo, nivel, at) {
+			fee.Promocao = promo.Nome
+			return fee, nil
+		}
+	}
+	rule, ok := p.rule(metodo, nivel)
+	if !ok {
+		return fee, nil
+	}
+	fee.Percentual, fee.Fixo = rule.Percentual, rule.Fixo
+	taxa, err := valor.Percent(rule.Percentual).Add(rule.Fixo)
+	if err != nil {
+		return AppliedFee{}, err
+	}
+	if fee.Valor, err = taxa.Min(valor); err != nil {
+		return AppliedFee{}, err
+	}
+	if fee.Liquido, err = valor.Sub(fee.Valor); err != nil {
+		return AppliedFee{}, err
+	}
+	return fee, nil
+}
+
+func (p FeePolicy) rule(metodo, nivel string) (FeeRule, bool) {
+	var fallback *FeeRule
+	for i, rule := range p.Regras {
+		if rule.Metodo != metodo {
+			continue
+		}
+		if rule.Nivel == nivel && nivel != "" {
+			return rule, true
+		}
+		if rule.Nivel == "" {
+			fallback = &p.Regras[i]
+		}
+	}
+	if fallback == nil {
+		return FeeRule{}, false
+	}
+	return *fallback, true
+}
+
+func (promo FeePromo) active(metodo, nivel string, at time.Time) bool {
+	if promo.Metodo != "" && promo.Metodo != metodo || promo.Nivel != "" && promo.Nivel != nivel {
+		return false
+	}
+	inicio, err1 := time.Parse(time.RFC3339, promo.Inicio)
+	fim, err2 := time.Parse(time.RFC3339, promo.Fim)
+	return err1 == nil && err2 == nil && !at.Before(inicio) && at.Before(fim)
+}
+
+// Net devolve a parte liquida de um valor devolvido de pagamento com essa taxa,
+// proporcional ao liquido creditado.
+func (f AppliedFee) Net(valor, pago money.Money) money.Money {
+	return f.Liquido.Prorate(valor, pago)
+}
@@ -0,0 +1,430 @@
+package repo
+
+import (
+	"BACK_SORTE_GO/common/money"
+	"BACK_SORTE_GO/common/store"
+	"BACK_SORTE_GO/common/store/dynamo"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Tipos de lancamento do livro razao.
+const (
+	LedgerCredito = "CREDITO" // pagamento bruto do doador entra na campanha
+	LedgerTaxa    = "TAXA"    // taxa da plataforma, ou a parte dela devolvida ao doador
+	LedgerEstorno = "ESTORNO" // parte liquida devolvida ao doador
+	LedgerResgate = "RESGATE" // saque do saldo pelo dono da campanha
+	LedgerAjuste  = "AJUSTE"  // correcao manual do financeiro
+)
+
+// Contas do livro razao. As tres primeiras tem o saldo gravado no PAYMENT da
+// campanha; DOADOR e AJUSTE sao so contrapartidas.
+const (
+	AccountCampanha   = "CAMPANHA"   // valor_disponivel
+	AccountPlataforma = "PLATAFORMA" // valor_taxas
+	AccountResgate    = "RESGATE"    // valor_tranferido
+	AccountDoador     = "DOADOR"
+	AccountAjuste     = "AJUSTE"
+)
+
+// ledgerBalanceAttrs liga cada conta com saldo gravado ao atributo do PAYMENT.
+var ledgerBalanceAttrs = []struct{ conta, attr string }{
+	{AccountCampanha, "valor_disponivel"},
+	{AccountPlataforma, "valor_taxas"},
+	{AccountResgate, "valor_tranferido"},
+}
+
+var ledgerAccounts = map[string]bool{
+	AccountCampanha: true, AccountPlataforma: true, AccountResgate: true, AccountDoador: true, AccountAjuste: true,
+}
+
+// ErrInsufficientBalance indica resgate ou estorno maior que o saldo disponivel.
+var ErrInsufficientBalance = errors.New("saldo disponivel insuficiente")
+
+func ledgerSK(data, id string) string {
+	return store.PrefixLedger + data + "#" + id
+}
+
+// ledgerRefSK e a marca do fato que gerou o lancamento: a mesma referencia e
+// tipo nunca sao lancados duas vezes na campanha.
+func ledgerRefSK(ref, tipo string) string {
+	return store.PrefixLedgerRef + ref + "#" + tipo
+}
+
+// debitsBalance diz se o lancamento tira dinheiro do saldo disponivel da
+// campanha e por isso depende dele (resgate e estorno ao doador). A taxa e
+// os ajustes podem deixar o saldo negativo.
+func (e LedgerEntry) debitsBalance() bool {
+	return e.Origem == AccountCampanha && (e.Tipo == LedgerResgate || e.Tipo == LedgerEstorno)
+}
+
+// LedgerRepo grava e confere o livro razao das campanhas.
+type LedgerRepo struct {
+	store dynamo.Store
+}
+
+func NewLedgerRepo(storeDDB dynamo.Store) LedgerRepo {
+	return LedgerRepo{store: storeDDB}
+}
+
+// PaymentEntries lanca um pagamento confirmado: o bruto entra na campanha e a
+// taxa sai dela para a plataforma. ref identifica o pagamento (txid ou
+// payment intent) e forma o id dos lancamentos.
+func PaymentEntries(ref string, bruto money.Money, fee AppliedFee) []LedgerEntry {
+	entries := []LedgerEntry{{
+		ID: LedgerCredito + "-" + ref, Tipo: LedgerCredito, Referencia: ref,
+		Origem: AccountDoador, Destino: AccountCampanha, Valor: bruto,
+	}}
+	if fee.Valor.Centavos > 0 {
+		entries = append(entries, LedgerEntry{
+			ID: LedgerTaxa + "-" + ref, Tipo: LedgerTaxa, Referencia: ref,
+			Origem: AccountCampanha, Destino: AccountPlataforma, Valor: fee.Valor,
+			Descricao: fmt.Sprintf("politica v%d", fee.Versao),
+		})
+	}
+	return entries
+}
+
+// RefundEntries lanca uma devolucao ao doador: a parte liquida sai da campanha
+// e o resto (a taxa cobrada sobre o valor devolvido) sai da plataforma.
+func RefundEntries(ref string, valor, liquido money.Money) ([]LedgerEntry, error) {
+	taxa, err := valor.Sub(liquido)
+	if err != nil {
+		return nil, err
+	}
+	var entries []LedgerEntry
+	if liquido.Centavos > 0 {
+		entries = append(entries, LedgerEntry{
+			ID: LedgerEstorno + "-" + ref, Tipo: LedgerEstorno, Referencia: ref,
+			Origem: AccountCampanha, Destino: AccountDoador, Valor: liquido,
+		})
+	}
+	if taxa.Centavos > 0 {
+		entries = append(entries, LedgerEntry{
+			ID: LedgerTaxa + "-" + ref, Tipo: LedgerTaxa, Referencia: ref,
+			Origem: AccountPlataforma, Destino: AccountDoador, Valor: taxa,
+		})
+	}
+	return entries, nil
+}
+
+// RefundReleaseEntries desfaz os lancamentos de RefundEntries quando a EFI nao
+// realiza a devolucao: o que saiu volta para a campanha e para a plataforma.
+func RefundReleaseEntries(ref string, valor, liquido money.Money) ([]LedgerEntry, error) {
+	entries, err := RefundEntries(ref, valor, liquido)
+	for i := range entries {
+		entries[i].Origem, entries[i].Destino = entries[i].Destino, entries[i].Origem
+	}
+	return entries, err
+}
+
+// Writes monta as escritas que gravam os lancamentos da campanha e somam cada
+// um ao saldo da sua conta no PAYMENT. Elas entram na transacao de quem gera o
+// lancamento (confirmacao, devolucao), cuja condicao garante que o fato e
+// lancado uma unica vez.
+func (r LedgerRepo) Writes(donationID string, entries ...LedgerEntry) ([]types.TransactWriteItem, error) {
+	items, update, _, err := r.writes(donationID, entries)
+	if err != nil || update == nil {
+		return items, err
+	}
+	return append(items, types.TransactWriteItem{Update: update}), nil
+}
+
+// writes devolve os Puts dos lancamentos e o update dos saldos em separado,
+// para quem precisa gravar mais campos no PAYMENT na mesma transacao (a
+// DynamoDB nao aceita duas operacoes no mesmo item). Resgates e estornos
+// condicionam o update ao saldo disponivel; o total debitado volta em debito.
+// Cada lancamento grava junto a sua marca LEDGERREF, entao o mesmo fato
+// lancado de novo falha a transacao.
+func (r LedgerRepo) writes(donationID string, entries []LedgerEntry) (items []types.TransactWriteItem, update *types.Update, debito money.Money, err error) {
+	if len(entries) == 0 {
+		return nil, nil, debito, nil
+	}
+	ts := now()
+	pk := store.DonationPK(donationID)
+	table := aws.String(r.store.TableName())
+	for _, e := range entries {
+		e.IDDoacao = donationID
+		if e.DataCriacao == "" {
+			e.DataCriacao = ts
+		}
+		if err := e.Validate(); err != nil {
+			return nil, nil, debito, err
+		}
+		if e.debitsBalance() {
+			if debito, err = debito.Add(e.Valor); err != nil {
+				return nil, nil, debito, err
+			}
+		}
+		puts, err := entryPuts(table, pk, e)
+		if err != nil {
+			return nil, nil, debito, err
+		}
+		items = append(items, puts...)
+	}
+
+	balances, err := Balances(entries)
+	if err != nil {
+		return nil, nil, debito, err
+	}
+	sets := []string{}
+	values := map[string]types.AttributeValue{
+		":z": dynamo.N("0"),
+		":d": dynamo.S(ts),
+	}
+	for i, b := range ledgerBalanceAttrs {
+		v := balances[b.conta]
+		if v.IsZero() {
+			continue
+		}
+		name := fmt.Sprintf(":v%d", i)
+		sets = append(sets, fmt.Sprintf("%s = if_not_exists(%s, :z) + %s", b.attr, b.attr, name))
+		values[name] = dynamo.N(v.String())
+	}
+	update = &types.Update{
+		TableName:                 table,
+		Key:                       itemKey(pk, skPayment),
+		UpdateExpression:          aws.String("SET " + strings.Join(append(sets, "data_update = :d"), ", ")),
+		ExpressionAttributeValues: values,
+	}
+	if !debito.IsZero() {
+		update.ConditionExpression = aws.String("valor_disponivel >= :debito")
+		values[":debito"] = dynamo.N(debito.String())
+	}
+	return items, update, debito, nil
+}
+
+// entryPuts devolve o put do lancamento e o da marca da sua referencia, os
+// dois condicionados a nao existir.
+func entryPuts(table *string, pk string, e LedgerEntry) ([]types.TransactWriteItem, error) {
+	item, err := marshalItem(e, map[string]string{"PK": pk, "SK": e.SK()})
+	if err != nil {
+		return nil, err
+	}
+	ref := map[string]types.AttributeValue{
+		"PK":   dynamo.S(pk),
+		"SK":   dynamo.S(e.RefSK()),
+		"id":   dynamo.S(e.ID),
+		"tipo": dynamo.S(e.Tipo),
+	}
+	return []types.TransactWriteItem{
+		{Put: &types.Put{TableName: table, Item: item, ConditionExpression: aws.String("attribute_not_exists(PK)")}},
+		{Put: &types.Put{TableName: table, Item: ref, ConditionExpression: aws.String("attribute_not_exists(PK)")}},
+	}, nil
+}
+
+// Validate confere tipo, contas e valor do lancamento.
+func (e LedgerEntry) Validate() error {
+	if strings.TrimSpace(e.ID) == "" || e.Tipo == "" {
+		return errors.New("lancamento sem id ou tipo")
+	}
+	if !ledgerAccounts[e.Origem] || !ledgerAccounts[e.Destino] || e.Origem == e.Destino {
+		return fmt.Errorf("contas invalidas no lancamento %s: %s -> %s", e.ID, e.Origem, e.Destino)
+	}
+	if e.Valor.Centavos <= 0 {
+		return fmt.Errorf("valor invalido no lancamento %s: %s", e.ID, e.Valor)
+	}
+	return nil
+}
+
+// AdjustmentEntry monta um ajuste manual do financeiro na conta informada
+// (CAMPANHA, PLATAFORMA ou RESGATE): valor positivo entra na conta, negativo
+// sai dela, com a conta AJUSTE de contrapartida.
+func AdjustmentEntry(id, conta string, valor money.Money, descricao, userID string) LedgerEntry {
+	e := LedgerEntry{
+		ID: LedgerAjuste + "-" + id, Tipo: LedgerAjuste, Referencia: id,
+		Origem: AccountAjuste, Destino: conta, Valor: valor,
+		Descricao: descricao, IDUser: userID,
+	}
+	if valor.IsNegative() {
+		e.Origem, e.Destino, e.Valor = conta, AccountAjuste, valor.Neg()
+	}
+	return e
+}
+
+// Record grava lancamentos avulsos (ajustes) com os saldos. Resgate ou
+// estorno acima do disponivel devolve ErrInsufficientBalance.
+func (r LedgerRepo) Record(ctx context.Context, donationID string, entries ...LedgerEntry) error {
+	items, update, debito, err := r.writes(donationID, entries)
+	if err != nil || update == nil {
+		return err
+	}
+	return r.transact(ctx, donationID, append(items, types.TransactWriteItem{Update: update}), debito)
+}
+
+// transact grava a transacao e, se uma condicao falhar, separa saldo
+// insuficiente para o debito de lancamento repetido ou concorrente.
+func (r LedgerRepo) transact(ctx context.Context, donationID string, items []types.TransactWriteItem, debito money.Money) error {
+	err := r.store.TransactWrite(ctx, items)
+	if !dynamo.IsConditionFailed(err) {
+		return err
+	}
+	if !debito.IsZero() {
+		var payment DonationPayment
+		if err := getItem(ctx, r.store, store.DonationPK(donationID), skPayment, &payment); err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+		if cmp, err := payment.ValorDisponivel.Cmp(debito); err != nil {
+			return err
+		} else if cmp < 0 {
+			return ErrInsufficientBalance
+		}
+	}
+	return ErrConflict
+}
+
+// OpenBalances lanca como AJUSTE de abertura a diferenca entre os saldos
+// gravados no PAYMENT e os recalculados, para campanhas com saldo anterior ao
+// livro razao. Os lancamentos nao mexem nos saldos (ja estao gravados) e a
+// transacao confere que eles nao mudaram desde a leitura; se mudaram, ErrConflict.
+func (r LedgerRepo) OpenBalances(ctx context.Context, donationID, userID string) (LedgerCheck, error) {
+	check, err := r.Check(ctx, donationID)
+	if err != nil || len(check.Divergencias) == 0 {
+		return check, err
+	}
+	ts := now()
+	pk := store.DonationPK(donationID)
+	table := aws.String(r.store.TableName())
+	conds := []string{}
+	values := map[string]types.AttributeValue{}
+	var items []types.TransactWriteItem
+	for i, d := range check.Divergencias {
+		e := AdjustmentEntry(fmt.Sprintf("ABERTURA-%s-%d", strings.ReplaceAll(ts, ":", ""), i), d.Conta, d.Diferenca, "saldo de abertura do livro razao", userID)
+		e.IDDoacao, e.DataCriacao = donationID, ts
+		puts, err := entryPuts(table, pk, e)
+		if err != nil {
+			return check, err
+		}
+		items = append(items, puts...)
+		name := fmt.Sprintf(":g%d", i)
+		conds = append(conds, fmt.Sprintf("%s = %s", d.Atributo, name))
+		values[name] = dynamo.N(d.Gravado.String())
+	}
+	items = append(items, types.TransactWriteItem{ConditionCheck: &types.ConditionCheck{
+		TableName:                 table,
+		Key:                       itemKey(pk, skPayment),
+		ConditionExpression:       aws.String(strings.Join(conds, " AND ")),
+		ExpressionAttributeValues: values,
+	}})
+	err = r.store.TransactWrite(ctx, items)
+	if dynamo.IsConditionFailed(err) {
+		return check, ErrConflict
+	}
+	if err != nil {
+		return check, err
+	}
+	return r.Check(ctx, donationID)
+}
+
+// List devolve os lancamentos da campanha em ordem cronologica.
+func (r LedgerRepo) List(ctx context.Context, donationID string) ([]LedgerEntry, error) {
+	items, err := queryAll(ctx, r.store, &dynamodb.QueryInput{
+		KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :sk)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": dynamo.S(store.DonationPK(donationID)),
+			":sk": dynamo.S(store.PrefixLedger),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	entries := []LedgerEntry{}
+	if err := attributevalue.UnmarshalListOfMaps(items, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// LedgerBalances e o saldo de cada conta: entradas menos saidas.
+type LedgerBalances map[string]money.Money
+
+// Balances recalcula os saldos a partir dos lancamentos.
+func Balances(entries []LedgerEntry) (LedgerBalances, error) {
+	out := LedgerBalances{}
+	for _, e := range entries {
+		origem, err := out[e.Origem].Sub(e.Valor)
+		if err != nil {
+			return nil, err
+		}
+		destino, err := out[e.Destino].Add(e.Valor)
+		if err != nil {
+			return nil, err
+		}
+		out[e.Origem], out[e.Destino] = origem, destino
+	}
+	return out, nil
+}
+
+// LedgerDivergence e uma conta cujo saldo gravado no PAYMENT difere do
+// recalculado pelos lancamentos.
+type LedgerDivergence struct {
+	Conta     string      `json:"conta"`
+	Atributo  string      `json:"atributo"`
+	Calculado money.Money `json:"calculado"`
+	Gravado   money.Money `json:"gravado"`
+	Diferenca money.Money `json:"diferenca"`
+}
+
+// LedgerCheck e o resultado da conferencia do livro razao de uma campanha.
+type LedgerCheck struct {
+	IDDoacao     string             `json:"id_doacao"`
+	Lancamentos  int                `json:"lancamentos"`
+	Saldos       LedgerBalances     `json:"saldos"`
+	Invalidos    []string           `json:"invalidos,omitempty"`
+	Divergencias []LedgerDivergence `json:"divergencias,omitempty"`
+}
+
+// OK indica livro razao sem lancamentos invalidos e saldos gravados iguais
+// aos recalculados.
+func (c LedgerCheck) OK() bool {
+	return len(c.Invalidos) == 0 && len(c.Divergencias) == 0
+}
+
+// Check recalcula os saldos da campanha pelos lancamentos e compara com os
+// gravados no PAYMENT. Saldos anteriores ao livro razao aparecem como
+// divergencia ate o financeiro lancar a abertura (OpenBalances).
+func (r LedgerRepo) Check(ctx context.Context, donationID string) (LedgerCheck, error) {
+	entries, err := r.List(ctx, donationID)
+	if err != nil {
+		return LedgerCheck{}, err
+	}
+	var payment DonationPayment
+	if err := getItem(ctx, r.store, store.DonationPK(donationID), skPayment, &payment); err != nil && !errors.Is(err, ErrNotFound) {
+		return LedgerCheck{}, err
+	}
+	saldos, err := Balances(entries)
+	if err != nil {
+		return LedgerCheck{}, err
+	}
+	check := LedgerCheck{IDDoacao: donationID, Lancamentos: len(entries), Saldos: saldos}
+	for _, e := range entries {
+		if err := e.Validate(); err != nil {
+			check.Invalidos = append(check.Invalidos, err.Error())
+		}
+	}
+	gravados := map[string]money.Money{
+		AccountCampanha:   payment.ValorDisponivel,
+		AccountPlataforma: payment.ValorTaxas,
+		AccountResgate:    payment.ValorTransferido,
+	}
+	for _, b := range ledgerBalanceAttrs {
+		calc, grav := check.Saldos[b.conta], gravados[b.conta]
+		diferenca, err := grav.Sub(calc)
+		if err != nil {
+			return LedgerCheck{}, err
+		}
+		if !diferenca.IsZero() {
+			check.Divergencias = append(check.Divergencias, LedgerDivergence{
+				Conta: b.conta, Atributo: b.attr, Calculado: calc, Gravado: grav, Diferenca: diferenca,
+			})
+		}
+	}
+	return check, nil
+}
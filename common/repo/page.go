@@ -0,0 +1,179 @@
+package repo
+
+import (
+	"BACK_SORTE_GO/common/config"
+	"BACK_SORTE_GO/common/store/dynamo"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ErrInvalidCursor indica cursor malformado, adulterado ou de outra listagem.
+var ErrInvalidCursor = errors.New("cursor invalido")
+
+// maxPageRounds limita as consultas de uma pagina quando o filtro descarta
+// muitos itens; a pagina volta menor e o cursor continua de onde parou.
+const maxPageRounds = 10
+
+// encodeCursor serializa o LastEvaluatedKey em um token opaco assinado com
+// HMAC. O escopo (particao consultada) entra na assinatura para que o cursor
+// nao seja reaproveitado em outra listagem. Chaves numericas (rankings) vao
+// como numero no JSON.
+func encodeCursor(scope string, key map[string]types.AttributeValue) (string, error) {
+	if len(key) == 0 {
+		return "", nil
+	}
+	plain := map[string]interface{}{}
+	for name, v := range key {
+		switch v := v.(type) {
+		case *types.AttributeValueMemberS:
+			plain[name] = v.Value
+		case *types.AttributeValueMemberN:
+			plain[name] = json.Number(v.Value)
+		default:
+			return "", errors.New("cursor: chave " + name + " nao e string nem numero")
+		}
+	}
+	payload, err := json.Marshal(plain)
+	if err != nil {
+		return "", err
+	}
+	body := base64.RawURLEncoding.EncodeToString(payload)
+	return body + "." + base64.RawURLEncoding.EncodeToString(signCursor(scope, body)), nil
+}
+
+// decodeCursor valida a assinatura e devolve o ExclusiveStartKey; cursor vazio
+// e o inicio da listagem.
+func decodeCursor(scope, cursor string) (map[string]types.AttributeValue, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	body, sig, ok := strings.Cut(cursor, ".")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, signCursor(scope, body)) {
+		return nil, ErrInvalidCursor
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var plain map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+	if err := dec.Decode(&plain); err != nil || len(plain) == 0 {
+		return nil, ErrInvalidCursor
+	}
+	key := make(map[string]types.AttributeValue, len(plain))
+	for name, v := range plain {
+		switch v := v.(type) {
+		case string:
+			key[name] = dynamo.S(v)
+		case json.Number:
+			key[name] = dynamo.N(v.String())
+		default:
+			return nil, ErrInvalidCursor
+		}
+	}
+	return key, nil
+}
+
+func signCursor(scope, body string) []byte {
+	h := hmac.New(sha256.New, []byte(config.GetCursorSecret()))
+	h.Write([]byte(scope + "|" + body))
+	return h.Sum(nil)
+}
+
+// queryPage le a partir do cursor ate juntar limit itens que passam no filtro.
+// Como o DynamoDB aplica Limit antes do FilterExpression, cada rodada pede so o
+// que falta e o proximo cursor e o LastEvaluatedKey da ultima leitura, sem pular
+// nem repetir itens. Cursor vazio no retorno indica a ultima pagina.
+func queryPage(ctx context.Context, storeDDB dynamo.Store, input *dynamodb.QueryInput, scope, cursor string, limit int) ([]map[string]types.AttributeValue, string, error) {
+	start, err := decodeCursor(scope, cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	input.ExclusiveStartKey = start
+
+	var items []map[string]types.AttributeValue
+	for round := 0; round < maxPageRounds && len(items) < limit; round++ {
+		input.Limit = aws.Int32(int32(limit - len(items)))
+		out, err := storeDDB.Query(ctx, input)
+		if err != nil {
+			return nil, "", err
+		}
+		items = append(items, out.Items...)
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+		if len(out.LastEvaluatedKey) == 0 {
+			return items, "", nil
+		}
+	}
+	next, err := encodeCursor(scope, input.ExclusiveStartKey)
+	return items, next, err
+}
+
+// queryAll segue o LastEvaluatedKey ate o fim da particao, para leituras que
+// precisam de todos os itens (somatorios) e nao podem parar em 1 MB.
+func queryAll(ctx context.Context, storeDDB dynamo.Store, input *dynamodb.QueryInput) ([]map[string]types.AttributeValue, error) {
+	var items []map[string]types.AttributeValue
+	for {
+		out, err := storeDDB.Query(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, out.Items...)
+		if len(out.LastEvaluatedKey) == 0 {
+			return items, nil
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
+
+// countAll conta os itens da consulta com Select COUNT, paginando pelo
+// LastEvaluatedKey.
+func countAll(ctx context.Context, storeDDB dynamo.Store, input *dynamodb.QueryInput) (int, error) {
+	input.Select = types.SelectCount
+	total := 0
+	for {
+		out, err := storeDDB.Query(ctx, input)
+		if err != nil {
+			return 0, err
+		}
+		total += int(out.Count)
+		if len(out.LastEvaluatedKey) == 0 {
+			return total, nil
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
+
+// PageByNumber atende o modo legado ?page=N sobre uma listagem por cursor:
+// avanca pelos cursores ate a pagina pedida, lendo so o necessario em vez da
+// particao inteira. Devolve tambem o cursor da pagina seguinte.
+func PageByNumber[T any](page, limit int, fetch func(cursor string, limit int) ([]T, string, error)) ([]T, string, error) {
+	skip := (page - 1) * limit
+	cursor := ""
+	for skip > 0 {
+		chunk, next, err := fetch(cursor, min(skip, 100))
+		if err != nil {
+			return nil, "", err
+		}
+		skip -= len(chunk)
+		cursor = next
+		if cursor == "" {
+			return []T{}, "", nil
+		}
+	}
+	return fetch(cursor, limit)
+}
@@ -0,0 +1,333 @@
+package repo
+
+import (
+	"BACK_SORTE_GO/common/store"
+	"BACK_SORTE_GO/common/store/dynamo"
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const skStatus = "STATUS"
+
+// PixRepo le e grava as cobrancas Pix (PIX# na campanha) e o status por txid (TX#).
+type PixRepo struct {
+	store dynamo.Store
+}
+
+func NewPixRepo(storeDDB dynamo.Store) PixRepo {
+	return PixRepo{store: storeDDB}
+}
+
+func pixSK(dataCriacao, id string) string {
+	return store.PrefixPix + dataCriacao + "#" + id
+}
+
+// removeOpen tira a cobranca do indice de acompanhamento (GSI5).
+const removeOpen = " REMOVE GSI5PK, GSI5SK"
+
+// CreateCharge grava a cobranca na campanha e o lookup TX#{txid}; PixSK e
+// preenchido a partir da cobranca. Com buscar ligado o TX# entra no GSI5
+// esparso lido pela conciliacao, do mais antigo para o mais novo.
+func (r PixRepo) CreateCharge(ctx context.Context, p Pix, st PixStatus) error {
+	st.PixSK = p.SK()
+	pixItem, err := marshalItem(p, map[string]string{"PK": store.DonationPK(p.IDDoacao), "SK": p.SK()})
+	if err != nil {
+		return err
+	}
+	keys := map[string]string{"PK": store.TxPK(p.TxID), "SK": skStatus}
+	if st.Buscar {
+		keys["GSI5PK"] = store.PixOpenPK
+		keys["GSI5SK"] = p.DataCriacao + "#" + p.TxID
+	}
+	statusItem, err := marshalItem(st, keys)
+	if err != nil {
+		return err
+	}
+	return Transact(ctx, r.store, putItems(r.store, pixItem, statusItem))
+}
+
+// ListByDonation devolve todas as cobrancas da campanha; newestFirst inverte a
+// ordem cronologica.
+func (r PixRepo) ListByDonation(ctx context.Context, donationID string, newestFirst bool) ([]Pix, error) {
+	items, err := queryAll(ctx, r.store, &dynamodb.QueryInput{
+		KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :sk)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": dynamo.S(store.DonationPK(donationID)),
+			":sk": dynamo.S(store.PrefixPix),
+		},
+		ScanIndexForward: aws.Bool(!newestFirst),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var charges []Pix
+	err = attributevalue.UnmarshalListOfMaps(items, &charges)
+	return charges, err
+}
+
+// ListMessagesPage devolve uma pagina das cobrancas visiveis (mensagens de
+// apoio), da mais recente para a mais antiga, e o cursor da pagina seguinte.
+func (r PixRepo) ListMessagesPage(ctx context.Context, donationID, cursor string, limit int) ([]Pix, string, error) {
+	pk := store.DonationPK(donationID)
+	items, next, err := queryPage(ctx, r.store, &dynamodb.QueryInput{
+		KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :sk)"),
+		FilterExpression:       aws.String("visivel = :v"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": dynamo.S(pk),
+			":sk": dynamo.S(store.PrefixPix),
+			":v":  dynamo.B(true),
+		},
+		ScanIndexForward: aws.Bool(false),
+	}, pk+"#"+store.PrefixPix, cursor, limit)
+	if err != nil {
+		return nil, "", err
+	}
+	charges := []Pix{}
+	err = attributevalue.UnmarshalListOfMaps(items, &charges)
+	return charges, next, err
+}
+
+// GetStatus devolve o status da cobranca pelo txid.
+func (r PixRepo) GetStatus(ctx context.Context, txid string) (PixStatus, error) {
+	var st PixStatus
+	err := getItem(ctx, r.store, store.TxPK(txid), skStatus, &st)
+	return st, err
+}
+
+// Confirm finaliza a cobranca como paga e, na mesma transacao, mostra a
+// mensagem na campanha, credita o valor liquido da taxa da politica atual
+// (gravada no PIX# em taxa) e atualiza o agregado AGG. A condicao em finalizado
+// garante que a confirmacao repetida (monitor e reprocessamentos) so conta uma
+// vez: applied volta false quando a cobranca ja estava finalizada.
+func (r PixRepo) Confirm(ctx context.Context, txid string) (st PixStatus, applied bool, err error) {
+	for attempt := 0; attempt < 2; attempt++ {
+		st, err = r.GetStatus(ctx, txid)
+		if err != nil || st.Finalizado {
+			return st, false, err
+		}
+		items, err := r.confirmWrites(ctx, txid, st)
+		if err != nil {
+			return PixStatus{}, false, err
+		}
+		err = r.store.TransactWrite(ctx, items)
+		if dynamo.IsConditionFailed(err) {
+			continue
+		}
+		if err != nil {
+			return PixStatus{}, false, err
+		}
+		st, err = r.GetStatus(ctx, txid)
+		return st, true, err
+	}
+	return PixStatus{}, false, ErrConflict
+}
+
+// confirmWrites monta as escritas de Confirm; sem campanha so o TX# e
+// finalizado. Se o doador pediu as novidades, a inscricao (SUB#) vai junto.
+func (r PixRepo) confirmWrites(ctx context.Context, txid string, st PixStatus) ([]types.TransactWriteItem, error) {
+	ts := now()
+	table := aws.String(r.store.TableName())
+	items := []types.TransactWriteItem{{Update: &types.Update{
+		TableName:           table,
+		Key:                 itemKey(store.TxPK(txid), skStatus),
+		UpdateExpression:    aws.String("SET #s = :s, buscar = :b, finalizado = :t, data_pago = :d" + removeOpen),
+		ConditionExpression: aws.String("attribute_not_exists(finalizado) OR finalizado = :f"),
+		ExpressionAttributeNames: map[string]string{
+			"#s": "status",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":s": dynamo.S("CONCLUIDA"),
+			":b": dynamo.B(false),
+			":t": dynamo.B(true),
+			":f": dynamo.B(false),
+			":d": dynamo.S(ts),
+		},
+	}}}
+	if st.IDDoacao == "" || st.PixSK == "" {
+		return items, nil
+	}
+
+	var charge Pix
+	if err := getItem(ctx, r.store, store.DonationPK(st.IDDoacao), st.PixSK, &charge); err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	progress, err := NewDonationRepo(r.store).ProgressWrites(ctx, st.IDDoacao, DonorCPF(charge.CPF), st.Valor, ts)
+	if errors.Is(err, ErrNotFound) {
+		return items, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	fee, err := NewFeeRepo(r.store).Quote(ctx, FeeMethodPix, st.IDDoacao, st.Valor, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	taxa, err := attributevalue.Marshal(fee)
+	if err != nil {
+		return nil, err
+	}
+
+	ledger, err := NewLedgerRepo(r.store).Writes(st.IDDoacao, PaymentEntries(txid, st.Valor, fee)...)
+	if err != nil {
+		return nil, err
+	}
+
+	pk := store.DonationPK(st.IDDoacao)
+	items = append(items,
+		types.TransactWriteItem{Update: &types.Update{
+			TableName:        table,
+			Key:              itemKey(pk, st.PixSK),
+			UpdateExpression: aws.String("SET visivel = :v, #s = :s, taxa = :taxa"),
+			ExpressionAttributeNames: map[string]string{
+				"#s": "status",
+			},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":v":    dynamo.B(true),
+				":s":    dynamo.S("CONCLUIDA"),
+				":taxa": taxa,
+			},
+		}},
+	)
+	items = append(items, ledger...)
+	items = append(items, progress...)
+	if !charge.Novidades || charge.Email == "" {
+		return items, nil
+	}
+	sub, err := NewDonationRepo(r.store).SubscriberItems(DonationSubscriber{
+		IDDoacao:   st.IDDoacao,
+		Email:      charge.Email,
+		Nome:       charge.Nome,
+		DateCreate: ts,
+	})
+	return append(items, sub...), err
+}
+
+// MarkExpired encerra a busca de uma cobranca vencida.
+func (r PixRepo) MarkExpired(ctx context.Context, txid string) error {
+	return r.StopTracking(ctx, txid, "VENCIDO")
+}
+
+// StopTracking grava o status final de uma cobranca nao paga (VENCIDO ou
+// REMOVIDA_*) e a tira do acompanhamento. Cobranca ja confirmada nao muda.
+func (r PixRepo) StopTracking(ctx context.Context, txid, status string) error {
+	err := r.store.TransactWrite(ctx, []types.TransactWriteItem{{Update: &types.Update{
+		TableName:           aws.String(r.store.TableName()),
+		Key:                 itemKey(store.TxPK(txid), skStatus),
+		UpdateExpression:    aws.String("SET #s = :s, buscar = :b" + removeOpen),
+		ConditionExpression: aws.String("attribute_exists(PK) AND (attribute_not_exists(finalizado) OR finalizado = :b)"),
+		ExpressionAttributeNames: map[string]string{
+			"#s": "status",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":s": dynamo.S(status),
+			":b": dynamo.B(false),
+		},
+	}}})
+	if dynamo.IsConditionFailed(err) {
+		return nil
+	}
+	return err
+}
+
+// ShowMessage torna visivel a mensagem da cobranca paga na campanha.
+func (r PixRepo) ShowMessage(ctx context.Context, donationID, pixSK string) error {
+	return r.store.UpdateItem(ctx, itemKey(store.DonationPK(donationID), pixSK), "SET visivel = :v, #s = :s", map[string]string{"#s": "status"}, map[string]types.AttributeValue{
+		":v": dynamo.B(true),
+		":s": dynamo.S("CONCLUIDA"),
+	})
+}
+
+// ListOpenPage devolve uma pagina das cobrancas em acompanhamento pelo GSI5,
+// das mais antigas para as mais novas.
+func (r PixRepo) ListOpenPage(ctx context.Context, cursor string, limit int) ([]PixStatus, string, error) {
+	items, next, err := queryPage(ctx, r.store, &dynamodb.QueryInput{
+		IndexName:              aws.String("GSI5"),
+		KeyConditionExpression: aws.String("GSI5PK = :pk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": dynamo.S(store.PixOpenPK),
+		},
+	}, "GSI5#"+store.PixOpenPK, cursor, limit)
+	if err != nil {
+		return nil, "", err
+	}
+	open := []PixStatus{}
+	err = attributevalue.UnmarshalListOfMaps(items, &open)
+	return open, next, err
+}
+
+// ScanLegacyOpen percorre os TX# com buscar ligado que ainda nao tem as chaves
+// do GSI5 (cobrancas criadas antes do indice).
+func (r PixRepo) ScanLegacyOpen(ctx context.Context, fn func(txid string, st PixStatus) error) error {
+	input := &dynamodb.ScanInput{
+		TableName:        aws.String(r.store.TableName()),
+		FilterExpression: aws.String("SK = :sk AND begins_with(PK, :pk) AND buscar = :t AND attribute_not_exists(GSI5PK)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":sk": dynamo.S(skStatus),
+			":pk": dynamo.S(store.PrefixTx),
+			":t":  dynamo.B(true),
+		},
+	}
+	for {
+		out, err := r.store.Scan(ctx, input)
+		if err != nil {
+			return err
+		}
+		for _, item := range out.Items {
+			var st PixStatus
+			if err := attributevalue.UnmarshalMap(item, &st); err != nil {
+				return err
+			}
+			pk, _ := item["PK"].(*types.AttributeValueMemberS)
+			if pk == nil {
+				continue
+			}
+			if err := fn(strings.TrimPrefix(pk.Value, store.PrefixTx), st); err != nil {
+				return err
+			}
+		}
+		if len(out.LastEvaluatedKey) == 0 {
+			return nil
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
+
+// IndexOpen grava as chaves do GSI5 em uma cobranca ainda em acompanhamento,
+// como CreateCharge faria. ErrConflict quando ela ja saiu do acompanhamento.
+func (r PixRepo) IndexOpen(ctx context.Context, txid string, st PixStatus) error {
+	err := r.store.TransactWrite(ctx, []types.TransactWriteItem{{Update: &types.Update{
+		TableName:           aws.String(r.store.TableName()),
+		Key:                 itemKey(store.TxPK(txid), skStatus),
+		UpdateExpression:    aws.String("SET GSI5PK = :pk, GSI5SK = :sk"),
+		ConditionExpression: aws.String("buscar = :t AND (attribute_not_exists(finalizado) OR finalizado = :f)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": dynamo.S(store.PixOpenPK),
+			":sk": dynamo.S(st.DataCriacao + "#" + txid),
+			":t":  dynamo.B(true),
+			":f":  dynamo.B(false),
+		},
+	}}})
+	if dynamo.IsConditionFailed(err) {
+		return ErrConflict
+	}
+	return err
+}
+
+// SaveReconcileRun grava o relatorio de uma execucao da conciliacao.
+func (r PixRepo) SaveReconcileRun(ctx context.Context, run PixReconcileRun) error {
+	item, err := marshalItem(run, map[string]string{
+		"PK": store.PrefixReconcile + "PIX",
+		"SK": run.DataInicio + "#" + run.ID,
+	})
+	if err != nil {
+		return err
+	}
+	return r.store.PutItem(ctx, item)
+}
@@ -0,0 +1,122 @@
+package repo
+
+import (
+	"BACK_SORTE_GO/common/store"
+	"BACK_SORTE_GO/common/store/dynamo"
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func postSK(id string) string {
+	return store.PrefixPost + id
+}
+
+// NewPostID gera o id da novidade ordenado pelo tempo (nanossegundos em base
+// 36), para a SK POST#{id} listar por data e localizar a novidade pela chave.
+func NewPostID(at time.Time) string {
+	return strconv.FormatInt(at.UnixNano(), 36)
+}
+
+// CreatePost grava a novidade na campanha.
+func (r DonationRepo) CreatePost(ctx context.Context, p DonationPost) error {
+	item, err := marshalItem(p, map[string]string{"PK": store.DonationPK(p.IDDoacao), "SK": p.SK()})
+	if err != nil {
+		return err
+	}
+	return Transact(ctx, r.store, putItems(r.store, item))
+}
+
+// ListPostsPage devolve uma pagina das novidades, da mais recente para a mais
+// antiga, e o cursor da pagina seguinte.
+func (r DonationRepo) ListPostsPage(ctx context.Context, donationID, cursor string, limit int) ([]DonationPost, string, error) {
+	pk := store.DonationPK(donationID)
+	items, next, err := queryPage(ctx, r.store, &dynamodb.QueryInput{
+		KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :sk)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": dynamo.S(pk),
+			":sk": dynamo.S(store.PrefixPost),
+		},
+		ScanIndexForward: aws.Bool(false),
+	}, pk+"#"+store.PrefixPost, cursor, limit)
+	if err != nil {
+		return nil, "", err
+	}
+	posts := []DonationPost{}
+	err = attributevalue.UnmarshalListOfMaps(items, &posts)
+	return posts, next, err
+}
+
+// GetPost le a novidade pela chave (POST#{id}).
+func (r DonationRepo) GetPost(ctx context.Context, donationID, postID string) (DonationPost, error) {
+	var p DonationPost
+	if postID == "" {
+		return p, ErrNotFound
+	}
+	err := getItem(ctx, r.store, store.DonationPK(donationID), postSK(postID), &p)
+	return p, err
+}
+
+// UpdatePost grava o texto e a imagem da novidade ja lida por GetPost.
+func (r DonationRepo) UpdatePost(ctx context.Context, p DonationPost) error {
+	err := r.store.TransactWrite(ctx, []types.TransactWriteItem{{Update: &types.Update{
+		TableName:           aws.String(r.store.TableName()),
+		Key:                 itemKey(store.DonationPK(p.IDDoacao), p.SK()),
+		UpdateExpression:    aws.String("SET texto = :t, img_caminho = :img, date_update = :d"),
+		ConditionExpression: aws.String("attribute_exists(PK)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":t":   dynamo.S(p.Texto),
+			":img": dynamo.S(p.ImgCaminho),
+			":d":   dynamo.S(p.DateUpdate),
+		},
+	}}})
+	if dynamo.IsConditionFailed(err) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// DeletePost apaga a novidade.
+func (r DonationRepo) DeletePost(ctx context.Context, p DonationPost) error {
+	return r.store.TransactWrite(ctx, []types.TransactWriteItem{{Delete: &types.Delete{
+		TableName: aws.String(r.store.TableName()),
+		Key:       itemKey(store.DonationPK(p.IDDoacao), p.SK()),
+	}}})
+}
+
+// SubscriberItems monta a inscricao do doador nas novidades da campanha; o put
+// sem condicao deixa a inscricao repetida idempotente.
+func (r DonationRepo) SubscriberItems(s DonationSubscriber) ([]types.TransactWriteItem, error) {
+	s.Email = strings.ToLower(strings.TrimSpace(s.Email))
+	item, err := marshalItem(s, map[string]string{
+		"PK": store.DonationPK(s.IDDoacao),
+		"SK": store.PrefixSubscriber + s.Email,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return putItems(r.store, item), nil
+}
+
+// ListSubscribers devolve os doadores inscritos nas novidades da campanha.
+func (r DonationRepo) ListSubscribers(ctx context.Context, donationID string) ([]DonationSubscriber, error) {
+	items, err := queryAll(ctx, r.store, &dynamodb.QueryInput{
+		KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :sk)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": dynamo.S(store.DonationPK(donationID)),
+			":sk": dynamo.S(store.PrefixSubscriber),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	subs := []DonationSubscriber{}
+	err = attributevalue.UnmarshalListOfMaps(items, &subs)
+	return subs, err
+}
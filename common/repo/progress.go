@@ -0,0 +1,263 @@
+package repo
+
+import (
+	"BACK_SORTE_GO/common/money"
+	"BACK_SORTE_GO/common/store"
+	"BACK_SORTE_GO/common/store/dynamo"
+	"BACK_SORTE_GO/common/text"
+	"context"
+	"errors"
+	"math"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const skProgress = "AGG"
+
+// DonorCPF e a chave do marcador de doador identificado pelo CPF (so digitos).
+func DonorCPF(cpf string) string {
+	digits := text.Digits(cpf)
+	if digits == "" {
+		return ""
+	}
+	return store.PrefixCPF + digits
+}
+
+// DonorEmail e a chave do marcador de doador identificado pelo email (Stripe).
+func DonorEmail(email string) string {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return ""
+	}
+	return "EMAIL#" + email
+}
+
+// Percentual e quanto da meta ja foi arrecadado, com uma casa decimal.
+func (p DonationProgress) Percentual() float64 {
+	if p.Meta.Centavos <= 0 {
+		return 0
+	}
+	return math.Round(float64(p.TotalArrecadado.Centavos)/float64(p.Meta.Centavos)*1000) / 10
+}
+
+// GetProgress devolve o agregado da campanha sem gravar nada. Campanhas
+// anteriores ao contador, enquanto a migracao nao grava o AGG, tem o agregado
+// calculado a partir dos Pix pagos a cada leitura.
+func (r DonationRepo) GetProgress(ctx context.Context, id string) (DonationProgress, error) {
+	var p DonationProgress
+	err := getItem(ctx, r.store, store.DonationPK(id), skProgress, &p)
+	if errors.Is(err, ErrNotFound) {
+		p, _, err = r.computeProgress(ctx, id)
+	}
+	return p, err
+}
+
+// EnsureProgress grava o AGG de uma campanha anterior ao contador a partir dos
+// PIX# pagos, com os marcadores de doador; created diz se foi gravado agora.
+// Usado pela migracao e pelas escritas que somam no AGG, para o ADD nao criar
+// um item so com a parcela.
+func (r DonationRepo) EnsureProgress(ctx context.Context, id string) (created bool, err error) {
+	pk := store.DonationPK(id)
+	existing, err := r.store.GetItem(ctx, pk, skProgress)
+	if err != nil || len(existing) > 0 {
+		return false, err
+	}
+	p, firstByDonor, err := r.computeProgress(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	for key, first := range firstByDonor {
+		marker, err := marshalItem(DonorMarker{IDDoacao: id, DataPrimeira: first}, map[string]string{"PK": pk, "SK": key})
+		if err != nil {
+			return false, err
+		}
+		if err := r.store.PutItem(ctx, marker); err != nil {
+			return false, err
+		}
+	}
+
+	item, err := marshalItem(p, map[string]string{"PK": pk, "SK": skProgress})
+	if err != nil {
+		return false, err
+	}
+	err = r.store.TransactWrite(ctx, []types.TransactWriteItem{{Put: &types.Put{
+		TableName:           aws.String(r.store.TableName()),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(PK)"),
+	}}})
+	if dynamo.IsConditionFailed(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// computeProgress recalcula o agregado pelos PIX# pagos (visiveis ou com
+// devolucao), descontando o valor_devolvido, e devolve a data da primeira
+// doacao de cada doador identificado. Como nos contadores, a cobranca toda
+// devolvida sai das doacoes mas o doador continua contado. Sem a data do
+// pagamento no PIX#, a ultima doacao fica com a data da cobranca.
+func (r DonationRepo) computeProgress(ctx context.Context, id string) (DonationProgress, map[string]string, error) {
+	profile, err := r.Get(ctx, id)
+	if err != nil {
+		return DonationProgress{}, nil, err
+	}
+	charges, err := NewPixRepo(r.store).ListByDonation(ctx, id, false)
+	if err != nil {
+		return DonationProgress{}, nil, err
+	}
+
+	p := DonationProgress{IDDoacao: id, Meta: profile.Valor}
+	firstByDonor := map[string]string{}
+	for _, c := range charges {
+		if !c.Visivel && c.ValorDevolvido.IsZero() {
+			continue
+		}
+		liquido, err := c.Valor.Sub(c.ValorDevolvido)
+		if err != nil {
+			return DonationProgress{}, nil, err
+		}
+		if p.TotalArrecadado, err = p.TotalArrecadado.Add(liquido); err != nil {
+			return DonationProgress{}, nil, err
+		}
+		if liquido.Centavos > 0 {
+			p.TotalDoacoes++
+		}
+		if c.DataCriacao > p.UltimaDoacao {
+			p.UltimaDoacao = c.DataCriacao
+		}
+		key := DonorCPF(c.CPF)
+		if key == "" {
+			p.TotalDoadores++
+			continue
+		}
+		if _, ok := firstByDonor[key]; !ok {
+			firstByDonor[key] = c.DataCriacao
+		}
+	}
+	p.TotalDoadores += len(firstByDonor)
+	return p, firstByDonor, nil
+}
+
+// progressItem e o AGG inicial gravado junto com a campanha.
+func progressItem(id string, meta money.Money) (map[string]types.AttributeValue, error) {
+	return marshalItem(DonationProgress{IDDoacao: id, Meta: meta}, map[string]string{
+		"PK": store.DonationPK(id),
+		"SK": skProgress,
+	})
+}
+
+// ProgressWrites monta as escritas que contabilizam um pagamento confirmado: o
+// AGG (total, doacoes, doadores e data da ultima), o arrecadado e os rankings
+// do PROFILE (GSI6SK/GSI7SK, sem efeito fora do feed) e, na primeira doacao do
+// doador, o marcador dele com put condicional. Se duas primeiras doacoes do
+// mesmo doador correrem juntas, uma transacao falha na condicao e o chamador
+// deve montar as escritas de novo.
+func (r DonationRepo) ProgressWrites(ctx context.Context, id, donorKey string, valor money.Money, at string) ([]types.TransactWriteItem, error) {
+	if _, err := r.EnsureProgress(ctx, id); err != nil {
+		return nil, err
+	}
+	pk := store.DonationPK(id)
+	table := aws.String(r.store.TableName())
+
+	var items []types.TransactWriteItem
+	newDonor := "1"
+	if donorKey != "" {
+		marker, err := r.store.GetItem(ctx, pk, donorKey)
+		if err != nil {
+			return nil, err
+		}
+		if len(marker) > 0 {
+			newDonor = "0"
+		} else {
+			item, err := marshalItem(DonorMarker{IDDoacao: id, DataPrimeira: at}, map[string]string{"PK": pk, "SK": donorKey})
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, types.TransactWriteItem{Put: &types.Put{
+				TableName:           table,
+				Item:                item,
+				ConditionExpression: aws.String("attribute_not_exists(PK)"),
+			}})
+		}
+	}
+
+	amount := dynamo.N(valor.String())
+	return append(items,
+		types.TransactWriteItem{Update: &types.Update{
+			TableName:        table,
+			Key:              itemKey(pk, skProgress),
+			UpdateExpression: aws.String("SET ultima_doacao = :at ADD total_arrecadado :v, total_doacoes :one, total_doadores :nd"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":at":  dynamo.S(at),
+				":v":   amount,
+				":one": dynamo.N("1"),
+				":nd":  dynamo.N(newDonor),
+			},
+		}},
+		types.TransactWriteItem{Update: &types.Update{
+			TableName:           table,
+			Key:                 itemKey(pk, skProfile),
+			UpdateExpression:    aws.String("ADD arrecadado :v, GSI6SK :v, GSI7SK :nv"),
+			ConditionExpression: aws.String("attribute_exists(PK)"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":v":  amount,
+				":nv": dynamo.N(valor.Neg().String()),
+			},
+		}},
+	), nil
+}
+
+// ProgressReversal monta as escritas que desfazem no AGG e no PROFILE (com os
+// rankings) um valor devolvido; full tira tambem a doacao da contagem. O
+// doador continua contado.
+func (r DonationRepo) ProgressReversal(ctx context.Context, id string, valor money.Money, full bool) ([]types.TransactWriteItem, error) {
+	doacoes := "0"
+	if full {
+		doacoes = "-1"
+	}
+	return r.progressDelta(ctx, id, valor.Neg(), doacoes)
+}
+
+// ProgressRestore refaz o que ProgressReversal desfez quando a devolucao nao
+// e realizada; wasFull devolve a doacao a contagem.
+func (r DonationRepo) ProgressRestore(ctx context.Context, id string, valor money.Money, wasFull bool) ([]types.TransactWriteItem, error) {
+	doacoes := "0"
+	if wasFull {
+		doacoes = "1"
+	}
+	return r.progressDelta(ctx, id, valor, doacoes)
+}
+
+// progressDelta soma delta ao total e ao arrecadado (com os rankings) e
+// doacoes a contagem, sem mexer nos doadores.
+func (r DonationRepo) progressDelta(ctx context.Context, id string, delta money.Money, doacoes string) ([]types.TransactWriteItem, error) {
+	if _, err := r.EnsureProgress(ctx, id); err != nil {
+		return nil, err
+	}
+	pk := store.DonationPK(id)
+	table := aws.String(r.store.TableName())
+	amount := dynamo.N(delta.String())
+	return []types.TransactWriteItem{
+		{Update: &types.Update{
+			TableName:        table,
+			Key:              itemKey(pk, skProgress),
+			UpdateExpression: aws.String("ADD total_arrecadado :v, total_doacoes :nd"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":v":  amount,
+				":nd": dynamo.N(doacoes),
+			},
+		}},
+		{Update: &types.Update{
+			TableName:           table,
+			Key:                 itemKey(pk, skProfile),
+			UpdateExpression:    aws.String("ADD arrecadado :v, GSI6SK :v, GSI7SK :nv"),
+			ConditionExpression: aws.String("attribute_exists(PK)"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":v":  amount,
+				":nv": dynamo.N(delta.Neg().String()),
+			},
+		}},
+	}, nil
+}
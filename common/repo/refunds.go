@@ -0,0 +1,278 @@
+package repo
+
+import (
+	"BACK_SORTE_GO/common/money"
+	"BACK_SORTE_GO/common/store"
+	"BACK_SORTE_GO/common/store/dynamo"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func refundSK(txid, id string) string {
+	return store.PrefixRefund + txid + "#" + id
+}
+
+// GetCharge le a cobranca PIX# da campanha.
+func (r PixRepo) GetCharge(ctx context.Context, donationID, pixSK string) (Pix, error) {
+	var p Pix
+	err := getItem(ctx, r.store, store.DonationPK(donationID), pixSK, &p)
+	return p, err
+}
+
+// GetRefund le uma devolucao pelo id usado na EFI.
+func (r PixRepo) GetRefund(ctx context.Context, donationID, txid, id string) (PixRefund, error) {
+	var rf PixRefund
+	err := getItem(ctx, r.store, store.DonationPK(donationID), refundSK(txid, id), &rf)
+	return rf, err
+}
+
+// ledgerRef e a referencia dos lancamentos da devolucao no livro razao.
+func (rf PixRefund) ledgerRef() string {
+	return rf.TxID + "-" + rf.ID
+}
+
+// ReserveRefund grava a devolucao PENDENTE antes de pedir a EFI e, na mesma
+// transacao, esconde a mensagem do PIX# (DEVOLVIDA ou DEVOLVIDA_PARCIAL), lanca
+// o estorno no livro razao (a parte liquida sai da campanha, pela taxa gravada
+// na cobranca, e o resto sai da plataforma) e desfaz o valor no agregado. O
+// PIX# e condicionado ao valor_devolvido lido em charge: duas devolucoes
+// concorrentes nao passam do valor pago e uma delas recebe ErrConflict, assim
+// como um id de devolucao repetido. O estorno, como todo debito do livro
+// razao, e condicionado ao valor_disponivel: campanha sem saldo para a parte
+// liquida (ja resgatada) recebe ErrInsufficientBalance.
+func (r PixRepo) ReserveRefund(ctx context.Context, rf PixRefund, charge Pix) error {
+	item, err := marshalItem(rf, map[string]string{"PK": store.DonationPK(rf.IDDoacao), "SK": rf.SK()})
+	if err != nil {
+		return err
+	}
+	devolvido, err := charge.ValorDevolvido.Add(rf.Valor)
+	if err != nil {
+		return err
+	}
+	cmp, err := devolvido.Cmp(charge.Valor)
+	if err != nil {
+		return err
+	}
+	if cmp > 0 {
+		return fmt.Errorf("devolucao de %s passa do valor pago %s", devolvido, charge.Valor)
+	}
+	full := cmp == 0
+	status := "DEVOLVIDA_PARCIAL"
+	if full {
+		status = "DEVOLVIDA"
+	}
+
+	pk := store.DonationPK(rf.IDDoacao)
+	table := aws.String(r.store.TableName())
+	pixUpdate := &types.Update{
+		TableName:           table,
+		Key:                 itemKey(pk, rf.PixSK),
+		UpdateExpression:    aws.String("SET valor_devolvido = :dev, visivel = :f, #s = :s"),
+		ConditionExpression: aws.String("attribute_exists(PK) AND (attribute_not_exists(valor_devolvido) OR valor_devolvido = :antes)"),
+		ExpressionAttributeNames: map[string]string{
+			"#s": "status",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":dev":   dynamo.N(devolvido.String()),
+			":antes": dynamo.N(charge.ValorDevolvido.String()),
+			":f":     dynamo.B(false),
+			":s":     dynamo.S(status),
+		},
+	}
+
+	liquido, err := rf.net(charge)
+	if err != nil {
+		return err
+	}
+	entries, err := RefundEntries(rf.ledgerRef(), rf.Valor, liquido)
+	if err != nil {
+		return err
+	}
+	ledgerRepo := NewLedgerRepo(r.store)
+	ledger, balances, debito, err := ledgerRepo.writes(rf.IDDoacao, entries)
+	if err != nil {
+		return err
+	}
+	items := append([]types.TransactWriteItem{
+		{Put: &types.Put{
+			TableName:           table,
+			Item:                item,
+			ConditionExpression: aws.String("attribute_not_exists(PK)"),
+		}},
+		{Update: pixUpdate},
+	}, ledger...)
+	items = append(items, types.TransactWriteItem{Update: balances})
+	reversal, err := NewDonationRepo(r.store).ProgressReversal(ctx, rf.IDDoacao, rf.Valor, full)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	return ledgerRepo.transact(ctx, rf.IDDoacao, append(items, reversal...), debito)
+}
+
+// ReleaseRefund desfaz a reserva de uma devolucao que a EFI nao realizou. Na
+// mesma transacao grava o status final no REFUND# (condicionado a aberta),
+// tira o valor do valor_devolvido do PIX# (a mensagem volta quando nada mais
+// esta devolvido), lanca no livro razao os lancamentos inversos do estorno e
+// refaz o valor no agregado. Repetir com a devolucao ja liberada nao faz nada.
+func (r PixRepo) ReleaseRefund(ctx context.Context, rf PixRefund) error {
+	for attempt := 0; attempt < 3; attempt++ {
+		charge, err := r.GetCharge(ctx, rf.IDDoacao, rf.PixSK)
+		if err != nil {
+			return err
+		}
+		items, err := r.releaseWrites(ctx, rf, charge)
+		if err != nil {
+			return err
+		}
+		err = r.store.TransactWrite(ctx, items)
+		if !dynamo.IsConditionFailed(err) {
+			return err
+		}
+		current, err := r.GetRefund(ctx, rf.IDDoacao, rf.TxID, rf.ID)
+		if err != nil {
+			return err
+		}
+		if !current.Open() {
+			return nil
+		}
+	}
+	return ErrConflict
+}
+
+// releaseWrites monta as escritas de ReleaseRefund a partir do PIX# lido.
+func (r PixRepo) releaseWrites(ctx context.Context, rf PixRefund, charge Pix) ([]types.TransactWriteItem, error) {
+	devolvido, err := charge.ValorDevolvido.Sub(rf.Valor)
+	if err != nil {
+		return nil, err
+	}
+	if devolvido.IsNegative() {
+		return nil, fmt.Errorf("devolucao de %s maior que o devolvido %s", rf.Valor, charge.ValorDevolvido)
+	}
+	cmp, err := charge.ValorDevolvido.Cmp(charge.Valor)
+	if err != nil {
+		return nil, err
+	}
+	wasFull := cmp == 0
+	status, visivel := "DEVOLVIDA_PARCIAL", false
+	if devolvido.IsZero() {
+		status, visivel = "CONCLUIDA", true
+	}
+
+	pk := store.DonationPK(rf.IDDoacao)
+	table := aws.String(r.store.TableName())
+	items := []types.TransactWriteItem{
+		{Update: r.openRefundUpdate(rf)},
+		{Update: &types.Update{
+			TableName:           table,
+			Key:                 itemKey(pk, rf.PixSK),
+			UpdateExpression:    aws.String("SET valor_devolvido = :dev, visivel = :v, #s = :s"),
+			ConditionExpression: aws.String("valor_devolvido = :antes"),
+			ExpressionAttributeNames: map[string]string{
+				"#s": "status",
+			},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":dev":   dynamo.N(devolvido.String()),
+				":antes": dynamo.N(charge.ValorDevolvido.String()),
+				":v":     dynamo.B(visivel),
+				":s":     dynamo.S(status),
+			},
+		}},
+	}
+
+	liquido, err := rf.net(charge)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := RefundReleaseEntries(rf.ledgerRef()+"-LIBERADA", rf.Valor, liquido)
+	if err != nil {
+		return nil, err
+	}
+	ledger, err := NewLedgerRepo(r.store).Writes(rf.IDDoacao, entries...)
+	if err != nil {
+		return nil, err
+	}
+	restore, err := NewDonationRepo(r.store).ProgressRestore(ctx, rf.IDDoacao, rf.Valor, wasFull)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	items = append(items, ledger...)
+	return append(items, restore...), nil
+}
+
+// Fee e a taxa gravada na confirmacao da cobranca; as confirmadas antes da
+// politica de taxas pagaram DefaultFeePolicy.
+func (p Pix) Fee() (AppliedFee, error) {
+	if p.Taxa != nil {
+		return *p.Taxa, nil
+	}
+	return DefaultFeePolicy.Apply(FeeMethodPix, "", p.Valor, time.Time{})
+}
+
+// net e a parte liquida da devolucao: a que saiu da campanha, pela taxa da
+// cobranca.
+func (rf PixRefund) net(charge Pix) (money.Money, error) {
+	fee, err := charge.Fee()
+	if err != nil {
+		return money.Money{}, err
+	}
+	return fee.Net(rf.Valor, charge.Valor), nil
+}
+
+// openRefundUpdate grava o status e o rtrId da EFI no REFUND#, condicionado a
+// devolucao ainda aberta (PENDENTE ou EM_PROCESSAMENTO).
+func (r PixRepo) openRefundUpdate(rf PixRefund) *types.Update {
+	return &types.Update{
+		TableName:           aws.String(r.store.TableName()),
+		Key:                 itemKey(store.DonationPK(rf.IDDoacao), rf.SK()),
+		UpdateExpression:    aws.String("SET #s = :s, rtr_id = :r, data_update = :d"),
+		ConditionExpression: aws.String("#s IN (:pendente, :processamento)"),
+		ExpressionAttributeNames: map[string]string{
+			"#s": "status",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":s":             dynamo.S(rf.Status),
+			":r":             dynamo.S(rf.RtrID),
+			":d":             dynamo.S(rf.DataUpdate),
+			":pendente":      dynamo.S("PENDENTE"),
+			":processamento": dynamo.S("EM_PROCESSAMENTO"),
+		},
+	}
+}
+
+// MarkRefundProcessing grava o rtrId da devolucao que a EFI aceitou mas ainda
+// processa (EM_PROCESSAMENTO). A reserva continua ate a EFI confirmar.
+func (r PixRepo) MarkRefundProcessing(ctx context.Context, rf PixRefund) error {
+	rf.Status = "EM_PROCESSAMENTO"
+	return r.updateOpenRefund(ctx, rf)
+}
+
+// FinishRefund conclui a devolucao que a EFI confirmou (DEVOLVIDO); a reserva
+// feita em ReserveRefund passa a ser definitiva. Repetir a confirmacao nao faz
+// nada.
+func (r PixRepo) FinishRefund(ctx context.Context, rf PixRefund) error {
+	rf.Status = "DEVOLVIDO"
+	return r.updateOpenRefund(ctx, rf)
+}
+
+// updateOpenRefund aplica openRefundUpdate. Devolucao ja finalizada com o
+// mesmo status, ou aviso de processamento que chegou depois do status final,
+// nao e erro; finalizada com outro status devolve ErrConflict.
+func (r PixRepo) updateOpenRefund(ctx context.Context, rf PixRefund) error {
+	err := r.store.TransactWrite(ctx, []types.TransactWriteItem{{Update: r.openRefundUpdate(rf)}})
+	if !dynamo.IsConditionFailed(err) {
+		return err
+	}
+	current, err := r.GetRefund(ctx, rf.IDDoacao, rf.TxID, rf.ID)
+	if err != nil {
+		return err
+	}
+	if current.Status == rf.Status || rf.Status == "EM_PROCESSAMENTO" {
+		return nil
+	}
+	return fmt.Errorf("%w: devolucao %s ja finalizada como %s", ErrConflict, rf.ID, current.Status)
+}
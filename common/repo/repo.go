@@ -0,0 +1,78 @@
+// Package repo concentra o acesso as entidades da tabela unica: monta as
+// chaves, preenche os GSIs e converte os itens para as structs de entities.go.
+package repo
+
+import (
+	"BACK_SORTE_GO/common/store/dynamo"
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ErrNotFound indica que o item pedido nao existe na tabela.
+var ErrNotFound = errors.New("item nao encontrado")
+
+// ErrConflict indica que o item mudou desde a versao lida pelo cliente.
+var ErrConflict = errors.New("item alterado por outra requisicao")
+
+// ErrLinkTaken indica que o nome publico ja pertence a outra campanha.
+var ErrLinkTaken = errors.New("nome_link ja em uso")
+
+// Transact grava em uma unica transacao os itens montados por um ou mais
+// repositorios (ex.: usuario e doacao criados juntos).
+func Transact(ctx context.Context, storeDDB dynamo.Store, groups ...[]types.TransactWriteItem) error {
+	var items []types.TransactWriteItem
+	for _, g := range groups {
+		items = append(items, g...)
+	}
+	return storeDDB.TransactWrite(ctx, items)
+}
+
+// itemKey e a chave primaria de um item.
+func itemKey(pk, sk string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"PK": dynamo.S(pk),
+		"SK": dynamo.S(sk),
+	}
+}
+
+// marshalItem converte a entidade e acrescenta chave e atributos de indice.
+func marshalItem(v interface{}, keys map[string]string) (map[string]types.AttributeValue, error) {
+	item, err := attributevalue.MarshalMap(v)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range keys {
+		item[name] = dynamo.S(value)
+	}
+	return item, nil
+}
+
+// putItems monta as escritas transacionais para os itens ja convertidos.
+func putItems(storeDDB dynamo.Store, items ...map[string]types.AttributeValue) []types.TransactWriteItem {
+	out := make([]types.TransactWriteItem, 0, len(items))
+	for _, item := range items {
+		out = append(out, types.TransactWriteItem{Put: &types.Put{TableName: aws.String(storeDDB.TableName()), Item: item}})
+	}
+	return out
+}
+
+// getItem le o item e o converte em out; devolve ErrNotFound se nao existe.
+func getItem(ctx context.Context, storeDDB dynamo.Store, pk, sk string, out interface{}) error {
+	item, err := storeDDB.GetItem(ctx, pk, sk)
+	if err != nil {
+		return err
+	}
+	if len(item) == 0 {
+		return ErrNotFound
+	}
+	return attributevalue.UnmarshalMap(item, out)
+}
+
+func emailKey(email string) string {
+	return "EMAIL#" + strings.ToLower(email)
+}
@@ -0,0 +1,451 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"BACK_SORTE_GO/common/money"
+	"BACK_SORTE_GO/common/store"
+	"BACK_SORTE_GO/common/store/dynamo"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestUserRepoCreateAndFindByEmail(t *testing.T) {
+	ctx := context.Background()
+	users := NewUserRepo(dynamo.NewMemory("core"))
+
+	err := users.Create(ctx, User{ID: "u-1", Name: "Maria", Email: "Maria@Example.com", PasswordHash: "hash", Active: true},
+		AccountLevel{ID: "l-1", IDUser: "u-1", Nivel: "BASICO"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := users.FindByEmail(ctx, "maria@example.com")
+	if err != nil || u.ID != "u-1" || u.PasswordHash != "hash" {
+		t.Fatalf("FindByEmail = %+v, %v", u, err)
+	}
+	if _, err := users.FindByEmail(ctx, "outra@example.com"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("email inexistente: %v", err)
+	}
+	if l, err := users.GetAccountLevel(ctx, "u-1"); err != nil || l.Nivel != "BASICO" {
+		t.Fatalf("GetAccountLevel = %+v, %v", l, err)
+	}
+}
+
+func TestDonationAndPixRepos(t *testing.T) {
+	ctx := context.Background()
+	storeDDB := dynamo.NewMemory("core")
+	donations := NewDonationRepo(storeDDB)
+	charges := NewPixRepo(storeDDB)
+
+	err := donations.Create(ctx, NewDonation{
+		Profile: Donation{ID: "d-1", IDUser: "u-1", Name: "Campanha", Valor: money.Cents(10000), DateCreate: "2024-01-01T00:00:00Z", NomeLink: "@campanha"},
+		Details: DonationDetails{ID: "x", IDDoacao: "d-1", Texto: "texto"},
+		Link:    DonationLink{ID: "y", IDDoacao: "d-1", NomeLink: "@campanha"},
+		Payment: DonationPayment{ID: "z", IDDoacao: "d-1", Status: "START"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := donations.ListByUser(ctx, "u-1")
+	if err != nil || len(list) != 1 || list[0].Valor != money.Cents(10000) {
+		t.Fatalf("ListByUser = %+v, %v", list, err)
+	}
+	if link, err := donations.FindLink(ctx, "@campanha"); err != nil || link.IDDoacao != "d-1" {
+		t.Fatalf("FindLink = %+v, %v", link, err)
+	}
+
+	charge := Pix{ID: "p-1", IDDoacao: "d-1", Valor: money.Cents(5000), Nome: "Ana", Novidades: true, Email: "ana@exemplo.com", DataCriacao: "2024-01-02T00:00:00Z", TxID: "tx-1", Status: "ATIVA"}
+	if err := charges.CreateCharge(ctx, charge, PixStatus{IDDoacao: "d-1", Status: "ATIVA", Buscar: true, IDPix: "tx-1", Valor: money.Cents(5000)}); err != nil {
+		t.Fatal(err)
+	}
+	if open, _, err := charges.ListOpenPage(ctx, "", 10); err != nil || len(open) != 1 || open[0].IDPix != "tx-1" {
+		t.Fatalf("ListOpenPage = %+v, %v", open, err)
+	}
+
+	st, applied, err := charges.Confirm(ctx, "tx-1")
+	if err != nil || !applied || !st.Finalizado || st.PixSK != charge.SK() {
+		t.Fatalf("Confirm = %+v, %v, %v", st, applied, err)
+	}
+	if _, applied, err := charges.Confirm(ctx, "tx-1"); err != nil || applied {
+		t.Fatalf("Confirm repetido = %v, %v", applied, err)
+	}
+	// O doador que pediu as novidades e inscrito na confirmacao.
+	if subs, err := donations.ListSubscribers(ctx, "d-1"); err != nil || len(subs) != 1 || subs[0].Email != "ana@exemplo.com" {
+		t.Fatalf("ListSubscribers = %+v, %v", subs, err)
+	}
+
+	if open, _, err := charges.ListOpenPage(ctx, "", 10); err != nil || len(open) != 0 {
+		t.Fatalf("confirmada continua em acompanhamento: %+v, %v", open, err)
+	}
+	if err := charges.MarkExpired(ctx, "tx-1"); err != nil {
+		t.Fatal(err)
+	}
+	if st, err := charges.GetStatus(ctx, "tx-1"); err != nil || st.Status != "CONCLUIDA" {
+		t.Fatalf("MarkExpired alterou cobranca paga: %+v, %v", st, err)
+	}
+
+	_, payments, err := donations.DetailsAndPayments(ctx, []string{"d-1"})
+	if err != nil || payments["d-1"].ValorDisponivel != money.Cents(4500) {
+		t.Fatalf("payment = %+v, %v", payments["d-1"], err)
+	}
+	msgs, err := charges.ListByDonation(ctx, "d-1", true)
+	if err != nil || len(msgs) != 1 || !msgs[0].Visivel {
+		t.Fatalf("ListByDonation = %+v, %v", msgs, err)
+	}
+	if fee := msgs[0].Taxa; fee == nil || fee.Versao != 0 || fee.Valor != money.Cents(500) || fee.Liquido != money.Cents(4500) {
+		t.Fatalf("taxa gravada = %+v", fee)
+	}
+	if item, _ := storeDDB.GetItem(ctx, store.TxPK("tx-1"), "STATUS"); len(item) == 0 {
+		t.Fatal("TX STATUS nao gravado")
+	}
+}
+
+func TestFeePolicy(t *testing.T) {
+	ctx := context.Background()
+	storeDDB := dynamo.NewMemory("core")
+	fees := NewFeeRepo(storeDDB)
+	at := time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC)
+
+	if p, err := fees.Current(ctx); err != nil || p.Versao != 0 {
+		t.Fatalf("Current sem versoes = %+v, %v", p, err)
+	}
+	if _, err := fees.Publish(ctx, FeePolicy{Regras: []FeeRule{{Metodo: "BOLETO", Percentual: 1}}}); err == nil {
+		t.Fatal("metodo invalido aceito")
+	}
+	if _, err := fees.Publish(ctx, FeePolicy{Regras: []FeeRule{{Metodo: FeeMethodPix}}, Promocoes: []FeePromo{{Nome: "x", Inicio: "2024-06-02T00:00:00Z", Fim: "2024-06-01T00:00:00Z"}}}); err == nil {
+		t.Fatal("promocao com fim antes do inicio aceita")
+	}
+
+	policy := FeePolicy{
+		Regras: []FeeRule{
+			{Metodo: FeeMethodPix, Percentual: 5, Fixo: money.Cents(50)},
+			{Metodo: FeeMethodPix, Nivel: "PREMIUM", Percentual: 2},
+			{Metodo: FeeMethodCartao, Percentual: 4, Fixo: money.Cents(39)},
+		},
+		Promocoes: []FeePromo{{Nome: "junho-cartao", Metodo: FeeMethodCartao, Inicio: "2024-06-01T00:00:00Z", Fim: "2024-07-01T00:00:00Z"}},
+	}
+	for i := 1; i <= 2; i++ {
+		p, err := fees.Publish(ctx, policy)
+		if err != nil || p.Versao != i {
+			t.Fatalf("Publish = %+v, %v", p, err)
+		}
+	}
+	if list, err := fees.List(ctx); err != nil || len(list) != 2 || list[0].Versao != 2 {
+		t.Fatalf("List = %+v, %v", list, err)
+	}
+
+	if err := NewUserRepo(storeDDB).Create(ctx, User{ID: "u-1", Email: "dono@example.com"}, AccountLevel{ID: "l-1", IDUser: "u-1", Nivel: "PREMIUM"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewDonationRepo(storeDDB).Create(ctx, NewDonation{
+		Profile: Donation{ID: "d-1", IDUser: "u-1", DateCreate: "2024-01-01T00:00:00Z", NomeLink: "@c"},
+		Link:    DonationLink{IDDoacao: "d-1", NomeLink: "@c"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		metodo, donation string
+		valor            money.Money
+		at               time.Time
+		want             AppliedFee
+	}{
+		{FeeMethodPix, "d-2", money.Cents(10000), at, AppliedFee{Versao: 2, Metodo: FeeMethodPix, Percentual: 5, Fixo: money.Cents(50), Valor: money.Cents(550), Liquido: money.Cents(9450)}},
+		{FeeMethodPix, "d-1", money.Cents(10000), at, AppliedFee{Versao: 2, Metodo: FeeMethodPix, Nivel: "PREMIUM", Percentual: 2, Valor: money.Cents(200), Liquido: money.Cents(9800)}},
+		{FeeMethodCartao, "d-1", money.Cents(10000), at, AppliedFee{Versao: 2, Metodo: FeeMethodCartao, Nivel: "PREMIUM", Promocao: "junho-cartao", Liquido: money.Cents(10000)}},
+		{FeeMethodCartao, "d-2", money.Cents(30), at.AddDate(0, 1, 0), AppliedFee{Versao: 2, Metodo: FeeMethodCartao, Percentual: 4, Fixo: money.Cents(39), Valor: money.Cents(30)}},
+	} {
+		got, err := fees.Quote(ctx, tc.metodo, tc.donation, tc.valor, tc.at)
+		if err != nil || got != tc.want {
+			t.Errorf("Quote(%s, %s, %s) = %+v, %v", tc.metodo, tc.donation, tc.valor, got, err)
+		}
+	}
+}
+
+// publishRace grava uma versao da politica antes da transacao de Publish, como
+// um administrador publicando ao mesmo tempo.
+type publishRace struct {
+	dynamo.Store
+	versao int
+}
+
+func (s publishRace) TransactWrite(ctx context.Context, items []types.TransactWriteItem) error {
+	if s.versao > 0 {
+		err := s.Store.PutItem(ctx, map[string]types.AttributeValue{
+			"PK":     dynamo.S(store.FeePolicyPK),
+			"SK":     dynamo.S(feeVersionSK(s.versao)),
+			"versao": dynamo.N(fmt.Sprint(s.versao)),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return s.Store.TransactWrite(ctx, items)
+}
+
+func TestFeePolicyPublishRejects(t *testing.T) {
+	ctx := context.Background()
+	storeDDB := dynamo.NewMemory("core")
+	fees := NewFeeRepo(storeDDB)
+	pix := FeeRule{Metodo: FeeMethodPix, Percentual: 5}
+
+	for name, p := range map[string]FeePolicy{
+		"nivel desconhecido na regra":    {Regras: []FeeRule{pix, {Metodo: FeeMethodPix, Nivel: "PREMIUN", Percentual: 1}}},
+		"nivel em minusculas":            {Regras: []FeeRule{pix, {Metodo: FeeMethodPix, Nivel: "premium", Percentual: 1}}},
+		"nivel desconhecido na promocao": {Regras: []FeeRule{pix}, Promocoes: []FeePromo{{Nome: "x", Nivel: "OURO", Inicio: "2024-06-01T00:00:00Z", Fim: "2024-06-02T00:00:00Z"}}},
+		"regra repetida":                 {Regras: []FeeRule{pix, {Metodo: FeeMethodPix, Percentual: 3}}},
+	} {
+		if _, err := fees.Publish(ctx, p); err == nil {
+			t.Errorf("%s: Publish aceitou", name)
+		}
+	}
+	if list, err := fees.List(ctx); err != nil || len(list) != 0 {
+		t.Fatalf("List apos politicas invalidas = %+v, %v", list, err)
+	}
+
+	// Versao 1 publicada por outro administrador entre a leitura e a escrita.
+	raced := NewFeeRepo(publishRace{Store: storeDDB, versao: 1})
+	if _, err := raced.Publish(ctx, FeePolicy{Regras: []FeeRule{pix}}); !errors.Is(err, ErrConflict) {
+		t.Fatalf("Publish na mesma versao = %v; want ErrConflict", err)
+	}
+	if p, err := fees.Publish(ctx, FeePolicy{Regras: []FeeRule{pix}}); err != nil || p.Versao != 2 {
+		t.Fatalf("Publish apos conflito = %+v, %v", p, err)
+	}
+}
+
+func TestListMessagesPageSkipsHiddenAndSignsCursor(t *testing.T) {
+	ctx := context.Background()
+	charges := NewPixRepo(dynamo.NewMemory("core"))
+	for i := 0; i < 7; i++ {
+		p := Pix{ID: fmt.Sprintf("p-%d", i), IDDoacao: "d-1", DataCriacao: fmt.Sprintf("2024-01-0%dT00:00:00Z", i+1), TxID: fmt.Sprintf("tx-%d", i)}
+		if err := charges.CreateCharge(ctx, p, PixStatus{IDDoacao: "d-1"}); err != nil {
+			t.Fatal(err)
+		}
+		if i%2 == 0 {
+			if err := charges.ShowMessage(ctx, "d-1", p.SK()); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	var got []string
+	cursor := ""
+	for {
+		page, next, err := charges.ListMessagesPage(ctx, "d-1", cursor, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range page {
+			got = append(got, p.ID)
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	if strings.Join(got, ",") != "p-6,p-4,p-2,p-0" {
+		t.Fatalf("mensagens = %v", got)
+	}
+
+	_, next, _ := charges.ListMessagesPage(ctx, "d-1", "", 1)
+	if _, _, err := charges.ListMessagesPage(ctx, "d-2", next, 1); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("cursor de outra campanha: %v", err)
+	}
+	if _, _, err := charges.ListMessagesPage(ctx, "d-1", next+"x", 1); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("cursor adulterado: %v", err)
+	}
+
+	second, _, err := PageByNumber(2, 2, func(cursor string, n int) ([]Pix, string, error) {
+		return charges.ListMessagesPage(ctx, "d-1", cursor, n)
+	})
+	if err != nil || len(second) != 2 || second[0].ID != "p-2" {
+		t.Fatalf("PageByNumber = %+v, %v", second, err)
+	}
+}
+
+func TestProgressCountsDistinctDonors(t *testing.T) {
+	ctx := context.Background()
+	storeDDB := dynamo.NewMemory("core")
+	donations := NewDonationRepo(storeDDB)
+	charges := NewPixRepo(storeDDB)
+	err := donations.Create(ctx, NewDonation{
+		Profile: Donation{ID: "d-1", IDUser: "u-1", Name: "Campanha", Valor: money.Cents(20000), DateCreate: "2024-01-01T00:00:00Z", NomeLink: "@campanha"},
+		Link:    DonationLink{ID: "y", IDDoacao: "d-1", NomeLink: "@campanha"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, cpf := range []string{"123.456.789-09", "12345678909", "98765432100"} {
+		txid := fmt.Sprintf("tx-%d", i)
+		p := Pix{ID: fmt.Sprintf("p-%d", i), IDDoacao: "d-1", Valor: money.Cents(2500), CPF: cpf, DataCriacao: fmt.Sprintf("2024-01-0%dT00:00:00Z", i+2), TxID: txid}
+		if err := charges.CreateCharge(ctx, p, PixStatus{IDDoacao: "d-1", IDPix: txid, Valor: money.Cents(2500)}); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := charges.Confirm(ctx, txid); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p, err := donations.GetProgress(ctx, "d-1")
+	if err != nil || p.TotalArrecadado != money.Cents(7500) || p.TotalDoacoes != 3 || p.TotalDoadores != 2 || p.Percentual() != 37.5 {
+		t.Fatalf("GetProgress = %+v, %v", p, err)
+	}
+	if d, _ := donations.Get(ctx, "d-1"); d.Arrecadado != money.Cents(7500) {
+		t.Fatalf("arrecadado = %v", d.Arrecadado)
+	}
+
+	// Campanha sem AGG (anterior ao contador): a leitura recalcula pelos Pix
+	// sem gravar e EnsureProgress grava o agregado.
+	if err := storeDDB.TransactWrite(ctx, []types.TransactWriteItem{{Delete: &types.Delete{
+		TableName: aws.String(storeDDB.TableName()),
+		Key:       itemKey(store.DonationPK("d-1"), skProgress),
+	}}}); err != nil {
+		t.Fatal(err)
+	}
+	// Na reconstrucao a ultima doacao e a data da cobranca, nao a do pagamento.
+	rebuilt, err := donations.GetProgress(ctx, "d-1")
+	if rebuilt.UltimaDoacao == "2024-01-04T00:00:00Z" {
+		rebuilt.UltimaDoacao = p.UltimaDoacao
+	}
+	if err != nil || rebuilt != p {
+		t.Fatalf("rebuild = %+v, %v; want %+v", rebuilt, err, p)
+	}
+	if item, _ := storeDDB.GetItem(ctx, store.DonationPK("d-1"), skProgress); len(item) != 0 {
+		t.Fatal("GetProgress gravou o AGG")
+	}
+	if created, err := donations.EnsureProgress(ctx, "d-1"); err != nil || !created {
+		t.Fatalf("EnsureProgress = %v, %v", created, err)
+	}
+	if created, err := donations.EnsureProgress(ctx, "d-1"); err != nil || created {
+		t.Fatalf("EnsureProgress repetido = %v, %v", created, err)
+	}
+	if _, err := donations.GetProgress(ctx, "nao-existe"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("campanha desconhecida: %v", err)
+	}
+}
+
+func TestLedger(t *testing.T) {
+	ctx := context.Background()
+	storeDDB := dynamo.NewMemory("core")
+	donations := NewDonationRepo(storeDDB)
+	charges := NewPixRepo(storeDDB)
+	ledger := NewLedgerRepo(storeDDB)
+	err := donations.Create(ctx, NewDonation{
+		Profile: Donation{ID: "d-1", IDUser: "u-1", Name: "Campanha", Valor: money.Cents(20000), DateCreate: "2024-01-01T00:00:00Z", NomeLink: "@campanha"},
+		Link:    DonationLink{ID: "y", IDDoacao: "d-1", NomeLink: "@campanha"},
+		Payment: DonationPayment{ID: "z", IDDoacao: "d-1", Status: "START"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pix []Pix
+	for i := 0; i < 2; i++ {
+		txid := fmt.Sprintf("tx-%d", i)
+		p := Pix{ID: fmt.Sprintf("p-%d", i), IDDoacao: "d-1", Valor: money.Cents(5000), DataCriacao: fmt.Sprintf("2024-01-0%dT00:00:00Z", i+2), TxID: txid}
+		if err := charges.CreateCharge(ctx, p, PixStatus{IDDoacao: "d-1", IDPix: txid, Valor: money.Cents(5000)}); err != nil {
+			t.Fatal(err)
+		}
+		pix = append(pix, p)
+	}
+
+	if _, _, err := charges.Confirm(ctx, "tx-0"); err != nil {
+		t.Fatal(err)
+	}
+	// O resgate le o saldo e outro Pix e confirmado antes de gravar: o credito
+	// novo continua no disponivel.
+	payment, err := donations.GetPayment(ctx, "d-1")
+	if err != nil || payment.ValorDisponivel != money.Cents(4500) {
+		t.Fatalf("saldo = %+v, %v", payment, err)
+	}
+	if _, _, err := charges.Confirm(ctx, "tx-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := donations.RequestRescue(ctx, "d-1", payment.ValorDisponivel, "u-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := donations.RequestRescue(ctx, "d-1", money.Cents(10000), "u-1"); !errors.Is(err, ErrInsufficientBalance) {
+		t.Fatalf("resgate acima do saldo = %v", err)
+	}
+
+	charge, _ := charges.GetCharge(ctx, "d-1", pix[1].SK())
+	rf := PixRefund{ID: "dev1", IDDoacao: "d-1", TxID: "tx-1", PixSK: charge.SK(), Valor: money.Cents(1000), Status: "PENDENTE", DataCriacao: "2024-01-05T00:00:00Z"}
+	if err := charges.ReserveRefund(ctx, rf, charge); err != nil {
+		t.Fatal(err)
+	}
+
+	payment, _ = donations.GetPayment(ctx, "d-1")
+	if payment.ValorDisponivel != money.Cents(3600) || payment.ValorTaxas != money.Cents(900) || payment.ValorTransferido != money.Cents(4500) || payment.Status != "PROCESS" {
+		t.Fatalf("saldos = %+v", payment)
+	}
+	entries, err := ledger.List(ctx, "d-1")
+	if err != nil || len(entries) != 7 {
+		t.Fatalf("lancamentos = %+v, %v", entries, err)
+	}
+	check, err := ledger.Check(ctx, "d-1")
+	if err != nil || !check.OK() || check.Saldos[AccountDoador] != money.Cents(-9000) {
+		t.Fatalf("conferencia = %+v, %v", check, err)
+	}
+	var total money.Money
+	for _, v := range check.Saldos {
+		if total, err = total.Add(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !total.IsZero() {
+		t.Fatalf("saldos somam %s", total)
+	}
+
+	// Saldo gravado fora do livro razao aparece na conferencia ate o ajuste.
+	if err := storeDDB.UpdateItem(ctx, itemKey(store.DonationPK("d-1"), skPayment), "SET valor_disponivel = valor_disponivel + :v", nil, map[string]types.AttributeValue{":v": dynamo.N("5.00")}); err != nil {
+		t.Fatal(err)
+	}
+	check, _ = ledger.Check(ctx, "d-1")
+	if check.OK() || len(check.Divergencias) != 1 || check.Divergencias[0].Diferenca != money.Cents(500) {
+		t.Fatalf("divergencia = %+v", check)
+	}
+	if check, err = ledger.OpenBalances(ctx, "d-1", "admin"); err != nil || !check.OK() || check.Saldos[AccountCampanha] != money.Cents(4100) {
+		t.Fatalf("apos abertura = %+v, %v", check, err)
+	}
+
+	// Ajuste manual mexe no saldo junto com o lancamento.
+	if err := ledger.Record(ctx, "d-1", AdjustmentEntry("a-1", AccountCampanha, money.Cents(-600), "tarifa bancaria", "admin")); err != nil {
+		t.Fatal(err)
+	}
+	payment, _ = donations.GetPayment(ctx, "d-1")
+	if check, _ = ledger.Check(ctx, "d-1"); !check.OK() || payment.ValorDisponivel != money.Cents(3500) {
+		t.Fatalf("apos ajuste = %+v, %+v", check, payment)
+	}
+
+	// O mesmo fato lancado de novo, em outro momento, esbarra na marca LEDGERREF.
+	again := AdjustmentEntry("a-1", AccountCampanha, money.Cents(-600), "tarifa bancaria", "admin")
+	again.DataCriacao = "2030-01-01T00:00:00Z"
+	if err := ledger.Record(ctx, "d-1", again); !errors.Is(err, ErrConflict) {
+		t.Fatalf("ajuste repetido = %v", err)
+	}
+	// Estorno acima do disponivel nao deixa o saldo negativo.
+	estorno, err := RefundEntries("tx-9", money.Cents(4000), money.Cents(3600))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ledger.Record(ctx, "d-1", estorno...); !errors.Is(err, ErrInsufficientBalance) {
+		t.Fatalf("estorno acima do saldo = %v", err)
+	}
+	// As marcas ficam fora da listagem, que segue a ordem da sort key.
+	entries, _ = ledger.List(ctx, "d-1")
+	if len(entries) != 9 {
+		t.Fatalf("lancamentos = %+v", entries)
+	}
+	for i := 1; i < len(entries); i++ {
+		if entries[i].DataCriacao < entries[i-1].DataCriacao {
+			t.Fatalf("lancamentos fora de ordem: %+v", entries)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package repo
+
+import (
+	"BACK_SORTE_GO/common/store"
+	"BACK_SORTE_GO/common/store/dynamo"
+	"BACK_SORTE_GO/common/text"
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// maxSearchTerms limita os termos por campanha: o titulo entra inteiro e o
+// texto completa ate o limite, mantendo a edicao dentro de uma transacao.
+const maxSearchTerms = 40
+
+// SearchResult e uma campanha encontrada com a quantidade de termos da busca
+// que ela contem.
+type SearchResult struct {
+	Donation
+	Matches int `json:"matches"`
+}
+
+// donationTerms sao os termos indexados da campanha, normalizados como o link.
+func donationTerms(name, texto string) []string {
+	return text.Tokens(name+" "+texto, maxSearchTerms)
+}
+
+func termSK(d Donation) string {
+	return store.PrefixDonation + d.DateCreate + "#" + d.ID
+}
+
+// termPuts monta a gravacao dos itens TERM# da campanha.
+func (r DonationRepo) termPuts(d Donation, terms []string) ([]types.TransactWriteItem, error) {
+	items := make([]map[string]types.AttributeValue, 0, len(terms))
+	for _, term := range terms {
+		item, err := marshalItem(SearchTerm{Term: term, IDDoacao: d.ID, DateCreate: d.DateCreate}, map[string]string{
+			"PK": store.TermPK(term),
+			"SK": termSK(d),
+		})
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return putItems(r.store, items...), nil
+}
+
+// termDeletes monta a remocao dos itens TERM# que a campanha deixou de ter.
+func (r DonationRepo) termDeletes(d Donation, terms []string) []types.TransactWriteItem {
+	out := make([]types.TransactWriteItem, 0, len(terms))
+	for _, term := range terms {
+		out = append(out, types.TransactWriteItem{Delete: &types.Delete{
+			TableName: aws.String(r.store.TableName()),
+			Key:       itemKey(store.TermPK(term), termSK(d)),
+		}})
+	}
+	return out
+}
+
+// diffTerms separa os termos que entram e os que saem do indice.
+func diffTerms(before, after []string) (added, removed []string) {
+	old := map[string]struct{}{}
+	for _, t := range before {
+		old[t] = struct{}{}
+	}
+	for _, t := range after {
+		if _, ok := old[t]; ok {
+			delete(old, t)
+			continue
+		}
+		added = append(added, t)
+	}
+	for _, t := range before {
+		if _, ok := old[t]; ok {
+			removed = append(removed, t)
+		}
+	}
+	return added, removed
+}
+
+// Search procura as campanhas abertas que contem os termos da consulta. Le
+// todas as entradas de cada termo: encerrar ou excluir apaga os TERM#, entao
+// as particoes so tem campanhas abertas. O ranking e pela quantidade de termos
+// encontrados e, no empate, pela campanha mais recente.
+func (r DonationRepo) Search(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	type hit struct {
+		id         string
+		dateCreate string
+		matches    int
+	}
+	hits := map[string]*hit{}
+	for _, term := range text.Tokens(query, 5) {
+		items, err := queryAll(ctx, r.store, &dynamodb.QueryInput{
+			KeyConditionExpression: aws.String("PK = :pk"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":pk": dynamo.S(store.TermPK(term)),
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		var entries []SearchTerm
+		if err := attributevalue.UnmarshalListOfMaps(items, &entries); err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			h := hits[e.IDDoacao]
+			if h == nil {
+				h = &hit{id: e.IDDoacao, dateCreate: e.DateCreate}
+				hits[e.IDDoacao] = h
+			}
+			h.matches++
+		}
+	}
+
+	ranked := make([]*hit, 0, len(hits))
+	for _, h := range hits {
+		ranked = append(ranked, h)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].matches != ranked[j].matches {
+			return ranked[i].matches > ranked[j].matches
+		}
+		return ranked[i].dateCreate > ranked[j].dateCreate
+	})
+
+	// Le os perfis em blocos ate completar o limite com campanhas abertas.
+	results := []SearchResult{}
+	for start := 0; start < len(ranked) && len(results) < limit; start += limit {
+		block := ranked[start:min(start+limit, len(ranked))]
+		keys := make([]map[string]types.AttributeValue, 0, len(block))
+		for _, h := range block {
+			keys = append(keys, itemKey(store.DonationPK(h.id), skProfile))
+		}
+		batch, err := r.store.BatchGet(ctx, keys)
+		if err != nil {
+			return nil, err
+		}
+		for _, h := range block {
+			item := batch[store.DonationPK(h.id)+"|"+skProfile]
+			if item == nil {
+				continue
+			}
+			var d Donation
+			if err := attributevalue.UnmarshalMap(item, &d); err != nil {
+				return nil, err
+			}
+			if !listed(d) {
+				continue
+			}
+			results = append(results, SearchResult{Donation: d, Matches: h.matches})
+			if len(results) == limit {
+				break
+			}
+		}
+	}
+	return results, nil
+}
+
+// IndexTerms grava os TERM# de uma campanha aberta criada antes da busca, que
+// so seria indexada na primeira edicao. A versao sobe como numa edicao; se a
+// campanha mudou, foi indexada ou encerrada no meio tempo devolve ErrConflict.
+func (r DonationRepo) IndexTerms(ctx context.Context, d Donation) error {
+	details, err := r.GetDetails(ctx, d.ID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	terms := donationTerms(d.Name, details.Texto)
+	puts, err := r.termPuts(d, terms)
+	if err != nil {
+		return err
+	}
+	termsAttr, err := attributevalue.Marshal(terms)
+	if err != nil {
+		return err
+	}
+	condition, values := versionCondition(d.Version)
+	values[":terms"] = termsAttr
+	values[":t"] = dynamo.B(true)
+	values[":f"] = dynamo.B(false)
+	err = r.store.TransactWrite(ctx, append([]types.TransactWriteItem{{Update: &types.Update{
+		TableName:                 aws.String(r.store.TableName()),
+		Key:                       itemKey(store.DonationPK(d.ID), skProfile),
+		UpdateExpression:          aws.String("SET terms = :terms, version = :nv"),
+		ConditionExpression:       aws.String("attribute_not_exists(terms) AND " + condition + " AND " + openCondition),
+		ExpressionAttributeValues: values,
+	}}}, puts...))
+	if dynamo.IsConditionFailed(err) {
+		return ErrConflict
+	}
+	return err
+}
@@ -0,0 +1,158 @@
+package repo
+
+import (
+	"BACK_SORTE_GO/common/store"
+	"BACK_SORTE_GO/common/store/dynamo"
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	skProfile      = "PROFILE"
+	skDetails      = "DETAILS"
+	skAccountLevel = "ACCOUNT#LEVEL"
+	prefixAccPay   = "ACCOUNT#PAYMENT#"
+)
+
+// UserRepo le e grava os itens do usuario (PROFILE, DETAILS e ACCOUNT#).
+type UserRepo struct {
+	store dynamo.Store
+}
+
+func NewUserRepo(storeDDB dynamo.Store) UserRepo {
+	return UserRepo{store: storeDDB}
+}
+
+// Get devolve o perfil do usuario.
+func (r UserRepo) Get(ctx context.Context, id string) (User, error) {
+	var u User
+	err := getItem(ctx, r.store, store.UserPK(id), skProfile, &u)
+	return u, err
+}
+
+// FindByEmail busca o perfil pelo email no GSI2.
+func (r UserRepo) FindByEmail(ctx context.Context, email string) (User, error) {
+	out, err := r.store.Query(ctx, &dynamodb.QueryInput{
+		IndexName:              aws.String("GSI2"),
+		KeyConditionExpression: aws.String("GSI2PK = :pk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": dynamo.S(emailKey(email)),
+		},
+		Limit: aws.Int32(1),
+	})
+	if err != nil {
+		return User{}, err
+	}
+	if len(out.Items) == 0 {
+		return User{}, ErrNotFound
+	}
+	var u User
+	err = attributevalue.UnmarshalMap(out.Items[0], &u)
+	return u, err
+}
+
+// CreateItems monta as escritas do cadastro: perfil (com o email no GSI2),
+// nivel da conta e, quando informada, a cobranca inicial do nivel.
+func (r UserRepo) CreateItems(u User, level AccountLevel, payment *AccountPayment) ([]types.TransactWriteItem, error) {
+	profile, err := marshalItem(u, map[string]string{
+		"PK":     store.UserPK(u.ID),
+		"SK":     skProfile,
+		"GSI2PK": emailKey(u.Email),
+		"GSI2SK": store.UserPK(u.ID),
+	})
+	if err != nil {
+		return nil, err
+	}
+	levelItem, err := marshalItem(level, map[string]string{"PK": store.UserPK(u.ID), "SK": skAccountLevel})
+	if err != nil {
+		return nil, err
+	}
+	items := []map[string]types.AttributeValue{profile, levelItem}
+	if payment != nil {
+		paymentItem, err := marshalItem(*payment, map[string]string{"PK": store.UserPK(u.ID), "SK": prefixAccPay + payment.ID})
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, paymentItem)
+	}
+	return putItems(r.store, items...), nil
+}
+
+// Create grava o cadastro montado por CreateItems em uma transacao.
+func (r UserRepo) Create(ctx context.Context, u User, level AccountLevel, payment *AccountPayment) error {
+	items, err := r.CreateItems(u, level, payment)
+	if err != nil {
+		return err
+	}
+	return Transact(ctx, r.store, items)
+}
+
+// UpdateName troca o nome do perfil.
+func (r UserRepo) UpdateName(ctx context.Context, id, name string) error {
+	return r.store.UpdateItem(ctx, itemKey(store.UserPK(id), skProfile), "SET #n = :n, date_update = :d", map[string]string{"#n": "name"}, map[string]types.AttributeValue{
+		":n": dynamo.S(name),
+		":d": dynamo.S(now()),
+	})
+}
+
+// UpdatePassword grava o novo hash bcrypt da senha.
+func (r UserRepo) UpdatePassword(ctx context.Context, id, passwordHash string) error {
+	return r.store.UpdateItem(ctx, itemKey(store.UserPK(id), skProfile), "SET password = :p, date_update = :d", nil, map[string]types.AttributeValue{
+		":p": dynamo.S(passwordHash),
+		":d": dynamo.S(now()),
+	})
+}
+
+// UpdateRoles substitui os papeis do usuario, registrando quem alterou.
+func (r UserRepo) UpdateRoles(ctx context.Context, id string, roles []string, updatedBy string) error {
+	rolesAttr, err := attributevalue.Marshal(roles)
+	if err != nil {
+		return err
+	}
+	return r.store.UpdateItem(ctx, itemKey(store.UserPK(id), skProfile), "SET #roles = :r, roles_updated_by = :by, date_update = :d", map[string]string{"#roles": "roles"}, map[string]types.AttributeValue{
+		":r":  rolesAttr,
+		":by": dynamo.S(updatedBy),
+		":d":  dynamo.S(now()),
+	})
+}
+
+// SetEmailValid marca o email do perfil como confirmado.
+func (r UserRepo) SetEmailValid(ctx context.Context, id string) error {
+	return r.store.UpdateItem(ctx, itemKey(store.UserPK(id), skProfile), "SET email_valid = :v, date_update = :d", nil, map[string]types.AttributeValue{
+		":v": dynamo.B(true),
+		":d": dynamo.S(time.Now().UTC().Format(time.RFC3339)),
+	})
+}
+
+// GetDetails devolve os dados complementares do usuario.
+func (r UserRepo) GetDetails(ctx context.Context, id string) (UserDetails, error) {
+	var d UserDetails
+	err := getItem(ctx, r.store, store.UserPK(id), skDetails, &d)
+	return d, err
+}
+
+// SetProfileImage grava a imagem de perfil sem apagar os demais campos de DETAILS.
+func (r UserRepo) SetProfileImage(ctx context.Context, id, detailsID, fileName string) error {
+	return r.store.UpdateItem(ctx, itemKey(store.UserPK(id), skDetails), "SET id = if_not_exists(id, :id), id_user = :u, img_perfil = :img, date_update = :d", nil, map[string]types.AttributeValue{
+		":id":  dynamo.S(detailsID),
+		":u":   dynamo.S(id),
+		":img": dynamo.S(fileName),
+		":d":   dynamo.S(now()),
+	})
+}
+
+// GetAccountLevel devolve o nivel da conta do usuario.
+func (r UserRepo) GetAccountLevel(ctx context.Context, id string) (AccountLevel, error) {
+	var l AccountLevel
+	err := getItem(ctx, r.store, store.UserPK(id), skAccountLevel, &l)
+	return l, err
+}
+
+func now() string {
+	return time.Now().Format(time.RFC3339)
+}
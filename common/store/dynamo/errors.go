@@ -0,0 +1,22 @@
+package dynamo
+
+import (
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// IsConditionFailed indica que a escrita (simples ou transacional) foi recusada
+// por uma ConditionExpression.
+func IsConditionFailed(err error) bool {
+	var txErr *types.TransactionCanceledException
+	if errors.As(err, &txErr) {
+		for _, reason := range txErr.CancellationReasons {
+			if reason.Code != nil && *reason.Code == "ConditionalCheckFailed" {
+				return true
+			}
+		}
+	}
+	var condErr *types.ConditionalCheckFailedException
+	return errors.As(err, &condErr)
+}
@@ -0,0 +1,785 @@
+package dynamo
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Avaliador das expressoes do DynamoDB usado pelo MemoryStore. Cobre o que os
+// servicos usam: atributos de primeiro nivel (nome ou #placeholder), comparacoes,
+// AND/OR/NOT, BETWEEN, IN, attribute_exists, attribute_not_exists, begins_with,
+// contains, size e, nos updates, SET (com +, -, if_not_exists e list_append),
+// REMOVE, ADD e DELETE. Caminhos aninhados (a.b, a[0]) nao sao suportados.
+
+type exprToken struct {
+	kind string // ident, name, value, op
+	text string
+}
+
+func tokenizeExpr(expr string) ([]exprToken, error) {
+	var tokens []exprToken
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '#' || c == ':' || isIdentChar(c):
+			j := i + 1
+			for j < len(expr) && isIdentChar(expr[j]) {
+				j++
+			}
+			kind := "ident"
+			if c == '#' {
+				kind = "name"
+			} else if c == ':' {
+				kind = "value"
+			}
+			if j == i+1 && kind != "ident" {
+				return nil, fmt.Errorf("dynamo: expressao invalida %q", expr)
+			}
+			tokens = append(tokens, exprToken{kind: kind, text: expr[i:j]})
+			i = j
+		case c == '<' || c == '>':
+			if i+1 < len(expr) && (expr[i+1] == '=' || (c == '<' && expr[i+1] == '>')) {
+				tokens = append(tokens, exprToken{kind: "op", text: expr[i : i+2]})
+				i += 2
+			} else {
+				tokens = append(tokens, exprToken{kind: "op", text: string(c)})
+				i++
+			}
+		case strings.IndexByte("=(),+-", c) >= 0:
+			tokens = append(tokens, exprToken{kind: "op", text: string(c)})
+			i++
+		default:
+			return nil, fmt.Errorf("dynamo: caractere %q nao suportado na expressao %q", c, expr)
+		}
+	}
+	return tokens, nil
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+	names  map[string]string
+	values map[string]types.AttributeValue
+}
+
+func newExprParser(expr string, names map[string]string, values map[string]types.AttributeValue) (*exprParser, error) {
+	tokens, err := tokenizeExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+	return &exprParser{tokens: tokens, names: names, values: values}, nil
+}
+
+func (p *exprParser) peek() exprToken {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return exprToken{}
+}
+
+func (p *exprParser) next() exprToken {
+	t := p.peek()
+	if p.pos < len(p.tokens) {
+		p.pos++
+	}
+	return t
+}
+
+func (p *exprParser) isKeyword(word string) bool {
+	t := p.peek()
+	return t.kind == "ident" && strings.EqualFold(t.text, word)
+}
+
+func (p *exprParser) isOp(op string) bool {
+	t := p.peek()
+	return t.kind == "op" && t.text == op
+}
+
+func (p *exprParser) expectOp(op string) error {
+	if !p.isOp(op) {
+		return fmt.Errorf("dynamo: esperado %q na expressao", op)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *exprParser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+// path resolve um atributo de primeiro nivel, traduzindo #placeholders.
+func (p *exprParser) path() (string, error) {
+	t := p.next()
+	switch t.kind {
+	case "ident":
+		return t.text, nil
+	case "name":
+		name, ok := p.names[t.text]
+		if !ok {
+			return "", fmt.Errorf("dynamo: nome %s nao definido", t.text)
+		}
+		return name, nil
+	}
+	return "", fmt.Errorf("dynamo: atributo esperado na expressao")
+}
+
+func (p *exprParser) value() (types.AttributeValue, error) {
+	t := p.next()
+	if t.kind != "value" {
+		return nil, fmt.Errorf("dynamo: valor esperado na expressao")
+	}
+	v, ok := p.values[t.text]
+	if !ok {
+		return nil, fmt.Errorf("dynamo: valor %s nao definido", t.text)
+	}
+	return v, nil
+}
+
+// condition interpreta uma ConditionExpression, FilterExpression ou
+// KeyConditionExpression completa.
+func (p *exprParser) condition() (func(map[string]types.AttributeValue) bool, error) {
+	cond, err := p.orExpr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, fmt.Errorf("dynamo: sobra %q na expressao", p.peek().text)
+	}
+	return cond, nil
+}
+
+func (p *exprParser) orExpr() (func(map[string]types.AttributeValue) bool, error) {
+	left, err := p.andExpr()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("OR") {
+		p.pos++
+		right, err := p.andExpr()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(item map[string]types.AttributeValue) bool { return l(item) || right(item) }
+	}
+	return left, nil
+}
+
+func (p *exprParser) andExpr() (func(map[string]types.AttributeValue) bool, error) {
+	left, err := p.notExpr()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("AND") {
+		p.pos++
+		right, err := p.notExpr()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(item map[string]types.AttributeValue) bool { return l(item) && right(item) }
+	}
+	return left, nil
+}
+
+func (p *exprParser) notExpr() (func(map[string]types.AttributeValue) bool, error) {
+	if p.isKeyword("NOT") {
+		p.pos++
+		inner, err := p.notExpr()
+		if err != nil {
+			return nil, err
+		}
+		return func(item map[string]types.AttributeValue) bool { return !inner(item) }, nil
+	}
+	return p.primary()
+}
+
+func (p *exprParser) primary() (func(map[string]types.AttributeValue) bool, error) {
+	if p.isOp("(") {
+		p.pos++
+		inner, err := p.orExpr()
+		if err != nil {
+			return nil, err
+		}
+		return inner, p.expectOp(")")
+	}
+
+	t := p.peek()
+	if t.kind == "ident" && p.pos+1 < len(p.tokens) && p.tokens[p.pos+1].text == "(" {
+		switch strings.ToLower(t.text) {
+		case "attribute_exists", "attribute_not_exists":
+			p.pos += 2
+			name, err := p.path()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectOp(")"); err != nil {
+				return nil, err
+			}
+			want := strings.EqualFold(t.text, "attribute_exists")
+			return func(item map[string]types.AttributeValue) bool {
+				_, ok := item[name]
+				return ok == want
+			}, nil
+		case "begins_with", "contains":
+			p.pos += 2
+			left, err := p.operand()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectOp(","); err != nil {
+				return nil, err
+			}
+			right, err := p.operand()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectOp(")"); err != nil {
+				return nil, err
+			}
+			if strings.EqualFold(t.text, "begins_with") {
+				return func(item map[string]types.AttributeValue) bool {
+					return beginsWith(left(item), right(item))
+				}, nil
+			}
+			return func(item map[string]types.AttributeValue) bool {
+				return containsValue(left(item), right(item))
+			}, nil
+		}
+	}
+
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.isKeyword("BETWEEN") {
+		p.pos++
+		low, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		if !p.isKeyword("AND") {
+			return nil, fmt.Errorf("dynamo: BETWEEN sem AND")
+		}
+		p.pos++
+		high, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		return func(item map[string]types.AttributeValue) bool {
+			v := left(item)
+			c1, ok1 := compareAttr(v, low(item))
+			c2, ok2 := compareAttr(v, high(item))
+			return ok1 && ok2 && c1 >= 0 && c2 <= 0
+		}, nil
+	}
+
+	if p.isKeyword("IN") {
+		p.pos++
+		if err := p.expectOp("("); err != nil {
+			return nil, err
+		}
+		var options []func(map[string]types.AttributeValue) types.AttributeValue
+		for {
+			opt, err := p.operand()
+			if err != nil {
+				return nil, err
+			}
+			options = append(options, opt)
+			if !p.isOp(",") {
+				break
+			}
+			p.pos++
+		}
+		if err := p.expectOp(")"); err != nil {
+			return nil, err
+		}
+		return func(item map[string]types.AttributeValue) bool {
+			v := left(item)
+			for _, opt := range options {
+				if equalAttr(v, opt(item)) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	}
+
+	op := p.next()
+	if op.kind != "op" {
+		return nil, fmt.Errorf("dynamo: comparador esperado na expressao")
+	}
+	right, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	switch op.text {
+	case "=":
+		return func(item map[string]types.AttributeValue) bool { return equalAttr(left(item), right(item)) }, nil
+	case "<>":
+		return func(item map[string]types.AttributeValue) bool {
+			l, r := left(item), right(item)
+			return l != nil && r != nil && !equalAttr(l, r)
+		}, nil
+	case "<", "<=", ">", ">=":
+		return func(item map[string]types.AttributeValue) bool {
+			c, ok := compareAttr(left(item), right(item))
+			if !ok {
+				return false
+			}
+			switch op.text {
+			case "<":
+				return c < 0
+			case "<=":
+				return c <= 0
+			case ">":
+				return c > 0
+			}
+			return c >= 0
+		}, nil
+	}
+	return nil, fmt.Errorf("dynamo: comparador %q nao suportado", op.text)
+}
+
+// operand devolve um atributo, um valor ou size(atributo).
+func (p *exprParser) operand() (func(map[string]types.AttributeValue) types.AttributeValue, error) {
+	t := p.peek()
+	if t.kind == "value" {
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		return func(map[string]types.AttributeValue) types.AttributeValue { return v }, nil
+	}
+	if t.kind == "ident" && strings.EqualFold(t.text, "size") && p.pos+1 < len(p.tokens) && p.tokens[p.pos+1].text == "(" {
+		p.pos += 2
+		name, err := p.path()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectOp(")"); err != nil {
+			return nil, err
+		}
+		return func(item map[string]types.AttributeValue) types.AttributeValue {
+			n, ok := attrSize(item[name])
+			if !ok {
+				return nil
+			}
+			return N(fmt.Sprint(n))
+		}, nil
+	}
+	name, err := p.path()
+	if err != nil {
+		return nil, err
+	}
+	return func(item map[string]types.AttributeValue) types.AttributeValue { return item[name] }, nil
+}
+
+// updateAction altera o item ja copiado; os valores sao calculados sobre o item original.
+type updateAction func(original, updated map[string]types.AttributeValue) error
+
+// update interpreta uma UpdateExpression.
+func (p *exprParser) update() ([]updateAction, error) {
+	var actions []updateAction
+	seen := map[string]bool{}
+	for !p.done() {
+		t := p.next()
+		clause := strings.ToUpper(t.text)
+		if t.kind != "ident" || seen[clause] {
+			return nil, fmt.Errorf("dynamo: clausula invalida %q no update", t.text)
+		}
+		seen[clause] = true
+		for {
+			var action updateAction
+			var err error
+			switch clause {
+			case "SET":
+				action, err = p.setAction()
+			case "REMOVE":
+				action, err = p.removeAction()
+			case "ADD", "DELETE":
+				action, err = p.addDeleteAction(clause == "ADD")
+			default:
+				err = fmt.Errorf("dynamo: clausula %q nao suportada", t.text)
+			}
+			if err != nil {
+				return nil, err
+			}
+			actions = append(actions, action)
+			if !p.isOp(",") {
+				break
+			}
+			p.pos++
+		}
+	}
+	if len(actions) == 0 {
+		return nil, fmt.Errorf("dynamo: update vazio")
+	}
+	return actions, nil
+}
+
+func (p *exprParser) setAction() (updateAction, error) {
+	name, err := p.path()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectOp("="); err != nil {
+		return nil, err
+	}
+	left, err := p.setOperand()
+	if err != nil {
+		return nil, err
+	}
+	var sign int
+	var right func(map[string]types.AttributeValue) (types.AttributeValue, error)
+	if p.isOp("+") || p.isOp("-") {
+		sign = 1
+		if p.next().text == "-" {
+			sign = -1
+		}
+		right, err = p.setOperand()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return func(original, updated map[string]types.AttributeValue) error {
+		v, err := left(original)
+		if err != nil {
+			return err
+		}
+		if right != nil {
+			r, err := right(original)
+			if err != nil {
+				return err
+			}
+			v, err = addNumbers(v, r, sign)
+			if err != nil {
+				return err
+			}
+		}
+		if v == nil {
+			return fmt.Errorf("dynamo: SET de %s referencia atributo inexistente", name)
+		}
+		updated[name] = v
+		return nil
+	}, nil
+}
+
+func (p *exprParser) setOperand() (func(map[string]types.AttributeValue) (types.AttributeValue, error), error) {
+	t := p.peek()
+	if t.kind == "ident" && p.pos+1 < len(p.tokens) && p.tokens[p.pos+1].text == "(" {
+		fn := strings.ToLower(t.text)
+		if fn != "if_not_exists" && fn != "list_append" {
+			return nil, fmt.Errorf("dynamo: funcao %q nao suportada no SET", t.text)
+		}
+		p.pos += 2
+		first, err := p.setOperand()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectOp(","); err != nil {
+			return nil, err
+		}
+		second, err := p.setOperand()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectOp(")"); err != nil {
+			return nil, err
+		}
+		if fn == "if_not_exists" {
+			return func(item map[string]types.AttributeValue) (types.AttributeValue, error) {
+				v, err := first(item)
+				if err != nil || v == nil {
+					return second(item)
+				}
+				return v, nil
+			}, nil
+		}
+		return func(item map[string]types.AttributeValue) (types.AttributeValue, error) {
+			a, err := first(item)
+			if err != nil {
+				return nil, err
+			}
+			b, err := second(item)
+			if err != nil {
+				return nil, err
+			}
+			la, ok1 := a.(*types.AttributeValueMemberL)
+			lb, ok2 := b.(*types.AttributeValueMemberL)
+			if !ok1 || !ok2 {
+				return nil, fmt.Errorf("dynamo: list_append exige listas")
+			}
+			out := append(append([]types.AttributeValue{}, la.Value...), lb.Value...)
+			return &types.AttributeValueMemberL{Value: out}, nil
+		}, nil
+	}
+
+	if t.kind == "value" {
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		return func(map[string]types.AttributeValue) (types.AttributeValue, error) { return v, nil }, nil
+	}
+	name, err := p.path()
+	if err != nil {
+		return nil, err
+	}
+	return func(item map[string]types.AttributeValue) (types.AttributeValue, error) {
+		v, ok := item[name]
+		if !ok {
+			return nil, nil
+		}
+		return v, nil
+	}, nil
+}
+
+func (p *exprParser) removeAction() (updateAction, error) {
+	name, err := p.path()
+	if err != nil {
+		return nil, err
+	}
+	return func(_, updated map[string]types.AttributeValue) error {
+		delete(updated, name)
+		return nil
+	}, nil
+}
+
+func (p *exprParser) addDeleteAction(add bool) (updateAction, error) {
+	name, err := p.path()
+	if err != nil {
+		return nil, err
+	}
+	v, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	return func(_, updated map[string]types.AttributeValue) error {
+		current, exists := updated[name]
+		if add {
+			if !exists {
+				updated[name] = v
+				return nil
+			}
+			if _, ok := v.(*types.AttributeValueMemberN); ok {
+				sum, err := addNumbers(current, v, 1)
+				if err != nil {
+					return err
+				}
+				updated[name] = sum
+				return nil
+			}
+			merged, err := mergeSets(current, v, true)
+			if err != nil {
+				return err
+			}
+			updated[name] = merged
+			return nil
+		}
+		if !exists {
+			return nil
+		}
+		rest, err := mergeSets(current, v, false)
+		if err != nil {
+			return err
+		}
+		if rest == nil {
+			delete(updated, name)
+		} else {
+			updated[name] = rest
+		}
+		return nil
+	}, nil
+}
+
+func parseNumber(v types.AttributeValue) (*big.Rat, bool) {
+	n, ok := v.(*types.AttributeValueMemberN)
+	if !ok {
+		return nil, false
+	}
+	r, ok := new(big.Rat).SetString(n.Value)
+	return r, ok
+}
+
+func formatNumber(r *big.Rat) string {
+	if r.IsInt() {
+		return r.Num().String()
+	}
+	s := strings.TrimRight(r.FloatString(38), "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+func addNumbers(a, b types.AttributeValue, sign int) (types.AttributeValue, error) {
+	x, ok1 := parseNumber(a)
+	y, ok2 := parseNumber(b)
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("dynamo: operacao aritmetica exige numeros")
+	}
+	if sign < 0 {
+		y.Neg(y)
+	}
+	return N(formatNumber(x.Add(x, y))), nil
+}
+
+// mergeSets une (add) ou subtrai (!add) conjuntos do mesmo tipo; devolve nil quando o resultado fica vazio.
+func mergeSets(current, delta types.AttributeValue, add bool) (types.AttributeValue, error) {
+	switch cur := current.(type) {
+	case *types.AttributeValueMemberSS:
+		d, ok := delta.(*types.AttributeValueMemberSS)
+		if !ok {
+			return nil, fmt.Errorf("dynamo: tipo de conjunto incompativel")
+		}
+		out := mergeStrings(cur.Value, d.Value, add)
+		if len(out) == 0 {
+			return nil, nil
+		}
+		return &types.AttributeValueMemberSS{Value: out}, nil
+	case *types.AttributeValueMemberNS:
+		d, ok := delta.(*types.AttributeValueMemberNS)
+		if !ok {
+			return nil, fmt.Errorf("dynamo: tipo de conjunto incompativel")
+		}
+		out := mergeStrings(cur.Value, d.Value, add)
+		if len(out) == 0 {
+			return nil, nil
+		}
+		return &types.AttributeValueMemberNS{Value: out}, nil
+	}
+	return nil, fmt.Errorf("dynamo: ADD/DELETE exige numero ou conjunto")
+}
+
+func mergeStrings(current, delta []string, add bool) []string {
+	in := map[string]bool{}
+	for _, v := range delta {
+		in[v] = true
+	}
+	var out []string
+	for _, v := range current {
+		if add {
+			delete(in, v)
+			out = append(out, v)
+		} else if !in[v] {
+			out = append(out, v)
+		}
+	}
+	if add {
+		for _, v := range delta {
+			if in[v] {
+				out = append(out, v)
+				delete(in, v)
+			}
+		}
+	}
+	return out
+}
+
+// compareAttr ordena dois valores do mesmo tipo escalar (S, N ou B).
+func compareAttr(a, b types.AttributeValue) (int, bool) {
+	switch x := a.(type) {
+	case *types.AttributeValueMemberS:
+		if y, ok := b.(*types.AttributeValueMemberS); ok {
+			return strings.Compare(x.Value, y.Value), true
+		}
+	case *types.AttributeValueMemberN:
+		rx, ok1 := parseNumber(x)
+		ry, ok2 := parseNumber(b)
+		if ok1 && ok2 {
+			return rx.Cmp(ry), true
+		}
+	case *types.AttributeValueMemberB:
+		if y, ok := b.(*types.AttributeValueMemberB); ok {
+			return bytes.Compare(x.Value, y.Value), true
+		}
+	}
+	return 0, false
+}
+
+func equalAttr(a, b types.AttributeValue) bool {
+	if a == nil || b == nil {
+		return false
+	}
+	if c, ok := compareAttr(a, b); ok {
+		return c == 0
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func beginsWith(a, b types.AttributeValue) bool {
+	switch x := a.(type) {
+	case *types.AttributeValueMemberS:
+		y, ok := b.(*types.AttributeValueMemberS)
+		return ok && strings.HasPrefix(x.Value, y.Value)
+	case *types.AttributeValueMemberB:
+		y, ok := b.(*types.AttributeValueMemberB)
+		return ok && bytes.HasPrefix(x.Value, y.Value)
+	}
+	return false
+}
+
+func containsValue(a, b types.AttributeValue) bool {
+	switch x := a.(type) {
+	case *types.AttributeValueMemberS:
+		y, ok := b.(*types.AttributeValueMemberS)
+		return ok && strings.Contains(x.Value, y.Value)
+	case *types.AttributeValueMemberSS:
+		y, ok := b.(*types.AttributeValueMemberS)
+		return ok && containsString(x.Value, y.Value)
+	case *types.AttributeValueMemberNS:
+		y, ok := b.(*types.AttributeValueMemberN)
+		if !ok {
+			return false
+		}
+		for _, v := range x.Value {
+			if equalAttr(N(v), y) {
+				return true
+			}
+		}
+	case *types.AttributeValueMemberL:
+		for _, v := range x.Value {
+			if equalAttr(v, b) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func attrSize(v types.AttributeValue) (int, bool) {
+	switch x := v.(type) {
+	case *types.AttributeValueMemberS:
+		return len(x.Value), true
+	case *types.AttributeValueMemberB:
+		return len(x.Value), true
+	case *types.AttributeValueMemberSS:
+		return len(x.Value), true
+	case *types.AttributeValueMemberNS:
+		return len(x.Value), true
+	case *types.AttributeValueMemberL:
+		return len(x.Value), true
+	case *types.AttributeValueMemberM:
+		return len(x.Value), true
+	}
+	return 0, false
+}
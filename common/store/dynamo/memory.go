@@ -0,0 +1,414 @@
+package dynamo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// MemoryStore implementa Store em memoria para testes. Entende PK/SK, os
+// indices GSI{n} (chaves {indice}PK/{indice}SK, esparsos como no DynamoDB),
+// paginacao por Limit/ExclusiveStartKey, as expressoes de expr.go e
+// transacoes atomicas com TransactionCanceledException.
+type MemoryStore struct {
+	Table string
+
+	mu    sync.Mutex
+	items map[string]map[string]types.AttributeValue
+}
+
+func NewMemory(table string) *MemoryStore {
+	return &MemoryStore{Table: table, items: map[string]map[string]types.AttributeValue{}}
+}
+
+func (s *MemoryStore) TableName() string {
+	return s.Table
+}
+
+func (s *MemoryStore) GetItem(_ context.Context, pk, sk string) (map[string]types.AttributeValue, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.items[pk+"|"+sk]
+	if !ok {
+		return nil, nil
+	}
+	return copyItem(item), nil
+}
+
+func (s *MemoryStore) PutItem(_ context.Context, item map[string]types.AttributeValue) error {
+	id, err := itemID(item)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items[id] = copyItem(item)
+	return nil
+}
+
+func (s *MemoryStore) UpdateItem(_ context.Context, key map[string]types.AttributeValue, update string, names map[string]string, values map[string]types.AttributeValue) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id, updated, err := s.applyUpdate(key, update, names, values)
+	if err != nil {
+		return err
+	}
+	s.items[id] = updated
+	return nil
+}
+
+func (s *MemoryStore) Query(_ context.Context, input *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+	if input.KeyConditionExpression == nil {
+		return nil, fmt.Errorf("dynamo: KeyConditionExpression obrigatoria")
+	}
+	p, err := newExprParser(*input.KeyConditionExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues)
+	if err != nil {
+		return nil, err
+	}
+	keyCond, err := p.condition()
+	if err != nil {
+		return nil, err
+	}
+	filter, err := parseOptionalCondition(input.FilterExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues)
+	if err != nil {
+		return nil, err
+	}
+
+	forward := input.ScanIndexForward == nil || *input.ScanIndexForward
+	page := s.page(aws.ToString(input.IndexName), keyCond, filter, forward, input.ExclusiveStartKey, aws.ToInt32(input.Limit))
+	out := &dynamodb.QueryOutput{
+		Count:            page.count,
+		ScannedCount:     page.scanned,
+		LastEvaluatedKey: page.lastKey,
+	}
+	if input.Select != types.SelectCount {
+		out.Items = project(page.items, input.ProjectionExpression, input.ExpressionAttributeNames)
+	}
+	return out, nil
+}
+
+func (s *MemoryStore) Scan(_ context.Context, input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+	filter, err := parseOptionalCondition(input.FilterExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues)
+	if err != nil {
+		return nil, err
+	}
+
+	page := s.page(aws.ToString(input.IndexName), nil, filter, true, input.ExclusiveStartKey, aws.ToInt32(input.Limit))
+	out := &dynamodb.ScanOutput{
+		Count:            page.count,
+		ScannedCount:     page.scanned,
+		LastEvaluatedKey: page.lastKey,
+	}
+	if input.Select != types.SelectCount {
+		out.Items = project(page.items, input.ProjectionExpression, input.ExpressionAttributeNames)
+	}
+	return out, nil
+}
+
+// TransactWrite confere todas as condicoes antes de gravar; se alguma falhar nada
+// e gravado e o erro traz o motivo de cada item, como no DynamoDB.
+func (s *MemoryStore) TransactWrite(_ context.Context, items []types.TransactWriteItem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	staged := map[string]map[string]types.AttributeValue{}
+	reasons := make([]types.CancellationReason, len(items))
+	failed := false
+
+	for i, op := range items {
+		var (
+			table     *string
+			key       map[string]types.AttributeValue
+			condition *string
+			names     map[string]string
+			values    map[string]types.AttributeValue
+		)
+		switch {
+		case op.Put != nil:
+			table, key, condition, names, values = op.Put.TableName, op.Put.Item, op.Put.ConditionExpression, op.Put.ExpressionAttributeNames, op.Put.ExpressionAttributeValues
+		case op.Update != nil:
+			table, key, condition, names, values = op.Update.TableName, op.Update.Key, op.Update.ConditionExpression, op.Update.ExpressionAttributeNames, op.Update.ExpressionAttributeValues
+		case op.Delete != nil:
+			table, key, condition, names, values = op.Delete.TableName, op.Delete.Key, op.Delete.ConditionExpression, op.Delete.ExpressionAttributeNames, op.Delete.ExpressionAttributeValues
+		case op.ConditionCheck != nil:
+			table, key, condition, names, values = op.ConditionCheck.TableName, op.ConditionCheck.Key, op.ConditionCheck.ConditionExpression, op.ConditionCheck.ExpressionAttributeNames, op.ConditionCheck.ExpressionAttributeValues
+		default:
+			return fmt.Errorf("dynamo: item %d da transacao sem operacao", i)
+		}
+		if aws.ToString(table) != s.Table {
+			return fmt.Errorf("dynamo: tabela %q desconhecida", aws.ToString(table))
+		}
+
+		id, err := itemID(key)
+		if err != nil {
+			return err
+		}
+		if _, dup := staged[id]; dup {
+			return fmt.Errorf("dynamo: transacao com mais de uma operacao no item %s", id)
+		}
+
+		cond, err := parseOptionalCondition(condition, names, values)
+		if err != nil {
+			return err
+		}
+		current := s.items[id]
+		if cond != nil && !cond(current) {
+			reasons[i] = types.CancellationReason{Code: aws.String("ConditionalCheckFailed"), Message: aws.String("The conditional request failed")}
+			failed = true
+			staged[id] = current
+			continue
+		}
+		reasons[i] = types.CancellationReason{Code: aws.String("None")}
+
+		switch {
+		case op.Put != nil:
+			staged[id] = copyItem(op.Put.Item)
+		case op.Update != nil:
+			_, updated, err := s.applyUpdate(op.Update.Key, aws.ToString(op.Update.UpdateExpression), names, values)
+			if err != nil {
+				return err
+			}
+			staged[id] = updated
+		case op.Delete != nil:
+			staged[id] = nil
+		default:
+			staged[id] = current
+		}
+	}
+
+	if failed {
+		return &types.TransactionCanceledException{
+			Message:             aws.String("Transaction cancelled, please refer cancellation reasons for specific reasons"),
+			CancellationReasons: reasons,
+		}
+	}
+
+	for id, item := range staged {
+		if item == nil {
+			delete(s.items, id)
+		} else {
+			s.items[id] = item
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) BatchGet(_ context.Context, keys []map[string]types.AttributeValue) (map[string]map[string]types.AttributeValue, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	items := map[string]map[string]types.AttributeValue{}
+	for _, key := range keys {
+		id, err := itemID(key)
+		if err != nil {
+			return nil, err
+		}
+		if item, ok := s.items[id]; ok {
+			items[id] = copyItem(item)
+		}
+	}
+	return items, nil
+}
+
+// applyUpdate devolve o item atualizado sem grava-lo; cria o item quando nao existe.
+func (s *MemoryStore) applyUpdate(key map[string]types.AttributeValue, update string, names map[string]string, values map[string]types.AttributeValue) (string, map[string]types.AttributeValue, error) {
+	id, err := itemID(key)
+	if err != nil {
+		return "", nil, err
+	}
+	p, err := newExprParser(update, names, values)
+	if err != nil {
+		return "", nil, err
+	}
+	actions, err := p.update()
+	if err != nil {
+		return "", nil, err
+	}
+
+	original := s.items[id]
+	if original == nil {
+		original = map[string]types.AttributeValue{"PK": key["PK"], "SK": key["SK"]}
+	}
+	updated := copyItem(original)
+	for _, action := range actions {
+		if err := action(original, updated); err != nil {
+			return "", nil, err
+		}
+	}
+	return id, updated, nil
+}
+
+type memoryPage struct {
+	items   []map[string]types.AttributeValue
+	count   int32
+	scanned int32
+	lastKey map[string]types.AttributeValue
+}
+
+// page percorre a tabela (ou o indice) em ordem de chave, aplicando a condicao de
+// chave, ExclusiveStartKey, Limit (antes do filtro) e o filtro.
+func (s *MemoryStore) page(index string, keyCond, filter func(map[string]types.AttributeValue) bool, forward bool, startKey map[string]types.AttributeValue, limit int32) memoryPage {
+	hashKey, rangeKey := "PK", "SK"
+	if index != "" {
+		hashKey, rangeKey = index+"PK", index+"SK"
+	}
+
+	s.mu.Lock()
+	var candidates []map[string]types.AttributeValue
+	for _, item := range s.items {
+		if _, ok := item[hashKey]; !ok {
+			continue
+		}
+		if keyCond != nil && !keyCond(item) {
+			continue
+		}
+		candidates = append(candidates, copyItem(item))
+	}
+	s.mu.Unlock()
+
+	order := func(a, b map[string]types.AttributeValue) int {
+		for _, attr := range []string{hashKey, rangeKey, "PK", "SK"} {
+			c, _ := compareAttr(a[attr], b[attr])
+			if a[attr] == nil && b[attr] != nil {
+				c = -1
+			} else if a[attr] != nil && b[attr] == nil {
+				c = 1
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return 0
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		c := order(candidates[i], candidates[j])
+		if !forward {
+			return c > 0
+		}
+		return c < 0
+	})
+
+	var out memoryPage
+	for i, item := range candidates {
+		if startKey != nil {
+			c := order(item, startKey)
+			if (forward && c <= 0) || (!forward && c >= 0) {
+				continue
+			}
+		}
+		out.scanned++
+		if filter == nil || filter(item) {
+			out.items = append(out.items, item)
+			out.count++
+		}
+		if limit > 0 && out.scanned == limit {
+			if i < len(candidates)-1 {
+				out.lastKey = map[string]types.AttributeValue{"PK": item["PK"], "SK": item["SK"]}
+				if index != "" {
+					out.lastKey[hashKey] = item[hashKey]
+					if v, ok := item[rangeKey]; ok {
+						out.lastKey[rangeKey] = v
+					}
+				}
+			}
+			break
+		}
+	}
+	return out
+}
+
+func parseOptionalCondition(expr *string, names map[string]string, values map[string]types.AttributeValue) (func(map[string]types.AttributeValue) bool, error) {
+	if expr == nil || strings.TrimSpace(*expr) == "" {
+		return nil, nil
+	}
+	p, err := newExprParser(*expr, names, values)
+	if err != nil {
+		return nil, err
+	}
+	return p.condition()
+}
+
+func project(items []map[string]types.AttributeValue, projection *string, names map[string]string) []map[string]types.AttributeValue {
+	if projection == nil || strings.TrimSpace(*projection) == "" {
+		return items
+	}
+	var attrs []string
+	for _, attr := range strings.Split(*projection, ",") {
+		attr = strings.TrimSpace(attr)
+		if name, ok := names[attr]; ok {
+			attr = name
+		}
+		attrs = append(attrs, attr)
+	}
+	out := make([]map[string]types.AttributeValue, 0, len(items))
+	for _, item := range items {
+		projected := map[string]types.AttributeValue{}
+		for _, attr := range attrs {
+			if v, ok := item[attr]; ok {
+				projected[attr] = v
+			}
+		}
+		out = append(out, projected)
+	}
+	return out
+}
+
+func itemID(item map[string]types.AttributeValue) (string, error) {
+	pk, ok1 := item["PK"].(*types.AttributeValueMemberS)
+	sk, ok2 := item["SK"].(*types.AttributeValueMemberS)
+	if !ok1 || !ok2 {
+		return "", fmt.Errorf("dynamo: item sem PK/SK")
+	}
+	return pk.Value + "|" + sk.Value, nil
+}
+
+// copyItem copia o item para que quem chama nao altere o estado guardado.
+func copyItem(item map[string]types.AttributeValue) map[string]types.AttributeValue {
+	if item == nil {
+		return nil
+	}
+	out := make(map[string]types.AttributeValue, len(item))
+	for k, v := range item {
+		out[k] = copyAttr(v)
+	}
+	return out
+}
+
+func copyAttr(v types.AttributeValue) types.AttributeValue {
+	switch x := v.(type) {
+	case *types.AttributeValueMemberS:
+		return &types.AttributeValueMemberS{Value: x.Value}
+	case *types.AttributeValueMemberN:
+		return &types.AttributeValueMemberN{Value: x.Value}
+	case *types.AttributeValueMemberBOOL:
+		return &types.AttributeValueMemberBOOL{Value: x.Value}
+	case *types.AttributeValueMemberNULL:
+		return &types.AttributeValueMemberNULL{Value: x.Value}
+	case *types.AttributeValueMemberB:
+		return &types.AttributeValueMemberB{Value: append([]byte(nil), x.Value...)}
+	case *types.AttributeValueMemberSS:
+		return &types.AttributeValueMemberSS{Value: append([]string(nil), x.Value...)}
+	case *types.AttributeValueMemberNS:
+		return &types.AttributeValueMemberNS{Value: append([]string(nil), x.Value...)}
+	case *types.AttributeValueMemberBS:
+		out := make([][]byte, len(x.Value))
+		for i, b := range x.Value {
+			out[i] = append([]byte(nil), b...)
+		}
+		return &types.AttributeValueMemberBS{Value: out}
+	case *types.AttributeValueMemberL:
+		out := make([]types.AttributeValue, len(x.Value))
+		for i, e := range x.Value {
+			out[i] = copyAttr(e)
+		}
+		return &types.AttributeValueMemberL{Value: out}
+	case *types.AttributeValueMemberM:
+		return &types.AttributeValueMemberM{Value: copyItem(x.Value)}
+	}
+	return v
+}
@@ -0,0 +1,132 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestMemoryStoreQuery(t *testing.T) {
+	s := NewMemory("core")
+	ctx := context.Background()
+	for _, item := range []map[string]types.AttributeValue{
+		{"PK": S("DONATION#1"), "SK": S("PROFILE"), "GSI1PK": S("USER#a"), "GSI1SK": S("DONATION#2024-01-01#1")},
+		{"PK": S("DONATION#2"), "SK": S("PROFILE"), "GSI1PK": S("USER#a"), "GSI1SK": S("DONATION#2024-02-01#2")},
+		{"PK": S("DONATION#1"), "SK": S("VIS#2024-01-03")},
+		{"PK": S("DONATION#1"), "SK": S("VIS#2024-01-02")},
+		{"PK": S("DONATION#1"), "SK": S("DETAILS")},
+	} {
+		if err := s.PutItem(ctx, item); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out, err := s.Query(ctx, &dynamodb.QueryInput{
+		KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :sk)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": S("DONATION#1"),
+			":sk": S("VIS#"),
+		},
+		ScanIndexForward: aws.Bool(false),
+		Limit:            aws.Int32(1),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Items) != 1 || out.Items[0]["SK"].(*types.AttributeValueMemberS).Value != "VIS#2024-01-03" || out.LastEvaluatedKey == nil {
+		t.Fatalf("primeira pagina inesperada: %v", out.Items)
+	}
+
+	next, err := s.Query(ctx, &dynamodb.QueryInput{
+		KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :sk)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": S("DONATION#1"),
+			":sk": S("VIS#"),
+		},
+		ScanIndexForward:  aws.Bool(false),
+		Limit:             aws.Int32(1),
+		ExclusiveStartKey: out.LastEvaluatedKey,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(next.Items) != 1 || next.Items[0]["SK"].(*types.AttributeValueMemberS).Value != "VIS#2024-01-02" || next.LastEvaluatedKey != nil {
+		t.Fatalf("segunda pagina inesperada: %v", next.Items)
+	}
+
+	// O indice e esparso: so entram itens com GSI1PK.
+	gsi, err := s.Query(ctx, &dynamodb.QueryInput{
+		IndexName:              aws.String("GSI1"),
+		KeyConditionExpression: aws.String("GSI1PK = :pk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": S("USER#a"),
+		},
+		ScanIndexForward: aws.Bool(false),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gsi.Items) != 2 || gsi.Items[0]["PK"].(*types.AttributeValueMemberS).Value != "DONATION#2" {
+		t.Fatalf("GSI1 inesperado: %v", gsi.Items)
+	}
+}
+
+func TestMemoryStoreUpdate(t *testing.T) {
+	s := NewMemory("core")
+	ctx := context.Background()
+	key := map[string]types.AttributeValue{"PK": S("DONATION#1"), "SK": S("PAYMENT")}
+
+	for i := 0; i < 2; i++ {
+		err := s.UpdateItem(ctx, key, "SET valor = if_not_exists(valor, :z) + :v ADD hits :one", nil, map[string]types.AttributeValue{
+			":z":   N("0"),
+			":v":   N("10.5"),
+			":one": N("1"),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := s.UpdateItem(ctx, key, "SET #s = :s REMOVE hits", map[string]string{"#s": "status"}, map[string]types.AttributeValue{":s": S("PAGO")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	item, _ := s.GetItem(ctx, "DONATION#1", "PAYMENT")
+	if item["valor"].(*types.AttributeValueMemberN).Value != "21" || item["status"].(*types.AttributeValueMemberS).Value != "PAGO" {
+		t.Fatalf("item inesperado: %v", item)
+	}
+	if _, ok := item["hits"]; ok {
+		t.Fatal("REMOVE nao removeu o atributo")
+	}
+}
+
+func TestMemoryStoreTransactWriteIsAtomic(t *testing.T) {
+	s := NewMemory("core")
+	ctx := context.Background()
+	if err := s.PutItem(ctx, map[string]types.AttributeValue{"PK": S("EVENT#1"), "SK": S("EVENT#1")}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := s.TransactWrite(ctx, []types.TransactWriteItem{
+		{Put: &types.Put{TableName: aws.String("core"), Item: map[string]types.AttributeValue{"PK": S("PAYMENT#1"), "SK": S("DONATION#1")}}},
+		{Put: &types.Put{
+			TableName:           aws.String("core"),
+			Item:                map[string]types.AttributeValue{"PK": S("EVENT#1"), "SK": S("EVENT#1")},
+			ConditionExpression: aws.String("attribute_not_exists(PK)"),
+		}},
+	})
+	var canceled *types.TransactionCanceledException
+	if !errors.As(err, &canceled) {
+		t.Fatalf("esperado TransactionCanceledException, veio %v", err)
+	}
+	if code := aws.ToString(canceled.CancellationReasons[1].Code); code != "ConditionalCheckFailed" {
+		t.Fatalf("motivo = %q", code)
+	}
+	if item, _ := s.GetItem(ctx, "PAYMENT#1", "DONATION#1"); item != nil {
+		t.Fatal("transacao cancelada gravou parte dos itens")
+	}
+}
@@ -0,0 +1,105 @@
+package dynamo
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Store e o acesso a tabela unica usado pelos handlers. DynamoStore fala com o
+// DynamoDB; MemoryStore (memory.go) roda em memoria para os testes.
+type Store interface {
+	TableName() string
+	GetItem(ctx context.Context, pk, sk string) (map[string]types.AttributeValue, error)
+	PutItem(ctx context.Context, item map[string]types.AttributeValue) error
+	UpdateItem(ctx context.Context, key map[string]types.AttributeValue, update string, names map[string]string, values map[string]types.AttributeValue) error
+	Query(ctx context.Context, input *dynamodb.QueryInput) (*dynamodb.QueryOutput, error)
+	Scan(ctx context.Context, input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error)
+	TransactWrite(ctx context.Context, items []types.TransactWriteItem) error
+	BatchGet(ctx context.Context, keys []map[string]types.AttributeValue) (map[string]map[string]types.AttributeValue, error)
+}
+
+type DynamoStore struct {
+	Client *dynamodb.Client
+	Table  string
+}
+
+func New(client *dynamodb.Client, table string) *DynamoStore {
+	return &DynamoStore{Client: client, Table: table}
+}
+
+func (s *DynamoStore) TableName() string {
+	return s.Table
+}
+
+func (s *DynamoStore) GetItem(ctx context.Context, pk, sk string) (map[string]types.AttributeValue, error) {
+	out, err := s.Client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: &s.Table,
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: pk},
+			"SK": &types.AttributeValueMemberS{Value: sk},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Item, nil
+}
+
+func (s *DynamoStore) PutItem(ctx context.Context, item map[string]types.AttributeValue) error {
+	_, err := s.Client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: &s.Table,
+		Item:      item,
+	})
+	return err
+}
+
+func (s *DynamoStore) UpdateItem(ctx context.Context, key map[string]types.AttributeValue, update string, names map[string]string, values map[string]types.AttributeValue) error {
+	_, err := s.Client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 &s.Table,
+		Key:                       key,
+		UpdateExpression:          &update,
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	})
+	return err
+}
+
+func (s *DynamoStore) Query(ctx context.Context, input *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+	input.TableName = &s.Table
+	return s.Client.Query(ctx, input)
+}
+
+func (s *DynamoStore) Scan(ctx context.Context, input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+	input.TableName = &s.Table
+	return s.Client.Scan(ctx, input)
+}
+
+func (s *DynamoStore) TransactWrite(ctx context.Context, items []types.TransactWriteItem) error {
+	_, err := s.Client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: items,
+	})
+	return err
+}
+
+func (s *DynamoStore) BatchGet(ctx context.Context, keys []map[string]types.AttributeValue) (map[string]map[string]types.AttributeValue, error) {
+	out, err := s.Client.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{
+		RequestItems: map[string]types.KeysAndAttributes{
+			s.Table: {
+				Keys: keys,
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	items := map[string]map[string]types.AttributeValue{}
+	for _, item := range out.Responses[s.Table] {
+		pk := item["PK"].(*types.AttributeValueMemberS).Value
+		sk := item["SK"].(*types.AttributeValueMemberS).Value
+		items[pk+"|"+sk] = item
+	}
+	return items, nil
+}
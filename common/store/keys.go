@@ -0,0 +1,106 @@
+package store
+
+import "strings"
+
+const (
+	PrefixUser          = "USER#"
+	PrefixDonation      = "DONATION#"
+	PrefixLink          = "LINK#"
+	PrefixPassword      = "PWDREC#"
+	PrefixTx            = "TX#"
+	PrefixContact       = "CONTACT#"
+	PrefixBank          = "BANK#"
+	PrefixVisualization = "VIS#"
+	PrefixPix           = "PIX#"
+	PrefixSession       = "SESSION#"
+	PrefixClient        = "CLIENT#"
+	PrefixLoginFail     = "LOGINFAIL#"
+	PrefixMFAChallenge  = "MFACHALLENGE#"
+	PrefixEdit          = "EDIT#"
+	PrefixArea          = "AREA#"
+	PrefixTerm          = "TERM#"
+	PrefixCPF           = "CPF#"
+	PrefixPost          = "POST#"
+	PrefixSubscriber    = "SUB#"
+	PrefixReconcile     = "RECONCILE#"
+	PrefixRefund        = "REFUND#"
+	PrefixLedger        = "LEDGER#"
+	PrefixLedgerRef     = "LEDGERREF#"
+
+	// ExplorePK agrupa no GSI4 todas as campanhas abertas do feed publico.
+	ExplorePK = "EXPLORE"
+	// PixOpenPK agrupa no GSI5 as cobrancas Pix ainda em acompanhamento (buscar).
+	PixOpenPK = "PIXOPEN"
+	// FeePolicyPK guarda as versoes da politica de taxas (SK V#{versao}).
+	FeePolicyPK = "FEEPOLICY"
+)
+
+func UserPK(id string) string {
+	return PrefixUser + id
+}
+
+func DonationPK(id string) string {
+	return PrefixDonation + id
+}
+
+// AreaPK e a particao do GSI3 com as campanhas abertas de uma categoria.
+func AreaPK(area string) string {
+	return PrefixArea + strings.ToLower(strings.TrimSpace(area))
+}
+
+// TermPK e a particao do indice invertido da busca para um termo ja normalizado.
+func TermPK(term string) string {
+	return PrefixTerm + term
+}
+
+func LinkPK(link string) string {
+	return PrefixLink + link
+}
+
+func PasswordPK(email string) string {
+	return PrefixPassword + strings.ToLower(email)
+}
+
+func TxPK(txid string) string {
+	return PrefixTx + txid
+}
+
+func ContactPK(id string) string {
+	return PrefixContact + id
+}
+
+func BankPK(id string) string {
+	return PrefixBank + id
+}
+
+func SessionPK(id string) string {
+	return PrefixSession + id
+}
+
+func ClientPK(id string) string {
+	return PrefixClient + id
+}
+
+func LoginFailEmailPK(email string) string {
+	return PrefixLoginFail + "EMAIL#" + strings.ToLower(email)
+}
+
+func LoginFailIPPK(ip string) string {
+	return PrefixLoginFail + "IP#" + ip
+}
+
+func LoginFailMFAPK(userID string) string {
+	return PrefixLoginFail + "MFA#" + userID
+}
+
+func MFAChallengePK(id string) string {
+	return PrefixMFAChallenge + id
+}
+
+func VisualizationPK(page string) string {
+	page = strings.TrimSpace(page)
+	if page == "" {
+		page = "UNKNOWN"
+	}
+	return PrefixVisualization + page
+}
@@ -0,0 +1,68 @@
+// Package text normaliza textos digitados pelo usuario (titulos, links e busca).
+package text
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// RemoveAccents tira os acentos mantendo as letras base ("Saúde" -> "Saude").
+func RemoveAccents(s string) string {
+	t := transform.Chain(norm.NFD, transform.RemoveFunc(isMn), norm.NFC)
+	result, _, _ := transform.String(t, s)
+	return result
+}
+
+func isMn(r rune) bool {
+	return unicode.Is(unicode.Mn, r)
+}
+
+// Digits mantem so os digitos ASCII ("123.456.789-09" -> "12345678909").
+func Digits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+// stopWords sao palavras comuns demais para ajudar na busca.
+var stopWords = map[string]struct{}{
+	"que": {}, "para": {}, "com": {}, "uma": {}, "por": {}, "dos": {}, "das": {},
+	"nos": {}, "nas": {}, "mais": {}, "como": {}, "sua": {}, "seu": {}, "pela": {},
+	"pelo": {}, "ajude": {}, "ajuda": {}, "campanha": {},
+}
+
+// Tokens quebra o texto em termos de busca: minusculos, sem acento, com pelo
+// menos 3 caracteres, sem stop words e sem repeticao, na ordem em que aparecem.
+// max limita a quantidade (0 = sem limite).
+func Tokens(s string, max int) []string {
+	folded := strings.ToLower(RemoveAccents(s))
+	words := strings.FieldsFunc(folded, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	seen := map[string]struct{}{}
+	var tokens []string
+	for _, w := range words {
+		if len([]rune(w)) < 3 {
+			continue
+		}
+		if _, ok := stopWords[w]; ok {
+			continue
+		}
+		if _, ok := seen[w]; ok {
+			continue
+		}
+		seen[w] = struct{}{}
+		tokens = append(tokens, w)
+		if max > 0 && len(tokens) == max {
+			break
+		}
+	}
+	return tokens
+}
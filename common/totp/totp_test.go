@@ -0,0 +1,85 @@
+package totp
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+// rfcSecret e a chave SHA1 do apendice B da RFC 6238 ("12345678901234567890").
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+// Os vetores da RFC tem 8 digitos; aqui ficam os 6 ultimos, que e o que Code gera.
+var rfcVectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestCodeRFC6238(t *testing.T) {
+	for _, v := range rfcVectors {
+		got, err := Code(rfcSecret, Step(time.Unix(v.unix, 0)))
+		if err != nil || got != v.code {
+			t.Errorf("Code(T=%d) = %q, %v; want %q", v.unix, got, err, v.code)
+		}
+	}
+	// Segredo digitado em minusculas e sem padding continua valido.
+	lower := "gezdgnbvgy3tqojqgezdgnbvgy3tqojq"
+	if got, err := Code(lower, Step(time.Unix(59, 0))); err != nil || got != "287082" {
+		t.Fatalf("Code(minusculas) = %q, %v", got, err)
+	}
+	if _, err := Code("nao e base32!", 1); err == nil {
+		t.Fatal("Code aceitou segredo invalido")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	code, err := Code(rfcSecret, Step(now))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if step, ok := Validate(rfcSecret, " "+code+" ", now); !ok || step != Step(now) {
+		t.Fatalf("Validate(passo atual) = %d, %v", step, ok)
+	}
+	// Um passo de tolerancia para cada lado; o passo aceito e o do codigo.
+	for _, shift := range []time.Duration{-Period * time.Second, Period * time.Second} {
+		if step, ok := Validate(rfcSecret, code, now.Add(shift)); !ok || step != Step(now) {
+			t.Errorf("Validate(%v) = %d, %v", shift, step, ok)
+		}
+	}
+	for _, shift := range []time.Duration{-2 * Period * time.Second, 2 * Period * time.Second} {
+		if _, ok := Validate(rfcSecret, code, now.Add(shift)); ok {
+			t.Errorf("Validate(%v) aceitou codigo fora da tolerancia", shift)
+		}
+	}
+	for _, bad := range []string{"", "12345", "1234567", "000000"} {
+		if _, ok := Validate(rfcSecret, bad, now); ok {
+			t.Errorf("Validate(%q) aceitou", bad)
+		}
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(RecoveryCodeCount)
+	if err != nil || len(codes) != RecoveryCodeCount {
+		t.Fatalf("GenerateRecoveryCodes = %v, %v", codes, err)
+	}
+	seen := map[string]bool{}
+	for _, c := range codes {
+		if len(c) != 11 || c[5] != '-' || seen[c] {
+			t.Fatalf("codigo inesperado %q em %v", c, codes)
+		}
+		seen[c] = true
+	}
+	if HashRecoveryCode("ABCDE-fghij") != HashRecoveryCode(" abcde fghij") {
+		t.Fatal("HashRecoveryCode nao normalizou caixa, hifen e espacos")
+	}
+}
@@ -4,15 +4,15 @@ import (
 	"context"
 	"fmt"
 
-	"BACK_SORTE_GO/config"
-	"BACK_SORTE_GO/internal/store/dynamo"
+	"BACK_SORTE_GO/common/config"
+	"BACK_SORTE_GO/common/store/dynamo"
 
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 )
 
 type App struct {
-	Store *dynamo.Store
+	Store dynamo.Store
 }
 
 func New(ctx context.Context) (*App, error) {
@@ -1,8 +1,8 @@
 package contact
 
 import (
-	"BACK_SORTE_GO/internal/store"
-	"BACK_SORTE_GO/internal/store/dynamo"
+	"BACK_SORTE_GO/common/store"
+	"BACK_SORTE_GO/common/store/dynamo"
 	"encoding/json"
 	"net/http"
 	"strings"
@@ -42,7 +42,7 @@ func ContactHealthHandler() http.HandlerFunc {
 	}
 }
 
-func ContactVisualizationHandler(storeDDB *dynamo.Store) http.HandlerFunc {
+func ContactVisualizationHandler(storeDDB dynamo.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req ContactVisualizationRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -100,7 +100,7 @@ func ContactVisualizationHandler(storeDDB *dynamo.Store) http.HandlerFunc {
 	}
 }
 
-func ContactMensagemHandler(storeDDB *dynamo.Store) http.HandlerFunc {
+func ContactMensagemHandler(storeDDB dynamo.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req ContactRequest
 
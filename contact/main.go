@@ -5,9 +5,9 @@ import (
 	"log"
 	"net/http"
 
+	"BACK_SORTE_GO/common/middleware"
 	"BACK_SORTE_GO/internal/app"
 	"BACK_SORTE_GO/internal/contact"
-	"BACK_SORTE_GO/internal/middleware"
 
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/awslabs/aws-lambda-go-api-proxy/httpadapter"
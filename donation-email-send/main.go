@@ -29,6 +29,9 @@ import (
 const (
 	emailTypeDonationCreated = "email-cadastro-doacao"
 	emailTypeEmailVerify     = "email-validar-email-usuario"
+	emailTypeAccountLocked   = "email-conta-bloqueada"
+	emailTypeDonationPost    = "email-novidade-doacao"
+	emailTypeDonationEnded   = "email-doacao-encerrada"
 
 	pendingPK = "EMAIL#PENDING"
 	pendingSK = "TS#"
@@ -42,6 +45,8 @@ type emailEvent struct {
 	DonationID     string `json:"donation_id"`
 	DonationName   string `json:"donation_name"`
 	DonationLink   string `json:"donation_link"`
+	LockedUntil    string `json:"locked_until,omitempty"`
+	PostTexto      string `json:"post_texto,omitempty"`
 	CreatedAt      string `json:"created_at"`
 }
 
@@ -348,6 +353,20 @@ func buildEmailContent(ctx context.Context, payload emailEvent) (string, string,
 		)
 		return subject, body, nil
 
+	case emailTypeAccountLocked:
+		until := "alguns minutos"
+		if t, err := time.Parse(time.RFC3339, payload.LockedUntil); err == nil {
+			until = t.In(time.FixedZone("BRT", -3*60*60)).Format("02/01/2006 15:04") + " (horario de Brasilia)"
+		}
+		subject := "Acesso bloqueado temporariamente - The Pure Grace"
+		body := fmt.Sprintf(
+			"Oi %s,\n\nDetectamos varias tentativas de login sem sucesso na sua conta e bloqueamos o acesso temporariamente.\n\nVoce podera entrar novamente a partir de %s.\n\nSe nao foi voce, recomendamos trocar sua senha em:\n%s\n\nEquipe The Pure Grace",
+			emptyIf(payload.RecipientName, "usuario"),
+			until,
+			cfg.appBaseURL,
+		)
+		return subject, body, nil
+
 	case emailTypeDonationCreated:
 		subject := "Sua doacao foi criada com sucesso"
 		body := fmt.Sprintf(
@@ -358,6 +377,29 @@ func buildEmailContent(ctx context.Context, payload emailEvent) (string, string,
 			whatsURL,
 		)
 		return subject, body, nil
+	case emailTypeDonationPost:
+		resumo := strings.TrimSpace(payload.PostTexto)
+		if r := []rune(resumo); len(r) > 280 {
+			resumo = string(r[:280]) + "..."
+		}
+		subject := fmt.Sprintf("Novidade na campanha %s", emptyIf(payload.DonationName, "que voce apoiou"))
+		body := fmt.Sprintf(
+			"Oi %s,\n\nA campanha \"%s\" que voce apoiou publicou uma novidade:\n\n%s\n\nVeja a atualizacao completa:\n%s\n\nEquipe The Pure Grace",
+			emptyIf(payload.RecipientName, "doador"),
+			emptyIf(payload.DonationName, "Minha doacao"),
+			resumo,
+			link,
+		)
+		return subject, body, nil
+	case emailTypeDonationEnded:
+		subject := "Sua campanha foi encerrada"
+		body := fmt.Sprintf(
+			"Oi %s,\n\nSua campanha \"%s\" chegou a data de encerramento e nao recebe mais doacoes.\n\nLink da campanha:\n%s\n\nFaca login no sistema para acompanhar o total arrecadado e solicitar o resgate.\n\nEquipe The Pure Grace",
+			emptyIf(payload.RecipientName, "usuario"),
+			emptyIf(payload.DonationName, "Minha doacao"),
+			link,
+		)
+		return subject, body, nil
 	default:
 		return "", "", fmt.Errorf("tipo de email desconhecido: %s", payload.Type)
 	}
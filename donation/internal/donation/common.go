@@ -1,65 +1,127 @@
 package donation
 
 import (
-	"BACK_SORTE_GO/internal/store"
-	"BACK_SORTE_GO/internal/store/dynamo"
+	"BACK_SORTE_GO/common/repo"
+	"BACK_SORTE_GO/common/store/dynamo"
+	"BACK_SORTE_GO/common/text"
 	"context"
+	"errors"
 	"fmt"
-	"math/rand"
 	"regexp"
 	"strings"
 	"time"
-	"unicode"
+)
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
-	"golang.org/x/text/transform"
-	"golang.org/x/text/unicode/norm"
+const (
+	linkMinLen = 3
+	linkMaxLen = 30
+	// maxLinkCandidates limita os sufixos tentados (@base, @base_2 ... @base_20).
+	maxLinkCandidates = 20
 )
 
-var jwtSecretKey1 = []byte("SUA_CHAVE_SECRETA")
+var (
+	linkPattern    = regexp.MustCompile(`^[a-z0-9_]+$`)
+	nonSlugPattern = regexp.MustCompile(`[^a-z0-9_]+`)
+)
 
-func removeAccents(s string) string {
-	t := transform.Chain(norm.NFD, transform.RemoveFunc(isMn), norm.NFC)
-	result, _, _ := transform.String(t, s)
-	return result
+// reservedLinks sao nomes que colidem com rotas do site ou confundem o doador.
+var reservedLinks = map[string]struct{}{
+	"admin": {}, "administrador": {}, "api": {}, "app": {}, "ajuda": {}, "help": {},
+	"login": {}, "logout": {}, "cadastro": {}, "signup": {}, "conta": {}, "perfil": {},
+	"user": {}, "users": {}, "usuario": {}, "donation": {}, "doacao": {}, "doacoes": {},
+	"pix": {}, "pagamento": {}, "payments": {}, "suporte": {}, "support": {}, "contato": {},
+	"contact": {}, "sobre": {}, "termos": {}, "privacidade": {}, "explore": {}, "search": {},
+	"busca": {}, "oficial": {}, "thepuregrace": {}, "puregrace": {},
 }
 
-func isMn(r rune) bool {
-	return unicode.Is(unicode.Mn, r)
+// brt e o fuso de Brasilia (sem horario de verao desde 2019), usado quando a
+// date_end vem so com o dia.
+var brt = time.FixedZone("BRT", -3*60*60)
+
+var errDateEndInvalid = errors.New("date_end deve ser uma data futura (AAAA-MM-DD ou RFC3339)")
+
+// parseDateEnd valida a data de encerramento e a devolve em RFC3339 UTC, o
+// formato comparado pelo job de encerramento. So o dia vale ate 23:59:59 BRT.
+func parseDateEnd(raw string, now time.Time) (string, error) {
+	raw = strings.TrimSpace(raw)
+	end, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		day, dayErr := time.ParseInLocation(time.DateOnly, raw, brt)
+		if dayErr != nil {
+			return "", errDateEndInvalid
+		}
+		end = day.Add(24*time.Hour - time.Second)
+	}
+	if !end.After(now) {
+		return "", errDateEndInvalid
+	}
+	return end.UTC().Format(time.RFC3339), nil
 }
 
-func generateUniqueLinkName(storeDDB *dynamo.Store, base string) (string, error) {
-	base = strings.ToLower(base)
-	base = removeAccents(base)
+var errLinkInvalid = fmt.Errorf("nome_link deve ter de %d a %d caracteres entre letras minusculas, numeros e _", linkMinLen, linkMaxLen)
+var errLinkReserved = errors.New("nome_link reservado")
+
+// slugify reduz o titulo a letras, numeros e _ sem acentos.
+func slugify(title string) string {
+	base := strings.ToLower(text.RemoveAccents(title))
 	base = strings.ReplaceAll(base, " ", "_")
+	return nonSlugPattern.ReplaceAllString(base, "")
+}
 
-	re := regexp.MustCompile(`[^a-z0-9_]+`)
-	base = re.ReplaceAllString(base, "")
+// validateCustomLink confere o nome escolhido pelo dono e devolve com o @.
+func validateCustomLink(raw string) (string, error) {
+	name := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(raw)), "@")
+	if len(name) < linkMinLen || len(name) > linkMaxLen || !linkPattern.MatchString(name) {
+		return "", errLinkInvalid
+	}
+	if _, ok := reservedLinks[name]; ok {
+		return "", errLinkReserved
+	}
+	return "@" + name, nil
+}
 
-	link := "@" + base
-	finalLink := link
+// linkCandidates gera, a partir do titulo, os nomes tentados em ordem fixa:
+// @base, @base_2, @base_3... Base curta demais ganha o prefixo campanha_ e base
+// reservada comeca direto no sufixo.
+func linkCandidates(title string) []string {
+	base := strings.Trim(slugify(title), "_")
+	if len(base) < linkMinLen {
+		base = strings.TrimSuffix("campanha_"+base, "_")
+	}
+	// deixa espaco para o maior sufixo dentro do limite
+	if limit := linkMaxLen - len(fmt.Sprintf("_%d", maxLinkCandidates)); len(base) > limit {
+		base = strings.TrimRight(base[:limit], "_")
+	}
 
-	letters := "abcdefghijklmnopqrstuvwxyz"
-	rand.Seed(time.Now().UnixNano())
+	candidates := make([]string, 0, maxLinkCandidates)
+	if _, reserved := reservedLinks[base]; !reserved {
+		candidates = append(candidates, "@"+base)
+	}
+	for i := 2; i <= maxLinkCandidates; i++ {
+		candidates = append(candidates, fmt.Sprintf("@%s_%d", base, i))
+	}
+	return candidates
+}
 
-	for {
-		out, err := storeDDB.Query(context.Background(), &dynamodb.QueryInput{
-			KeyConditionExpression: aws.String("PK = :pk"),
-			ExpressionAttributeValues: map[string]types.AttributeValue{
-				":pk": dynamo.S(store.LinkPK(finalLink)),
-			},
-			Limit: aws.Int32(1),
-		})
-		if err != nil {
+// createWithLink tenta os candidatos em ordem: o nome ja registrado e pulado e
+// a corrida entre duas criacoes e resolvida pelo put condicional do LINK# dentro
+// de create, que devolve repo.ErrLinkTaken para o perdedor.
+func createWithLink(ctx context.Context, storeDDB dynamo.Store, candidates []string, create func(nomeLink string) error) (string, error) {
+	donations := repo.NewDonationRepo(storeDDB)
+	for _, candidate := range candidates {
+		if _, err := donations.FindLink(ctx, candidate); err == nil {
+			continue
+		} else if !errors.Is(err, repo.ErrNotFound) {
 			return "", err
 		}
-		if len(out.Items) == 0 {
-			break
+		err := create(candidate)
+		if errors.Is(err, repo.ErrLinkTaken) {
+			continue
 		}
-		finalLink = fmt.Sprintf("%s_%c", link, letters[rand.Intn(len(letters))])
+		if err != nil {
+			return "", err
+		}
+		return candidate, nil
 	}
-
-	return finalLink, nil
+	return "", repo.ErrLinkTaken
 }
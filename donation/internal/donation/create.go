@@ -1,45 +1,29 @@
 package donation
 
 import (
-	"BACK_SORTE_GO/config"
-	"BACK_SORTE_GO/internal/store"
-	"BACK_SORTE_GO/internal/store/dynamo"
+	"BACK_SORTE_GO/common/config"
+	"BACK_SORTE_GO/common/middleware"
+	"BACK_SORTE_GO/common/money"
+	"BACK_SORTE_GO/common/repo"
+	"BACK_SORTE_GO/common/store/dynamo"
 	"BACK_SORTE_GO/utils"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
-	"strconv"
-	"strings"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
-	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
-func DonationHandler(storeDDB *dynamo.Store) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			http.Error(w, "Token nao fornecido", http.StatusUnauthorized)
-			return
-		}
+// uploadImage envia a imagem da campanha ao S3; nos testes e trocada por um stub.
+var uploadImage = utils.UploadToS3
 
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		claims := jwt.MapClaims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return jwtSecretKey1, nil
-		})
-		if err != nil || !token.Valid {
-			http.Error(w, "Token invalido", http.StatusUnauthorized)
-			return
-		}
-
-		idUser, ok := claims["sub"].(string)
-		if !ok || idUser == "" {
+func DonationHandler(storeDDB dynamo.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idUser := middleware.UserIDFromContext(r.Context())
+		if idUser == "" {
 			http.Error(w, "ID do usuario invalido", http.StatusUnauthorized)
 			return
 		}
@@ -59,12 +43,24 @@ func DonationHandler(storeDDB *dynamo.Store) http.HandlerFunc {
 			return
 		}
 
-		valor, err := strconv.ParseFloat(valorStr, 64)
-		if err != nil || valor <= 0 {
+		valor, err := money.Parse(valorStr)
+		if err != nil || valor.Centavos <= 0 {
 			http.Error(w, "Valor invalido", http.StatusBadRequest)
 			return
 		}
 
+		candidates, err := requestedLinkCandidates(r, name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		dateEnd, err := requestedDateEnd(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
 		file, handler, err := r.FormFile("image")
 		if err != nil {
 			http.Error(w, "Imagem obrigatoria", http.StatusBadRequest)
@@ -73,7 +69,7 @@ func DonationHandler(storeDDB *dynamo.Store) http.HandlerFunc {
 		defer file.Close()
 
 		imgFileName := fmt.Sprintf("%s_%d_%s", idUser, time.Now().Unix(), handler.Filename)
-		imgPath, err := utils.UploadToS3(file, imgFileName, config.GetawsBucketNameImgDoacao())
+		imgPath, err := uploadImage(file, imgFileName, config.GetawsBucketNameImgDoacao())
 		if err != nil {
 			http.Error(w, "Erro ao subir imagem: "+err.Error(), http.StatusInternalServerError)
 			return
@@ -81,68 +77,16 @@ func DonationHandler(storeDDB *dynamo.Store) http.HandlerFunc {
 
 		donationID := uuid.NewString()
 		now := time.Now().Format(time.RFC3339)
-		nomeLink, err := generateUniqueLinkName(storeDDB, name)
-		if err != nil {
-			http.Error(w, "Erro ao gerar nome_link: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		donationItem := map[string]types.AttributeValue{
-			"PK":          dynamo.S(store.DonationPK(donationID)),
-			"SK":          dynamo.S("PROFILE"),
-			"GSI1PK":      dynamo.S(store.UserPK(idUser)),
-			"GSI1SK":      dynamo.S("DONATION#" + now + "#" + donationID),
-			"id":          dynamo.S(donationID),
-			"id_user":     dynamo.S(idUser),
-			"name":        dynamo.S(name),
-			"valor":       dynamo.N(fmt.Sprintf("%.2f", valor)),
-			"active":      dynamo.B(true),
-			"dell":        dynamo.B(false),
-			"closed":      dynamo.B(false),
-			"date_start":  dynamo.S(now),
-			"date_create": dynamo.S(now),
-			"date_update": dynamo.S(""),
-			"nome_link":   dynamo.S(nomeLink),
-		}
-
-		detailsItem := map[string]types.AttributeValue{
-			"PK":          dynamo.S(store.DonationPK(donationID)),
-			"SK":          dynamo.S("DETAILS"),
-			"id":          dynamo.S(uuid.NewString()),
-			"id_doacao":   dynamo.S(donationID),
-			"texto":       dynamo.S(texto),
-			"img_caminho": dynamo.S(imgPath),
-			"area":        dynamo.S(area),
-		}
-
-		linkItem := map[string]types.AttributeValue{
-			"PK":        dynamo.S(store.LinkPK(nomeLink)),
-			"SK":        dynamo.S("DONATION#" + donationID),
-			"id":        dynamo.S(uuid.NewString()),
-			"id_doacao": dynamo.S(donationID),
-			"nome_link": dynamo.S(nomeLink),
-			"id_user":   dynamo.S(idUser),
-		}
-
-		paymentItem := map[string]types.AttributeValue{
-			"PK":               dynamo.S(store.DonationPK(donationID)),
-			"SK":               dynamo.S("PAYMENT"),
-			"id":               dynamo.S(uuid.NewString()),
-			"id_doacao":        dynamo.S(donationID),
-			"valor_disponivel": dynamo.N("0"),
-			"valor_tranferido": dynamo.N("0"),
-			"solicitado":       dynamo.B(false),
-			"status":           dynamo.S("START"),
-			"data_update":      dynamo.S(now),
-		}
-
 		ctx := r.Context()
-		err = storeDDB.TransactWrite(ctx, []types.TransactWriteItem{
-			{Put: &types.Put{TableName: &storeDDB.Table, Item: donationItem}},
-			{Put: &types.Put{TableName: &storeDDB.Table, Item: detailsItem}},
-			{Put: &types.Put{TableName: &storeDDB.Table, Item: linkItem}},
-			{Put: &types.Put{TableName: &storeDDB.Table, Item: paymentItem}},
+		nomeLink, err := createWithLink(ctx, storeDDB, candidates, func(nomeLink string) error {
+			donation := newDonation(donationID, idUser, name, valor, texto, imgPath, area, nomeLink, now)
+			donation.Profile.DateEnd = dateEnd
+			return repo.NewDonationRepo(storeDDB).Create(ctx, donation)
 		})
+		if errors.Is(err, repo.ErrLinkTaken) {
+			http.Error(w, "nome_link ja em uso", http.StatusConflict)
+			return
+		}
 		if err != nil {
 			http.Error(w, "Erro ao salvar doacao: "+err.Error(), http.StatusInternalServerError)
 			return
@@ -168,7 +112,7 @@ func DonationHandler(storeDDB *dynamo.Store) http.HandlerFunc {
 	}
 }
 
-func DonationCreateSimpleHandler(storeDDB *dynamo.Store) http.HandlerFunc {
+func DonationCreateSimpleHandler(storeDDB dynamo.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if err := r.ParseMultipartForm(10 << 20); err != nil {
 			http.Error(w, "Erro ao ler formulario: "+err.Error(), http.StatusBadRequest)
@@ -197,58 +141,33 @@ func DonationCreateSimpleHandler(storeDDB *dynamo.Store) http.HandlerFunc {
 		}
 
 		ctx := r.Context()
-		existsOut, err := storeDDB.Query(ctx, &dynamodb.QueryInput{
-			IndexName:              aws.String("GSI2"),
-			KeyConditionExpression: aws.String("GSI2PK = :pk"),
-			ExpressionAttributeValues: map[string]types.AttributeValue{
-				":pk": dynamo.S("EMAIL#" + strings.ToLower(email)),
-			},
-			Limit: aws.Int32(1),
-		})
-		if err != nil {
-			http.Error(w, "Erro ao verificar email: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		if len(existsOut.Items) > 0 {
+		users := repo.NewUserRepo(storeDDB)
+		if _, err := users.FindByEmail(ctx, email); err == nil {
 			http.Error(w, "Email ja cadastrado", http.StatusBadRequest)
 			return
+		} else if !errors.Is(err, repo.ErrNotFound) {
+			http.Error(w, "Erro ao verificar email: "+err.Error(), http.StatusInternalServerError)
+			return
 		}
 
 		userID := uuid.NewString()
 		now := time.Now().Format(time.RFC3339)
 
-		userItem := map[string]types.AttributeValue{
-			"PK":          dynamo.S(store.UserPK(userID)),
-			"SK":          dynamo.S("PROFILE"),
-			"GSI2PK":      dynamo.S("EMAIL#" + strings.ToLower(email)),
-			"GSI2SK":      dynamo.S(store.UserPK(userID)),
-			"id":          dynamo.S(userID),
-			"name":        dynamo.S(fullName),
-			"email":       dynamo.S(email),
-			"password":    dynamo.S(string(hashedPassword)),
-			"cpf":         dynamo.S(cpf),
-			"active":      dynamo.B(true),
-			"inicial":     dynamo.B(false),
-			"dell":        dynamo.B(false),
-			"date_create": dynamo.S(now),
-			"date_update": dynamo.S(now),
+		meta, err := money.Parse(metaStr)
+		if err != nil || meta.Centavos <= 0 {
+			http.Error(w, "Meta invalida", http.StatusBadRequest)
+			return
 		}
 
-		contaNivelItem := map[string]types.AttributeValue{
-			"PK":             dynamo.S(store.UserPK(userID)),
-			"SK":             dynamo.S("ACCOUNT#LEVEL"),
-			"id":             dynamo.S(uuid.NewString()),
-			"id_user":        dynamo.S(userID),
-			"nivel":          dynamo.S("BASICO"),
-			"ativo":          dynamo.B(false),
-			"status":         dynamo.S("INATIVO"),
-			"tipo_pagamento": dynamo.S("INATIVO"),
-			"data_update":    dynamo.S(now),
+		candidates, err := requestedLinkCandidates(r, titulo)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
 
-		meta, err := utils.StringToFloat(metaStr)
+		dateEnd, err := requestedDateEnd(r)
 		if err != nil {
-			http.Error(w, "Meta invalida", http.StatusBadRequest)
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
@@ -260,76 +179,54 @@ func DonationCreateSimpleHandler(storeDDB *dynamo.Store) http.HandlerFunc {
 		defer file.Close()
 
 		imgFileName := fmt.Sprintf("%s_%d_%s", userID, time.Now().Unix(), header.Filename)
-		imgPath, err := utils.UploadToS3(file, imgFileName, config.GetawsBucketNameImgDoacao())
+		imgPath, err := uploadImage(file, imgFileName, config.GetawsBucketNameImgDoacao())
 		if err != nil {
 			http.Error(w, "Erro ao subir imagem: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		donationID := uuid.NewString()
-		nomeLink, err := generateUniqueLinkName(storeDDB, titulo)
+		userItems, err := users.CreateItems(repo.User{
+			ID:           userID,
+			Name:         fullName,
+			Email:        email,
+			PasswordHash: string(hashedPassword),
+			CPF:          cpf,
+			Active:       true,
+			DateCreate:   now,
+			DateUpdate:   now,
+		}, repo.AccountLevel{
+			ID:            uuid.NewString(),
+			IDUser:        userID,
+			Nivel:         repo.AccountLevelBasico,
+			Status:        "INATIVO",
+			TipoPagamento: "INATIVO",
+			DataUpdate:    now,
+		}, nil)
 		if err != nil {
-			http.Error(w, "Erro ao gerar nome_link: "+err.Error(), http.StatusInternalServerError)
+			http.Error(w, "Erro ao salvar dados: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		donationItem := map[string]types.AttributeValue{
-			"PK":          dynamo.S(store.DonationPK(donationID)),
-			"SK":          dynamo.S("PROFILE"),
-			"GSI1PK":      dynamo.S(store.UserPK(userID)),
-			"GSI1SK":      dynamo.S("DONATION#" + now + "#" + donationID),
-			"id":          dynamo.S(donationID),
-			"id_user":     dynamo.S(userID),
-			"name":        dynamo.S(titulo),
-			"valor":       dynamo.N(fmt.Sprintf("%.2f", meta)),
-			"active":      dynamo.B(true),
-			"dell":        dynamo.B(false),
-			"closed":      dynamo.B(false),
-			"date_start":  dynamo.S(now),
-			"date_create": dynamo.S(now),
-			"date_update": dynamo.S(now),
-			"nome_link":   dynamo.S(nomeLink),
-		}
-
-		detailsItem := map[string]types.AttributeValue{
-			"PK":          dynamo.S(store.DonationPK(donationID)),
-			"SK":          dynamo.S("DETAILS"),
-			"id":          dynamo.S(uuid.NewString()),
-			"id_doacao":   dynamo.S(donationID),
-			"texto":       dynamo.S(texto),
-			"img_caminho": dynamo.S(imgPath),
-			"area":        dynamo.S(categoria),
-		}
-
-		linkItem := map[string]types.AttributeValue{
-			"PK":        dynamo.S(store.LinkPK(nomeLink)),
-			"SK":        dynamo.S("DONATION#" + donationID),
-			"id":        dynamo.S(uuid.NewString()),
-			"id_doacao": dynamo.S(donationID),
-			"nome_link": dynamo.S(nomeLink),
-			"id_user":   dynamo.S(userID),
-		}
-
-		paymentItem := map[string]types.AttributeValue{
-			"PK":               dynamo.S(store.DonationPK(donationID)),
-			"SK":               dynamo.S("PAYMENT"),
-			"id":               dynamo.S(uuid.NewString()),
-			"id_doacao":        dynamo.S(donationID),
-			"valor_disponivel": dynamo.N("0"),
-			"valor_tranferido": dynamo.N("0"),
-			"solicitado":       dynamo.B(false),
-			"status":           dynamo.S("START"),
-			"data_update":      dynamo.S(now),
-		}
-
-		err = storeDDB.TransactWrite(ctx, []types.TransactWriteItem{
-			{Put: &types.Put{TableName: &storeDDB.Table, Item: userItem}},
-			{Put: &types.Put{TableName: &storeDDB.Table, Item: contaNivelItem}},
-			{Put: &types.Put{TableName: &storeDDB.Table, Item: donationItem}},
-			{Put: &types.Put{TableName: &storeDDB.Table, Item: detailsItem}},
-			{Put: &types.Put{TableName: &storeDDB.Table, Item: linkItem}},
-			{Put: &types.Put{TableName: &storeDDB.Table, Item: paymentItem}},
+		donationID := uuid.NewString()
+		nomeLink, err := createWithLink(ctx, storeDDB, candidates, func(nomeLink string) error {
+			donation := newDonation(donationID, userID, titulo, meta, texto, imgPath, categoria, nomeLink, now)
+			donation.Profile.DateUpdate = now
+			donation.Profile.DateEnd = dateEnd
+			donationItems, err := repo.NewDonationRepo(storeDDB).CreateItems(donation)
+			if err != nil {
+				return err
+			}
+			// o unico item condicional da transacao e o LINK#
+			err = repo.Transact(ctx, storeDDB, userItems, donationItems)
+			if dynamo.IsConditionFailed(err) {
+				return repo.ErrLinkTaken
+			}
+			return err
 		})
+		if errors.Is(err, repo.ErrLinkTaken) {
+			http.Error(w, "nome_link ja em uso", http.StatusConflict)
+			return
+		}
 		if err != nil {
 			http.Error(w, "Erro ao salvar dados: "+err.Error(), http.StatusInternalServerError)
 			return
@@ -353,3 +250,61 @@ func DonationCreateSimpleHandler(storeDDB *dynamo.Store) http.HandlerFunc {
 		})
 	}
 }
+
+// requestedLinkCandidates usa o nome_link escolhido no formulario, se houver;
+// senao gera os candidatos a partir do titulo.
+func requestedLinkCandidates(r *http.Request, title string) ([]string, error) {
+	custom := r.FormValue("nome_link")
+	if custom == "" {
+		return linkCandidates(title), nil
+	}
+	nomeLink, err := validateCustomLink(custom)
+	if err != nil {
+		return nil, err
+	}
+	return []string{nomeLink}, nil
+}
+
+// requestedDateEnd le a date_end opcional do formulario de criacao.
+func requestedDateEnd(r *http.Request) (string, error) {
+	raw := r.FormValue("date_end")
+	if raw == "" {
+		return "", nil
+	}
+	return parseDateEnd(raw, time.Now())
+}
+
+// newDonation monta os itens de uma campanha nova, ainda sem arrecadacao.
+func newDonation(id, idUser, name string, valor money.Money, texto, imgPath, area, nomeLink, now string) repo.NewDonation {
+	return repo.NewDonation{
+		Profile: repo.Donation{
+			ID:         id,
+			IDUser:     idUser,
+			Name:       name,
+			Valor:      valor,
+			Active:     true,
+			DateStart:  now,
+			DateCreate: now,
+			NomeLink:   nomeLink,
+		},
+		Details: repo.DonationDetails{
+			ID:         uuid.NewString(),
+			IDDoacao:   id,
+			Texto:      texto,
+			ImgCaminho: imgPath,
+			Area:       area,
+		},
+		Link: repo.DonationLink{
+			ID:       uuid.NewString(),
+			IDDoacao: id,
+			NomeLink: nomeLink,
+			IDUser:   idUser,
+		},
+		Payment: repo.DonationPayment{
+			ID:         uuid.NewString(),
+			IDDoacao:   id,
+			Status:     "START",
+			DataUpdate: now,
+		},
+	}
+}
@@ -0,0 +1,151 @@
+package donation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"BACK_SORTE_GO/common/middleware"
+	"BACK_SORTE_GO/common/store"
+	"BACK_SORTE_GO/common/store/dynamo"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func stubUpload(t *testing.T) {
+	t.Helper()
+	original := uploadImage
+	uploadImage = func(_ multipart.File, filename, _ string) (string, error) {
+		return "https://bucket.test/doacoes/" + filename, nil
+	}
+	t.Cleanup(func() { uploadImage = original })
+}
+
+func multipartRequest(t *testing.T, target string, fields map[string]string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for k, v := range fields {
+		if err := mw.WriteField(k, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	part, err := mw.CreateFormFile("image", "capa.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write([]byte("png"))
+	mw.Close()
+
+	r := httptest.NewRequest(http.MethodPost, target, &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return r
+}
+
+func createDonation(t *testing.T, storeDDB dynamo.Store, userID, name string) map[string]string {
+	t.Helper()
+	r := multipartRequest(t, "/donation", map[string]string{
+		"name":  name,
+		"valor": "1500",
+		"texto": "Ajude no tratamento",
+		"area":  "saude",
+	})
+	r = r.WithContext(middleware.WithPrincipal(r.Context(), middleware.Principal{UserID: userID}))
+
+	w := httptest.NewRecorder()
+	DonationHandler(storeDDB)(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+	var resp map[string]string
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestDonationHandler(t *testing.T) {
+	stubUpload(t)
+	storeDDB := dynamo.NewMemory("core")
+	ctx := context.Background()
+
+	first := createDonation(t, storeDDB, "u-1", "Ajuda à Maria")
+	if first["nome_link"] != "@ajuda_a_maria" {
+		t.Fatalf("nome_link = %q", first["nome_link"])
+	}
+
+	for _, sk := range []string{"PROFILE", "DETAILS", "PAYMENT"} {
+		item, err := storeDDB.GetItem(ctx, store.DonationPK(first["id"]), sk)
+		if err != nil || len(item) == 0 {
+			t.Fatalf("item %s nao gravado: %v", sk, err)
+		}
+	}
+	link, _ := storeDDB.GetItem(ctx, store.LinkPK(first["nome_link"]), "LINK")
+	if len(link) == 0 {
+		t.Fatal("LINK nao gravado")
+	}
+
+	// Mesmo titulo ganha o sufixo seguinte; a listagem do dono vem pelo GSI1.
+	second := createDonation(t, storeDDB, "u-1", "Ajuda à Maria")
+	if second["nome_link"] != "@ajuda_a_maria_2" {
+		t.Fatalf("nome_link = %q", second["nome_link"])
+	}
+	out, err := storeDDB.Query(ctx, &dynamodb.QueryInput{
+		IndexName:              aws.String("GSI1"),
+		KeyConditionExpression: aws.String("GSI1PK = :pk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": dynamo.S(store.UserPK("u-1")),
+		},
+	})
+	if err != nil || len(out.Items) != 2 {
+		t.Fatalf("GSI1: %d itens, err %v", len(out.Items), err)
+	}
+}
+
+func TestDonationHandlerRequiresUser(t *testing.T) {
+	stubUpload(t)
+	storeDDB := dynamo.NewMemory("core")
+
+	r := multipartRequest(t, "/donation", map[string]string{"name": "x", "valor": "1", "texto": "x", "area": "x"})
+	w := httptest.NewRecorder()
+	DonationHandler(storeDDB)(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("status %d, esperado 401", w.Code)
+	}
+}
+
+func TestDonationCreateSimpleHandlerRejectsKnownEmail(t *testing.T) {
+	stubUpload(t)
+	storeDDB := dynamo.NewMemory("core")
+	err := storeDDB.PutItem(context.Background(), map[string]types.AttributeValue{
+		"PK":     dynamo.S(store.UserPK("u-1")),
+		"SK":     dynamo.S("PROFILE"),
+		"GSI2PK": dynamo.S("EMAIL#maria@example.com"),
+		"GSI2SK": dynamo.S(store.UserPK("u-1")),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := multipartRequest(t, "/donation/createUserAndDonation", map[string]string{
+		"fullName":  "Maria",
+		"cpf":       "12345678909",
+		"email":     "Maria@Example.com",
+		"senha":     "senha",
+		"titulo":    "Campanha",
+		"meta":      "100,50",
+		"categoria": "saude",
+		"texto":     "texto",
+	})
+	w := httptest.NewRecorder()
+	DonationCreateSimpleHandler(storeDDB)(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status %d, esperado 400", w.Code)
+	}
+}
@@ -0,0 +1,215 @@
+package donation
+
+import (
+	"BACK_SORTE_GO/common/config"
+	"BACK_SORTE_GO/common/middleware"
+	"BACK_SORTE_GO/common/money"
+	"BACK_SORTE_GO/common/repo"
+	"BACK_SORTE_GO/common/store/dynamo"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// DonationEditHandler altera titulo, meta, texto, area, imagem e data de
+// encerramento da campanha. Aceita o mesmo formulario da criacao com os campos
+// opcionais e exige a `version` lida pelo dono; se a campanha mudou nesse meio
+// tempo responde 409.
+func DonationEditHandler(storeDDB dynamo.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idUser := middleware.UserIDFromContext(r.Context())
+		if idUser == "" {
+			http.Error(w, "ID do usuario invalido", http.StatusUnauthorized)
+			return
+		}
+
+		donationID := mux.Vars(r)["id"]
+		if donationID == "" {
+			http.Error(w, "ID da doacao e obrigatorio na URL", http.StatusBadRequest)
+			return
+		}
+
+		if err := r.ParseMultipartForm(10 << 20); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+			http.Error(w, "Erro ao ler formulario", http.StatusBadRequest)
+			return
+		}
+
+		version, err := strconv.ParseInt(r.FormValue("version"), 10, 64)
+		if err != nil || version < 0 {
+			http.Error(w, "Campo 'version' obrigatorio", http.StatusBadRequest)
+			return
+		}
+
+		var changes repo.DonationChanges
+		for field, target := range map[string]**string{"name": &changes.Name, "texto": &changes.Texto, "area": &changes.Area} {
+			if _, ok := r.Form[field]; !ok {
+				continue
+			}
+			value := strings.TrimSpace(r.FormValue(field))
+			if value == "" {
+				http.Error(w, fmt.Sprintf("Campo '%s' nao pode ser vazio", field), http.StatusBadRequest)
+				return
+			}
+			*target = &value
+		}
+		// date_end vazia remove o encerramento automatico.
+		if _, ok := r.Form["date_end"]; ok {
+			dateEnd := ""
+			if raw := r.FormValue("date_end"); raw != "" {
+				if dateEnd, err = parseDateEnd(raw, time.Now()); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+			changes.DateEnd = &dateEnd
+		}
+		if valorStr := r.FormValue("valor"); valorStr != "" {
+			valor, err := money.Parse(valorStr)
+			if err != nil || valor.Centavos <= 0 {
+				http.Error(w, "Valor invalido", http.StatusBadRequest)
+				return
+			}
+			changes.Valor = &valor
+		}
+
+		ctx := r.Context()
+		donations := repo.NewDonationRepo(storeDDB)
+		donation, err := donations.Get(ctx, donationID)
+		if err != nil || donation.Dell {
+			http.Error(w, "Doacao nao encontrada", http.StatusNotFound)
+			return
+		}
+		if donation.IDUser != idUser {
+			http.Error(w, "Usuario nao autorizado a editar esta doacao", http.StatusForbidden)
+			return
+		}
+		if donation.Version != version {
+			http.Error(w, "Doacao alterada por outra requisicao, recarregue e tente novamente", http.StatusConflict)
+			return
+		}
+
+		// A imagem so sobe depois das validacoes para nao deixar arquivo orfao no bucket.
+		file, header, err := r.FormFile("image")
+		if err == nil {
+			defer file.Close()
+			imgFileName := fmt.Sprintf("%s_%d_%s", idUser, time.Now().Unix(), header.Filename)
+			imgPath, err := uploadImage(file, imgFileName, config.GetawsBucketNameImgDoacao())
+			if err != nil {
+				http.Error(w, "Erro ao subir imagem: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			changes.ImgCaminho = &imgPath
+		}
+
+		edit, err := donations.Edit(ctx, donationID, version, changes, idUser)
+		if errors.Is(err, repo.ErrConflict) {
+			http.Error(w, "Doacao alterada por outra requisicao, recarregue e tente novamente", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Erro ao editar doacao: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": "Doacao atualizada com sucesso",
+			"version": edit.Version,
+			"changes": edit.Changes,
+		})
+	}
+}
+
+// DonationEditHistoryHandler lista as edicoes da campanha para o dono.
+func DonationEditHistoryHandler(storeDDB dynamo.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		donationID := mux.Vars(r)["id"]
+		ctx := r.Context()
+		donations := repo.NewDonationRepo(storeDDB)
+		donation, err := donations.Get(ctx, donationID)
+		if err != nil {
+			http.Error(w, "Doacao nao encontrada", http.StatusNotFound)
+			return
+		}
+		if !canManageDonation(ctx, donation) {
+			http.Error(w, "Usuario nao autorizado", http.StatusForbidden)
+			return
+		}
+
+		edits, err := donations.ListEdits(ctx, donationID)
+		if err != nil {
+			http.Error(w, "Erro ao buscar historico: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(edits)
+	}
+}
+
+// DonationLinkRenameHandler troca o nome publico da campanha. O link anterior
+// continua valido e redireciona para o novo.
+func DonationLinkRenameHandler(storeDDB dynamo.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idUser := middleware.UserIDFromContext(r.Context())
+		if idUser == "" {
+			http.Error(w, "ID do usuario invalido", http.StatusUnauthorized)
+			return
+		}
+
+		donationID := mux.Vars(r)["id"]
+		var req struct {
+			NomeLink string `json:"nome_link"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "JSON invalido", http.StatusBadRequest)
+			return
+		}
+		nomeLink, err := validateCustomLink(req.NomeLink)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx := r.Context()
+		donations := repo.NewDonationRepo(storeDDB)
+		donation, err := donations.Get(ctx, donationID)
+		if err != nil || donation.Dell {
+			http.Error(w, "Doacao nao encontrada", http.StatusNotFound)
+			return
+		}
+		if donation.IDUser != idUser {
+			http.Error(w, "Usuario nao autorizado a editar esta doacao", http.StatusForbidden)
+			return
+		}
+
+		err = donations.RenameLink(ctx, donation, repo.DonationLink{
+			ID:       uuid.NewString(),
+			IDDoacao: donationID,
+			NomeLink: nomeLink,
+			IDUser:   idUser,
+		})
+		if errors.Is(err, repo.ErrLinkTaken) {
+			http.Error(w, "nome_link ja em uso", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Erro ao trocar nome_link: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"message":   "Link atualizado com sucesso",
+			"nome_link": nomeLink,
+			"link":      buildDonationPublicLink(nomeLink),
+		})
+	}
+}
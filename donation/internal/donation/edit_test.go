@@ -0,0 +1,123 @@
+package donation
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"BACK_SORTE_GO/common/middleware"
+	"BACK_SORTE_GO/common/money"
+	"BACK_SORTE_GO/common/repo"
+	"BACK_SORTE_GO/common/store/dynamo"
+
+	"github.com/gorilla/mux"
+)
+
+func editDonation(t *testing.T, storeDDB dynamo.Store, userID, donationID string, fields map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := multipartRequest(t, "/donation/"+donationID, fields)
+	r.Method = http.MethodPatch
+	r = mux.SetURLVars(r, map[string]string{"id": donationID})
+	r = r.WithContext(middleware.WithPrincipal(r.Context(), middleware.Principal{UserID: userID}))
+	w := httptest.NewRecorder()
+	DonationEditHandler(storeDDB)(w, r)
+	return w
+}
+
+func TestDonationEditHandler(t *testing.T) {
+	stubUpload(t)
+	storeDDB := dynamo.NewMemory("core")
+	ctx := context.Background()
+	created := createDonation(t, storeDDB, "u-1", "Campanha")
+
+	if w := editDonation(t, storeDDB, "u-2", created["id"], map[string]string{"version": "0", "texto": "outro"}); w.Code != http.StatusForbidden {
+		t.Fatalf("outro usuario: status %d", w.Code)
+	}
+
+	w := editDonation(t, storeDDB, "u-1", created["id"], map[string]string{"version": "0", "texto": "Ajude no tratamento da Maria", "valor": "2000"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+
+	donations := repo.NewDonationRepo(storeDDB)
+	d, _ := donations.Get(ctx, created["id"])
+	details, _ := donations.GetDetails(ctx, created["id"])
+	if d.Version != 1 || d.Valor != money.Cents(200000) || details.Texto != "Ajude no tratamento da Maria" {
+		t.Fatalf("campanha = %+v, detalhes = %+v", d, details)
+	}
+
+	// A mesma versao lida antes da edicao agora esta obsoleta.
+	if w := editDonation(t, storeDDB, "u-1", created["id"], map[string]string{"version": "0", "name": "Novo"}); w.Code != http.StatusConflict {
+		t.Fatalf("versao obsoleta: status %d", w.Code)
+	}
+
+	edits, err := donations.ListEdits(ctx, created["id"])
+	if err != nil || len(edits) != 1 || edits[0].Changes["valor"].To != "2000.00" || edits[0].UpdatedBy != "u-1" {
+		t.Fatalf("historico = %+v, %v", edits, err)
+	}
+}
+
+func TestLinkCandidates(t *testing.T) {
+	got := linkCandidates("Admin")
+	if got[0] != "@admin_2" || len(got) != maxLinkCandidates-1 {
+		t.Fatalf("reservado: %v", got[:2])
+	}
+	if got := linkCandidates("!!"); got[0] != "@campanha" {
+		t.Fatalf("titulo vazio: %v", got[0])
+	}
+	long := linkCandidates("uma campanha com um titulo bem mais longo que o limite")
+	if last := long[len(long)-1]; len(last)-1 > linkMaxLen {
+		t.Fatalf("candidato longo: %q", last)
+	}
+	if _, err := validateCustomLink("@ab"); err == nil {
+		t.Fatal("link curto aceito")
+	}
+}
+
+func TestDonationLinkRename(t *testing.T) {
+	stubUpload(t)
+	storeDDB := dynamo.NewMemory("core")
+	created := createDonation(t, storeDDB, "u-1", "Campanha")
+
+	rename := func(userID, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPut, "/donation/"+created["id"]+"/link", strings.NewReader(body))
+		r = mux.SetURLVars(r, map[string]string{"id": created["id"]})
+		r = r.WithContext(middleware.WithPrincipal(r.Context(), middleware.Principal{UserID: userID}))
+		w := httptest.NewRecorder()
+		DonationLinkRenameHandler(storeDDB)(w, r)
+		return w
+	}
+	if w := rename("u-1", `{"nome_link":"@login"}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("reservado: status %d", w.Code)
+	}
+	if w := rename("u-2", `{"nome_link":"@nova"}`); w.Code != http.StatusForbidden {
+		t.Fatalf("outro usuario: status %d", w.Code)
+	}
+	if w := rename("u-1", `{"nome_link":"@Nova_Campanha"}`); w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/donation/link/@campanha", nil)
+	r = mux.SetURLVars(r, map[string]string{"nome_link": "@campanha"})
+	w := httptest.NewRecorder()
+	DonationByLinkHandler(storeDDB)(w, r)
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/donation/link/@nova_campanha" {
+		t.Fatalf("alias: status %d, Location %q", w.Code, w.Header().Get("Location"))
+	}
+
+	// O link antigo continua reservado para a campanha.
+	req := multipartRequest(t, "/donation", map[string]string{"name": "Outra", "valor": "10", "texto": "x", "area": "x", "nome_link": "@campanha"})
+	req = req.WithContext(middleware.WithPrincipal(req.Context(), middleware.Principal{UserID: "u-2"}))
+	w = httptest.NewRecorder()
+	DonationHandler(storeDDB)(w, req)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("link de alias reaproveitado: status %d", w.Code)
+	}
+
+	// Voltar ao nome antigo reativa o alias da propria campanha.
+	if w := rename("u-1", `{"nome_link":"@campanha"}`); w.Code != http.StatusOK {
+		t.Fatalf("volta ao link antigo: status %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -1,9 +1,9 @@
 package donation
 
 import (
-	"BACK_SORTE_GO/config"
-	"BACK_SORTE_GO/internal/store"
-	"BACK_SORTE_GO/internal/store/dynamo"
+	"BACK_SORTE_GO/common/config"
+	"BACK_SORTE_GO/common/repo"
+	"BACK_SORTE_GO/common/store/dynamo"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -13,13 +13,14 @@ import (
 	"time"
 
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
 )
 
 const (
 	emailEventTypeDonationCreated = "email-cadastro-doacao"
 	emailEventTypeEmailVerify     = "email-validar-email-usuario"
+	emailEventTypeDonationPost    = "email-novidade-doacao"
+	emailEventTypeDonationEnded   = "email-doacao-encerrada"
 )
 
 type donationEmailEvent struct {
@@ -30,6 +31,7 @@ type donationEmailEvent struct {
 	DonationID     string `json:"donation_id"`
 	DonationName   string `json:"donation_name"`
 	DonationLink   string `json:"donation_link"`
+	PostTexto      string `json:"post_texto,omitempty"`
 	CreatedAt      string `json:"created_at"`
 }
 
@@ -118,25 +120,48 @@ func sendEmailVerificationEvent(ctx context.Context, userID, recipientName, reci
 	return publishDonationEmailEvent(ctx, event)
 }
 
-func lookupUserContact(ctx context.Context, storeDDB *dynamo.Store, userID string) (string, string, error) {
-	item, err := storeDDB.GetItem(ctx, store.UserPK(userID), "PROFILE")
-	if err != nil {
-		return "", "", err
+// sendDonationPostEmailEvent avisa um doador inscrito que a campanha publicou
+// uma novidade.
+func sendDonationPostEmailEvent(ctx context.Context, sub repo.DonationSubscriber, donation repo.Donation, post repo.DonationPost) error {
+	event := donationEmailEvent{
+		Type:           emailEventTypeDonationPost,
+		UserID:         donation.IDUser,
+		RecipientName:  sub.Nome,
+		RecipientEmail: sub.Email,
+		DonationID:     donation.ID,
+		DonationName:   donation.Name,
+		DonationLink:   buildDonationPublicLink(donation.NomeLink),
+		PostTexto:      post.Texto,
+		CreatedAt:      time.Now().Format(time.RFC3339),
 	}
+	return publishDonationEmailEvent(ctx, event)
+}
 
-	email := ""
-	if raw, ok := item["email"]; ok {
-		if v, ok := raw.(*types.AttributeValueMemberS); ok {
-			email = strings.TrimSpace(v.Value)
-		}
+// sendDonationEndedEmailEvent avisa o dono que a campanha chegou a date_end e
+// foi encerrada.
+func sendDonationEndedEmailEvent(ctx context.Context, recipientName, recipientEmail string, donation repo.Donation) error {
+	event := donationEmailEvent{
+		Type:           emailEventTypeDonationEnded,
+		UserID:         donation.IDUser,
+		RecipientName:  recipientName,
+		RecipientEmail: recipientEmail,
+		DonationID:     donation.ID,
+		DonationName:   donation.Name,
+		DonationLink:   buildDonationPublicLink(donation.NomeLink),
+		CreatedAt:      time.Now().Format(time.RFC3339),
 	}
-	name := ""
-	if raw, ok := item["name"]; ok {
-		if v, ok := raw.(*types.AttributeValueMemberS); ok {
-			name = strings.TrimSpace(v.Value)
-		}
+	return publishDonationEmailEvent(ctx, event)
+}
+
+func lookupUserContact(ctx context.Context, storeDDB dynamo.Store, userID string) (string, string, error) {
+	user, err := repo.NewUserRepo(storeDDB).Get(ctx, userID)
+	if err != nil {
+		return "", "", err
 	}
 
+	email := strings.TrimSpace(user.Email)
+	name := strings.TrimSpace(user.Name)
+
 	if email == "" {
 		return "", "", fmt.Errorf("usuario %s sem email no PROFILE", userID)
 	}
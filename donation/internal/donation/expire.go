@@ -0,0 +1,45 @@
+package donation
+
+import (
+	"BACK_SORTE_GO/common/repo"
+	"BACK_SORTE_GO/common/store/dynamo"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// autoCloseUser e o updated_by gravado quando o job encerra a campanha.
+const autoCloseUser = "SYSTEM"
+
+// CloseExpiredDonations encerra as campanhas abertas cuja date_end ja passou,
+// com a mesma mudanca de estado do DonationClosedHandler, e avisa cada dono por
+// e-mail. E chamado pelo agendamento do EventBridge (ver main.go); uma falha
+// numa campanha nao impede as demais.
+func CloseExpiredDonations(ctx context.Context, storeDDB dynamo.Store, at time.Time) (int, error) {
+	donations := repo.NewDonationRepo(storeDDB)
+	ended, err := donations.ListEnded(ctx, at)
+	if err != nil {
+		return 0, err
+	}
+
+	closed := 0
+	var errs []error
+	for _, d := range ended {
+		if err := donations.Close(ctx, d.ID, autoCloseUser); err != nil {
+			errs = append(errs, fmt.Errorf("doacao %s: %w", d.ID, err))
+			continue
+		}
+		closed++
+
+		email, name, err := lookupUserContact(ctx, storeDDB, d.IDUser)
+		if err != nil {
+			fmt.Printf("aviso: falha ao buscar contato do usuario %s para envio de email: %v\n", d.IDUser, err)
+			continue
+		}
+		if err := sendDonationEndedEmailEvent(ctx, name, email, d); err != nil {
+			fmt.Printf("aviso: falha ao publicar evento de encerramento da doacao %s: %v\n", d.ID, err)
+		}
+	}
+	return closed, errors.Join(errs...)
+}
@@ -0,0 +1,87 @@
+package donation
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"BACK_SORTE_GO/common/middleware"
+	"BACK_SORTE_GO/common/repo"
+	"BACK_SORTE_GO/common/store/dynamo"
+)
+
+func TestParseDateEnd(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	if got, err := parseDateEnd("2025-03-10", now); err != nil || got != "2025-03-11T02:59:59Z" {
+		t.Fatalf("dia = %q, %v", got, err)
+	}
+	if got, err := parseDateEnd("2025-04-01T10:00:00-03:00", now); err != nil || got != "2025-04-01T13:00:00Z" {
+		t.Fatalf("RFC3339 = %q, %v", got, err)
+	}
+	for _, raw := range []string{"2025-03-09", "10/04/2025", "2025-03-10T11:00:00Z"} {
+		if _, err := parseDateEnd(raw, now); err == nil {
+			t.Fatalf("%q aceita", raw)
+		}
+	}
+}
+
+func TestCloseExpiredDonations(t *testing.T) {
+	stubUpload(t)
+	storeDDB := dynamo.NewMemory("core")
+	ctx := context.Background()
+	donations := repo.NewDonationRepo(storeDDB)
+
+	end := time.Now().AddDate(0, 0, 2).Format(time.DateOnly)
+	r := multipartRequest(t, "/donation", map[string]string{"name": "Com prazo", "valor": "100", "texto": "x", "area": "saude", "date_end": end})
+	r = r.WithContext(middleware.WithPrincipal(r.Context(), middleware.Principal{UserID: "u-1"}))
+	w := httptest.NewRecorder()
+	DonationHandler(storeDDB)(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+	open := createDonation(t, storeDDB, "u-2", "Sem prazo")
+	if w := editDonation(t, storeDDB, "u-2", open["id"], map[string]string{"version": "0", "date_end": "2020-01-01"}); w.Code != http.StatusBadRequest {
+		t.Fatalf("date_end passada: status %d", w.Code)
+	}
+
+	list, err := donations.ListByUser(ctx, "u-1")
+	if err != nil || len(list) != 1 || list[0].DateEnd == "" {
+		t.Fatalf("ListByUser = %+v, %v", list, err)
+	}
+	withEnd := list[0]
+
+	if n, err := CloseExpiredDonations(ctx, storeDDB, time.Now()); err != nil || n != 0 {
+		t.Fatalf("antes da data: %d, %v", n, err)
+	}
+	later := time.Now().AddDate(0, 0, 3)
+	if withEnd.AcceptsPayments(time.Now()) == withEnd.AcceptsPayments(later) {
+		t.Fatal("AcceptsPayments ignora date_end")
+	}
+	if n, err := CloseExpiredDonations(ctx, storeDDB, later); err != nil || n != 1 {
+		t.Fatalf("depois da data: %d, %v", n, err)
+	}
+
+	d, _ := donations.Get(ctx, withEnd.ID)
+	if !d.Closed || d.Active || d.UpdatedBy != autoCloseUser {
+		t.Fatalf("campanha vencida = %+v", d)
+	}
+	if d, _ := donations.Get(ctx, open["id"]); d.Closed {
+		t.Fatal("campanha sem date_end encerrada")
+	}
+	if n, _ := CloseExpiredDonations(ctx, storeDDB, later); n != 0 {
+		t.Fatalf("reencerrou %d campanhas", n)
+	}
+
+	// Tirar a date_end na edicao tira a campanha do indice de encerramento.
+	if w := editDonation(t, storeDDB, "u-2", open["id"], map[string]string{"version": "0", "date_end": end}); w.Code != http.StatusOK {
+		t.Fatalf("edicao com date_end: status %d", w.Code)
+	}
+	if w := editDonation(t, storeDDB, "u-2", open["id"], map[string]string{"version": "1", "date_end": ""}); w.Code != http.StatusOK {
+		t.Fatalf("edicao sem date_end: status %d", w.Code)
+	}
+	if n, err := CloseExpiredDonations(ctx, storeDDB, later); err != nil || n != 0 {
+		t.Fatalf("date_end removida: %d, %v", n, err)
+	}
+}
@@ -0,0 +1,118 @@
+package donation
+
+import (
+	"BACK_SORTE_GO/common/repo"
+	"BACK_SORTE_GO/common/store/dynamo"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	exploreRecentes   = "recentes"
+	exploreArrecadado = "arrecadado"
+	exploreMeta       = "meta"
+)
+
+// exploreItem e a campanha no feed publico, com o percentual da meta atingido.
+type exploreItem struct {
+	donationPublic
+	Progresso float64 `json:"progresso"`
+}
+
+// DonationExploreHandler lista as campanhas abertas para visitantes, filtrando
+// por area e ordenando por recentes (padrao), arrecadado ou meta (menor valor
+// que falta para bater a meta; as que ja bateram vao para o fim). Todas as
+// ordenacoes vem de indices e seguem por cursor; ?page=N sem cursor mantem o
+// modo legado por numero de pagina.
+func DonationExploreHandler(storeDDB dynamo.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		area := strings.TrimSpace(query.Get("area"))
+		order := query.Get("sort")
+		if order == "" {
+			order = exploreRecentes
+		}
+		if order != exploreRecentes && order != exploreArrecadado && order != exploreMeta {
+			http.Error(w, "Parametro 'sort' invalido (recentes, arrecadado ou meta)", http.StatusBadRequest)
+			return
+		}
+		limit, err := strconv.Atoi(query.Get("limit"))
+		if err != nil || limit < 1 || limit > 100 {
+			limit = 10
+		}
+
+		ctx := r.Context()
+		donations := repo.NewDonationRepo(storeDDB)
+		response := map[string]interface{}{
+			"area":  area,
+			"sort":  order,
+			"limit": limit,
+		}
+
+		fetch := func(cursor string, n int) ([]repo.Donation, string, error) {
+			switch order {
+			case exploreArrecadado:
+				return donations.ExploreByRaised(ctx, area, cursor, n)
+			case exploreMeta:
+				return donations.ExploreByGoal(ctx, area, cursor, n)
+			}
+			return donations.Explore(ctx, area, cursor, n)
+		}
+		var selected []repo.Donation
+		var next string
+		page, _ := strconv.Atoi(query.Get("page"))
+		if page > 0 && !query.Has("cursor") {
+			selected, next, err = repo.PageByNumber(page, limit, fetch)
+			response["page"] = page
+		} else {
+			selected, next, err = fetch(query.Get("cursor"), limit)
+			response["next_cursor"] = next
+		}
+		if errors.Is(err, repo.ErrInvalidCursor) {
+			http.Error(w, "Cursor invalido", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Erro ao buscar doacoes: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		response["has_next_page"] = next != ""
+
+		ids := make([]string, 0, len(selected))
+		for _, d := range selected {
+			ids = append(ids, d.ID)
+		}
+		details, _, err := donations.DetailsAndPayments(ctx, ids)
+		if err != nil {
+			http.Error(w, "Erro ao buscar detalhes: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		items := make([]exploreItem, 0, len(selected))
+		for _, d := range selected {
+			item := exploreItem{donationPublic: donationPublic{Donation: d}, Progresso: math.Round(progress(d)*1000) / 10}
+			if dd, ok := details[d.ID]; ok {
+				item.Texto = dd.Texto
+				item.ImgCaminho = dd.ImgCaminho
+				item.Area = dd.Area
+			}
+			items = append(items, item)
+		}
+		response["items"] = items
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// progress e a fracao da meta ja arrecadada.
+func progress(d repo.Donation) float64 {
+	if d.Valor.Centavos <= 0 {
+		return 0
+	}
+	return float64(d.Arrecadado.Centavos) / float64(d.Valor.Centavos)
+}
@@ -0,0 +1,142 @@
+package donation
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"BACK_SORTE_GO/common/money"
+	"BACK_SORTE_GO/common/repo"
+	"BACK_SORTE_GO/common/store/dynamo"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func explore(t *testing.T, storeDDB dynamo.Store, query string) []exploreItem {
+	t.Helper()
+	w := httptest.NewRecorder()
+	DonationExploreHandler(storeDDB)(w, httptest.NewRequest(http.MethodGet, "/donation/explore?"+query, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Items []exploreItem `json:"items"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp.Items
+}
+
+func TestDonationExploreHandler(t *testing.T) {
+	stubUpload(t)
+	storeDDB := dynamo.NewMemory("core")
+	ctx := context.Background()
+	donations := repo.NewDonationRepo(storeDDB)
+
+	a := createDonation(t, storeDDB, "u-1", "Campanha A")
+	b := createDonation(t, storeDDB, "u-2", "Campanha B")
+	c := createDonation(t, storeDDB, "u-3", "Campanha C")
+	for id, valor := range map[string]money.Money{b["id"]: money.Cents(90000), c["id"]: money.Cents(30000)} {
+		writes, err := donations.ProgressWrites(ctx, id, "", valor, "2024-01-02T00:00:00Z")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.Transact(ctx, storeDDB, writes); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := donations.Close(ctx, a["id"], "u-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	items := explore(t, storeDDB, "area=Saude&sort=arrecadado")
+	if len(items) != 2 || items[0].ID != b["id"] || items[1].ID != c["id"] || items[0].Progresso != 60 {
+		t.Fatalf("feed = %+v", items)
+	}
+	if items := explore(t, storeDDB, "area=educacao"); len(items) != 0 {
+		t.Fatalf("outra area: %+v", items)
+	}
+
+	w := httptest.NewRecorder()
+	DonationExploreHandler(storeDDB)(w, httptest.NewRequest(http.MethodGet, "/donation/explore?sort=popular", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("sort invalido: status %d", w.Code)
+	}
+}
+
+func TestDonationExploreRankingsAndFeedMigration(t *testing.T) {
+	stubUpload(t)
+	storeDDB := dynamo.NewMemory("core")
+	ctx := context.Background()
+	donations := repo.NewDonationRepo(storeDDB)
+
+	near := createDonation(t, storeDDB, "u-1", "Quase la")
+	far := createDonation(t, storeDDB, "u-2", "Longe")
+	done := createDonation(t, storeDDB, "u-3", "Meta batida")
+	for id, valor := range map[string]money.Money{near["id"]: money.Cents(140000), far["id"]: money.Cents(10000), done["id"]: money.Cents(160000)} {
+		writes, err := donations.ProgressWrites(ctx, id, "", valor, "2024-01-02T00:00:00Z")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.Transact(ctx, storeDDB, writes); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Campanha anterior ao feed: PROFILE sem as chaves dos indices.
+	legacy := map[string]types.AttributeValue{
+		"PK": dynamo.S("DONATION#legada"), "SK": dynamo.S("PROFILE"),
+		"id": dynamo.S("legada"), "id_user": dynamo.S("u-4"), "name": dynamo.S("Legada"),
+		"valor": dynamo.N("1000"), "arrecadado": dynamo.N("999"), "active": dynamo.B(true),
+		"date_create": dynamo.S("2020-01-01T00:00:00Z"),
+	}
+	if err := storeDDB.PutItem(ctx, legacy); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := RunMigration(ctx, storeDDB, "feed"); err != nil || n != 4 {
+		t.Fatalf("migracao feed = %d, %v", n, err)
+	}
+
+	// Pagina de um em um pelo cursor, passando da parte das que nao bateram a
+	// meta para as que ja bateram.
+	var order []string
+	cursor := ""
+	for i := 0; i < 6; i++ {
+		w := httptest.NewRecorder()
+		DonationExploreHandler(storeDDB)(w, httptest.NewRequest(http.MethodGet, "/donation/explore?sort=meta&limit=1&cursor="+url.QueryEscape(cursor), nil))
+		var resp struct {
+			Items      []exploreItem `json:"items"`
+			NextCursor string        `json:"next_cursor"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || w.Code != http.StatusOK {
+			t.Fatalf("meta: status %d, %v", w.Code, err)
+		}
+		for _, item := range resp.Items {
+			order = append(order, item.ID)
+		}
+		if cursor = resp.NextCursor; cursor == "" {
+			break
+		}
+	}
+	want := []string{"legada", near["id"], far["id"], done["id"]}
+	if strings.Join(order, ",") != strings.Join(want, ",") {
+		t.Fatalf("sort=meta = %v, quer %v", order, want)
+	}
+
+	items := explore(t, storeDDB, "sort=arrecadado&page=2&limit=2")
+	if len(items) != 2 || items[0].ID != "legada" || items[1].ID != far["id"] {
+		t.Fatalf("sort=arrecadado pagina 2 = %+v", items)
+	}
+
+	if err := donations.Close(ctx, done["id"], "u-3"); err != nil {
+		t.Fatal(err)
+	}
+	if items := explore(t, storeDDB, "sort=meta&area=saude"); len(items) != 2 || items[0].ID != near["id"] {
+		t.Fatalf("meta por area apos encerrar = %+v", items)
+	}
+}
@@ -0,0 +1,152 @@
+package donation
+
+import (
+	"BACK_SORTE_GO/common/middleware"
+	"BACK_SORTE_GO/common/money"
+	"BACK_SORTE_GO/common/repo"
+	"BACK_SORTE_GO/common/store/dynamo"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// DonationLedgerHandler devolve o livro razao da campanha com a conferencia
+// dos saldos gravados contra os recalculados pelos lancamentos.
+func DonationLedgerHandler(storeDDB dynamo.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		donationID := mux.Vars(r)["id"]
+		ctx := r.Context()
+		donation, err := repo.NewDonationRepo(storeDDB).Get(ctx, donationID)
+		if err != nil {
+			http.Error(w, "Doacao nao encontrada", http.StatusNotFound)
+			return
+		}
+		if !canManageDonation(ctx, donation) {
+			http.Error(w, "Usuario nao autorizado", http.StatusForbidden)
+			return
+		}
+
+		ledger := repo.NewLedgerRepo(storeDDB)
+		entries, err := ledger.List(ctx, donationID)
+		if err != nil {
+			http.Error(w, "Erro ao buscar lancamentos: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		check, err := ledger.Check(ctx, donationID)
+		if err != nil {
+			http.Error(w, "Erro ao conferir saldos: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"lancamentos": entries,
+			"conferencia": check,
+			"consistente": check.OK(),
+		})
+	}
+}
+
+// DonationLedgerAdjustHandler lanca um ajuste manual do financeiro (ADMIN) em
+// um saldo da campanha, como a abertura de saldos anteriores ao livro razao.
+// O ajuste e um lancamento novo; os anteriores nao mudam.
+func DonationLedgerAdjustHandler(storeDDB dynamo.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		adminID := middleware.UserIDFromContext(r.Context())
+		if adminID == "" {
+			http.Error(w, "ID do usuario invalido", http.StatusUnauthorized)
+			return
+		}
+
+		donationID := mux.Vars(r)["id"]
+		var req struct {
+			Conta     string      `json:"conta"`
+			Valor     money.Money `json:"valor"`
+			Descricao string      `json:"descricao"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Erro ao processar o JSON", http.StatusBadRequest)
+			return
+		}
+		if req.Conta == "" {
+			req.Conta = repo.AccountCampanha
+		}
+		switch req.Conta {
+		case repo.AccountCampanha, repo.AccountPlataforma, repo.AccountResgate:
+		default:
+			http.Error(w, "Conta invalida para ajuste", http.StatusBadRequest)
+			return
+		}
+		if req.Valor.IsZero() {
+			http.Error(w, "Valor do ajuste e obrigatorio", http.StatusBadRequest)
+			return
+		}
+		if strings.TrimSpace(req.Descricao) == "" {
+			http.Error(w, "Descricao do ajuste e obrigatoria", http.StatusBadRequest)
+			return
+		}
+
+		ctx := r.Context()
+		if _, err := repo.NewDonationRepo(storeDDB).Get(ctx, donationID); err != nil {
+			http.Error(w, "Doacao nao encontrada", http.StatusNotFound)
+			return
+		}
+
+		entry := repo.AdjustmentEntry(uuid.NewString(), req.Conta, req.Valor, strings.TrimSpace(req.Descricao), adminID)
+		entry.IDDoacao, entry.DataCriacao = donationID, time.Now().Format(time.RFC3339)
+		err := repo.NewLedgerRepo(storeDDB).Record(ctx, donationID, entry)
+		if errors.Is(err, repo.ErrConflict) {
+			http.Error(w, "Ajuste concorrente, tente novamente", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Erro ao lancar ajuste: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(entry)
+	}
+}
+
+// DonationLedgerOpenHandler lanca a abertura do livro razao (ADMIN): a
+// diferenca entre os saldos gravados antes do livro razao e os recalculados
+// vira AJUSTE, sem mexer nos saldos.
+func DonationLedgerOpenHandler(storeDDB dynamo.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		adminID := middleware.UserIDFromContext(r.Context())
+		if adminID == "" {
+			http.Error(w, "ID do usuario invalido", http.StatusUnauthorized)
+			return
+		}
+
+		donationID := mux.Vars(r)["id"]
+		ctx := r.Context()
+		if _, err := repo.NewDonationRepo(storeDDB).Get(ctx, donationID); err != nil {
+			http.Error(w, "Doacao nao encontrada", http.StatusNotFound)
+			return
+		}
+
+		check, err := repo.NewLedgerRepo(storeDDB).OpenBalances(ctx, donationID, adminID)
+		if errors.Is(err, repo.ErrConflict) {
+			http.Error(w, "Saldo alterado durante a abertura, tente novamente", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Erro ao abrir livro razao: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"conferencia": check,
+			"consistente": check.OK(),
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package donation
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"BACK_SORTE_GO/common/middleware"
+	"BACK_SORTE_GO/common/money"
+	"BACK_SORTE_GO/common/repo"
+	"BACK_SORTE_GO/common/store/dynamo"
+
+	"github.com/gorilla/mux"
+)
+
+func ledgerRequest(method, donationID, userID, body string, roles ...string) *http.Request {
+	r := httptest.NewRequest(method, "/donation/"+donationID+"/ledger", strings.NewReader(body))
+	r = mux.SetURLVars(r, map[string]string{"id": donationID})
+	return r.WithContext(middleware.WithPrincipal(r.Context(), middleware.Principal{UserID: userID, Roles: roles}))
+}
+
+func TestDonationRescueAndLedger(t *testing.T) {
+	stubUpload(t)
+	storeDDB := dynamo.NewMemory("core")
+	ctx := context.Background()
+	created := createDonation(t, storeDDB, "u-1", "Campanha")
+	id := created["id"]
+	ledger := repo.NewLedgerRepo(storeDDB)
+
+	fee, err := repo.DefaultFeePolicy.Apply(repo.FeeMethodPix, "", money.Cents(5000), time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ledger.Record(ctx, id, repo.PaymentEntries("tx-1", money.Cents(5000), fee)...); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	DonationRescueHandler(storeDDB)(w, ledgerRequest(http.MethodGet, id, "u-1", ""))
+	if w.Code != http.StatusOK {
+		t.Fatalf("resgate: status %d: %s", w.Code, w.Body.String())
+	}
+	var rescue struct {
+		ValorDisponivel money.Money `json:"valor_disponivel"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&rescue); err != nil || rescue.ValorDisponivel != money.Cents(4500) {
+		t.Fatalf("resgate = %+v, %v", rescue, err)
+	}
+
+	// Sem saldo, o segundo pedido nao lanca nada.
+	w = httptest.NewRecorder()
+	DonationRescueHandler(storeDDB)(w, ledgerRequest(http.MethodGet, id, "u-1", ""))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("resgate sem saldo: status %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	DonationLedgerAdjustHandler(storeDDB)(w, ledgerRequest(http.MethodPost, id, "admin", `{"valor":"-1,50","descricao":"tarifa bancaria"}`, middleware.RoleAdmin))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("ajuste: status %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	DonationLedgerHandler(storeDDB)(w, ledgerRequest(http.MethodGet, id, "u-2", ""))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("outro usuario: status %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	DonationLedgerHandler(storeDDB)(w, ledgerRequest(http.MethodGet, id, "u-1", ""))
+	var resp struct {
+		Lancamentos []repo.LedgerEntry `json:"lancamentos"`
+		Conferencia repo.LedgerCheck   `json:"conferencia"`
+		Consistente bool               `json:"consistente"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || !resp.Consistente || len(resp.Lancamentos) != 4 {
+		t.Fatalf("livro razao = %+v, %v", resp, err)
+	}
+	if got := resp.Conferencia.Saldos[repo.AccountCampanha]; got != money.Cents(-150) {
+		t.Fatalf("saldo da campanha = %s", got)
+	}
+}
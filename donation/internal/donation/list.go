@@ -1,114 +1,107 @@
 package donation
 
 import (
-	"BACK_SORTE_GO/internal/store"
-	"BACK_SORTE_GO/internal/store/dynamo"
+	"BACK_SORTE_GO/common/middleware"
+	"BACK_SORTE_GO/common/repo"
+	"BACK_SORTE_GO/common/store/dynamo"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
-	"strings"
-	"time"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
-	"github.com/golang-jwt/jwt/v4"
 	"github.com/gorilla/mux"
 )
 
-func DonationListByIDUserHandler(storeDDB *dynamo.Store) http.HandlerFunc {
+// donationListItem e a campanha na listagem do dono, com DETAILS e PAYMENT.
+type donationListItem struct {
+	repo.Donation
+	Texto     string                `json:"texto,omitempty"`
+	Img       string                `json:"img,omitempty"`
+	Area      string                `json:"area,omitempty"`
+	Pagamento *repo.DonationPayment `json:"pagamento,omitempty"`
+}
+
+func DonationListByIDUserHandler(storeDDB dynamo.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		idUser := r.URL.Query().Get("id_user")
+		query := r.URL.Query()
+		idUser := query.Get("id_user")
 		if idUser == "" {
 			http.Error(w, "Parametro 'id_user' e obrigatorio", http.StatusBadRequest)
 			return
 		}
 
-		pageStr := r.URL.Query().Get("page")
-		limitStr := r.URL.Query().Get("limit")
 		page := 1
 		limit := 10
-		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
+		if p, err := strconv.Atoi(query.Get("page")); err == nil && p > 0 {
 			page = p
 		}
-		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+		if l, err := strconv.Atoi(query.Get("limit")); err == nil && l > 0 {
 			if l > 100 {
 				limit = 100
 			} else {
 				limit = l
 			}
 		}
-		offset := (page - 1) * limit
 
+		// Com ?cursor= (vazio na primeira pagina) a listagem segue pelo cursor;
+		// sem ele mantem o modo legado por numero de pagina.
 		ctx := r.Context()
-		out, err := storeDDB.Query(ctx, &dynamodb.QueryInput{
-			IndexName:              aws.String("GSI1"),
-			KeyConditionExpression: aws.String("GSI1PK = :pk"),
-			ExpressionAttributeValues: map[string]types.AttributeValue{
-				":pk": dynamo.S(store.UserPK(idUser)),
-			},
-			ScanIndexForward: aws.Bool(false),
-		})
+		donations := repo.NewDonationRepo(storeDDB)
+		cursorMode := query.Has("cursor")
+		var selected []repo.Donation
+		var next string
+		var err error
+		if cursorMode {
+			selected, next, err = donations.ListByUserPage(ctx, idUser, query.Get("cursor"), limit)
+		} else {
+			selected, next, err = repo.PageByNumber(page, limit, func(cursor string, n int) ([]repo.Donation, string, error) {
+				return donations.ListByUserPage(ctx, idUser, cursor, n)
+			})
+		}
+		if errors.Is(err, repo.ErrInvalidCursor) {
+			http.Error(w, "Cursor invalido", http.StatusBadRequest)
+			return
+		}
 		if err != nil {
 			http.Error(w, "Erro ao buscar doacoes: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		total := len(out.Items)
-		end := offset + limit
-		if offset > total {
-			offset = total
-		}
-		if end > total {
-			end = total
-		}
-		selected := out.Items[offset:end]
-
-		keys := make([]map[string]types.AttributeValue, 0, len(selected)*2)
-		for _, item := range selected {
-			pk := item["PK"].(*types.AttributeValueMemberS).Value
-			keys = append(keys, map[string]types.AttributeValue{"PK": dynamo.S(pk), "SK": dynamo.S("DETAILS")})
-			keys = append(keys, map[string]types.AttributeValue{"PK": dynamo.S(pk), "SK": dynamo.S("PAYMENT")})
-		}
-		var batch map[string]map[string]types.AttributeValue
-		if len(keys) > 0 {
-			batch, _ = storeDDB.BatchGet(ctx, keys)
+		ids := make([]string, 0, len(selected))
+		for _, d := range selected {
+			ids = append(ids, d.ID)
 		}
+		details, payments, _ := donations.DetailsAndPayments(ctx, ids)
 
-		var donations []map[string]interface{}
-		for _, item := range selected {
-			var d map[string]interface{}
-			attributevalue.UnmarshalMap(item, &d)
-
-			pk := item["PK"].(*types.AttributeValueMemberS).Value
-			detail := batch[pk+"|DETAILS"]
-			payment := batch[pk+"|PAYMENT"]
-
-			if detail != nil {
-				var dd map[string]interface{}
-				attributevalue.UnmarshalMap(detail, &dd)
-				d["texto"] = dd["texto"]
-				d["img"] = dd["img_caminho"]
-				d["area"] = dd["area"]
+		var items []donationListItem
+		for _, d := range selected {
+			item := donationListItem{Donation: d}
+			if dd, ok := details[d.ID]; ok {
+				item.Texto = dd.Texto
+				item.Img = dd.ImgCaminho
+				item.Area = dd.Area
 			}
-			if payment != nil {
-				var pp map[string]interface{}
-				attributevalue.UnmarshalMap(payment, &pp)
-				d["pagamento"] = pp
+			if pp, ok := payments[d.ID]; ok {
+				item.Pagamento = &pp
 			}
-
-			donations = append(donations, d)
+			items = append(items, item)
 		}
 
-		hasNext := end < total
-
 		response := map[string]interface{}{
-			"items":         donations,
-			"page":          page,
+			"items":         items,
 			"limit":         limit,
-			"total":         total,
-			"has_next_page": hasNext,
+			"has_next_page": next != "",
+			"next_cursor":   next,
+		}
+		if !cursorMode {
+			total, err := donations.CountByUser(ctx, idUser)
+			if err != nil {
+				http.Error(w, "Erro ao buscar doacoes: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			response["page"] = page
+			response["total"] = total
+			response["has_next_page"] = page*limit < total
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -116,7 +109,7 @@ func DonationListByIDUserHandler(storeDDB *dynamo.Store) http.HandlerFunc {
 	}
 }
 
-func DonationDellHandler(storeDDB *dynamo.Store) http.HandlerFunc {
+func DonationDellHandler(storeDDB dynamo.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		donationID := vars["id"]
@@ -125,48 +118,25 @@ func DonationDellHandler(storeDDB *dynamo.Store) http.HandlerFunc {
 			return
 		}
 
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			http.Error(w, "Token nao fornecido", http.StatusUnauthorized)
-			return
-		}
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		claims := jwt.MapClaims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return jwtSecretKey1, nil
-		})
-		if err != nil || !token.Valid {
-			http.Error(w, "Token invalido", http.StatusUnauthorized)
-			return
-		}
-
-		userID, ok := claims["sub"].(string)
-		if !ok || userID == "" {
+		userID := middleware.UserIDFromContext(r.Context())
+		if userID == "" {
 			http.Error(w, "Token sem id_user", http.StatusUnauthorized)
 			return
 		}
 
 		ctx := r.Context()
-		item, err := storeDDB.GetItem(ctx, store.DonationPK(donationID), "PROFILE")
-		if err != nil || len(item) == 0 {
+		donations := repo.NewDonationRepo(storeDDB)
+		donation, err := donations.Get(ctx, donationID)
+		if err != nil {
 			http.Error(w, "Doacao nao encontrada", http.StatusNotFound)
 			return
 		}
-		if v, ok := item["id_user"].(*types.AttributeValueMemberS); ok {
-			if v.Value != userID {
-				http.Error(w, "Usuario nao autorizado a deletar esta doacao", http.StatusForbidden)
-				return
-			}
+		if !canManageDonation(ctx, donation) {
+			http.Error(w, "Usuario nao autorizado a deletar esta doacao", http.StatusForbidden)
+			return
 		}
 
-		err = storeDDB.UpdateItem(ctx, map[string]types.AttributeValue{
-			"PK": dynamo.S(store.DonationPK(donationID)),
-			"SK": dynamo.S("PROFILE"),
-		}, "SET dell = :d, date_update = :u", nil, map[string]types.AttributeValue{
-			":d": dynamo.B(true),
-			":u": dynamo.S(time.Now().Format(time.RFC3339)),
-		})
-		if err != nil {
+		if err := donations.MarkDeleted(ctx, donationID, userID); err != nil {
 			http.Error(w, "Erro ao deletar doacao: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
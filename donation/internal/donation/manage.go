@@ -1,43 +1,26 @@
 package donation
 
 import (
-	"BACK_SORTE_GO/internal/store"
-	"BACK_SORTE_GO/internal/store/dynamo"
+	"BACK_SORTE_GO/common/middleware"
+	"BACK_SORTE_GO/common/repo"
+	"BACK_SORTE_GO/common/store"
+	"BACK_SORTE_GO/common/store/dynamo"
+	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"net/http"
-	"strconv"
 	"strings"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
-	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 )
 
-func DonationClosedHandler(storeDDB *dynamo.Store) http.HandlerFunc {
+func DonationClosedHandler(storeDDB dynamo.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			http.Error(w, "Token nao fornecido", http.StatusUnauthorized)
-			return
-		}
-
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		claims := jwt.MapClaims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return jwtSecretKey1, nil
-		})
-		if err != nil || !token.Valid {
-			http.Error(w, "Token invalido", http.StatusUnauthorized)
-			return
-		}
-
-		idUserToken, ok := claims["sub"].(string)
-		if !ok || idUserToken == "" {
+		idUserToken := middleware.UserIDFromContext(r.Context())
+		if idUserToken == "" {
 			http.Error(w, "ID do usuario invalido no token", http.StatusUnauthorized)
 			return
 		}
@@ -50,27 +33,18 @@ func DonationClosedHandler(storeDDB *dynamo.Store) http.HandlerFunc {
 		}
 
 		ctx := r.Context()
-		item, err := storeDDB.GetItem(ctx, store.DonationPK(donationID), "PROFILE")
-		if err != nil || len(item) == 0 {
+		donations := repo.NewDonationRepo(storeDDB)
+		donation, err := donations.Get(ctx, donationID)
+		if err != nil {
 			http.Error(w, "Doacao nao encontrada", http.StatusNotFound)
 			return
 		}
-		if v, ok := item["id_user"].(*types.AttributeValueMemberS); ok {
-			if v.Value != idUserToken {
-				http.Error(w, "Voce nao tem permissao para encerrar esta doacao", http.StatusForbidden)
-				return
-			}
+		if !canManageDonation(ctx, donation) {
+			http.Error(w, "Voce nao tem permissao para encerrar esta doacao", http.StatusForbidden)
+			return
 		}
 
-		err = storeDDB.UpdateItem(ctx, map[string]types.AttributeValue{
-			"PK": dynamo.S(store.DonationPK(donationID)),
-			"SK": dynamo.S("PROFILE"),
-		}, "SET active = :a, closed = :c, date_update = :d", nil, map[string]types.AttributeValue{
-			":a": dynamo.B(false),
-			":c": dynamo.B(true),
-			":d": dynamo.S(time.Now().Format(time.RFC3339)),
-		})
-		if err != nil {
+		if err := donations.Close(ctx, donationID, idUserToken); err != nil {
 			http.Error(w, "Erro ao encerrar doacao: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -82,26 +56,19 @@ func DonationClosedHandler(storeDDB *dynamo.Store) http.HandlerFunc {
 	}
 }
 
-func DonationRescueHandler(storeDDB *dynamo.Store) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			http.Error(w, "Token nao fornecido", http.StatusUnauthorized)
-			return
-		}
-
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		claims := jwt.MapClaims{}
-		_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return jwtSecretKey1, nil
-		})
-		if err != nil {
-			http.Error(w, "Token invalido", http.StatusUnauthorized)
-			return
-		}
+// canManageDonation libera o dono da campanha e a equipe de suporte (ADMIN).
+func canManageDonation(ctx context.Context, donation repo.Donation) bool {
+	principal, _ := middleware.PrincipalFromContext(ctx)
+	if principal.HasRole(middleware.RoleAdmin) {
+		return true
+	}
+	return donation.IDUser != "" && donation.IDUser == principal.UserID
+}
 
-		idUser, ok := claims["sub"].(string)
-		if !ok || idUser == "" {
+func DonationRescueHandler(storeDDB dynamo.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idUser := middleware.UserIDFromContext(r.Context())
+		if idUser == "" {
 			http.Error(w, "ID do usuario invalido", http.StatusUnauthorized)
 			return
 		}
@@ -114,68 +81,50 @@ func DonationRescueHandler(storeDDB *dynamo.Store) http.HandlerFunc {
 		}
 
 		ctx := r.Context()
-		item, err := storeDDB.GetItem(ctx, store.DonationPK(idDoacao), "PROFILE")
-		if err != nil || len(item) == 0 {
+		donations := repo.NewDonationRepo(storeDDB)
+		donation, err := donations.Get(ctx, idDoacao)
+		if err != nil {
 			http.Error(w, "Doacao nao encontrada", http.StatusNotFound)
 			return
 		}
-		if v, ok := item["id_user"].(*types.AttributeValueMemberS); ok {
-			if v.Value != idUser {
-				http.Error(w, "Voce nao tem permissao para resgatar essa doacao", http.StatusForbidden)
-				return
-			}
-		}
-
-		out, err := storeDDB.Query(ctx, &dynamodb.QueryInput{
-			KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :sk)"),
-			ExpressionAttributeValues: map[string]types.AttributeValue{
-				":pk": dynamo.S(store.DonationPK(idDoacao)),
-				":sk": dynamo.S(store.PrefixPix),
-			},
-		})
-		if err != nil {
-			http.Error(w, "Erro ao calcular total recebido: "+err.Error(), http.StatusInternalServerError)
+		if donation.IDUser != idUser {
+			http.Error(w, "Voce nao tem permissao para resgatar essa doacao", http.StatusForbidden)
 			return
 		}
 
-		var totalValor float64
-		for _, item := range out.Items {
-			if st, ok := item["status"].(*types.AttributeValueMemberS); ok && st.Value == "CONCLUIDA" {
-				if v, ok := item["valor"].(*types.AttributeValueMemberN); ok {
-					val, _ := strconv.ParseFloat(v.Value, 64)
-					totalValor += val
-				}
-			}
+		payment, err := donations.GetPayment(ctx, idDoacao)
+		if err != nil && !errors.Is(err, repo.ErrNotFound) {
+			http.Error(w, "Erro ao buscar saldo: "+err.Error(), http.StatusInternalServerError)
+			return
 		}
-
-		if totalValor <= 0 {
+		if payment.ValorDisponivel.Centavos <= 0 {
 			http.Error(w, "Nenhum valor disponivel para resgate", http.StatusBadRequest)
 			return
 		}
 
-		valorDisponivel := totalValor * 0.90
-		dataSolicitado := time.Now().Format(time.RFC3339)
-
-		err = storeDDB.UpdateItem(ctx, map[string]types.AttributeValue{
-			"PK": dynamo.S(store.DonationPK(idDoacao)),
-			"SK": dynamo.S("PAYMENT"),
-		}, "SET valor_disponivel = :v, data_solicitado = :d, status = :s, solicitado = :b, data_update = :u", nil, map[string]types.AttributeValue{
-			":v": dynamo.N(fmt.Sprintf("%.2f", valorDisponivel)),
-			":d": dynamo.S(dataSolicitado),
-			":s": dynamo.S("PROCESS"),
-			":b": dynamo.B(true),
-			":u": dynamo.S(time.Now().Format(time.RFC3339)),
-		})
+		// O resgate e um lancamento no livro razao condicionado ao saldo lido:
+		// pagamentos confirmados no meio tempo continuam no disponivel.
+		entry, err := donations.RequestRescue(ctx, idDoacao, payment.ValorDisponivel, idUser)
+		if errors.Is(err, repo.ErrInsufficientBalance) || errors.Is(err, repo.ErrConflict) {
+			http.Error(w, "Saldo alterado durante o resgate, tente novamente", http.StatusConflict)
+			return
+		}
 		if err != nil {
 			http.Error(w, "Erro ao atualizar pagamento: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
+		resgateTotal, err := payment.ValorTransferido.Add(entry.Valor)
+		if err != nil {
+			http.Error(w, "Erro ao somar resgates: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"message":          "Resgate processado com sucesso",
-			"valor_disponivel": valorDisponivel,
-			"resgate_total":    totalValor,
+			"valor_disponivel": entry.Valor,
+			"resgate_total":    resgateTotal,
+			"lancamento":       entry,
 		})
 	}
 }
@@ -207,7 +156,7 @@ type DonationVisualizationRequest struct {
 	CreatePag3     bool   `json:"create_pag3"`
 }
 
-func DonationVisualization(storeDDB *dynamo.Store) http.HandlerFunc {
+func DonationVisualization(storeDDB dynamo.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req DonationVisualizationRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
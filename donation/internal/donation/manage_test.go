@@ -0,0 +1,30 @@
+package donation
+
+import (
+	"context"
+	"testing"
+
+	"BACK_SORTE_GO/common/middleware"
+	"BACK_SORTE_GO/common/repo"
+)
+
+func TestCanManageDonation(t *testing.T) {
+	owner := middleware.WithPrincipal(context.Background(), middleware.Principal{UserID: "u-1"})
+	admin := middleware.WithPrincipal(context.Background(), middleware.Principal{UserID: "adm", Roles: []string{middleware.RoleAdmin}})
+	for _, tc := range []struct {
+		name string
+		ctx  context.Context
+		d    repo.Donation
+		want bool
+	}{
+		{"dono", owner, repo.Donation{IDUser: "u-1"}, true},
+		{"outro usuario", owner, repo.Donation{IDUser: "u-2"}, false},
+		{"campanha sem dono", owner, repo.Donation{}, false},
+		{"sem login", context.Background(), repo.Donation{}, false},
+		{"admin", admin, repo.Donation{}, true},
+	} {
+		if got := canManageDonation(tc.ctx, tc.d); got != tc.want {
+			t.Errorf("%s: canManageDonation = %v", tc.name, got)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package donation
+
+import (
+	"BACK_SORTE_GO/common/repo"
+	"BACK_SORTE_GO/common/store/dynamo"
+	"context"
+	"errors"
+	"fmt"
+)
+
+// migrations sao as migracoes pontuais de dados, rodadas por invocacao direta
+// da lambda com {"migracao": "<nome>"} (ver main.go). Cada uma pode ser
+// repetida sem efeito colateral e devolve quantos itens tratou.
+var migrations = map[string]func(context.Context, dynamo.Store) (int, error){
+	"feed":     indexLegacyFeed,
+	"busca":    indexLegacyTerms,
+	"agregado": rebuildLegacyProgress,
+}
+
+// RunMigration roda a migracao pelo nome.
+func RunMigration(ctx context.Context, storeDDB dynamo.Store, name string) (int, error) {
+	run, ok := migrations[name]
+	if !ok {
+		return 0, fmt.Errorf("migracao desconhecida: %q", name)
+	}
+	return run(ctx, storeDDB)
+}
+
+// indexLegacyFeed poe no feed (GSI3/GSI4), nos rankings (GSI6/GSI7) e no
+// indice de encerramento (GSI8) as campanhas abertas criadas antes deles, que
+// so entrariam na primeira edicao.
+// Campanhas ja indexadas tem as chaves regravadas com os mesmos valores.
+func indexLegacyFeed(ctx context.Context, storeDDB dynamo.Store) (int, error) {
+	donations := repo.NewDonationRepo(storeDDB)
+	indexed := 0
+	var errs []error
+	err := donations.ScanProfiles(ctx, func(d repo.Donation) error {
+		if !d.Active || d.Closed || d.Dell {
+			return nil
+		}
+		err := donations.IndexFeed(ctx, d)
+		if errors.Is(err, repo.ErrConflict) {
+			return nil
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("doacao %s: %w", d.ID, err))
+			return nil
+		}
+		indexed++
+		return nil
+	})
+	return indexed, errors.Join(append(errs, err)...)
+}
+
+// indexLegacyTerms grava os TERM# das campanhas abertas criadas antes da busca,
+// que so seriam indexadas na primeira edicao.
+func indexLegacyTerms(ctx context.Context, storeDDB dynamo.Store) (int, error) {
+	donations := repo.NewDonationRepo(storeDDB)
+	indexed := 0
+	var errs []error
+	err := donations.ScanProfiles(ctx, func(d repo.Donation) error {
+		if !d.Active || d.Closed || d.Dell || d.Terms != nil {
+			return nil
+		}
+		err := donations.IndexTerms(ctx, d)
+		if errors.Is(err, repo.ErrConflict) {
+			return nil
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("doacao %s: %w", d.ID, err))
+			return nil
+		}
+		indexed++
+		return nil
+	})
+	return indexed, errors.Join(append(errs, err)...)
+}
+
+// rebuildLegacyProgress grava o AGG das campanhas anteriores ao contador, que
+// ate la tem o progresso recalculado pelos Pix a cada leitura.
+func rebuildLegacyProgress(ctx context.Context, storeDDB dynamo.Store) (int, error) {
+	donations := repo.NewDonationRepo(storeDDB)
+	created := 0
+	var errs []error
+	err := donations.ScanProfiles(ctx, func(d repo.Donation) error {
+		ok, err := donations.EnsureProgress(ctx, d.ID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("doacao %s: %w", d.ID, err))
+			return nil
+		}
+		if ok {
+			created++
+		}
+		return nil
+	})
+	return created, errors.Join(append(errs, err)...)
+}
@@ -0,0 +1,252 @@
+package donation
+
+import (
+	"BACK_SORTE_GO/common/config"
+	"BACK_SORTE_GO/common/middleware"
+	"BACK_SORTE_GO/common/repo"
+	"BACK_SORTE_GO/common/store/dynamo"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+)
+
+// postMaxLen limita o texto de uma novidade.
+const postMaxLen = 5000
+
+// ownedDonation le a campanha da URL e confere se o usuario logado e o dono;
+// em caso de erro ja responde e devolve ok=false.
+func ownedDonation(w http.ResponseWriter, r *http.Request, storeDDB dynamo.Store) (repo.Donation, bool) {
+	idUser := middleware.UserIDFromContext(r.Context())
+	if idUser == "" {
+		http.Error(w, "ID do usuario invalido", http.StatusUnauthorized)
+		return repo.Donation{}, false
+	}
+	donation, err := repo.NewDonationRepo(storeDDB).Get(r.Context(), mux.Vars(r)["id"])
+	if err != nil || donation.Dell {
+		http.Error(w, "Doacao nao encontrada", http.StatusNotFound)
+		return repo.Donation{}, false
+	}
+	if donation.IDUser != idUser {
+		http.Error(w, "Usuario nao autorizado a editar esta doacao", http.StatusForbidden)
+		return repo.Donation{}, false
+	}
+	return donation, true
+}
+
+// readPostForm le texto e imagem da novidade (multipart ou urlencoded). A
+// imagem so sobe depois de validado o texto.
+func readPostForm(w http.ResponseWriter, r *http.Request, idUser string, textoRequired bool) (texto, imgPath *string, ok bool) {
+	if err := r.ParseMultipartForm(10 << 20); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		http.Error(w, "Erro ao ler formulario", http.StatusBadRequest)
+		return nil, nil, false
+	}
+	if _, sent := r.Form["texto"]; sent || textoRequired {
+		value := strings.TrimSpace(r.FormValue("texto"))
+		if value == "" {
+			http.Error(w, "Campo 'texto' obrigatorio", http.StatusBadRequest)
+			return nil, nil, false
+		}
+		if utf8.RuneCountInString(value) > postMaxLen {
+			http.Error(w, fmt.Sprintf("Campo 'texto' deve ter no maximo %d caracteres", postMaxLen), http.StatusBadRequest)
+			return nil, nil, false
+		}
+		texto = &value
+	}
+
+	file, header, err := r.FormFile("image")
+	if err == nil {
+		defer file.Close()
+		imgFileName := fmt.Sprintf("%s_%d_%s", idUser, time.Now().Unix(), header.Filename)
+		path, err := uploadImage(file, imgFileName, config.GetawsBucketNameImgDoacao())
+		if err != nil {
+			http.Error(w, "Erro ao subir imagem: "+err.Error(), http.StatusInternalServerError)
+			return nil, nil, false
+		}
+		imgPath = &path
+	}
+	return texto, imgPath, true
+}
+
+// DonationPostCreateHandler publica uma novidade na campanha (texto e imagem
+// opcional) e avisa por e-mail os doadores inscritos.
+func DonationPostCreateHandler(storeDDB dynamo.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		donation, ok := ownedDonation(w, r, storeDDB)
+		if !ok {
+			return
+		}
+		texto, imgPath, ok := readPostForm(w, r, donation.IDUser, true)
+		if !ok {
+			return
+		}
+
+		at := time.Now()
+		post := repo.DonationPost{
+			ID:         repo.NewPostID(at),
+			IDDoacao:   donation.ID,
+			IDUser:     donation.IDUser,
+			Texto:      *texto,
+			DateCreate: at.Format(time.RFC3339),
+		}
+		if imgPath != nil {
+			post.ImgCaminho = *imgPath
+		}
+		ctx := r.Context()
+		if err := repo.NewDonationRepo(storeDDB).CreatePost(ctx, post); err != nil {
+			http.Error(w, "Erro ao publicar novidade: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		notifyPostSubscribers(ctx, storeDDB, donation, post)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": "Novidade publicada com sucesso",
+			"post":    post,
+		})
+	}
+}
+
+// notifyPostSubscribers enfileira um e-mail por doador inscrito; falhas so
+// geram aviso e nao impedem os demais, a novidade ja esta publicada.
+func notifyPostSubscribers(ctx context.Context, storeDDB dynamo.Store, donation repo.Donation, post repo.DonationPost) {
+	subs, err := repo.NewDonationRepo(storeDDB).ListSubscribers(ctx, donation.ID)
+	if err != nil {
+		fmt.Printf("aviso: falha ao buscar inscritos da doacao %s: %v\n", donation.ID, err)
+		return
+	}
+	for _, sub := range subs {
+		if err := sendDonationPostEmailEvent(ctx, sub, donation, post); err != nil {
+			fmt.Printf("aviso: falha ao publicar evento de novidade da doacao %s: %v\n", donation.ID, err)
+			continue
+		}
+	}
+}
+
+// DonationPostsHandler lista as novidades da campanha, da mais recente para a
+// mais antiga, paginadas por cursor.
+func DonationPostsHandler(storeDDB dynamo.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		donations := repo.NewDonationRepo(storeDDB)
+		donation, err := donations.Get(ctx, mux.Vars(r)["id"])
+		if err != nil || donation.Dell {
+			http.Error(w, "Doacao nao encontrada", http.StatusNotFound)
+			return
+		}
+		if status, msg := closedDonationAccess(ctx, donation); status != 0 {
+			http.Error(w, msg, status)
+			return
+		}
+
+		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+		if err != nil || limit < 1 || limit > 50 {
+			limit = 10
+		}
+		posts, next, err := donations.ListPostsPage(ctx, donation.ID, r.URL.Query().Get("cursor"), limit)
+		if errors.Is(err, repo.ErrInvalidCursor) {
+			http.Error(w, "Cursor invalido", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Erro ao buscar novidades: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"items":       posts,
+			"next_cursor": next,
+		})
+	}
+}
+
+// DonationPostEditHandler altera o texto e/ou a imagem de uma novidade.
+func DonationPostEditHandler(storeDDB dynamo.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		donation, ok := ownedDonation(w, r, storeDDB)
+		if !ok {
+			return
+		}
+		ctx := r.Context()
+		donations := repo.NewDonationRepo(storeDDB)
+		post, err := donations.GetPost(ctx, donation.ID, mux.Vars(r)["postId"])
+		if errors.Is(err, repo.ErrNotFound) {
+			http.Error(w, "Novidade nao encontrada", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Erro ao buscar novidade: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		texto, imgPath, ok := readPostForm(w, r, donation.IDUser, false)
+		if !ok {
+			return
+		}
+		if texto == nil && imgPath == nil {
+			http.Error(w, "Informe 'texto' ou 'image'", http.StatusBadRequest)
+			return
+		}
+		if texto != nil {
+			post.Texto = *texto
+		}
+		if imgPath != nil {
+			post.ImgCaminho = *imgPath
+		}
+		post.DateUpdate = time.Now().Format(time.RFC3339)
+
+		err = donations.UpdatePost(ctx, post)
+		if errors.Is(err, repo.ErrNotFound) {
+			http.Error(w, "Novidade nao encontrada", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Erro ao editar novidade: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": "Novidade atualizada com sucesso",
+			"post":    post,
+		})
+	}
+}
+
+// DonationPostDeleteHandler apaga uma novidade da campanha.
+func DonationPostDeleteHandler(storeDDB dynamo.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		donation, ok := ownedDonation(w, r, storeDDB)
+		if !ok {
+			return
+		}
+		ctx := r.Context()
+		donations := repo.NewDonationRepo(storeDDB)
+		post, err := donations.GetPost(ctx, donation.ID, mux.Vars(r)["postId"])
+		if errors.Is(err, repo.ErrNotFound) {
+			http.Error(w, "Novidade nao encontrada", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Erro ao buscar novidade: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := donations.DeletePost(ctx, post); err != nil {
+			http.Error(w, "Erro ao apagar novidade: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"message": "Novidade apagada com sucesso"})
+	}
+}
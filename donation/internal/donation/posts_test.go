@@ -0,0 +1,103 @@
+package donation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"BACK_SORTE_GO/common/middleware"
+	"BACK_SORTE_GO/common/repo"
+	"BACK_SORTE_GO/common/store/dynamo"
+
+	"github.com/gorilla/mux"
+)
+
+func postRequest(t *testing.T, method, userID string, vars, fields map[string]string) *http.Request {
+	t.Helper()
+	r := multipartRequest(t, "/donation/"+vars["id"]+"/posts", fields)
+	r.Method = method
+	r = mux.SetURLVars(r, vars)
+	return r.WithContext(middleware.WithPrincipal(r.Context(), middleware.Principal{UserID: userID}))
+}
+
+func TestDonationPosts(t *testing.T) {
+	stubUpload(t)
+	storeDDB := dynamo.NewMemory("core")
+	created := createDonation(t, storeDDB, "u-1", "Campanha")
+	vars := map[string]string{"id": created["id"]}
+
+	w := httptest.NewRecorder()
+	DonationPostCreateHandler(storeDDB)(w, postRequest(t, http.MethodPost, "u-2", vars, map[string]string{"texto": "x"}))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("outro usuario: status %d", w.Code)
+	}
+
+	var ids []string
+	for i := 0; i < 7; i++ {
+		w := httptest.NewRecorder()
+		DonationPostCreateHandler(storeDDB)(w, postRequest(t, http.MethodPost, "u-1", vars, map[string]string{"texto": fmt.Sprintf("novidade %d", i)}))
+		if w.Code != http.StatusCreated {
+			t.Fatalf("status %d: %s", w.Code, w.Body.String())
+		}
+		var resp struct{ Post repo.DonationPost }
+		json.NewDecoder(w.Body).Decode(&resp)
+		if resp.Post.ImgCaminho == "" {
+			t.Fatal("imagem nao gravada")
+		}
+		ids = append(ids, resp.Post.ID)
+	}
+
+	// A pagina publica traz as primeiras novidades e o cursor do resto.
+	r := httptest.NewRequest(http.MethodGet, "/donation/link/"+created["nome_link"], nil)
+	r = mux.SetURLVars(r, map[string]string{"nome_link": created["nome_link"]})
+	w = httptest.NewRecorder()
+	DonationByLinkHandler(storeDDB)(w, r)
+	var page donationByLink
+	if err := json.NewDecoder(w.Body).Decode(&page); err != nil || len(page.Posts) != linkPostsLimit || page.PostsNextCursor == "" {
+		t.Fatalf("link: %+v, %v", page, err)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/donation/"+created["id"]+"/posts?cursor="+page.PostsNextCursor, nil)
+	w = httptest.NewRecorder()
+	DonationPostsHandler(storeDDB)(w, mux.SetURLVars(r, vars))
+	var rest struct {
+		Items      []repo.DonationPost `json:"items"`
+		NextCursor string              `json:"next_cursor"`
+	}
+	json.NewDecoder(w.Body).Decode(&rest)
+	if w.Code != http.StatusOK || len(rest.Items) != 2 || rest.NextCursor != "" {
+		t.Fatalf("segunda pagina: status %d, %+v", w.Code, rest)
+	}
+	seen := map[string]bool{}
+	for _, p := range append(page.Posts, rest.Items...) {
+		seen[p.ID] = true
+	}
+	if len(seen) != len(ids) {
+		t.Fatalf("paginas repetem novidades: %v", seen)
+	}
+
+	editVars := map[string]string{"id": created["id"], "postId": ids[0]}
+	w = httptest.NewRecorder()
+	DonationPostEditHandler(storeDDB)(w, postRequest(t, http.MethodPatch, "u-1", editVars, map[string]string{"texto": "corrigida"}))
+	if w.Code != http.StatusOK {
+		t.Fatalf("editar: status %d: %s", w.Code, w.Body.String())
+	}
+	donations := repo.NewDonationRepo(storeDDB)
+	if p, err := donations.GetPost(context.Background(), created["id"], ids[0]); err != nil || p.Texto != "corrigida" || p.DateUpdate == "" {
+		t.Fatalf("GetPost = %+v, %v", p, err)
+	}
+
+	w = httptest.NewRecorder()
+	DonationPostDeleteHandler(storeDDB)(w, postRequest(t, http.MethodDelete, "u-1", editVars, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("apagar: status %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	DonationPostDeleteHandler(storeDDB)(w, postRequest(t, http.MethodDelete, "u-1", editVars, nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("apagar de novo: status %d", w.Code)
+	}
+}
@@ -0,0 +1,56 @@
+package donation
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"BACK_SORTE_GO/common/money"
+	"BACK_SORTE_GO/common/repo"
+	"BACK_SORTE_GO/common/store/dynamo"
+
+	"github.com/gorilla/mux"
+)
+
+func summary(t *testing.T, storeDDB dynamo.Store, id string) DonationSummary {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/pix/total/"+id, nil), map[string]string{"id": id})
+	DonationSummaryByIDHandler(storeDDB)(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+	var resp DonationSummary
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestDonationSummaryByIDHandler(t *testing.T) {
+	stubUpload(t)
+	storeDDB := dynamo.NewMemory("core")
+	ctx := context.Background()
+
+	created := createDonation(t, storeDDB, "u-1", "Campanha")
+	writes, err := repo.NewDonationRepo(storeDDB).ProgressWrites(ctx, created["id"], "", money.Cents(30000), "2024-01-02T00:00:00Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Transact(ctx, storeDDB, writes); err != nil {
+		t.Fatal(err)
+	}
+	if got := summary(t, storeDDB, created["id"]); got.ValorTotal != "300.00" || got.Meta != "1500.00" || got.Percentual != 20 {
+		t.Fatalf("resumo = %+v", got)
+	}
+
+	// Id desconhecido segue respondendo 200 com tudo zerado, sem gravar nada.
+	if got := summary(t, storeDDB, "nao-existe"); got.ValorTotal != "0.00" || got.TotalDoadores != 0 {
+		t.Fatalf("id desconhecido = %+v", got)
+	}
+	if item, _ := storeDDB.GetItem(ctx, "DONATION#nao-existe", "AGG"); len(item) != 0 {
+		t.Fatalf("leitura gravou AGG: %+v", item)
+	}
+}
@@ -1,19 +1,37 @@
 package donation
 
 import (
+	"BACK_SORTE_GO/common/middleware"
 	"BACK_SORTE_GO/internal/app"
 
 	"github.com/gorilla/mux"
 )
 
 func RegisterRoutes(router *mux.Router, a *app.App) {
-	router.HandleFunc("/donation", DonationHandler(a.Store)).Methods("POST")
+	auth := middleware.RequireAuth(a.Auth)
+	optionalAuth := middleware.OptionalAuth(a.Auth)
+	recentMFA := middleware.RequireRecentMFA(a.Store)
+	admin := middleware.RequireRole(middleware.RoleAdmin)
+
+	router.Handle("/donation", auth(DonationHandler(a.Store))).Methods("POST")
 	router.HandleFunc("/donation/list", DonationListByIDUserHandler(a.Store)).Methods("GET")
-	router.HandleFunc("/donation/{id}", DonationDellHandler(a.Store)).Methods("DELETE")
-	router.HandleFunc("/donation/link/{nome_link}", DonationByLinkHandler(a.Store)).Methods("GET")
+	router.HandleFunc("/donation/explore", DonationExploreHandler(a.Store)).Methods("GET")
+	router.HandleFunc("/donation/search", DonationSearchHandler(a.Store)).Methods("GET")
+	router.Handle("/donation/{id}", auth(DonationDellHandler(a.Store))).Methods("DELETE")
+	router.Handle("/donation/{id}", auth(DonationEditHandler(a.Store))).Methods("PATCH")
+	router.Handle("/donation/{id}/link", auth(DonationLinkRenameHandler(a.Store))).Methods("PUT")
+	router.Handle("/donation/{id}/edits", auth(DonationEditHistoryHandler(a.Store))).Methods("GET")
+	router.Handle("/donation/{id}/ledger", auth(DonationLedgerHandler(a.Store))).Methods("GET")
+	router.Handle("/donation/{id}/ledger/ajuste", auth(recentMFA(admin(DonationLedgerAdjustHandler(a.Store))))).Methods("POST")
+	router.Handle("/donation/{id}/ledger/abertura", auth(recentMFA(admin(DonationLedgerOpenHandler(a.Store))))).Methods("POST")
+	router.Handle("/donation/{id}/posts", optionalAuth(DonationPostsHandler(a.Store))).Methods("GET")
+	router.Handle("/donation/{id}/posts", auth(DonationPostCreateHandler(a.Store))).Methods("POST")
+	router.Handle("/donation/{id}/posts/{postId}", auth(DonationPostEditHandler(a.Store))).Methods("PATCH")
+	router.Handle("/donation/{id}/posts/{postId}", auth(DonationPostDeleteHandler(a.Store))).Methods("DELETE")
+	router.Handle("/donation/link/{nome_link}", optionalAuth(DonationByLinkHandler(a.Store))).Methods("GET")
 	router.HandleFunc("/donation/mensagem", DonationMensagesHandler(a.Store)).Methods("GET")
-	router.HandleFunc("/donation/closed/{id}", DonationClosedHandler(a.Store)).Methods("GET")
-	router.HandleFunc("/donation/rescue/{id}", DonationRescueHandler(a.Store)).Methods("GET")
+	router.Handle("/donation/closed/{id}", auth(DonationClosedHandler(a.Store))).Methods("GET")
+	router.Handle("/donation/rescue/{id}", auth(recentMFA(DonationRescueHandler(a.Store)))).Methods("GET")
 	router.HandleFunc("/donation/visualization", DonationVisualization(a.Store)).Methods("POST")
 	router.HandleFunc("/donation/createUserAndDonation", DonationCreateSimpleHandler(a.Store)).Methods("POST")
 }
@@ -0,0 +1,68 @@
+package donation
+
+import (
+	"BACK_SORTE_GO/common/repo"
+	"BACK_SORTE_GO/common/store/dynamo"
+	"BACK_SORTE_GO/common/text"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// searchItem e a campanha encontrada na busca, com os termos que casaram.
+type searchItem struct {
+	donationPublic
+	Matches int `json:"matches"`
+}
+
+// DonationSearchHandler busca campanhas abertas pelo titulo ou texto usando o
+// indice invertido TERM# (ver repo.DonationRepo.Search).
+func DonationSearchHandler(storeDDB dynamo.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := strings.TrimSpace(r.URL.Query().Get("q"))
+		if len(text.Tokens(q, 0)) == 0 {
+			http.Error(w, "Parametro 'q' precisa de ao menos uma palavra com 3 letras", http.StatusBadRequest)
+			return
+		}
+		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+		if err != nil || limit < 1 || limit > 50 {
+			limit = 10
+		}
+
+		ctx := r.Context()
+		donations := repo.NewDonationRepo(storeDDB)
+		results, err := donations.Search(ctx, q, limit)
+		if err != nil {
+			http.Error(w, "Erro ao buscar doacoes: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		ids := make([]string, 0, len(results))
+		for _, res := range results {
+			ids = append(ids, res.ID)
+		}
+		details, _, err := donations.DetailsAndPayments(ctx, ids)
+		if err != nil {
+			http.Error(w, "Erro ao buscar detalhes: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		items := make([]searchItem, 0, len(results))
+		for _, res := range results {
+			item := searchItem{donationPublic: donationPublic{Donation: res.Donation}, Matches: res.Matches}
+			if dd, ok := details[res.ID]; ok {
+				item.Texto = dd.Texto
+				item.ImgCaminho = dd.ImgCaminho
+				item.Area = dd.Area
+			}
+			items = append(items, item)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"q":     q,
+			"items": items,
+		})
+	}
+}
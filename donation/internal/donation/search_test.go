@@ -0,0 +1,117 @@
+package donation
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"BACK_SORTE_GO/common/repo"
+	"BACK_SORTE_GO/common/store"
+	"BACK_SORTE_GO/common/store/dynamo"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func search(t *testing.T, storeDDB dynamo.Store, q string) []searchItem {
+	t.Helper()
+	w := httptest.NewRecorder()
+	DonationSearchHandler(storeDDB)(w, httptest.NewRequest(http.MethodGet, "/donation/search?q="+q, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Items []searchItem `json:"items"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp.Items
+}
+
+func TestDonationSearchHandler(t *testing.T) {
+	stubUpload(t)
+	storeDDB := dynamo.NewMemory("core")
+	ctx := context.Background()
+
+	maria := createDonation(t, storeDDB, "u-1", "Cirurgia da Conceição")
+	joao := createDonation(t, storeDDB, "u-2", "Cadeira de rodas para o João")
+	closed := createDonation(t, storeDDB, "u-3", "Cirurgia do Pedro")
+	if err := repo.NewDonationRepo(storeDDB).Close(ctx, closed["id"], "u-3"); err != nil {
+		t.Fatal(err)
+	}
+
+	items := search(t, storeDDB, "conceicao%20cirurgia")
+	if len(items) != 1 || items[0].ID != maria["id"] || items[0].Matches != 2 {
+		t.Fatalf("busca = %+v", items)
+	}
+	if items := search(t, storeDDB, "JOÃO"); len(items) != 1 || items[0].ID != joao["id"] {
+		t.Fatalf("busca com acento = %+v", items)
+	}
+
+	// A edicao do titulo troca os termos indexados.
+	if w := editDonation(t, storeDDB, "u-2", joao["id"], map[string]string{"version": "0", "name": "Cadeira para a Ana"}); w.Code != http.StatusOK {
+		t.Fatalf("edicao: status %d", w.Code)
+	}
+	if items := search(t, storeDDB, "joao"); len(items) != 0 {
+		t.Fatalf("termo removido ainda encontrado: %+v", items)
+	}
+	if items := search(t, storeDDB, "ana%20cadeira"); len(items) != 1 || items[0].Matches != 2 {
+		t.Fatalf("termo novo = %+v", items)
+	}
+}
+
+func TestDonationSearchIndexLifecycle(t *testing.T) {
+	stubUpload(t)
+	storeDDB := dynamo.NewMemory("core")
+	ctx := context.Background()
+	donations := repo.NewDonationRepo(storeDDB)
+
+	closed := createDonation(t, storeDDB, "u-1", "Cirurgia do Pedro")
+	deleted := createDonation(t, storeDDB, "u-2", "Cirurgia da Ana")
+	if err := donations.Close(ctx, closed["id"], "u-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := donations.MarkDeleted(ctx, deleted["id"], "u-2"); err != nil {
+		t.Fatal(err)
+	}
+	out, err := storeDDB.Query(ctx, &dynamodb.QueryInput{
+		KeyConditionExpression:    aws.String("PK = :pk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":pk": dynamo.S(store.TermPK("cirurgia"))},
+	})
+	if err != nil || len(out.Items) != 0 {
+		t.Fatalf("TERM# apos encerrar/excluir = %d, %v", len(out.Items), err)
+	}
+	// A edicao de uma campanha encerrada nao devolve os termos ao indice.
+	if w := editDonation(t, storeDDB, "u-1", closed["id"], map[string]string{"version": "1", "name": "Cirurgia urgente"}); w.Code != http.StatusOK {
+		t.Fatalf("edicao: status %d: %s", w.Code, w.Body.String())
+	}
+	if out, _ := storeDDB.Query(ctx, &dynamodb.QueryInput{
+		KeyConditionExpression:    aws.String("PK = :pk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":pk": dynamo.S(store.TermPK("urgente"))},
+	}); len(out.Items) != 0 {
+		t.Fatalf("campanha encerrada reindexada: %+v", out.Items)
+	}
+
+	// Campanha anterior a busca: sem terms nem TERM#.
+	legacy := map[string]types.AttributeValue{
+		"PK": dynamo.S("DONATION#legada"), "SK": dynamo.S("PROFILE"),
+		"id": dynamo.S("legada"), "id_user": dynamo.S("u-3"), "name": dynamo.S("Cirurgia do Joao"),
+		"valor": dynamo.N("1000"), "active": dynamo.B(true), "date_create": dynamo.S("2020-01-01T00:00:00Z"),
+	}
+	if err := storeDDB.PutItem(ctx, legacy); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := RunMigration(ctx, storeDDB, "busca"); err != nil || n != 1 {
+		t.Fatalf("migracao busca = %d, %v", n, err)
+	}
+	if items := search(t, storeDDB, "cirurgia%20joao"); len(items) != 1 || items[0].ID != "legada" || items[0].Matches != 2 {
+		t.Fatalf("busca apos migracao = %+v", items)
+	}
+	if n, err := RunMigration(ctx, storeDDB, "busca"); err != nil || n != 0 {
+		t.Fatalf("migracao repetida = %d, %v", n, err)
+	}
+}
@@ -2,13 +2,16 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"log"
 	"net/http"
+	"time"
 
+	"BACK_SORTE_GO/common/middleware"
 	"BACK_SORTE_GO/internal/app"
 	"BACK_SORTE_GO/internal/donation"
-	"BACK_SORTE_GO/internal/middleware"
 
+	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/awslabs/aws-lambda-go-api-proxy/httpadapter"
 	"github.com/gorilla/mux"
@@ -29,5 +32,29 @@ func main() {
 	donation.RegisterRoutes(router, a)
 
 	adapter := httpadapter.NewV2(router)
-	lambda.Start(adapter.ProxyWithContext)
+	lambda.Start(func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		// O agendamento do EventBridge encerra as campanhas vencidas; o resto e HTTP.
+		var schedule events.CloudWatchEvent
+		if err := json.Unmarshal(raw, &schedule); err == nil && schedule.Source == "aws.events" {
+			closed, err := donation.CloseExpiredDonations(ctx, a.Store, time.Now())
+			log.Printf("encerramento automatico: %d campanhas encerradas", closed)
+			return map[string]int{"closed": closed}, err
+		}
+
+		// Migracoes pontuais chegam por invocacao direta: {"migracao": "feed"}.
+		var migration struct {
+			Migracao string `json:"migracao"`
+		}
+		if err := json.Unmarshal(raw, &migration); err == nil && migration.Migracao != "" {
+			done, err := donation.RunMigration(ctx, a.Store, migration.Migracao)
+			log.Printf("migracao %s: %d itens", migration.Migracao, done)
+			return map[string]int{"itens": done}, err
+		}
+
+		var req events.APIGatewayV2HTTPRequest
+		if err := json.Unmarshal(raw, &req); err != nil {
+			return nil, err
+		}
+		return adapter.ProxyWithContext(ctx, req)
+	})
 }
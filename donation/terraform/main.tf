@@ -139,3 +139,23 @@ resource "aws_lambda_permission" "donation" {
   principal     = "apigateway.amazonaws.com"
   source_arn    = "${aws_apigatewayv2_api.http.execution_arn}/*/*/donation/*"
 }
+
+resource "aws_cloudwatch_event_rule" "close_expired" {
+  name                = "${var.project_name}-donation-close-expired"
+  description         = "Encerra as campanhas com date_end vencida."
+  schedule_expression = var.close_expired_schedule_expression
+}
+
+resource "aws_cloudwatch_event_target" "close_expired_target" {
+  rule      = aws_cloudwatch_event_rule.close_expired.name
+  target_id = "donation-close-expired"
+  arn       = aws_lambda_function.donation.arn
+}
+
+resource "aws_lambda_permission" "allow_eventbridge_close_expired" {
+  statement_id  = "AllowExecutionFromEventBridgeCloseExpired"
+  action        = "lambda:InvokeFunction"
+  function_name = aws_lambda_function.donation.function_name
+  principal     = "events.amazonaws.com"
+  source_arn    = aws_cloudwatch_event_rule.close_expired.arn
+}
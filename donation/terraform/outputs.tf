@@ -9,3 +9,7 @@ output "email_events_queue_arn" {
 output "email_events_queue_url" {
   value = aws_sqs_queue.email_events.url
 }
+
+output "close_expired_schedule_rule" {
+  value = aws_cloudwatch_event_rule.close_expired.name
+}
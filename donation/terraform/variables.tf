@@ -18,8 +18,8 @@ variable "aws_bucket_name_img_doacao" {
 }
 
 variable "jwt_secret" {
-  type    = string
-  default = ""
+  type      = string
+  sensitive = true
 }
 
 variable "lambda_zip" {
@@ -35,3 +35,8 @@ variable "app_base_url" {
   type    = string
   default = "https://www.thepuregrace.com"
 }
+
+variable "close_expired_schedule_expression" {
+  type    = string
+  default = "rate(1 hour)"
+}
@@ -58,6 +58,66 @@ resource "aws_dynamodb_table" "core" {
     type = "S"
   }
 
+  attribute {
+    name = "GSI3PK"
+    type = "S"
+  }
+
+  attribute {
+    name = "GSI3SK"
+    type = "S"
+  }
+
+  attribute {
+    name = "GSI4PK"
+    type = "S"
+  }
+
+  attribute {
+    name = "GSI4SK"
+    type = "S"
+  }
+
+  attribute {
+    name = "GSI5PK"
+    type = "S"
+  }
+
+  attribute {
+    name = "GSI5SK"
+    type = "S"
+  }
+
+  attribute {
+    name = "GSI6PK"
+    type = "S"
+  }
+
+  attribute {
+    name = "GSI6SK"
+    type = "N"
+  }
+
+  attribute {
+    name = "GSI7PK"
+    type = "S"
+  }
+
+  attribute {
+    name = "GSI7SK"
+    type = "N"
+  }
+
+  attribute {
+    name = "GSI8PK"
+    type = "S"
+  }
+
+  attribute {
+    name = "GSI8SK"
+    type = "S"
+  }
+
   global_secondary_index {
     name               = "GSI1"
     hash_key           = "GSI1PK"
@@ -76,6 +136,65 @@ resource "aws_dynamodb_table" "core" {
     write_capacity     = 7
   }
 
+  global_secondary_index {
+    name               = "GSI3"
+    hash_key           = "GSI3PK"
+    range_key          = "GSI3SK"
+    projection_type    = "ALL"
+    read_capacity      = 7
+    write_capacity     = 7
+  }
+
+  global_secondary_index {
+    name               = "GSI4"
+    hash_key           = "GSI4PK"
+    range_key          = "GSI4SK"
+    projection_type    = "ALL"
+    read_capacity      = 7
+    write_capacity     = 7
+  }
+
+  global_secondary_index {
+    name               = "GSI5"
+    hash_key           = "GSI5PK"
+    range_key          = "GSI5SK"
+    projection_type    = "ALL"
+    read_capacity      = 7
+    write_capacity     = 7
+  }
+
+  global_secondary_index {
+    name               = "GSI6"
+    hash_key           = "GSI6PK"
+    range_key          = "GSI6SK"
+    projection_type    = "ALL"
+    read_capacity      = 7
+    write_capacity     = 7
+  }
+
+  global_secondary_index {
+    name               = "GSI7"
+    hash_key           = "GSI7PK"
+    range_key          = "GSI7SK"
+    projection_type    = "ALL"
+    read_capacity      = 7
+    write_capacity     = 7
+  }
+
+  global_secondary_index {
+    name               = "GSI8"
+    hash_key           = "GSI8PK"
+    range_key          = "GSI8SK"
+    projection_type    = "ALL"
+    read_capacity      = 7
+    write_capacity     = 7
+  }
+
+  ttl {
+    attribute_name = "ttl"
+    enabled        = true
+  }
+
   point_in_time_recovery {
     enabled = var.enable_pitr
   }
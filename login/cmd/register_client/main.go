@@ -0,0 +1,87 @@
+// register_client grava um cliente (CLIENT#{id}) no DynamoDB. Quando -secret nao
+// e informado, gera um segredo aleatorio e o imprime uma unica vez.
+//
+//	go run ./cmd/register_client -id site -name "Site" -grants password,refresh_token \
+//	  -origins https://www.thepuregrace.com,https://thepuregrace.com
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	"BACK_SORTE_GO/common/config"
+	"BACK_SORTE_GO/common/store/dynamo"
+	"BACK_SORTE_GO/internal/login"
+
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+func main() {
+	id := flag.String("id", "", "client_id")
+	name := flag.String("name", "", "nome do cliente")
+	secret := flag.String("secret", "", "client_secret (gerado quando vazio)")
+	grants := flag.String("grants", login.GrantPassword+","+login.GrantRefreshToken, "grant types permitidos, separados por virgula")
+	origins := flag.String("origins", "", "origins permitidos, separados por virgula")
+	scopes := flag.String("scopes", "", "escopos dos tokens client_credentials, separados por virgula")
+	flag.Parse()
+
+	if *id == "" {
+		log.Fatal("-id e obrigatorio")
+	}
+
+	config.LoadEnv()
+	region := config.GetAwsRegion()
+	table := config.GetDynamoTableName()
+	if region == "" || table == "" {
+		log.Fatal("AWS_REGION e DYNAMODB_TABLE sao obrigatorios")
+	}
+
+	ctx := context.Background()
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(region))
+	if err != nil {
+		log.Fatalf("erro ao carregar config AWS: %v", err)
+	}
+	storeDDB := dynamo.New(dynamodb.NewFromConfig(cfg), table)
+
+	clientSecret := *secret
+	if clientSecret == "" {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			log.Fatalf("erro ao gerar segredo: %v", err)
+		}
+		clientSecret = base64.RawURLEncoding.EncodeToString(b)
+	}
+
+	client := login.Client{
+		ID:             *id,
+		Name:           *name,
+		GrantTypes:     splitList(*grants),
+		AllowedOrigins: splitList(*origins),
+		Scopes:         splitList(*scopes),
+		Active:         true,
+	}
+	if err := login.PutClient(ctx, storeDDB, client, clientSecret); err != nil {
+		log.Fatalf("erro ao gravar cliente: %v", err)
+	}
+
+	fmt.Printf("client_id=%s\n", client.ID)
+	if *secret == "" {
+		fmt.Printf("client_secret=%s\n", clientSecret)
+	}
+}
+
+func splitList(value string) []string {
+	var out []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
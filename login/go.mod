@@ -5,6 +5,7 @@ go 1.23.0
 toolchain go1.23.4
 
 require (
+	BACK_SORTE_GO/common v0.0.0
 	github.com/aws/aws-lambda-go v1.47.0
 	github.com/awslabs/aws-lambda-go-api-proxy v0.16.1
 	github.com/golang-jwt/jwt/v4 v4.5.1
@@ -17,6 +18,7 @@ require (
 
 require (
 	github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue v1.20.31
+	github.com/aws/aws-sdk-go-v2/service/sqs v1.42.21
 	github.com/efipay/sdk-go-apis-efi v0.0.0-20231207185217-6dca10834f8f
 	golang.org/x/text v0.26.0
 )
@@ -50,3 +52,5 @@ require (
 )
 
 replace github.com/lib/pq => github.com/lib/pq v1.10.9
+
+replace BACK_SORTE_GO/common => ../common
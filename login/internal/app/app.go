@@ -4,15 +4,17 @@ import (
 	"context"
 	"fmt"
 
-	"BACK_SORTE_GO/config"
-	"BACK_SORTE_GO/internal/store/dynamo"
+	"BACK_SORTE_GO/common/config"
+	"BACK_SORTE_GO/common/middleware"
+	"BACK_SORTE_GO/common/store/dynamo"
 
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 )
 
 type App struct {
-	Store *dynamo.Store
+	Store dynamo.Store
+	Auth  middleware.AuthConfig
 }
 
 func New(ctx context.Context) (*App, error) {
@@ -28,6 +30,11 @@ func New(ctx context.Context) (*App, error) {
 		return nil, fmt.Errorf("DYNAMODB_TABLE nao definido")
 	}
 
+	auth := middleware.AuthConfigFromEnv()
+	if len(auth.Secret) == 0 {
+		return nil, fmt.Errorf("JWT_SECRET nao definido")
+	}
+
 	cfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(region))
 	if err != nil {
 		return nil, fmt.Errorf("erro ao carregar config AWS: %w", err)
@@ -36,5 +43,5 @@ func New(ctx context.Context) (*App, error) {
 	ddb := dynamodb.NewFromConfig(cfg)
 	store := dynamo.New(ddb, table)
 
-	return &App{Store: store}, nil
+	return &App{Store: store, Auth: auth}, nil
 }
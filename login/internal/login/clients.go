@@ -0,0 +1,164 @@
+package login
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"BACK_SORTE_GO/common/store"
+	"BACK_SORTE_GO/common/store/dynamo"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	GrantPassword          = "password"
+	GrantRefreshToken      = "refresh_token"
+	GrantClientCredentials = "client_credentials"
+	// GrantMFAOTP conclui o desafio de segundo fator aberto pelo grant password.
+	GrantMFAOTP = "mfa_otp"
+
+	clientSK = "CLIENT"
+)
+
+var errClientInvalid = errors.New("cliente invalido")
+
+// Client e um cliente registrado (CLIENT#{id} / CLIENT). O segredo fica somente
+// como hash bcrypt; GrantTypes limita os fluxos aceitos, AllowedOrigins os
+// Origins de navegador e Scopes os escopos dos tokens client_credentials.
+type Client struct {
+	ID             string   `dynamodbav:"id"`
+	Name           string   `dynamodbav:"name"`
+	SecretHash     string   `dynamodbav:"secret_hash"`
+	GrantTypes     []string `dynamodbav:"grant_types"`
+	AllowedOrigins []string `dynamodbav:"allowed_origins"`
+	Scopes         []string `dynamodbav:"scopes"`
+	Active         bool     `dynamodbav:"active"`
+	DateCreate     string   `dynamodbav:"date_create"`
+}
+
+// AllowsGrant confere o grant no cadastro. mfa_otp e a continuacao do password
+// e vale para todo cliente que aceita password.
+func (c Client) AllowsGrant(grantType string) bool {
+	if grantType == GrantMFAOTP {
+		return containsString(c.GrantTypes, GrantPassword)
+	}
+	return containsString(c.GrantTypes, grantType)
+}
+
+// AllowsOrigin aceita chamadas sem Origin (servidor a servidor); com Origin, ele
+// precisa estar cadastrado no cliente.
+func (c Client) AllowsOrigin(origin string) bool {
+	return origin == "" || containsString(c.AllowedOrigins, origin)
+}
+
+// GrantScopes resolve o parametro scope do client_credentials: vazio concede
+// todos os escopos do cliente; senao cada escopo pedido precisa estar cadastrado.
+func (c Client) GrantScopes(requested string) ([]string, bool) {
+	fields := strings.Fields(requested)
+	if len(fields) == 0 {
+		return c.Scopes, true
+	}
+	for _, scope := range fields {
+		if !containsString(c.Scopes, scope) {
+			return nil, false
+		}
+	}
+	return fields, true
+}
+
+// PutClient grava (ou substitui) um cliente com o hash do segredo informado.
+func PutClient(ctx context.Context, storeDDB dynamo.Store, client Client, secret string) error {
+	if client.ID == "" || secret == "" {
+		return errClientInvalid
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	client.SecretHash = string(hash)
+	if client.DateCreate == "" {
+		client.DateCreate = time.Now().UTC().Format(time.RFC3339)
+	}
+
+	item, err := attributevalue.MarshalMap(client)
+	if err != nil {
+		return err
+	}
+	item["PK"] = dynamo.S(store.ClientPK(client.ID))
+	item["SK"] = dynamo.S(clientSK)
+	return storeDDB.PutItem(ctx, item)
+}
+
+// authenticateClient valida a credencial Basic {client_id}:{client_secret} e o Origin da requisicao.
+func authenticateClient(ctx context.Context, storeDDB dynamo.Store, r *http.Request) (Client, error) {
+	clientID, secret, ok := r.BasicAuth()
+	if !ok || clientID == "" || secret == "" {
+		return Client{}, errClientInvalid
+	}
+
+	item, err := storeDDB.GetItem(ctx, store.ClientPK(clientID), clientSK)
+	if err != nil {
+		return Client{}, err
+	}
+	if len(item) == 0 {
+		return Client{}, errClientInvalid
+	}
+
+	var client Client
+	if err := attributevalue.UnmarshalMap(item, &client); err != nil {
+		return Client{}, err
+	}
+	if !client.Active || client.SecretHash == "" {
+		return Client{}, errClientInvalid
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(client.SecretHash), []byte(secret)); err != nil {
+		return Client{}, errClientInvalid
+	}
+	if !client.AllowsOrigin(r.Header.Get("Origin")) {
+		return Client{}, errClientInvalid
+	}
+	return client, nil
+}
+
+// tokenRequestClient faz as validacoes comuns dos endpoints de token: content-type
+// de formulario, leitura do formulario e cliente autenticado. Em caso de falha ja
+// responde a requisicao e retorna false.
+func tokenRequestClient(w http.ResponseWriter, r *http.Request, storeDDB dynamo.Store) (Client, bool) {
+	if r.Header.Get("Content-Type") != "application/x-www-form-urlencoded" {
+		http.Error(w, "Cabecalhos invalidos", http.StatusUnauthorized)
+		return Client{}, false
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Erro ao processar os parametros", http.StatusBadRequest)
+		return Client{}, false
+	}
+
+	client, err := authenticateClient(r.Context(), storeDDB, r)
+	if errors.Is(err, errClientInvalid) {
+		http.Error(w, "Cliente invalido", http.StatusUnauthorized)
+		return Client{}, false
+	}
+	if err != nil {
+		http.Error(w, "Erro ao validar cliente", http.StatusInternalServerError)
+		return Client{}, false
+	}
+	return client, true
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func joinScopes(scopes []string) string {
+	return strings.Join(scopes, " ")
+}
@@ -0,0 +1,87 @@
+package login
+
+import (
+	"BACK_SORTE_GO/common/config"
+	"BACK_SORTE_GO/common/repo"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+const loginEmailEventTypeAccountLocked = "email-conta-bloqueada"
+
+type loginEmailEvent struct {
+	Type           string `json:"type"`
+	UserID         string `json:"user_id"`
+	RecipientName  string `json:"recipient_name"`
+	RecipientEmail string `json:"recipient_email"`
+	LockedUntil    string `json:"locked_until"`
+	CreatedAt      string `json:"created_at"`
+}
+
+var (
+	loginSQSClientOnce sync.Once
+	loginSQSClient     *sqs.Client
+	loginSQSClientErr  error
+)
+
+func getLoginSQSClient(ctx context.Context) (*sqs.Client, error) {
+	loginSQSClientOnce.Do(func() {
+		region := config.GetAwsRegion()
+		if region == "" {
+			loginSQSClientErr = fmt.Errorf("AWS_REGION nao definido")
+			return
+		}
+		cfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(region))
+		if err != nil {
+			loginSQSClientErr = fmt.Errorf("erro ao carregar config AWS para SQS: %w", err)
+			return
+		}
+		loginSQSClient = sqs.NewFromConfig(cfg)
+	})
+	return loginSQSClient, loginSQSClientErr
+}
+
+func publishLoginEmailEvent(ctx context.Context, event loginEmailEvent) error {
+	queueURL := os.Getenv("EMAIL_EVENTS_QUEUE_URL")
+	if queueURL == "" {
+		return fmt.Errorf("EMAIL_EVENTS_QUEUE_URL nao definido")
+	}
+
+	client, err := getLoginSQSClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("erro ao serializar evento de email: %w", err)
+	}
+
+	body := string(payload)
+	_, err = client.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:    &queueURL,
+		MessageBody: &body,
+	})
+	if err != nil {
+		return fmt.Errorf("erro ao enviar evento para SQS: %w", err)
+	}
+	return nil
+}
+
+func sendAccountLockedEvent(ctx context.Context, user repo.User, lockedUntil time.Time) error {
+	return publishLoginEmailEvent(ctx, loginEmailEvent{
+		Type:           loginEmailEventTypeAccountLocked,
+		UserID:         user.ID,
+		RecipientName:  user.Name,
+		RecipientEmail: user.Email,
+		LockedUntil:    lockedUntil.Format(time.RFC3339),
+		CreatedAt:      time.Now().Format(time.RFC3339),
+	})
+}
@@ -1,24 +1,22 @@
-﻿package login
+package login
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 	"strings"
 	"time"
 
-	"BACK_SORTE_GO/internal/store"
-	"BACK_SORTE_GO/internal/store/dynamo"
+	"BACK_SORTE_GO/common/middleware"
+	"BACK_SORTE_GO/common/repo"
+	"BACK_SORTE_GO/common/store"
+	"BACK_SORTE_GO/common/store/dynamo"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
-	"github.com/golang-jwt/jwt/v4"
 	"golang.org/x/crypto/bcrypt"
 )
 
-var jwtSecretKey = []byte("SUA_CHAVE_SECRETA")
-
 type ContaNivel struct {
 	ID            string     `json:"id"`
 	IDUser        string     `json:"id_user"`
@@ -30,32 +28,11 @@ type ContaNivel struct {
 	DataUpdate    time.Time  `json:"data_update"`
 }
 
-type userItem struct {
-	ID         string `dynamodbav:"id"`
-	Name       string `dynamodbav:"name"`
-	Email      string `dynamodbav:"email"`
-	Password   string `dynamodbav:"password"`
-	CPF        string `dynamodbav:"cpf"`
-	Active     bool   `dynamodbav:"active"`
-	Inicial    bool   `dynamodbav:"inicial"`
-	Dell       bool   `dynamodbav:"dell"`
-	DateCreate string `dynamodbav:"date_create"`
-}
-
-type contaNivelItem struct {
-	ID            string `dynamodbav:"id"`
-	IDUser        string `dynamodbav:"id_user"`
-	Nivel         string `dynamodbav:"nivel"`
-	Ativo         bool   `dynamodbav:"ativo"`
-	Status        string `dynamodbav:"status"`
-	DataPagamento string `dynamodbav:"data_pagamento"`
-	TipoPagamento string `dynamodbav:"tipo_pagamento"`
-	DataUpdate    string `dynamodbav:"data_update"`
-}
-
 type LoginResponse struct {
-	Token string `json:"token"`
-	User  struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+	User         struct {
 		ID         string    `json:"id"`
 		Name       string    `json:"name"`
 		Email      string    `json:"email"`
@@ -63,126 +40,179 @@ type LoginResponse struct {
 		Active     bool      `json:"active"`
 		Inicial    bool      `json:"inicial"`
 		Dell       bool      `json:"dell"`
+		Roles      []string  `json:"roles,omitempty"`
 		DateCreate time.Time `json:"date_create"`
 	} `json:"user"`
 	ContaNivel *ContaNivel `json:"conta_nivel,omitempty"`
 }
 
-func LoginHandler(storeDDB *dynamo.Store) http.HandlerFunc {
+// LoginHandler e o endpoint de token: autentica o cliente (Basic) e atende os
+// grants password, refresh_token, client_credentials e mfa_otp permitidos a ele.
+func LoginHandler(storeDDB dynamo.Store, auth middleware.AuthConfig) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
-		contentType := r.Header.Get("Content-Type")
-		if authHeader != "Basic QVBJX05BTUVfQUNDRVNTOkFQSV9TRUNSRVRfQUNDRVNT" || contentType != "application/x-www-form-urlencoded" {
-			http.Error(w, "Cabecalhos invalidos", http.StatusUnauthorized)
+		client, ok := tokenRequestClient(w, r, storeDDB)
+		if !ok {
 			return
 		}
 
-		if err := r.ParseForm(); err != nil {
-			http.Error(w, "Erro ao processar os parametros", http.StatusBadRequest)
+		grantType := r.FormValue("grant_type")
+		if !client.AllowsGrant(grantType) {
+			http.Error(w, "grant_type nao permitido para o cliente", http.StatusBadRequest)
 			return
 		}
 
-		username := r.FormValue("username")
-		password := r.FormValue("password")
-		grantType := r.FormValue("grant_type")
-
-		if grantType != "password" || username == "" || password == "" {
+		switch grantType {
+		case GrantPassword:
+			passwordGrant(w, r, storeDDB, auth, client)
+		case GrantRefreshToken:
+			refreshTokenGrant(w, r, storeDDB, auth, client)
+		case GrantClientCredentials:
+			clientCredentialsGrant(w, r, auth, client)
+		case GrantMFAOTP:
+			mfaOTPGrant(w, r, storeDDB, auth, client)
+		default:
 			http.Error(w, "Parametros invalidos", http.StatusBadRequest)
-			return
 		}
+	}
+}
 
-		ctx := r.Context()
-		out, err := storeDDB.Query(ctx, &dynamodb.QueryInput{
-			IndexName:              aws.String("GSI2"),
-			KeyConditionExpression: aws.String("GSI2PK = :pk"),
-			ExpressionAttributeValues: map[string]types.AttributeValue{
-				":pk": dynamo.S("EMAIL#" + strings.ToLower(username)),
-			},
-			Limit: aws.Int32(1),
-		})
-		if err != nil || len(out.Items) == 0 {
-			http.Error(w, "Usuario ou senha invalidos", http.StatusUnauthorized)
-			return
-		}
+func passwordGrant(w http.ResponseWriter, r *http.Request, storeDDB dynamo.Store, auth middleware.AuthConfig, client Client) {
+	username := r.FormValue("username")
+	password := r.FormValue("password")
 
-		var user userItem
-		if err := attributevalue.UnmarshalMap(out.Items[0], &user); err != nil {
-			http.Error(w, "Erro ao buscar usuario", http.StatusInternalServerError)
-			return
-		}
+	if username == "" || password == "" {
+		http.Error(w, "Parametros invalidos", http.StatusBadRequest)
+		return
+	}
 
-		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
-			http.Error(w, "Usuario ou senha invalidos", http.StatusUnauthorized)
-			return
-		}
+	ctx := r.Context()
+	email := strings.ToLower(username)
+	ip := remoteIP(r.RemoteAddr)
 
-		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-			"sub": user.ID,
-			"exp": time.Now().Add(time.Hour * 24).Unix(),
-		})
-		tokenString, err := token.SignedString(jwtSecretKey)
-		if err != nil {
-			http.Error(w, "Erro ao gerar token", http.StatusInternalServerError)
-			return
-		}
+	block, err := checkLoginAllowed(ctx, storeDDB, email, ip)
+	if err != nil {
+		http.Error(w, "Erro ao validar tentativas de login", http.StatusInternalServerError)
+		return
+	}
+	if block != nil {
+		writeLoginBlocked(w, *block)
+		return
+	}
+
+	user, err := repo.NewUserRepo(storeDDB).FindByEmail(ctx, email)
+	if errors.Is(err, repo.ErrNotFound) {
+		rejectLogin(ctx, w, storeDDB, email, ip, nil)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Usuario ou senha invalidos", http.StatusUnauthorized)
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		rejectLogin(ctx, w, storeDDB, email, ip, &user)
+		return
+	}
+
+	if err := resetFailures(ctx, storeDDB, store.LoginFailEmailPK(email)); err != nil {
+		log.Printf("erro ao zerar falhas de login: %v", err)
+	}
+
+	mfa, err := loadUserMFA(ctx, storeDDB, user.ID)
+	if err != nil {
+		http.Error(w, "Erro ao verificar segundo fator", http.StatusInternalServerError)
+		return
+	}
+	if mfa.Enabled {
+		writeMFAChallenge(ctx, w, storeDDB, user.ID, client.ID)
+		return
+	}
+
+	writeLoginResponse(w, r, storeDDB, auth, client, user, time.Time{})
+}
+
+// writeLoginResponse emite o token de acesso, abre a sessao e responde com os
+// dados do usuario. mfaAt e o momento da verificacao TOTP, quando houve.
+func writeLoginResponse(w http.ResponseWriter, r *http.Request, storeDDB dynamo.Store, auth middleware.AuthConfig, client Client, user repo.User, mfaAt time.Time) {
+	ctx := r.Context()
+	tokenString, expiresAt, err := issueAccessToken(auth, user.ID, client.ID, user.Roles, mfaAt)
+	if err != nil {
+		http.Error(w, "Erro ao gerar token", http.StatusInternalServerError)
+		return
+	}
 
-		var contaNivel *ContaNivel
-		if item, err := storeDDB.GetItem(ctx, store.UserPK(user.ID), "ACCOUNT#LEVEL"); err == nil && len(item) > 0 {
-			var raw contaNivelItem
-			if err := attributevalue.UnmarshalMap(item, &raw); err == nil {
-				var dtUpdate time.Time
-				if raw.DataUpdate != "" {
-					dtUpdate, _ = time.Parse(time.RFC3339, raw.DataUpdate)
-				}
-				var dtPag *time.Time
-				if raw.DataPagamento != "" {
-					if t, err := time.Parse(time.RFC3339, raw.DataPagamento); err == nil {
-						dtPag = &t
-					}
-				}
-				contaNivel = &ContaNivel{
-					ID:            raw.ID,
-					IDUser:        raw.IDUser,
-					Nivel:         raw.Nivel,
-					Ativo:         raw.Ativo,
-					Status:        raw.Status,
-					DataPagamento: dtPag,
-					TipoPagamento: raw.TipoPagamento,
-					DataUpdate:    dtUpdate,
-				}
+	refreshToken, err := createSession(ctx, storeDDB, user.ID, client.ID, r.UserAgent(), r.RemoteAddr, mfaAt)
+	if err != nil {
+		http.Error(w, "Erro ao criar sessao", http.StatusInternalServerError)
+		return
+	}
+
+	var contaNivel *ContaNivel
+	if raw, err := repo.NewUserRepo(storeDDB).GetAccountLevel(ctx, user.ID); err == nil {
+		var dtUpdate time.Time
+		if raw.DataUpdate != "" {
+			dtUpdate, _ = time.Parse(time.RFC3339, raw.DataUpdate)
+		}
+		var dtPag *time.Time
+		if raw.DataPagamento != "" {
+			if t, err := time.Parse(time.RFC3339, raw.DataPagamento); err == nil {
+				dtPag = &t
 			}
 		}
-
-		response := LoginResponse{
-			Token: tokenString,
-			User: struct {
-				ID         string    `json:"id"`
-				Name       string    `json:"name"`
-				Email      string    `json:"email"`
-				CPF        string    `json:"cpf"`
-				Active     bool      `json:"active"`
-				Inicial    bool      `json:"inicial"`
-				Dell       bool      `json:"dell"`
-				DateCreate time.Time `json:"date_create"`
-			}{
-				ID:         user.ID,
-				Name:       user.Name,
-				Email:      user.Email,
-				CPF:        user.CPF,
-				Active:     user.Active,
-				Inicial:    user.Inicial,
-				Dell:       user.Dell,
-				DateCreate: parseTimeOrNow(user.DateCreate),
-			},
+		contaNivel = &ContaNivel{
+			ID:            raw.ID,
+			IDUser:        raw.IDUser,
+			Nivel:         raw.Nivel,
+			Ativo:         raw.Ativo,
+			Status:        raw.Status,
+			DataPagamento: dtPag,
+			TipoPagamento: raw.TipoPagamento,
+			DataUpdate:    dtUpdate,
 		}
+	}
 
-		if contaNivel != nil {
-			response.ContaNivel = contaNivel
-		}
+	response := LoginResponse{
+		Token:        tokenString,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(time.Until(expiresAt).Seconds()),
+		User: struct {
+			ID         string    `json:"id"`
+			Name       string    `json:"name"`
+			Email      string    `json:"email"`
+			CPF        string    `json:"cpf"`
+			Active     bool      `json:"active"`
+			Inicial    bool      `json:"inicial"`
+			Dell       bool      `json:"dell"`
+			Roles      []string  `json:"roles,omitempty"`
+			DateCreate time.Time `json:"date_create"`
+		}{
+			ID:         user.ID,
+			Name:       user.Name,
+			Email:      user.Email,
+			CPF:        user.CPF,
+			Active:     user.Active,
+			Inicial:    user.Inicial,
+			Dell:       user.Dell,
+			Roles:      user.Roles,
+			DateCreate: parseTimeOrNow(user.DateCreate),
+		},
+	}
+
+	if contaNivel != nil {
+		response.ContaNivel = contaNivel
+	}
 
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// rejectLogin registra a falha e responde 401, ou 423 se ela bloqueou a conta.
+func rejectLogin(ctx context.Context, w http.ResponseWriter, storeDDB dynamo.Store, email, ip string, user *repo.User) {
+	if lockedUntil := registerLoginFailure(ctx, storeDDB, email, ip, user); !lockedUntil.IsZero() {
+		writeLoginBlocked(w, loginBlock{Locked: true, Until: lockedUntil})
+		return
 	}
+	http.Error(w, "Usuario ou senha invalidos", http.StatusUnauthorized)
 }
 
 func parseTimeOrNow(value string) time.Time {
@@ -194,4 +224,3 @@ func parseTimeOrNow(value string) time.Time {
 	}
 	return time.Now()
 }
-
@@ -0,0 +1,235 @@
+package login
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"BACK_SORTE_GO/common/middleware"
+	"BACK_SORTE_GO/common/store"
+	"BACK_SORTE_GO/common/store/dynamo"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	testClientID     = "web"
+	testClientSecret = "segredo-web"
+	testUserID       = "u-1"
+	testEmail        = "Maria@Example.com"
+	testPassword     = "senha-forte"
+)
+
+var testAuth = middleware.AuthConfig{Secret: []byte("segredo-de-teste"), Issuer: "back-sorte"}
+
+func newTestStore(t *testing.T) *dynamo.MemoryStore {
+	t.Helper()
+	ctx := context.Background()
+	storeDDB := dynamo.NewMemory("core")
+
+	err := PutClient(ctx, storeDDB, Client{
+		ID:         testClientID,
+		Name:       "Site",
+		GrantTypes: []string{GrantPassword, GrantRefreshToken},
+		Active:     true,
+	}, testClientSecret)
+	if err != nil {
+		t.Fatalf("PutClient: %v", err)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = storeDDB.PutItem(ctx, map[string]types.AttributeValue{
+		"PK":          dynamo.S(store.UserPK(testUserID)),
+		"SK":          dynamo.S("PROFILE"),
+		"GSI2PK":      dynamo.S("EMAIL#" + strings.ToLower(testEmail)),
+		"GSI2SK":      dynamo.S(store.UserPK(testUserID)),
+		"id":          dynamo.S(testUserID),
+		"name":        dynamo.S("Maria"),
+		"email":       dynamo.S(testEmail),
+		"password":    dynamo.S(string(hash)),
+		"active":      dynamo.B(true),
+		"date_create": dynamo.S("2024-01-02T03:04:05Z"),
+	})
+	if err != nil {
+		t.Fatalf("PutItem: %v", err)
+	}
+	return storeDDB
+}
+
+func tokenRequest(form url.Values, secret string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.SetBasicAuth(testClientID, secret)
+	r.RemoteAddr = "203.0.113.7"
+	return r
+}
+
+func passwordForm(password string) url.Values {
+	return url.Values{"grant_type": {GrantPassword}, "username": {testEmail}, "password": {password}}
+}
+
+func login(t *testing.T, storeDDB dynamo.Store) LoginResponse {
+	t.Helper()
+	w := httptest.NewRecorder()
+	LoginHandler(storeDDB, testAuth)(w, tokenRequest(passwordForm(testPassword), testClientSecret))
+	if w.Code != http.StatusOK {
+		t.Fatalf("login: status %d: %s", w.Code, w.Body.String())
+	}
+	var resp LoginResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestPasswordGrant(t *testing.T) {
+	storeDDB := newTestStore(t)
+
+	resp := login(t, storeDDB)
+	if resp.User.ID != testUserID || resp.RefreshToken == "" {
+		t.Fatalf("resposta inesperada: %+v", resp)
+	}
+	principal, err := middleware.ParseAccessToken(testAuth, resp.Token)
+	if err != nil {
+		t.Fatalf("token invalido: %v", err)
+	}
+	if principal.UserID != testUserID || principal.ClientID != testClientID {
+		t.Fatalf("principal inesperado: %+v", principal)
+	}
+}
+
+func TestPasswordGrantRejectsClient(t *testing.T) {
+	storeDDB := newTestStore(t)
+
+	w := httptest.NewRecorder()
+	LoginHandler(storeDDB, testAuth)(w, tokenRequest(passwordForm(testPassword), "errado"))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("status %d, esperado 401", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	form := url.Values{"grant_type": {GrantClientCredentials}}
+	LoginHandler(storeDDB, testAuth)(w, tokenRequest(form, testClientSecret))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("grant nao cadastrado: status %d, esperado 400", w.Code)
+	}
+}
+
+func TestClientCredentialsGrant(t *testing.T) {
+	storeDDB := newTestStore(t)
+	ctx := context.Background()
+	for _, c := range []Client{
+		{ID: "batch", GrantTypes: []string{GrantClientCredentials}, Scopes: []string{"pix:monitor", "donations:read"}, Active: true},
+		{ID: "antigo", GrantTypes: []string{GrantClientCredentials}, Scopes: []string{"pix:monitor"}},
+	} {
+		if err := PutClient(ctx, storeDDB, c, "segredo-"+c.ID); err != nil {
+			t.Fatalf("PutClient %s: %v", c.ID, err)
+		}
+	}
+
+	grant := func(clientID, secret, scope string) *httptest.ResponseRecorder {
+		form := url.Values{"grant_type": {GrantClientCredentials}}
+		if scope != "" {
+			form.Set("scope", scope)
+		}
+		r := tokenRequest(form, secret)
+		r.SetBasicAuth(clientID, secret)
+		w := httptest.NewRecorder()
+		LoginHandler(storeDDB, testAuth)(w, r)
+		return w
+	}
+
+	for name, tc := range map[string]struct {
+		clientID, secret, scope string
+		want                    int
+	}{
+		"cliente inativo":     {"antigo", "segredo-antigo", "", http.StatusUnauthorized},
+		"segredo errado":      {"batch", "errado", "", http.StatusUnauthorized},
+		"cliente inexistente": {"outro", "segredo-outro", "", http.StatusUnauthorized},
+		"escopo nao liberado": {"batch", "segredo-batch", "pix:monitor users:write", http.StatusBadRequest},
+	} {
+		if w := grant(tc.clientID, tc.secret, tc.scope); w.Code != tc.want {
+			t.Errorf("%s: status %d; want %d", name, w.Code, tc.want)
+		}
+	}
+
+	for scope, want := range map[string]string{
+		"":            "pix:monitor donations:read",
+		"pix:monitor": "pix:monitor",
+	} {
+		w := grant("batch", "segredo-batch", scope)
+		if w.Code != http.StatusOK {
+			t.Fatalf("scope %q: status %d: %s", scope, w.Code, w.Body.String())
+		}
+		var resp ClientTokenResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		principal, err := middleware.ParseAccessToken(testAuth, resp.Token)
+		if err != nil || principal.ClientID != "batch" || principal.UserID != "" || resp.Scope != want {
+			t.Fatalf("scope %q: resposta %+v, principal %+v, %v", scope, resp, principal, err)
+		}
+		if scope != "" && principal.HasScope("donations:read") {
+			t.Fatalf("scope %q: token com escopo nao pedido", scope)
+		}
+	}
+}
+
+func TestPasswordGrantThrottlesFailures(t *testing.T) {
+	storeDDB := newTestStore(t)
+
+	for i := 0; i < emailLimits.delayAfter; i++ {
+		w := httptest.NewRecorder()
+		LoginHandler(storeDDB, testAuth)(w, tokenRequest(passwordForm("errada"), testClientSecret))
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("tentativa %d: status %d, esperado 401", i+1, w.Code)
+		}
+	}
+
+	// Depois de delayAfter falhas ate a senha certa espera o atraso.
+	w := httptest.NewRecorder()
+	LoginHandler(storeDDB, testAuth)(w, tokenRequest(passwordForm(testPassword), testClientSecret))
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("status %d, esperado 429 com Retry-After", w.Code)
+	}
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	storeDDB := newTestStore(t)
+	first := login(t, storeDDB)
+
+	refresh := func(token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		form := url.Values{"grant_type": {GrantRefreshToken}, "refresh_token": {token}}
+		LoginHandler(storeDDB, testAuth)(w, tokenRequest(form, testClientSecret))
+		return w
+	}
+
+	w := refresh(first.RefreshToken)
+	if w.Code != http.StatusOK {
+		t.Fatalf("refresh: status %d: %s", w.Code, w.Body.String())
+	}
+	var rotated RefreshResponse
+	if err := json.NewDecoder(w.Body).Decode(&rotated); err != nil {
+		t.Fatal(err)
+	}
+	if rotated.RefreshToken == "" || rotated.RefreshToken == first.RefreshToken {
+		t.Fatalf("refresh token nao foi rotacionado")
+	}
+
+	// Reusar o token consumido revoga a familia inteira.
+	if w := refresh(first.RefreshToken); w.Code != http.StatusUnauthorized {
+		t.Fatalf("reuso: status %d, esperado 401", w.Code)
+	}
+	if w := refresh(rotated.RefreshToken); w.Code != http.StatusUnauthorized {
+		t.Fatalf("apos reuso: status %d, esperado 401", w.Code)
+	}
+}
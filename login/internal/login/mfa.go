@@ -0,0 +1,310 @@
+package login
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"BACK_SORTE_GO/common/middleware"
+	"BACK_SORTE_GO/common/repo"
+	"BACK_SORTE_GO/common/store"
+	"BACK_SORTE_GO/common/store/dynamo"
+	"BACK_SORTE_GO/common/totp"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+)
+
+// Usuarios com segundo fator ativo (USER#{id} / MFA#TOTP, cadastrado pelo servico
+// users) recebem um desafio no grant password (MFACHALLENGE#{id} / CHALLENGE) e so
+// obtem tokens apos concluir o grant mfa_otp com um codigo TOTP ou de recuperacao.
+const (
+	userMFASK               = "MFA#TOTP"
+	mfaChallengeSK          = "CHALLENGE"
+	mfaChallengeTTL         = 5 * time.Minute
+	mfaChallengeMaxAttempts = 5
+)
+
+var errMFACodeInvalid = errors.New("codigo do segundo fator invalido")
+
+type userMFA struct {
+	Secret        string   `dynamodbav:"secret"`
+	Enabled       bool     `dynamodbav:"enabled"`
+	RecoveryCodes []string `dynamodbav:"recovery_codes"`
+}
+
+type mfaChallenge struct {
+	ID        string `dynamodbav:"id"`
+	UserID    string `dynamodbav:"id_user"`
+	ClientID  string `dynamodbav:"id_client"`
+	Attempts  int    `dynamodbav:"attempts"`
+	Used      bool   `dynamodbav:"used"`
+	ExpiresAt string `dynamodbav:"expires_at"`
+}
+
+type MFAChallengeResponse struct {
+	MFARequired bool   `json:"mfa_required"`
+	ChallengeID string `json:"challenge_id"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+type MFAVerifyResponse struct {
+	Token     string `json:"token"`
+	ExpiresIn int64  `json:"expires_in"`
+}
+
+func loadUserMFA(ctx context.Context, storeDDB dynamo.Store, userID string) (userMFA, error) {
+	item, err := storeDDB.GetItem(ctx, store.UserPK(userID), userMFASK)
+	if err != nil || len(item) == 0 {
+		return userMFA{}, err
+	}
+	var mfa userMFA
+	err = attributevalue.UnmarshalMap(item, &mfa)
+	return mfa, err
+}
+
+func writeMFAChallenge(ctx context.Context, w http.ResponseWriter, storeDDB dynamo.Store, userID, clientID string) {
+	id := uuid.NewString()
+	now := time.Now().UTC()
+	expiresAt := now.Add(mfaChallengeTTL)
+
+	err := storeDDB.PutItem(ctx, map[string]types.AttributeValue{
+		"PK":          dynamo.S(store.MFAChallengePK(id)),
+		"SK":          dynamo.S(mfaChallengeSK),
+		"id":          dynamo.S(id),
+		"id_user":     dynamo.S(userID),
+		"id_client":   dynamo.S(clientID),
+		"attempts":    dynamo.N("0"),
+		"used":        dynamo.B(false),
+		"date_create": dynamo.S(now.Format(time.RFC3339)),
+		"expires_at":  dynamo.S(expiresAt.Format(time.RFC3339)),
+		"ttl":         dynamo.N(strconv.FormatInt(expiresAt.Unix(), 10)),
+	})
+	if err != nil {
+		http.Error(w, "Erro ao criar desafio do segundo fator", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(MFAChallengeResponse{
+		MFARequired: true,
+		ChallengeID: id,
+		ExpiresIn:   int64(mfaChallengeTTL.Seconds()),
+	})
+}
+
+// verifySecondFactor aceita um codigo TOTP, recusando um passo ja usado, ou um
+// codigo de recuperacao, que e consumido. As escritas condicionais vao por
+// TransactWrite porque o Store nao expoe UpdateItem com condicao.
+func verifySecondFactor(ctx context.Context, storeDDB dynamo.Store, userID string, mfa userMFA, code string) error {
+	key := map[string]types.AttributeValue{
+		"PK": dynamo.S(store.UserPK(userID)),
+		"SK": dynamo.S(userMFASK),
+	}
+
+	update := &types.Update{TableName: aws.String(storeDDB.TableName()), Key: key}
+	if step, ok := totp.Validate(mfa.Secret, code, time.Now()); ok {
+		update.UpdateExpression = aws.String("SET last_step = :s")
+		update.ConditionExpression = aws.String("enabled = :t AND (attribute_not_exists(last_step) OR last_step < :s)")
+		update.ExpressionAttributeValues = map[string]types.AttributeValue{
+			":s": dynamo.N(strconv.FormatInt(step, 10)),
+			":t": dynamo.B(true),
+		}
+	} else {
+		hash := totp.HashRecoveryCode(code)
+		if !containsString(mfa.RecoveryCodes, hash) {
+			return errMFACodeInvalid
+		}
+		update.UpdateExpression = aws.String("DELETE recovery_codes :hs")
+		update.ConditionExpression = aws.String("enabled = :t AND contains(recovery_codes, :h)")
+		update.ExpressionAttributeValues = map[string]types.AttributeValue{
+			":hs": &types.AttributeValueMemberSS{Value: []string{hash}},
+			":h":  dynamo.S(hash),
+			":t":  dynamo.B(true),
+		}
+	}
+
+	err := storeDDB.TransactWrite(ctx, []types.TransactWriteItem{{Update: update}})
+	if isConditionalCheckFailed(err) {
+		return errMFACodeInvalid
+	}
+	return err
+}
+
+// checkSecondFactor aplica o limite de tentativas por usuario (LOGINFAIL#MFA#{id})
+// e confere o codigo. Ja responde a requisicao quando retorna false.
+func checkSecondFactor(w http.ResponseWriter, r *http.Request, storeDDB dynamo.Store, userID string, mfa userMFA, code string) bool {
+	ctx := r.Context()
+	pk := store.LoginFailMFAPK(userID)
+	ip := remoteIP(r.RemoteAddr)
+
+	counter, err := loadFailureCounter(ctx, storeDDB, pk)
+	if err != nil {
+		http.Error(w, "Erro ao validar tentativas", http.StatusInternalServerError)
+		return false
+	}
+	if block := counterBlock(counter, time.Now().UTC(), false); block != nil {
+		writeLoginBlocked(w, *block)
+		return false
+	}
+
+	err = verifySecondFactor(ctx, storeDDB, userID, mfa, code)
+	if errors.Is(err, errMFACodeInvalid) {
+		if _, err := recordLoginFailure(ctx, storeDDB, pk, emailLimits, ip); err != nil {
+			log.Printf("erro ao registrar falha do segundo fator: %v", err)
+		}
+		http.Error(w, "Codigo invalido", http.StatusUnauthorized)
+		return false
+	}
+	if err != nil {
+		http.Error(w, "Erro ao verificar segundo fator", http.StatusInternalServerError)
+		return false
+	}
+
+	if err := resetFailures(ctx, storeDDB, pk); err != nil {
+		log.Printf("erro ao zerar falhas do segundo fator: %v", err)
+	}
+	return true
+}
+
+// mfaOTPGrant conclui o desafio aberto pelo grant password e emite os tokens.
+func mfaOTPGrant(w http.ResponseWriter, r *http.Request, storeDDB dynamo.Store, auth middleware.AuthConfig, client Client) {
+	challengeID := r.FormValue("challenge_id")
+	code := r.FormValue("code")
+	if challengeID == "" || code == "" {
+		http.Error(w, "Parametros invalidos", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	challengeKey := map[string]types.AttributeValue{
+		"PK": dynamo.S(store.MFAChallengePK(challengeID)),
+		"SK": dynamo.S(mfaChallengeSK),
+	}
+	item, err := storeDDB.GetItem(ctx, store.MFAChallengePK(challengeID), mfaChallengeSK)
+	if err != nil {
+		http.Error(w, "Erro ao buscar desafio", http.StatusInternalServerError)
+		return
+	}
+	var challenge mfaChallenge
+	if len(item) > 0 {
+		if err := attributevalue.UnmarshalMap(item, &challenge); err != nil {
+			http.Error(w, "Erro ao buscar desafio", http.StatusInternalServerError)
+			return
+		}
+	}
+	expiresAt, _ := time.Parse(time.RFC3339, challenge.ExpiresAt)
+	if challenge.ID == "" || challenge.Used || challenge.ClientID != client.ID ||
+		challenge.Attempts >= mfaChallengeMaxAttempts || !time.Now().Before(expiresAt) {
+		http.Error(w, "Desafio invalido ou expirado", http.StatusUnauthorized)
+		return
+	}
+
+	mfa, err := loadUserMFA(ctx, storeDDB, challenge.UserID)
+	if err != nil {
+		http.Error(w, "Erro ao verificar segundo fator", http.StatusInternalServerError)
+		return
+	}
+	if !mfa.Enabled {
+		http.Error(w, "Desafio invalido ou expirado", http.StatusUnauthorized)
+		return
+	}
+
+	if !checkSecondFactor(w, r, storeDDB, challenge.UserID, mfa, code) {
+		if err := storeDDB.UpdateItem(ctx, challengeKey, "ADD attempts :one", nil, map[string]types.AttributeValue{
+			":one": dynamo.N("1"),
+		}); err != nil {
+			log.Printf("erro ao contar tentativa do desafio: %v", err)
+		}
+		return
+	}
+
+	// O desafio vale para uma unica sessao.
+	err = storeDDB.TransactWrite(ctx, []types.TransactWriteItem{{
+		Update: &types.Update{
+			TableName:           aws.String(storeDDB.TableName()),
+			Key:                 challengeKey,
+			UpdateExpression:    aws.String("SET used = :t"),
+			ConditionExpression: aws.String("used = :f"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":t": dynamo.B(true),
+				":f": dynamo.B(false),
+			},
+		},
+	}})
+	if isConditionalCheckFailed(err) {
+		http.Error(w, "Desafio invalido ou expirado", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Erro ao concluir desafio", http.StatusInternalServerError)
+		return
+	}
+
+	user, err := repo.NewUserRepo(storeDDB).Get(ctx, challenge.UserID)
+	if errors.Is(err, repo.ErrNotFound) {
+		http.Error(w, "Usuario nao encontrado", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Erro ao buscar usuario", http.StatusInternalServerError)
+		return
+	}
+
+	writeLoginResponse(w, r, storeDDB, auth, client, user, time.Now())
+}
+
+// MFAVerifyHandler e a verificacao de reforco: com um token de acesso valido e um
+// codigo do segundo fator, emite um novo token com mfa_at atual, exigido pelas
+// operacoes sensiveis (dados bancarios, resgate).
+func MFAVerifyHandler(storeDDB dynamo.Store, auth middleware.AuthConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, _ := middleware.PrincipalFromContext(r.Context())
+		if principal.UserID == "" {
+			http.Error(w, "Token invalido", http.StatusUnauthorized)
+			return
+		}
+
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Erro ao processar os parametros", http.StatusBadRequest)
+			return
+		}
+		code := r.FormValue("code")
+		if code == "" {
+			http.Error(w, "Parametros invalidos", http.StatusBadRequest)
+			return
+		}
+
+		mfa, err := loadUserMFA(r.Context(), storeDDB, principal.UserID)
+		if err != nil {
+			http.Error(w, "Erro ao verificar segundo fator", http.StatusInternalServerError)
+			return
+		}
+		if !mfa.Enabled {
+			http.Error(w, "Segundo fator nao esta ativo", http.StatusBadRequest)
+			return
+		}
+
+		if !checkSecondFactor(w, r, storeDDB, principal.UserID, mfa, code) {
+			return
+		}
+
+		tokenString, expiresAt, err := issueAccessToken(auth, principal.UserID, principal.ClientID, principal.Roles, time.Now())
+		if err != nil {
+			http.Error(w, "Erro ao gerar token", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(MFAVerifyResponse{
+			Token:     tokenString,
+			ExpiresIn: int64(time.Until(expiresAt).Seconds()),
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package login
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"BACK_SORTE_GO/common/middleware"
+	"BACK_SORTE_GO/common/store"
+	"BACK_SORTE_GO/common/store/dynamo"
+	"BACK_SORTE_GO/common/totp"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const testRecoveryCode = "abcde-12345"
+
+// newMFATestStore cadastra o segundo fator do usuario de teste e retorna o segredo.
+func newMFATestStore(t *testing.T) (*dynamo.MemoryStore, string) {
+	t.Helper()
+	storeDDB := newTestStore(t)
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = storeDDB.PutItem(context.Background(), map[string]types.AttributeValue{
+		"PK":             dynamo.S(store.UserPK(testUserID)),
+		"SK":             dynamo.S(userMFASK),
+		"secret":         dynamo.S(secret),
+		"enabled":        dynamo.B(true),
+		"recovery_codes": &types.AttributeValueMemberSS{Value: []string{totp.HashRecoveryCode(testRecoveryCode)}},
+	})
+	if err != nil {
+		t.Fatalf("PutItem: %v", err)
+	}
+	return storeDDB, secret
+}
+
+// mfaChallengeFor faz o grant password e retorna o desafio aberto.
+func mfaChallengeFor(t *testing.T, storeDDB dynamo.Store) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	LoginHandler(storeDDB, testAuth)(w, tokenRequest(passwordForm(testPassword), testClientSecret))
+	var resp MFAChallengeResponse
+	if w.Code != http.StatusOK || json.NewDecoder(w.Body).Decode(&resp) != nil || !resp.MFARequired || resp.ChallengeID == "" {
+		t.Fatalf("password com segundo fator: status %d, %+v", w.Code, resp)
+	}
+	return resp.ChallengeID
+}
+
+func mfaOTP(storeDDB dynamo.Store, challengeID, code string) *httptest.ResponseRecorder {
+	form := url.Values{"grant_type": {GrantMFAOTP}, "challenge_id": {challengeID}, "code": {code}}
+	w := httptest.NewRecorder()
+	LoginHandler(storeDDB, testAuth)(w, tokenRequest(form, testClientSecret))
+	return w
+}
+
+func TestMFAOTPGrant(t *testing.T) {
+	storeDDB, secret := newMFATestStore(t)
+	code, err := totp.Code(secret, totp.Step(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Codigo errado nao consome o desafio.
+	challenge := mfaChallengeFor(t, storeDDB)
+	wrong := "000000"
+	if wrong == code {
+		wrong = "111111"
+	}
+	if w := mfaOTP(storeDDB, challenge, wrong); w.Code != http.StatusUnauthorized {
+		t.Fatalf("codigo errado: status %d, esperado 401", w.Code)
+	}
+
+	w := mfaOTP(storeDDB, challenge, code)
+	if w.Code != http.StatusOK {
+		t.Fatalf("codigo valido: status %d: %s", w.Code, w.Body.String())
+	}
+	var resp LoginResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	principal, err := middleware.ParseAccessToken(testAuth, resp.Token)
+	if err != nil || principal.UserID != testUserID || principal.MFAAt.IsZero() {
+		t.Fatalf("token apos mfa_otp = %+v, %v", principal, err)
+	}
+
+	// O desafio vale para uma unica sessao.
+	if w := mfaOTP(storeDDB, challenge, code); w.Code != http.StatusUnauthorized {
+		t.Fatalf("desafio reusado: status %d, esperado 401", w.Code)
+	}
+	// O mesmo passo de tempo nao serve para outro desafio.
+	if w := mfaOTP(storeDDB, mfaChallengeFor(t, storeDDB), code); w.Code != http.StatusUnauthorized {
+		t.Fatalf("passo reusado: status %d, esperado 401", w.Code)
+	}
+}
+
+func TestMFAOTPGrantRecoveryCodeSingleUse(t *testing.T) {
+	storeDDB, _ := newMFATestStore(t)
+
+	if w := mfaOTP(storeDDB, mfaChallengeFor(t, storeDDB), "ABCDE 12345"); w.Code != http.StatusOK {
+		t.Fatalf("codigo de recuperacao: status %d: %s", w.Code, w.Body.String())
+	}
+	if w := mfaOTP(storeDDB, mfaChallengeFor(t, storeDDB), testRecoveryCode); w.Code != http.StatusUnauthorized {
+		t.Fatalf("codigo de recuperacao reusado: status %d, esperado 401", w.Code)
+	}
+}
+
+func TestMFAOTPGrantExpiredChallenge(t *testing.T) {
+	storeDDB, secret := newMFATestStore(t)
+	challenge := mfaChallengeFor(t, storeDDB)
+
+	err := storeDDB.UpdateItem(context.Background(), map[string]types.AttributeValue{
+		"PK": dynamo.S(store.MFAChallengePK(challenge)),
+		"SK": dynamo.S(mfaChallengeSK),
+	}, "SET expires_at = :e", nil, map[string]types.AttributeValue{
+		":e": dynamo.S(time.Now().Add(-time.Second).UTC().Format(time.RFC3339)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, err := totp.Code(secret, totp.Step(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := mfaOTP(storeDDB, challenge, code); w.Code != http.StatusUnauthorized {
+		t.Fatalf("desafio expirado: status %d, esperado 401", w.Code)
+	}
+}
@@ -0,0 +1,137 @@
+package login
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"BACK_SORTE_GO/common/middleware"
+	"BACK_SORTE_GO/common/repo"
+	"BACK_SORTE_GO/common/store/dynamo"
+)
+
+type RefreshResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+type ClientTokenResponse struct {
+	Token     string `json:"token"`
+	TokenType string `json:"token_type"`
+	ExpiresIn int64  `json:"expires_in"`
+	Scope     string `json:"scope,omitempty"`
+}
+
+// RefreshHandler mantem /login/refresh; equivale a /login com grant_type=refresh_token.
+func RefreshHandler(storeDDB dynamo.Store, auth middleware.AuthConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		client, ok := tokenRequestClient(w, r, storeDDB)
+		if !ok {
+			return
+		}
+		if !client.AllowsGrant(GrantRefreshToken) {
+			http.Error(w, "grant_type nao permitido para o cliente", http.StatusBadRequest)
+			return
+		}
+		refreshTokenGrant(w, r, storeDDB, auth, client)
+	}
+}
+
+func refreshTokenGrant(w http.ResponseWriter, r *http.Request, storeDDB dynamo.Store, auth middleware.AuthConfig, client Client) {
+	refreshToken := r.FormValue("refresh_token")
+	if refreshToken == "" {
+		http.Error(w, "Parametros invalidos", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	rotated, err := rotateRefreshToken(ctx, storeDDB, client.ID, refreshToken)
+	if errors.Is(err, errRefreshInvalid) || errors.Is(err, errRefreshReused) || errors.Is(err, errSessionRevoked) {
+		http.Error(w, "Refresh token invalido", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Erro ao renovar sessao", http.StatusInternalServerError)
+		return
+	}
+
+	user, err := repo.NewUserRepo(storeDDB).Get(ctx, rotated.UserID)
+	if errors.Is(err, repo.ErrNotFound) {
+		http.Error(w, "Usuario nao encontrado", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Erro ao buscar usuario", http.StatusInternalServerError)
+		return
+	}
+	if !user.Active || user.Dell {
+		http.Error(w, "Usuario inativo", http.StatusUnauthorized)
+		return
+	}
+
+	tokenString, expiresAt, err := issueAccessToken(auth, user.ID, client.ID, user.Roles, rotated.MFAAt)
+	if err != nil {
+		http.Error(w, "Erro ao gerar token", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RefreshResponse{
+		Token:        tokenString,
+		RefreshToken: rotated.RefreshToken,
+		ExpiresIn:    int64(time.Until(expiresAt).Seconds()),
+	})
+}
+
+// clientCredentialsGrant emite um token de maquina, sem refresh token nem sessao.
+// O parametro scope e opcional; sem ele o token recebe todos os escopos do
+// cliente e, com ele, cada escopo pedido precisa estar cadastrado.
+func clientCredentialsGrant(w http.ResponseWriter, r *http.Request, auth middleware.AuthConfig, client Client) {
+	scopes, ok := client.GrantScopes(r.FormValue("scope"))
+	if !ok {
+		http.Error(w, "Escopo nao permitido para o cliente", http.StatusBadRequest)
+		return
+	}
+
+	tokenString, expiresAt, err := issueClientToken(auth, client, scopes)
+	if err != nil {
+		http.Error(w, "Erro ao gerar token", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ClientTokenResponse{
+		Token:     tokenString,
+		TokenType: "Bearer",
+		ExpiresIn: int64(time.Until(expiresAt).Seconds()),
+		Scope:     joinScopes(scopes),
+	})
+}
+
+func LogoutHandler(storeDDB dynamo.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		client, ok := tokenRequestClient(w, r, storeDDB)
+		if !ok {
+			return
+		}
+
+		refreshToken := r.FormValue("refresh_token")
+		if refreshToken == "" {
+			http.Error(w, "Parametros invalidos", http.StatusBadRequest)
+			return
+		}
+
+		err := revokeSessionByToken(r.Context(), storeDDB, client.ID, refreshToken)
+		if err != nil && !errors.Is(err, errRefreshInvalid) {
+			http.Error(w, "Erro ao encerrar sessao", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"message": "Sessao encerrada com sucesso",
+		})
+	}
+}
@@ -1,11 +1,15 @@
 package login
 
 import (
+	"BACK_SORTE_GO/common/middleware"
 	"BACK_SORTE_GO/internal/app"
 
 	"github.com/gorilla/mux"
 )
 
 func RegisterRoutes(router *mux.Router, a *app.App) {
-	router.HandleFunc("/login", LoginHandler(a.Store)).Methods("POST")
+	router.HandleFunc("/login", LoginHandler(a.Store, a.Auth)).Methods("POST")
+	router.HandleFunc("/login/refresh", RefreshHandler(a.Store, a.Auth)).Methods("POST")
+	router.HandleFunc("/login/logout", LogoutHandler(a.Store)).Methods("POST")
+	router.Handle("/login/mfa/verify", middleware.RequireAuth(a.Auth)(MFAVerifyHandler(a.Store, a.Auth))).Methods("POST")
 }
@@ -0,0 +1,293 @@
+package login
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"BACK_SORTE_GO/common/config"
+	"BACK_SORTE_GO/common/store"
+	"BACK_SORTE_GO/common/store/dynamo"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+)
+
+// Cada login abre uma familia de sessao (SESSION#{id} / FAMILY). Os refresh tokens
+// ficam na mesma particao (REFRESH#{sha256}) e sao de uso unico: o refresh marca o
+// token atual como usado e grava o proximo. Apresentar um token ja usado revoga a
+// familia inteira.
+const (
+	sessionFamilySK    = "FAMILY"
+	refreshTokenPrefix = "REFRESH#"
+)
+
+var (
+	errRefreshInvalid = errors.New("refresh token invalido")
+	errRefreshReused  = errors.New("refresh token reutilizado")
+	errSessionRevoked = errors.New("sessao revogada")
+)
+
+func newRefreshSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashRefreshSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// O refresh token entregue ao cliente tem o formato {sessionId}.{secret}.
+func splitRefreshToken(token string) (string, string, bool) {
+	sessionID, secret, ok := strings.Cut(strings.TrimSpace(token), ".")
+	if !ok || sessionID == "" || secret == "" {
+		return "", "", false
+	}
+	return sessionID, secret, true
+}
+
+func refreshTokenItem(sessionID, userID, clientID, secret string, now, expiresAt time.Time) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"PK":          dynamo.S(store.SessionPK(sessionID)),
+		"SK":          dynamo.S(refreshTokenPrefix + hashRefreshSecret(secret)),
+		"id_session":  dynamo.S(sessionID),
+		"id_user":     dynamo.S(userID),
+		"id_client":   dynamo.S(clientID),
+		"used":        dynamo.B(false),
+		"date_create": dynamo.S(now.Format(time.RFC3339)),
+		"expires_at":  dynamo.S(expiresAt.Format(time.RFC3339)),
+		"ttl":         dynamo.N(strconv.FormatInt(expiresAt.Unix(), 10)),
+	}
+}
+
+// createSession abre uma nova familia de sessao, presa ao cliente que fez o login,
+// e retorna o primeiro refresh token. mfaAt (quando nao zero) e repassado aos
+// tokens renovados da sessao.
+func createSession(ctx context.Context, storeDDB dynamo.Store, userID, clientID, userAgent, ip string, mfaAt time.Time) (string, error) {
+	secret, err := newRefreshSecret()
+	if err != nil {
+		return "", err
+	}
+
+	sessionID := uuid.NewString()
+	now := time.Now().UTC()
+	expiresAt := now.Add(config.GetJwtRefreshTTL())
+
+	familyItem := map[string]types.AttributeValue{
+		"PK":          dynamo.S(store.SessionPK(sessionID)),
+		"SK":          dynamo.S(sessionFamilySK),
+		"id":          dynamo.S(sessionID),
+		"id_user":     dynamo.S(userID),
+		"id_client":   dynamo.S(clientID),
+		"revoked":     dynamo.B(false),
+		"user_agent":  dynamo.S(userAgent),
+		"ip":          dynamo.S(ip),
+		"date_create": dynamo.S(now.Format(time.RFC3339)),
+		"date_update": dynamo.S(now.Format(time.RFC3339)),
+		"expires_at":  dynamo.S(expiresAt.Format(time.RFC3339)),
+		"ttl":         dynamo.N(strconv.FormatInt(expiresAt.Unix(), 10)),
+	}
+	if !mfaAt.IsZero() {
+		familyItem["mfa_at"] = dynamo.N(strconv.FormatInt(mfaAt.Unix(), 10))
+	}
+
+	err = storeDDB.TransactWrite(ctx, []types.TransactWriteItem{
+		{Put: &types.Put{TableName: aws.String(storeDDB.TableName()), Item: familyItem}},
+		{Put: &types.Put{TableName: aws.String(storeDDB.TableName()), Item: refreshTokenItem(sessionID, userID, clientID, secret, now, expiresAt)}},
+	})
+	if err != nil {
+		return "", err
+	}
+	return sessionID + "." + secret, nil
+}
+
+// rotatedSession e o resultado de um refresh: usuario, proximo refresh token e a
+// verificacao de segundo fator feita no login da sessao.
+type rotatedSession struct {
+	UserID       string
+	RefreshToken string
+	MFAAt        time.Time
+}
+
+// rotateRefreshToken consome o refresh token e emite o proximo da mesma familia.
+// Somente o cliente dono da sessao pode renova-la.
+func rotateRefreshToken(ctx context.Context, storeDDB dynamo.Store, clientID, refreshToken string) (rotatedSession, error) {
+	sessionID, secret, ok := splitRefreshToken(refreshToken)
+	if !ok {
+		return rotatedSession{}, errRefreshInvalid
+	}
+
+	family, err := storeDDB.GetItem(ctx, store.SessionPK(sessionID), sessionFamilySK)
+	if err != nil {
+		return rotatedSession{}, err
+	}
+	if len(family) == 0 {
+		return rotatedSession{}, errRefreshInvalid
+	}
+	if b, ok := family["revoked"].(*types.AttributeValueMemberBOOL); ok && b.Value {
+		return rotatedSession{}, errSessionRevoked
+	}
+	if !sessionOwnedBy(family, clientID) {
+		return rotatedSession{}, errRefreshInvalid
+	}
+
+	tokenSK := refreshTokenPrefix + hashRefreshSecret(secret)
+	current, err := storeDDB.GetItem(ctx, store.SessionPK(sessionID), tokenSK)
+	if err != nil {
+		return rotatedSession{}, err
+	}
+	if len(current) == 0 {
+		return rotatedSession{}, errRefreshInvalid
+	}
+	if b, ok := current["used"].(*types.AttributeValueMemberBOOL); ok && b.Value {
+		_ = revokeSession(ctx, storeDDB, sessionID, "reuse")
+		return rotatedSession{}, errRefreshReused
+	}
+
+	now := time.Now().UTC()
+	if exp, ok := current["expires_at"].(*types.AttributeValueMemberS); ok {
+		if t, err := time.Parse(time.RFC3339, exp.Value); err != nil || now.After(t) {
+			return rotatedSession{}, errRefreshInvalid
+		}
+	}
+
+	userID := ""
+	if v, ok := current["id_user"].(*types.AttributeValueMemberS); ok {
+		userID = v.Value
+	}
+	if userID == "" {
+		return rotatedSession{}, errRefreshInvalid
+	}
+
+	nextSecret, err := newRefreshSecret()
+	if err != nil {
+		return rotatedSession{}, err
+	}
+	// A familia mantem a expiracao original; a rotacao nao estende a sessao.
+	expiresAt := now.Add(config.GetJwtRefreshTTL())
+	if exp, ok := family["expires_at"].(*types.AttributeValueMemberS); ok {
+		if t, err := time.Parse(time.RFC3339, exp.Value); err == nil {
+			expiresAt = t
+		}
+	}
+	nextSK := refreshTokenPrefix + hashRefreshSecret(nextSecret)
+
+	err = storeDDB.TransactWrite(ctx, []types.TransactWriteItem{
+		{
+			Update: &types.Update{
+				TableName: aws.String(storeDDB.TableName()),
+				Key: map[string]types.AttributeValue{
+					"PK": dynamo.S(store.SessionPK(sessionID)),
+					"SK": dynamo.S(tokenSK),
+				},
+				UpdateExpression:    aws.String("SET used = :u, date_used = :d, replaced_by = :r"),
+				ConditionExpression: aws.String("used = :f"),
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":u": dynamo.B(true),
+					":f": dynamo.B(false),
+					":d": dynamo.S(now.Format(time.RFC3339)),
+					":r": dynamo.S(nextSK),
+				},
+			},
+		},
+		{
+			ConditionCheck: &types.ConditionCheck{
+				TableName: aws.String(storeDDB.TableName()),
+				Key: map[string]types.AttributeValue{
+					"PK": dynamo.S(store.SessionPK(sessionID)),
+					"SK": dynamo.S(sessionFamilySK),
+				},
+				ConditionExpression: aws.String("revoked = :f"),
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":f": dynamo.B(false),
+				},
+			},
+		},
+		{Put: &types.Put{TableName: aws.String(storeDDB.TableName()), Item: refreshTokenItem(sessionID, userID, clientID, nextSecret, now, expiresAt)}},
+	})
+	if err != nil {
+		if isConditionalCheckFailed(err) {
+			// Outro refresh consumiu o mesmo token ao mesmo tempo: trata como reuso.
+			_ = revokeSession(ctx, storeDDB, sessionID, "reuse")
+			return rotatedSession{}, errRefreshReused
+		}
+		return rotatedSession{}, err
+	}
+
+	rotated := rotatedSession{UserID: userID, RefreshToken: sessionID + "." + nextSecret}
+	if v, ok := family["mfa_at"].(*types.AttributeValueMemberN); ok {
+		if unix, err := strconv.ParseInt(v.Value, 10, 64); err == nil {
+			rotated.MFAAt = time.Unix(unix, 0)
+		}
+	}
+	return rotated, nil
+}
+
+// revokeSessionByToken revoga a familia do refresh token informado, desde que ele exista.
+func revokeSessionByToken(ctx context.Context, storeDDB dynamo.Store, clientID, refreshToken string) error {
+	sessionID, secret, ok := splitRefreshToken(refreshToken)
+	if !ok {
+		return errRefreshInvalid
+	}
+	family, err := storeDDB.GetItem(ctx, store.SessionPK(sessionID), sessionFamilySK)
+	if err != nil {
+		return err
+	}
+	if len(family) == 0 || !sessionOwnedBy(family, clientID) {
+		return errRefreshInvalid
+	}
+	item, err := storeDDB.GetItem(ctx, store.SessionPK(sessionID), refreshTokenPrefix+hashRefreshSecret(secret))
+	if err != nil {
+		return err
+	}
+	if len(item) == 0 {
+		return errRefreshInvalid
+	}
+	return revokeSession(ctx, storeDDB, sessionID, "logout")
+}
+
+// sessionOwnedBy confere o cliente da familia. Sessoes abertas antes do registro
+// de clientes nao tem id_client e continuam aceitas ate expirarem.
+func sessionOwnedBy(family map[string]types.AttributeValue, clientID string) bool {
+	v, ok := family["id_client"].(*types.AttributeValueMemberS)
+	if !ok || v.Value == "" {
+		return true
+	}
+	return v.Value == clientID
+}
+
+func revokeSession(ctx context.Context, storeDDB dynamo.Store, sessionID, reason string) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	return storeDDB.UpdateItem(ctx, map[string]types.AttributeValue{
+		"PK": dynamo.S(store.SessionPK(sessionID)),
+		"SK": dynamo.S(sessionFamilySK),
+	}, "SET revoked = :r, revoked_reason = :m, date_revoked = :d, date_update = :d", nil, map[string]types.AttributeValue{
+		":r": dynamo.B(true),
+		":m": dynamo.S(reason),
+		":d": dynamo.S(now),
+	})
+}
+
+func isConditionalCheckFailed(err error) bool {
+	var txErr *types.TransactionCanceledException
+	if errors.As(err, &txErr) {
+		for _, reason := range txErr.CancellationReasons {
+			if reason.Code != nil && *reason.Code == "ConditionalCheckFailed" {
+				return true
+			}
+		}
+	}
+	var condErr *types.ConditionalCheckFailedException
+	return errors.As(err, &condErr)
+}
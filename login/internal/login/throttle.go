@@ -0,0 +1,256 @@
+package login
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"BACK_SORTE_GO/common/repo"
+	"BACK_SORTE_GO/common/store"
+	"BACK_SORTE_GO/common/store/dynamo"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Falhas de login sao contadas por email (LOGINFAIL#EMAIL#{email}) e por IP
+// (LOGINFAIL#IP#{ip}) dentro de uma janela. A partir de delayAfter falhas cada
+// nova tentativa so e aceita depois de um atraso que dobra a cada falha; em
+// lockAfter falhas a chave fica bloqueada por loginLockDuration.
+const (
+	loginFailSK       = "COUNTER"
+	loginFailWindow   = 15 * time.Minute
+	loginLockDuration = 15 * time.Minute
+	loginMaxDelay     = 30 * time.Second
+)
+
+type throttleLimits struct {
+	delayAfter int
+	lockAfter  int
+}
+
+var (
+	emailLimits = throttleLimits{delayAfter: 3, lockAfter: 8}
+	ipLimits    = throttleLimits{delayAfter: 10, lockAfter: 50}
+)
+
+type failureCounter struct {
+	Failures      int    `dynamodbav:"failures"`
+	WindowEnd     string `dynamodbav:"window_end"`
+	NextAttemptAt string `dynamodbav:"next_attempt_at"`
+	LockedUntil   string `dynamodbav:"locked_until"`
+}
+
+// loginBlock descreve por que uma tentativa foi recusada antes de conferir a senha.
+type loginBlock struct {
+	Locked bool // conta bloqueada (email); caso contrario e atraso/limite de IP
+	Until  time.Time
+}
+
+func (b loginBlock) RetryAfterSeconds() string {
+	secs := int64(time.Until(b.Until).Seconds()) + 1
+	if secs < 1 {
+		secs = 1
+	}
+	return strconv.FormatInt(secs, 10)
+}
+
+func (c failureCounter) expired(now time.Time) bool {
+	end, err := time.Parse(time.RFC3339, c.WindowEnd)
+	return err != nil || !now.Before(end)
+}
+
+func (c failureCounter) lockedUntil(now time.Time) (time.Time, bool) {
+	t, err := time.Parse(time.RFC3339, c.LockedUntil)
+	if err != nil || !now.Before(t) {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+func (c failureCounter) delayedUntil(now time.Time) (time.Time, bool) {
+	t, err := time.Parse(time.RFC3339, c.NextAttemptAt)
+	if err != nil || !now.Before(t) {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+func loadFailureCounter(ctx context.Context, storeDDB dynamo.Store, pk string) (failureCounter, error) {
+	item, err := storeDDB.GetItem(ctx, pk, loginFailSK)
+	if err != nil || len(item) == 0 {
+		return failureCounter{}, err
+	}
+	var counter failureCounter
+	if err := attributevalue.UnmarshalMap(item, &counter); err != nil {
+		return failureCounter{}, err
+	}
+	return counter, nil
+}
+
+// checkLoginAllowed retorna um bloqueio quando o email ou o IP ainda estao em
+// atraso ou bloqueados.
+func checkLoginAllowed(ctx context.Context, storeDDB dynamo.Store, email, ip string) (*loginBlock, error) {
+	now := time.Now().UTC()
+
+	emailCounter, err := loadFailureCounter(ctx, storeDDB, store.LoginFailEmailPK(email))
+	if err != nil {
+		return nil, err
+	}
+	if block := counterBlock(emailCounter, now, true); block != nil {
+		return block, nil
+	}
+
+	if ip == "" {
+		return nil, nil
+	}
+	ipCounter, err := loadFailureCounter(ctx, storeDDB, store.LoginFailIPPK(ip))
+	if err != nil {
+		return nil, err
+	}
+	return counterBlock(ipCounter, now, false), nil
+}
+
+// recordLoginFailure soma uma falha na chave e aplica atraso/bloqueio conforme
+// os limites. Quando esta falha causa o bloqueio, retorna o fim dele.
+func recordLoginFailure(ctx context.Context, storeDDB dynamo.Store, pk string, limits throttleLimits, ip string) (time.Time, error) {
+	now := time.Now().UTC()
+	key := map[string]types.AttributeValue{
+		"PK": dynamo.S(pk),
+		"SK": dynamo.S(loginFailSK),
+	}
+
+	counter, err := loadFailureCounter(ctx, storeDDB, pk)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if counter.expired(now) {
+		windowEnd := now.Add(loginFailWindow)
+		err := storeDDB.PutItem(ctx, map[string]types.AttributeValue{
+			"PK":           dynamo.S(pk),
+			"SK":           dynamo.S(loginFailSK),
+			"failures":     dynamo.N("1"),
+			"window_end":   dynamo.S(windowEnd.Format(time.RFC3339)),
+			"last_failure": dynamo.S(now.Format(time.RFC3339)),
+			"last_ip":      dynamo.S(ip),
+			"ttl":          dynamo.N(strconv.FormatInt(windowEnd.Unix(), 10)),
+		})
+		return time.Time{}, err
+	}
+
+	// ADD e atomico: tentativas concorrentes nao se perdem no contador.
+	err = storeDDB.UpdateItem(ctx, key, "ADD failures :one SET last_failure = :now, last_ip = :ip", nil, map[string]types.AttributeValue{
+		":one": dynamo.N("1"),
+		":now": dynamo.S(now.Format(time.RFC3339)),
+		":ip":  dynamo.S(ip),
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+	counter, err = loadFailureCounter(ctx, storeDDB, pk)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if counter.Failures >= limits.lockAfter {
+		if _, already := counter.lockedUntil(now); already {
+			return time.Time{}, nil
+		}
+		// O bloqueio encerra a janela: depois dele a contagem recomeca do zero.
+		lockedUntil := now.Add(loginLockDuration)
+		err := storeDDB.UpdateItem(ctx, key, "SET locked_until = :l, window_end = :l, #ttl = :ttl", map[string]string{"#ttl": "ttl"}, map[string]types.AttributeValue{
+			":l":   dynamo.S(lockedUntil.Format(time.RFC3339)),
+			":ttl": dynamo.N(strconv.FormatInt(lockedUntil.Unix(), 10)),
+		})
+		if err != nil {
+			return time.Time{}, err
+		}
+		return lockedUntil, nil
+	}
+
+	if counter.Failures >= limits.delayAfter {
+		delay := loginMaxDelay
+		if shift := counter.Failures - limits.delayAfter; shift < 5 {
+			delay = min(time.Second<<shift, loginMaxDelay)
+		}
+		return time.Time{}, storeDDB.UpdateItem(ctx, key, "SET next_attempt_at = :n", nil, map[string]types.AttributeValue{
+			":n": dynamo.S(now.Add(delay).Format(time.RFC3339)),
+		})
+	}
+	return time.Time{}, nil
+}
+
+// resetFailures zera o contador depois de um acerto (login ou segundo fator).
+// O contador do IP nunca e zerado: um acerto nao apaga tentativas contra outras contas.
+func resetFailures(ctx context.Context, storeDDB dynamo.Store, pk string) error {
+	counter, err := loadFailureCounter(ctx, storeDDB, pk)
+	if err != nil || counter.Failures == 0 {
+		return err
+	}
+	return storeDDB.UpdateItem(ctx, map[string]types.AttributeValue{
+		"PK": dynamo.S(pk),
+		"SK": dynamo.S(loginFailSK),
+	}, "SET failures = :z REMOVE next_attempt_at, locked_until", nil, map[string]types.AttributeValue{
+		":z": dynamo.N("0"),
+	})
+}
+
+// counterBlock converte atraso/bloqueio ativo do contador em loginBlock.
+func counterBlock(counter failureCounter, now time.Time, lockIsAccount bool) *loginBlock {
+	if until, ok := counter.lockedUntil(now); ok {
+		return &loginBlock{Locked: lockIsAccount, Until: until}
+	}
+	if until, ok := counter.delayedUntil(now); ok {
+		return &loginBlock{Until: until}
+	}
+	return nil
+}
+
+// registerLoginFailure conta a falha no email e no IP. Quando o email e
+// bloqueado, avisa o dono da conta (se existir) e retorna o fim do bloqueio.
+func registerLoginFailure(ctx context.Context, storeDDB dynamo.Store, email, ip string, user *repo.User) time.Time {
+	lockedUntil, err := recordLoginFailure(ctx, storeDDB, store.LoginFailEmailPK(email), emailLimits, ip)
+	if err != nil {
+		log.Printf("erro ao registrar falha de login por email: %v", err)
+	}
+	if ip != "" {
+		if _, err := recordLoginFailure(ctx, storeDDB, store.LoginFailIPPK(ip), ipLimits, ip); err != nil {
+			log.Printf("erro ao registrar falha de login por ip: %v", err)
+		}
+	}
+
+	userID := ""
+	if user != nil {
+		userID = user.ID
+	}
+	log.Printf("login recusado: ip=%s user_id=%s bloqueado=%t", ip, userID, !lockedUntil.IsZero())
+
+	if !lockedUntil.IsZero() && user != nil {
+		if err := sendAccountLockedEvent(ctx, *user, lockedUntil); err != nil {
+			log.Printf("erro ao publicar evento de conta bloqueada: %v", err)
+		}
+	}
+	return lockedUntil
+}
+
+// writeLoginBlocked responde 423 para conta bloqueada e 429 para atraso ou IP
+// bloqueado, sempre com Retry-After.
+func writeLoginBlocked(w http.ResponseWriter, block loginBlock) {
+	w.Header().Set("Retry-After", block.RetryAfterSeconds())
+	if block.Locked {
+		http.Error(w, "Conta bloqueada temporariamente por excesso de tentativas", http.StatusLocked)
+		return
+	}
+	http.Error(w, "Muitas tentativas de login, aguarde para tentar novamente", http.StatusTooManyRequests)
+}
+
+// remoteIP extrai o IP de origem; o API Gateway repassa apenas o IP em RemoteAddr.
+func remoteIP(remoteAddr string) string {
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		return host
+	}
+	return remoteAddr
+}
@@ -0,0 +1,57 @@
+package login
+
+import (
+	"time"
+
+	"BACK_SORTE_GO/common/config"
+	"BACK_SORTE_GO/common/middleware"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+// issueAccessToken assina o token de um usuario, registrando o cliente que o
+// obteve e, quando houve, a ultima verificacao do segundo fator.
+func issueAccessToken(auth middleware.AuthConfig, userID, clientID string, roles []string, mfaAt time.Time) (string, time.Time, error) {
+	claims := middleware.AccessClaims{
+		Roles:    roles,
+		ClientID: clientID,
+	}
+	if !mfaAt.IsZero() {
+		claims.MFAAt = mfaAt.Unix()
+	}
+	return signAccessToken(auth, claims, userID)
+}
+
+// issueClientToken assina o token client_credentials: sem usuario, sub client:{id}
+// e os escopos concedidos ao cliente.
+func issueClientToken(auth middleware.AuthConfig, client Client, scopes []string) (string, time.Time, error) {
+	return signAccessToken(auth, middleware.AccessClaims{
+		ClientID: client.ID,
+		Scope:    joinScopes(scopes),
+	}, middleware.ClientSubjectPrefix+client.ID)
+}
+
+// signAccessToken assina um token HS256 com sub, jti, iat e exp configurados por ambiente.
+func signAccessToken(auth middleware.AuthConfig, claims middleware.AccessClaims, subject string) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(config.GetJwtAccessTTL())
+
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        uuid.NewString(),
+		Subject:   subject,
+		Issuer:    auth.Issuer,
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+	}
+	if auth.Audience != "" {
+		claims.Audience = jwt.ClaimStrings{auth.Audience}
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenString, err := token.SignedString(auth.Secret)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return tokenString, expiresAt, nil
+}
@@ -35,6 +35,25 @@ resource "aws_iam_role_policy_attachment" "lambda_dynamo" {
   policy_arn = "arn:aws:iam::aws:policy/AmazonDynamoDBFullAccess"
 }
 
+resource "aws_iam_role_policy" "lambda_sqs_publish" {
+  count = var.email_events_queue_arn == "" ? 0 : 1
+  name  = "${var.project_name}-login-sqs-publish"
+  role  = aws_iam_role.lambda_role.id
+
+  policy = jsonencode({
+    Version = "2012-10-17"
+    Statement = [
+      {
+        Effect = "Allow"
+        Action = [
+          "sqs:SendMessage"
+        ]
+        Resource = var.email_events_queue_arn
+      }
+    ]
+  })
+}
+
 resource "aws_lambda_function" "login" {
   function_name = "${var.project_name}-login"
   role          = aws_iam_role.lambda_role.arn
@@ -45,8 +64,11 @@ resource "aws_lambda_function" "login" {
 
   environment {
     variables = {
-      DYNAMODB_TABLE = var.dynamodb_table
-      JWT_SECRET     = var.jwt_secret
+      DYNAMODB_TABLE         = var.dynamodb_table
+      JWT_SECRET             = var.jwt_secret
+      JWT_ACCESS_TTL         = var.jwt_access_ttl
+      JWT_REFRESH_TTL        = var.jwt_refresh_ttl
+      EMAIL_EVENTS_QUEUE_URL = var.email_events_queue_url
     }
   }
 }
@@ -13,6 +13,26 @@ variable "dynamodb_table" {
 }
 
 variable "jwt_secret" {
+  type      = string
+  sensitive = true
+}
+
+variable "jwt_access_ttl" {
+  type    = string
+  default = "15m"
+}
+
+variable "jwt_refresh_ttl" {
+  type    = string
+  default = "720h"
+}
+
+variable "email_events_queue_url" {
+  type    = string
+  default = ""
+}
+
+variable "email_events_queue_arn" {
   type    = string
   default = ""
 }
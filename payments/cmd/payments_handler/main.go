@@ -5,8 +5,8 @@ import (
 	"encoding/json"
 	"log"
 
+	"BACK_SORTE_GO/common/store/dynamo"
 	"BACK_SORTE_GO/internal/config"
-	"BACK_SORTE_GO/internal/dynamo"
 	"BACK_SORTE_GO/internal/handlers"
 	"BACK_SORTE_GO/internal/router"
 	"BACK_SORTE_GO/internal/stripeclient"
@@ -8,27 +8,30 @@ import (
 	"strings"
 	"time"
 
+	"BACK_SORTE_GO/common/money"
+	"BACK_SORTE_GO/common/repo"
+	"BACK_SORTE_GO/common/store/dynamo"
 	"BACK_SORTE_GO/internal/config"
-	"BACK_SORTE_GO/internal/dynamo"
 	"BACK_SORTE_GO/internal/models"
 	"BACK_SORTE_GO/internal/stripeclient"
 	"BACK_SORTE_GO/internal/utils"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/google/uuid"
 	"github.com/stripe/stripe-go/v78"
 )
 
 type Handler struct {
-	Store  *dynamo.Store
+	Store  dynamo.Store
 	Stripe *stripeclient.Client
 	Cfg    config.Config
 	Log    *utils.Logger
 }
 
-func NewHandler(store *dynamo.Store, stripeClient *stripeclient.Client, cfg config.Config, logger *utils.Logger) *Handler {
+func NewHandler(store dynamo.Store, stripeClient *stripeclient.Client, cfg config.Config, logger *utils.Logger) *Handler {
 	return &Handler{
 		Store:  store,
 		Stripe: stripeClient,
@@ -44,6 +47,8 @@ type createDonationRequest struct {
 	Donor      struct {
 		Name  string `json:"name"`
 		Email string `json:"email"`
+		// Updates inscreve o doador nas novidades da campanha por e-mail.
+		Updates bool `json:"updates"`
 	} `json:"donor"`
 }
 
@@ -60,6 +65,8 @@ type createCheckoutSessionRequest struct {
 	Donor      struct {
 		Name  string `json:"name"`
 		Email string `json:"email"`
+		// Updates inscreve o doador nas novidades da campanha por e-mail.
+		Updates bool `json:"updates"`
 	} `json:"donor"`
 }
 
@@ -111,6 +118,7 @@ func (h *Handler) CreateDonation(w http.ResponseWriter, r *http.Request) {
 		"status":         dynamo.S(string(models.DonationStatusCreated)),
 		"donorName":      dynamo.S(donorName),
 		"donorEmail":     dynamo.S(donorEmail),
+		"notifyUpdates":  dynamo.B(req.Donor.Updates),
 		"createdAt":      dynamo.S(now),
 		"updatedAt":      dynamo.S(now),
 	}
@@ -282,6 +290,7 @@ func (h *Handler) CreateCheckoutSession(w http.ResponseWriter, r *http.Request)
 		"status":         dynamo.S(string(models.DonationStatusCreated)),
 		"donorName":      dynamo.S(donorName),
 		"donorEmail":     dynamo.S(donorEmail),
+		"notifyUpdates":  dynamo.B(req.Donor.Updates),
 		"createdAt":      dynamo.S(now),
 		"updatedAt":      dynamo.S(now),
 	}
@@ -519,8 +528,27 @@ func (h *Handler) handleStripeEvent(ctx context.Context, event stripe.Event, sta
 		},
 	}
 
-	if err := h.Store.TransactWrite(ctx, []types.TransactWriteItem{eventPut, paymentUpdate, donationUpdate}); err != nil {
+	for attempt := 0; ; attempt++ {
+		progress, fee, err := h.campaignWrites(ctx, status, campaignID, donationID, pi.ID, pi.Amount, eventCreated)
+		if err != nil {
+			h.Log.Error("erro_processar_evento", map[string]interface{}{"error": err.Error(), "eventId": event.ID, "paymentIntentId": pi.ID})
+			return map[string]string{"status": "error"}, err
+		}
+		payment, err := withFee(paymentUpdate, fee)
+		if err != nil {
+			h.Log.Error("erro_processar_evento", map[string]interface{}{"error": err.Error(), "eventId": event.ID, "paymentIntentId": pi.ID})
+			return map[string]string{"status": "error"}, err
+		}
+		err = h.Store.TransactWrite(ctx, append([]types.TransactWriteItem{eventPut, payment, donationUpdate}, progress...))
+		if err == nil {
+			break
+		}
 		if isConditionalCheckFailed(err) {
+			// O marcador do doador pode ter sido criado por outro evento no meio
+			// tempo; remonta o agregado uma vez antes de tratar como reentrega.
+			if len(progress) > 0 && attempt == 0 {
+				continue
+			}
 			h.Log.Info("evento_ja_processado", map[string]interface{}{"eventId": event.ID})
 			return map[string]string{"status": "ok"}, nil
 		}
@@ -540,6 +568,73 @@ func (h *Handler) handleStripeEvent(ctx context.Context, event stripe.Event, sta
 	return map[string]string{"status": "ok"}, nil
 }
 
+// campaignWrites soma o pagamento aprovado ao agregado da campanha
+// (DONATION#{campaignId} / AGG), contando o doador pelo email, lanca no livro
+// razao o credito e a taxa de cartao (o liquido fica no PAYMENT) e inscreve o
+// doador nas novidades se ele pediu. Campanhas que nao existem na tabela ficam
+// sem agregado e sem taxa.
+func (h *Handler) campaignWrites(ctx context.Context, status models.PaymentStatus, campaignID, donationID, paymentIntentID string, amount int64, at string) ([]types.TransactWriteItem, *repo.AppliedFee, error) {
+	if status != models.PaymentStatusSucceeded || campaignID == "" {
+		return nil, nil, nil
+	}
+	donation, err := h.Store.GetItem(ctx, "DONATION#"+donationID, "DONATION#"+donationID)
+	if err != nil {
+		return nil, nil, err
+	}
+	campaigns := repo.NewDonationRepo(h.Store)
+	email := getStringAttr(donation, "donorEmail")
+	valor := money.Cents(amount)
+	writes, err := campaigns.ProgressWrites(ctx, campaignID, repo.DonorEmail(email), valor, at)
+	if errors.Is(err, repo.ErrNotFound) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	fee, err := repo.NewFeeRepo(h.Store).Quote(ctx, repo.FeeMethodCartao, campaignID, valor, time.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+	ledger, err := repo.NewLedgerRepo(h.Store).Writes(campaignID, repo.PaymentEntries(paymentIntentID, valor, fee)...)
+	if err != nil {
+		return nil, nil, err
+	}
+	writes = append(writes, ledger...)
+	if !getBoolAttr(donation, "notifyUpdates") || email == "" {
+		return writes, &fee, nil
+	}
+	sub, err := campaigns.SubscriberItems(repo.DonationSubscriber{
+		IDDoacao:   campaignID,
+		Email:      email,
+		Nome:       getStringAttr(donation, "donorName"),
+		DateCreate: at,
+	})
+	return append(writes, sub...), &fee, err
+}
+
+// withFee acrescenta ao update do PAYMENT#{pi} a taxa aplicada no credito da
+// campanha, para o extrato mostrar a versao da politica usada.
+func withFee(item types.TransactWriteItem, fee *repo.AppliedFee) (types.TransactWriteItem, error) {
+	if fee == nil {
+		return item, nil
+	}
+	av, err := attributevalue.Marshal(fee)
+	if err != nil {
+		return item, err
+	}
+	update := *item.Update
+	update.UpdateExpression = aws.String(*update.UpdateExpression + ", #fee = :fee")
+	update.ExpressionAttributeNames = map[string]string{"#fee": "fee"}
+	for k, v := range item.Update.ExpressionAttributeNames {
+		update.ExpressionAttributeNames[k] = v
+	}
+	update.ExpressionAttributeValues = map[string]types.AttributeValue{":fee": av}
+	for k, v := range item.Update.ExpressionAttributeValues {
+		update.ExpressionAttributeValues[k] = v
+	}
+	return types.TransactWriteItem{Update: &update}, nil
+}
+
 func (h *Handler) handleStripeEventWithoutMetadata(ctx context.Context, event stripe.Event, pi stripe.PaymentIntent, status models.PaymentStatus) (map[string]string, error) {
 	now := time.Now().UTC().Format(time.RFC3339)
 	eventCreated := time.Unix(event.Created, 0).UTC().Format(time.RFC3339)
@@ -640,6 +735,15 @@ func getStringAttr(item map[string]types.AttributeValue, key string) string {
 	return ""
 }
 
+func getBoolAttr(item map[string]types.AttributeValue, key string) bool {
+	if val, ok := item[key]; ok {
+		if b, ok := val.(*types.AttributeValueMemberBOOL); ok {
+			return b.Value
+		}
+	}
+	return false
+}
+
 func getNumberAttr(item map[string]types.AttributeValue, key string) string {
 	if val, ok := item[key]; ok {
 		if n, ok := val.(*types.AttributeValueMemberN); ok {
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"BACK_SORTE_GO/common/money"
+	"BACK_SORTE_GO/common/repo"
+	"BACK_SORTE_GO/common/store/dynamo"
+	"BACK_SORTE_GO/internal/config"
+	"BACK_SORTE_GO/internal/models"
+	"BACK_SORTE_GO/internal/utils"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func newTestHandler() (*Handler, *dynamo.MemoryStore) {
+	store := dynamo.NewMemory("core")
+	return NewHandler(store, nil, config.Config{DynamoTableName: "core", Env: "test"}, utils.NewLogger()), store
+}
+
+// stripeEvent monta o envelope do EventBridge com um evento payment_intent.
+func stripeEvent(t *testing.T, eventID, eventType, paymentIntentID string, metadata map[string]string) events.EventBridgeEvent {
+	t.Helper()
+	detail, err := json.Marshal(map[string]interface{}{
+		"id":      eventID,
+		"object":  "event",
+		"type":    eventType,
+		"created": 1700000000,
+		"data": map[string]interface{}{
+			"object": map[string]interface{}{
+				"id":            paymentIntentID,
+				"object":        "payment_intent",
+				"amount":        2500,
+				"currency":      "brl",
+				"metadata":      metadata,
+				"latest_charge": "ch_1",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return events.EventBridgeEvent{ID: "eb-" + eventID, Source: "aws.partner/stripe.com", DetailType: eventType, Detail: detail}
+}
+
+func stringAttr(t *testing.T, store dynamo.Store, pk, sk, attr string) string {
+	t.Helper()
+	item, err := store.GetItem(context.Background(), pk, sk)
+	if err != nil || len(item) == 0 {
+		t.Fatalf("item %s / %s nao encontrado: %v", pk, sk, err)
+	}
+	return getStringAttr(item, attr)
+}
+
+func TestStripeEventFlow(t *testing.T) {
+	h, store := newTestHandler()
+	ctx := context.Background()
+
+	w := httptest.NewRecorder()
+	body := `{"campaignId":"camp-1","amount":"25,00","donor":{"name":"Ana","email":"ana@example.com"}}`
+	h.CreateDonation(w, httptest.NewRequest(http.MethodPost, "/payments/donations", strings.NewReader(body)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("CreateDonation: status %d: %s", w.Code, w.Body.String())
+	}
+	var created map[string]string
+	json.NewDecoder(w.Body).Decode(&created)
+	donationID := created["donationId"]
+
+	// O PaymentIntent criado na Stripe fica gravado como PENDING.
+	err := store.PutItem(ctx, map[string]types.AttributeValue{
+		"PK":              dynamo.S("PAYMENT#pi_1"),
+		"SK":              dynamo.S("DONATION#" + donationID),
+		"paymentIntentId": dynamo.S("pi_1"),
+		"donationId":      dynamo.S(donationID),
+		"status":          dynamo.S(string(models.PaymentStatusPending)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := stripeEvent(t, "evt_1", "payment_intent.succeeded", "pi_1", map[string]string{"donationId": donationID, "campaignId": "camp-1"})
+	out, err := h.HandleEventBridge(ctx, event)
+	if err != nil || out["status"] != "ok" {
+		t.Fatalf("HandleEventBridge: %v %v", out, err)
+	}
+
+	if got := stringAttr(t, store, "PAYMENT#pi_1", "DONATION#"+donationID, "status"); got != string(models.PaymentStatusSucceeded) {
+		t.Fatalf("payment status = %q", got)
+	}
+	if got := stringAttr(t, store, "PAYMENT#pi_1", "DONATION#"+donationID, "chargeId"); got != "ch_1" {
+		t.Fatalf("chargeId = %q", got)
+	}
+	if got := stringAttr(t, store, "DONATION#"+donationID, "DONATION#"+donationID, "status"); got != string(models.DonationStatusPaid) {
+		t.Fatalf("donation status = %q", got)
+	}
+
+	// Reentrega do mesmo evento nao reprocessa.
+	failed := stripeEvent(t, "evt_1", "payment_intent.payment_failed", "pi_1", map[string]string{"donationId": donationID})
+	if out, err := h.HandleEventBridge(ctx, failed); err != nil || out["status"] != "ok" {
+		t.Fatalf("reentrega: %v %v", out, err)
+	}
+	if got := stringAttr(t, store, "DONATION#"+donationID, "DONATION#"+donationID, "status"); got != string(models.DonationStatusPaid) {
+		t.Fatalf("reentrega alterou a donation: %q", got)
+	}
+}
+
+func TestStripeEventUnknownPayment(t *testing.T) {
+	h, store := newTestHandler()
+	ctx := context.Background()
+
+	// Sem payment gravado a transacao e cancelada e o evento nao fica marcado.
+	event := stripeEvent(t, "evt_2", "payment_intent.succeeded", "pi_2", map[string]string{"donationId": "d-404"})
+	if out, _ := h.HandleEventBridge(ctx, event); out["status"] != "ok" {
+		t.Fatalf("status = %v", out)
+	}
+	if item, _ := store.GetItem(ctx, "PAYMENT#pi_2", "DONATION#d-404"); len(item) != 0 {
+		t.Fatal("payment criado sem donation")
+	}
+	if item, _ := store.GetItem(ctx, "EVENT#evt_2", "EVENT#evt_2"); len(item) != 0 {
+		t.Fatal("evento marcado como processado")
+	}
+
+	// Sem metadata o pagamento e registrado em DONATION#UNKNOWN.
+	event = stripeEvent(t, "evt_3", "payment_intent.succeeded", "pi_3", nil)
+	if out, err := h.HandleEventBridge(ctx, event); err != nil || out["status"] != "ok" {
+		t.Fatalf("sem metadata: %v %v", out, err)
+	}
+	item, _ := store.GetItem(ctx, "PAYMENT#pi_3", "DONATION#UNKNOWN")
+	if getNumberAttr(item, "amount") != "2500" || getStringAttr(item, "status") != string(models.PaymentStatusSucceeded) {
+		t.Fatalf("payment sem metadata inesperado: %v", item)
+	}
+
+	if out, _ := h.HandleEventBridge(ctx, stripeEvent(t, "evt_4", "charge.refunded", "pi_3", nil)); out["status"] != "ignored" {
+		t.Fatalf("evento desconhecido: %v", out)
+	}
+}
+
+func TestStripeEventUpdatesCampaignProgress(t *testing.T) {
+	h, store := newTestHandler()
+	ctx := context.Background()
+	donations := repo.NewDonationRepo(store)
+	err := donations.Create(ctx, repo.NewDonation{
+		Profile: repo.Donation{ID: "camp-1", IDUser: "u-1", Name: "Campanha", Valor: money.Cents(10000), DateCreate: "2024-01-01T00:00:00Z", NomeLink: "@campanha"},
+		Link:    repo.DonationLink{ID: "l-1", IDDoacao: "camp-1", NomeLink: "@campanha"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Duas doacoes do mesmo email contam um doador.
+	var donationIDs []string
+	for i, email := range []string{"ana@example.com", "ANA@example.com"} {
+		w := httptest.NewRecorder()
+		body := `{"campaignId":"camp-1","amount":"25,00","donor":{"name":"Ana","email":"` + email + `","updates":true}}`
+		h.CreateDonation(w, httptest.NewRequest(http.MethodPost, "/payments/donations", strings.NewReader(body)))
+		var created map[string]string
+		json.NewDecoder(w.Body).Decode(&created)
+		donationIDs = append(donationIDs, created["donationId"])
+
+		pi := fmt.Sprintf("pi_%d", i)
+		err := store.PutItem(ctx, map[string]types.AttributeValue{
+			"PK":     dynamo.S("PAYMENT#" + pi),
+			"SK":     dynamo.S("DONATION#" + created["donationId"]),
+			"status": dynamo.S(string(models.PaymentStatusPending)),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		event := stripeEvent(t, fmt.Sprintf("evt_%d", i), "payment_intent.succeeded", pi, map[string]string{"donationId": created["donationId"], "campaignId": "camp-1"})
+		for range 2 {
+			if out, err := h.HandleEventBridge(ctx, event); err != nil || out["status"] != "ok" {
+				t.Fatalf("HandleEventBridge: %v %v", out, err)
+			}
+		}
+	}
+
+	p, err := donations.GetProgress(ctx, "camp-1")
+	if err != nil || p.TotalArrecadado != money.Cents(5000) || p.TotalDoacoes != 2 || p.TotalDoadores != 1 || p.Percentual() != 50 {
+		t.Fatalf("GetProgress = %+v, %v", p, err)
+	}
+	if subs, err := donations.ListSubscribers(ctx, "camp-1"); err != nil || len(subs) != 1 || subs[0].Email != "ana@example.com" {
+		t.Fatalf("ListSubscribers = %+v, %v", subs, err)
+	}
+
+	// Sem politica publicada vale a taxa padrao de cartao, gravada no pagamento.
+	if _, payments, err := donations.DetailsAndPayments(ctx, []string{"camp-1"}); err != nil || payments["camp-1"].ValorDisponivel != money.Cents(4500) {
+		t.Fatalf("saldo = %+v, %v", payments["camp-1"], err)
+	}
+	if check, err := repo.NewLedgerRepo(store).Check(ctx, "camp-1"); err != nil || !check.OK() || check.Lancamentos != 4 || check.Saldos[repo.AccountPlataforma] != money.Cents(500) {
+		t.Fatalf("livro razao = %+v, %v", check, err)
+	}
+	item, err := store.GetItem(ctx, "PAYMENT#pi_0", "DONATION#"+donationIDs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fee repo.AppliedFee
+	if err := attributevalue.Unmarshal(item["fee"], &fee); err != nil || fee.Metodo != repo.FeeMethodCartao || fee.Valor != money.Cents(250) || fee.Liquido != money.Cents(2250) {
+		t.Fatalf("fee = %+v, %v", fee, err)
+	}
+}
@@ -3,15 +3,15 @@ package router
 import (
 	"net/http"
 
+	"BACK_SORTE_GO/common/middleware"
 	"BACK_SORTE_GO/internal/handlers"
-	"BACK_SORTE_GO/internal/utils"
 
 	"github.com/gorilla/mux"
 )
 
 func New(h *handlers.Handler) *mux.Router {
 	router := mux.NewRouter()
-	router.Use(utils.CorsMiddleware)
+	router.Use(middleware.CorsMiddleware)
 	router.PathPrefix("/").Methods(http.MethodOptions).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusNoContent)
 	})
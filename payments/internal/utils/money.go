@@ -1,11 +1,14 @@
 package utils
 
 import (
+	"BACK_SORTE_GO/common/money"
 	"errors"
 	"strconv"
 	"strings"
 )
 
+// ParseAmountToCents le o amount da API: com separador decimal o valor esta em
+// reais ("10,50" ou "10.50"); sem separador, ja em centavos ("1050").
 func ParseAmountToCents(amount string) (int64, error) {
 	value := strings.TrimSpace(amount)
 	if value == "" {
@@ -16,47 +19,15 @@ func ParseAmountToCents(amount string) (int64, error) {
 		return 0, errors.New("use apenas um separador decimal")
 	}
 
-	if strings.Contains(value, ",") {
-		value = strings.ReplaceAll(value, ",", ".")
-	}
-
-	if strings.Contains(value, ".") {
-		parts := strings.Split(value, ".")
-		if len(parts) != 2 {
-			return 0, errors.New("formato decimal invalido")
-		}
-		intPart := parts[0]
-		fracPart := parts[1]
-		if intPart == "" {
-			intPart = "0"
-		}
-		if !isDigits(intPart) || !isDigits(fracPart) {
-			return 0, errors.New("valor deve conter apenas digitos")
-		}
-		if len(fracPart) > 2 {
-			return 0, errors.New("use no maximo 2 casas decimais")
-		}
-		if len(fracPart) == 1 {
-			fracPart = fracPart + "0"
-		}
-		if len(fracPart) == 0 {
-			fracPart = "00"
-		}
-
-		intVal, err := strconv.ParseInt(intPart, 10, 64)
+	if strings.ContainsAny(value, ",.") {
+		valor, err := money.Parse(value)
 		if err != nil {
-			return 0, errors.New("parte inteira invalida")
+			return 0, err
 		}
-		fracVal, err := strconv.ParseInt(fracPart, 10, 64)
-		if err != nil {
-			return 0, errors.New("parte decimal invalida")
-		}
-
-		cents := intVal*100 + fracVal
-		if cents <= 0 {
+		if valor.Centavos <= 0 {
 			return 0, errors.New("amount deve ser maior que zero")
 		}
-		return cents, nil
+		return valor.Centavos, nil
 	}
 
 	if !isDigits(value) {
@@ -0,0 +1,119 @@
+// Package brcode monta o payload EMV do Pix ("copia e cola") no formato BR Code
+// do Banco Central: campos TLV (id de 2 digitos, tamanho de 2 digitos e valor)
+// fechados pelo CRC16-CCITT.
+package brcode
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"BACK_SORTE_GO/common/money"
+	"BACK_SORTE_GO/common/text"
+)
+
+const (
+	gui       = "br.gov.bcb.pix"
+	maxNome   = 25
+	maxCidade = 15
+	maxTxID   = 25
+)
+
+var txidPattern = regexp.MustCompile(`^[A-Za-z0-9]{1,25}$`)
+
+// Payload e o conteudo do QR Code. Chave gera um QR estatico (reutilizavel) e
+// URL, o location de uma cobranca, gera um QR dinamico.
+type Payload struct {
+	Chave  string
+	URL    string
+	Valor  money.Money // zero deixa o pagador escolher o valor
+	Nome   string      // recebedor; sem acento e cortado em 25 caracteres
+	Cidade string      // do recebedor; sem acento e cortada em 15 caracteres
+	TxID   string      // ate 25 letras ou numeros; vazio vira ***
+}
+
+// String monta o payload com o CRC no final.
+func (p Payload) String() (string, error) {
+	if (p.Chave == "") == (p.URL == "") {
+		return "", errors.New("brcode: informe a chave ou a URL")
+	}
+	if p.TxID != "" && !txidPattern.MatchString(p.TxID) {
+		return "", fmt.Errorf("brcode: txid %q invalido", p.TxID)
+	}
+	if p.Valor.IsNegative() {
+		return "", errors.New("brcode: valor negativo")
+	}
+
+	account := field("00", gui)
+	if p.Chave != "" {
+		account += field("01", p.Chave)
+	} else {
+		account += field("25", strings.TrimPrefix(strings.TrimPrefix(p.URL, "https://"), "http://"))
+	}
+	if len(account) > 99 {
+		return "", errors.New("brcode: chave ou URL longa demais")
+	}
+	nome, cidade := clean(p.Nome, maxNome), clean(p.Cidade, maxCidade)
+	if nome == "" || cidade == "" {
+		return "", errors.New("brcode: nome e cidade do recebedor sao obrigatorios")
+	}
+	txid := p.TxID
+	if txid == "" {
+		txid = "***"
+	}
+
+	var b strings.Builder
+	b.WriteString(field("00", "01"))
+	if p.URL != "" {
+		// 12: o QR dinamico vale para um unico pagamento.
+		b.WriteString(field("01", "12"))
+	}
+	b.WriteString(field("26", account))
+	b.WriteString(field("52", "0000"))
+	b.WriteString(field("53", "986"))
+	if p.Valor.Centavos > 0 {
+		b.WriteString(field("54", p.Valor.String()))
+	}
+	b.WriteString(field("58", "BR"))
+	b.WriteString(field("59", nome))
+	b.WriteString(field("60", cidade))
+	b.WriteString(field("62", field("05", txid)))
+	b.WriteString("6304")
+	return b.String() + CRC16(b.String()), nil
+}
+
+// CRC16 e o CRC16-CCITT (polinomio 0x1021, inicial 0xFFFF) em 4 digitos
+// hexadecimais maiusculos, como exige o BR Code.
+func CRC16(data string) string {
+	crc := uint16(0xFFFF)
+	for i := 0; i < len(data); i++ {
+		crc ^= uint16(data[i]) << 8
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return fmt.Sprintf("%04X", crc)
+}
+
+func field(id, value string) string {
+	return fmt.Sprintf("%s%02d%s", id, len(value), value)
+}
+
+// clean tira acentos e caracteres fora do ASCII imprimivel e corta em max.
+func clean(s string, max int) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7E {
+			return -1
+		}
+		return r
+	}, text.RemoveAccents(strings.TrimSpace(s)))
+	if len(s) > max {
+		s = strings.TrimSpace(s[:max])
+	}
+	return s
+}
@@ -0,0 +1,52 @@
+package brcode
+
+import (
+	"strings"
+	"testing"
+
+	"BACK_SORTE_GO/common/money"
+)
+
+func TestCRC16(t *testing.T) {
+	if got := CRC16("123456789"); got != "29B1" {
+		t.Fatalf("CRC16 = %s", got)
+	}
+}
+
+func TestPayloadString(t *testing.T) {
+	// Exemplo do manual do BR Code do Banco Central.
+	got, err := Payload{Chave: "123e4567-e12b-12d1-a456-426655440000", Nome: "Fulano de Tal", Cidade: "BRASILIA"}.String()
+	want := "00020126580014br.gov.bcb.pix0136123e4567-e12b-12d1-a456-4266554400005204000053039865802BR5913Fulano de Tal6008BRASILIA62070503***63041D3D"
+	if err != nil || got != want {
+		t.Fatalf("String() = %q, %v", got, err)
+	}
+
+	got, err = Payload{Chave: "doador@example.com", Valor: money.Cents(1050), Nome: "João Conceição da Silva Júnior", Cidade: "São José dos Campos", TxID: "abc123"}.String()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, part := range []string{"540510.50", "5925Joao Conceicao da Silva J", "6015Sao Jose dos Ca", "62100506abc123"} {
+		if !strings.Contains(got, part) {
+			t.Errorf("%q sem %q", got, part)
+		}
+	}
+	if crc := got[len(got)-4:]; crc != CRC16(got[:len(got)-4]) {
+		t.Fatalf("CRC %s nao confere", crc)
+	}
+
+	got, err = Payload{URL: "https://qrpix.example.com/v2/abc", Nome: "Plataforma", Cidade: "Sao Paulo"}.String()
+	if err != nil || !strings.Contains(got, "010212") || !strings.Contains(got, "2524qrpix.example.com/v2/abc") {
+		t.Fatalf("dinamico = %q, %v", got, err)
+	}
+
+	for _, p := range []Payload{
+		{Nome: "X", Cidade: "Y"},
+		{Chave: "k", URL: "u", Nome: "X", Cidade: "Y"},
+		{Chave: "k", Nome: "X", Cidade: "Y", TxID: "com-traco"},
+		{Chave: "k", Cidade: "Y"},
+	} {
+		if _, err := p.String(); err == nil {
+			t.Errorf("%+v deveria falhar", p)
+		}
+	}
+}
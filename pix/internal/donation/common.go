@@ -1,3 +0,0 @@
-package donation
-
-var jwtSecretKey1 = []byte("SUA_CHAVE_SECRETA")
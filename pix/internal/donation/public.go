@@ -1,20 +1,17 @@
 package donation
 
 import (
-	"BACK_SORTE_GO/internal/store"
-	"BACK_SORTE_GO/internal/store/dynamo"
+	"BACK_SORTE_GO/common/middleware"
+	"BACK_SORTE_GO/common/repo"
+	"BACK_SORTE_GO/common/store/dynamo"
+	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
-	"github.com/golang-jwt/jwt/v4"
 	"github.com/gorilla/mux"
 )
 
@@ -28,12 +25,66 @@ type DonationMessageFull struct {
 	DataCriacao time.Time `json:"data_criacao"`
 }
 
+// DonationSummary e o progresso da campanha lido do agregado AGG.
 type DonationSummary struct {
-	ValorTotal    string `json:"valor_total"`
-	TotalDoadores int    `json:"total_doadores"`
+	ValorTotal    string  `json:"valor_total"`
+	TotalDoadores int     `json:"total_doadores"`
+	TotalDoacoes  int     `json:"total_doacoes"`
+	Meta          string  `json:"meta"`
+	Percentual    float64 `json:"percentual"`
+	UltimaDoacao  string  `json:"ultima_doacao,omitempty"`
 }
 
-func DonationByLinkHandler(storeDDB *dynamo.Store) http.HandlerFunc {
+// donationPublic e a pagina publica da campanha: perfil com texto, imagem e area.
+type donationPublic struct {
+	repo.Donation
+	Texto      string `json:"texto"`
+	ImgCaminho string `json:"img_caminho"`
+	Area       string `json:"area"`
+}
+
+// linkPostsLimit e quantas novidades vem junto com a pagina publica; as demais
+// sao lidas em /donation/{id}/posts com posts_next_cursor.
+const linkPostsLimit = 5
+
+// donationByLink e a pagina publica com a primeira pagina de novidades.
+type donationByLink struct {
+	donationPublic
+	Posts           []repo.DonationPost `json:"posts"`
+	PostsNextCursor string              `json:"posts_next_cursor"`
+}
+
+// closedDonationAccess libera campanha encerrada so para o dono; devolve o
+// status e a mensagem do erro, ou 0 se o acesso e permitido.
+func closedDonationAccess(ctx context.Context, d repo.Donation) (int, string) {
+	if !d.Closed {
+		return 0, ""
+	}
+	idFromToken := middleware.UserIDFromContext(ctx)
+	if idFromToken == "" {
+		return http.StatusUnauthorized, "Doacao fechada. Acesso nao autorizado"
+	}
+	if idFromToken != d.IDUser {
+		return http.StatusForbidden, "Voce nao tem permissao para acessar esta doacao fechada"
+	}
+	return 0, ""
+}
+
+// messageFromPix converte uma cobranca paga em mensagem publica.
+func messageFromPix(p repo.Pix) DonationMessageFull {
+	criacao, _ := time.Parse(time.RFC3339, p.DataCriacao)
+	return DonationMessageFull{
+		ID:          p.ID,
+		Valor:       p.Valor.String(),
+		CPF:         p.CPF,
+		Nome:        p.Nome,
+		Mensagem:    p.Mensagem,
+		Anonimo:     p.Anonimo,
+		DataCriacao: criacao,
+	}
+}
+
+func DonationByLinkHandler(storeDDB dynamo.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		nomeLink := vars["nome_link"]
@@ -44,87 +95,60 @@ func DonationByLinkHandler(storeDDB *dynamo.Store) http.HandlerFunc {
 		}
 
 		ctx := r.Context()
-		out, err := storeDDB.Query(ctx, &dynamodb.QueryInput{
-			KeyConditionExpression: aws.String("PK = :pk"),
-			ExpressionAttributeValues: map[string]types.AttributeValue{
-				":pk": dynamo.S(store.LinkPK(nomeLink)),
-			},
-			Limit: aws.Int32(1),
-		})
-		if err != nil || len(out.Items) == 0 {
-			http.Error(w, "Doacao nao encontrada", http.StatusNotFound)
-			return
-		}
-		linkItem := out.Items[0]
-		idDoacao := ""
-		if v, ok := linkItem["id_doacao"].(*types.AttributeValueMemberS); ok {
-			idDoacao = v.Value
-		}
-		if idDoacao == "" {
+		donations := repo.NewDonationRepo(storeDDB)
+		link, err := donations.FindLink(ctx, nomeLink)
+		if err != nil || link.IDDoacao == "" {
 			http.Error(w, "Doacao nao encontrada", http.StatusNotFound)
 			return
 		}
 
-		profile, err := storeDDB.GetItem(ctx, store.DonationPK(idDoacao), "PROFILE")
-		if err != nil || len(profile) == 0 {
+		profile, err := donations.Get(ctx, link.IDDoacao)
+		if err != nil {
 			http.Error(w, "Erro ao buscar doacao", http.StatusInternalServerError)
 			return
 		}
 
-		closed := false
-		if v, ok := profile["closed"].(*types.AttributeValueMemberBOOL); ok {
-			closed = v.Value
-		}
-		idUser := ""
-		if v, ok := profile["id_user"].(*types.AttributeValueMemberS); ok {
-			idUser = v.Value
+		// Link antigo de campanha renomeada: redireciona para o nome atual.
+		if link.Alias && profile.NomeLink != "" && profile.NomeLink != nomeLink {
+			http.Redirect(w, r, strings.TrimSuffix(r.URL.Path, nomeLink)+profile.NomeLink, http.StatusMovedPermanently)
+			return
 		}
 
-		if closed {
-			authHeader := r.Header.Get("Authorization")
-			if authHeader == "" {
-				http.Error(w, "Doacao fechada. Acesso nao autorizado", http.StatusUnauthorized)
-				return
-			}
-
-			tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-			claims := jwt.MapClaims{}
-			token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-				return jwtSecretKey1, nil
-			})
-			if err != nil || !token.Valid {
-				http.Error(w, "Token invalido", http.StatusUnauthorized)
-				return
-			}
-
-			idFromToken, ok := claims["sub"].(string)
-			if !ok || idFromToken == "" || idFromToken != idUser {
-				http.Error(w, "Voce nao tem permissao para acessar esta doacao fechada", http.StatusForbidden)
-				return
-			}
+		if status, msg := closedDonationAccess(ctx, profile); status != 0 {
+			http.Error(w, msg, status)
+			return
 		}
 
-		details, err := storeDDB.GetItem(ctx, store.DonationPK(idDoacao), "DETAILS")
-		if err != nil || len(details) == 0 {
+		details, err := donations.GetDetails(ctx, link.IDDoacao)
+		if err != nil {
 			http.Error(w, "Erro ao buscar detalhes", http.StatusInternalServerError)
 			return
 		}
 
-		response := map[string]interface{}{}
-		attributevalue.UnmarshalMap(profile, &response)
-		var dd map[string]interface{}
-		attributevalue.UnmarshalMap(details, &dd)
-		response["texto"] = dd["texto"]
-		response["img_caminho"] = dd["img_caminho"]
-		response["area"] = dd["area"]
-		response["nome_link"] = nomeLink
+		posts, postsNext, err := donations.ListPostsPage(ctx, link.IDDoacao, "", linkPostsLimit)
+		if err != nil {
+			http.Error(w, "Erro ao buscar novidades", http.StatusInternalServerError)
+			return
+		}
+
+		profile.NomeLink = nomeLink
+		response := donationByLink{
+			donationPublic: donationPublic{
+				Donation:   profile,
+				Texto:      details.Texto,
+				ImgCaminho: details.ImgCaminho,
+				Area:       details.Area,
+			},
+			Posts:           posts,
+			PostsNextCursor: postsNext,
+		}
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(response)
 	}
 }
 
-func DonationMensagesHandler(storeDDB *dynamo.Store) http.HandlerFunc {
+func DonationMensagesHandler(storeDDB dynamo.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		idDoacao := r.URL.Query().Get("id")
 		if idDoacao == "" {
@@ -132,59 +156,64 @@ func DonationMensagesHandler(storeDDB *dynamo.Store) http.HandlerFunc {
 			return
 		}
 
-		pageStr := r.URL.Query().Get("page")
-		limitStr := r.URL.Query().Get("limit")
-
-		page, err := strconv.Atoi(pageStr)
+		query := r.URL.Query()
+		page, err := strconv.Atoi(query.Get("page"))
 		if err != nil || page < 1 {
 			page = 1
 		}
 
-		limit, err := strconv.Atoi(limitStr)
+		limit, err := strconv.Atoi(query.Get("limit"))
 		if err != nil || limit < 1 || limit > 100 {
 			limit = 10
 		}
 
-		offset := (page - 1) * limit
-
+		// Com ?cursor= a resposta e {items, next_cursor}; sem ele mantem o array
+		// por numero de pagina e o proximo cursor vai no header X-Next-Cursor.
 		ctx := r.Context()
-		out, err := storeDDB.Query(ctx, &dynamodb.QueryInput{
-			KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :sk)"),
-			ExpressionAttributeValues: map[string]types.AttributeValue{
-				":pk": dynamo.S(store.DonationPK(idDoacao)),
-				":sk": dynamo.S(store.PrefixPix),
-			},
-			ScanIndexForward: aws.Bool(false),
-		})
+		charges := repo.NewPixRepo(storeDDB)
+		cursorMode := query.Has("cursor")
+		var pixPage []repo.Pix
+		var next string
+		if cursorMode {
+			pixPage, next, err = charges.ListMessagesPage(ctx, idDoacao, query.Get("cursor"), limit)
+		} else {
+			pixPage, next, err = repo.PageByNumber(page, limit, func(cursor string, n int) ([]repo.Pix, string, error) {
+				return charges.ListMessagesPage(ctx, idDoacao, cursor, n)
+			})
+		}
+		if errors.Is(err, repo.ErrInvalidCursor) {
+			http.Error(w, "Cursor invalido", http.StatusBadRequest)
+			return
+		}
 		if err != nil {
 			http.Error(w, "Erro ao buscar mensagens: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		visible := make([]DonationMessageFull, 0)
-		for _, item := range out.Items {
-			if b, ok := item["visivel"].(*types.AttributeValueMemberBOOL); ok && b.Value {
-				var msg DonationMessageFull
-				attributevalue.UnmarshalMap(item, &msg)
-				visible = append(visible, msg)
-			}
-		}
-
-		end := offset + limit
-		if offset > len(visible) {
-			offset = len(visible)
-		}
-		if end > len(visible) {
-			end = len(visible)
+		mensagens := make([]DonationMessageFull, 0, len(pixPage))
+		for _, charge := range pixPage {
+			mensagens = append(mensagens, messageFromPix(charge))
 		}
-		mensagens := visible[offset:end]
 
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(mensagens)
+		if !cursorMode {
+			if next != "" {
+				w.Header().Set("X-Next-Cursor", next)
+			}
+			json.NewEncoder(w).Encode(mensagens)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"items":       mensagens,
+			"next_cursor": next,
+		})
 	}
 }
 
-func DonationSummaryByIDHandler(storeDDB *dynamo.Store) http.HandlerFunc {
+// DonationSummaryByIDHandler devolve o progresso da campanha (total, doadores e
+// percentual da meta) com uma leitura do agregado, sem gravar nada. Como antes
+// do agregado, id desconhecido responde 200 com tudo zerado.
+func DonationSummaryByIDHandler(storeDDB dynamo.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		idDoacao := vars["id"]
@@ -193,36 +222,22 @@ func DonationSummaryByIDHandler(storeDDB *dynamo.Store) http.HandlerFunc {
 			return
 		}
 
-		ctx := r.Context()
-		out, err := storeDDB.Query(ctx, &dynamodb.QueryInput{
-			KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :sk)"),
-			ExpressionAttributeValues: map[string]types.AttributeValue{
-				":pk": dynamo.S(store.DonationPK(idDoacao)),
-				":sk": dynamo.S(store.PrefixPix),
-			},
-		})
+		progress, err := repo.NewDonationRepo(storeDDB).GetProgress(r.Context(), idDoacao)
+		if errors.Is(err, repo.ErrNotFound) {
+			err = nil
+		}
 		if err != nil {
 			http.Error(w, "Erro ao buscar resumo da doacao: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		var total float64
-		donors := map[string]struct{}{}
-		for _, item := range out.Items {
-			if b, ok := item["visivel"].(*types.AttributeValueMemberBOOL); ok && b.Value {
-				if v, ok := item["valor"].(*types.AttributeValueMemberN); ok {
-					val, _ := strconv.ParseFloat(v.Value, 64)
-					total += val
-				}
-				if cpf, ok := item["cpf"].(*types.AttributeValueMemberS); ok {
-					donors[cpf.Value] = struct{}{}
-				}
-			}
-		}
-
 		resumo := DonationSummary{
-			ValorTotal:    fmt.Sprintf("%.2f", total),
-			TotalDoadores: len(donors),
+			ValorTotal:    progress.TotalArrecadado.String(),
+			TotalDoadores: progress.TotalDoadores,
+			TotalDoacoes:  progress.TotalDoacoes,
+			Meta:          progress.Meta.String(),
+			Percentual:    progress.Percentual(),
+			UltimaDoacao:  progress.UltimaDoacao,
 		}
 
 		w.Header().Set("Content-Type", "application/json")
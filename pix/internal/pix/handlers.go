@@ -1,19 +1,16 @@
 package pix
 
 import (
-	"BACK_SORTE_GO/config"
-	"BACK_SORTE_GO/internal/store"
-	"BACK_SORTE_GO/internal/store/dynamo"
-	"context"
+	"BACK_SORTE_GO/common/config"
+	"BACK_SORTE_GO/common/repo"
+	"BACK_SORTE_GO/common/store/dynamo"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/efipay/sdk-go-apis-efi/src/efipay/pix"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
@@ -27,6 +24,10 @@ type PixChargeRequest struct {
 	Mensagem string `json:"mensagem"`
 	Anonimo  bool   `json:"anonimo"`
 	IdDoacao string `json:"id"`
+	// Novidades inscreve o doador nas novidades da campanha por e-mail quando
+	// o Pix for confirmado; exige Email.
+	Novidades bool   `json:"novidades"`
+	Email     string `json:"email"`
 }
 
 func parseTimeISO(v interface{}) time.Time {
@@ -40,7 +41,7 @@ func parseTimeISO(v interface{}) time.Time {
 	return t
 }
 
-func CreatePixTokenHandler(storeDDB *dynamo.Store) http.HandlerFunc {
+func CreatePixTokenHandler(storeDDB dynamo.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "Metodo nao permitido", http.StatusMethodNotAllowed)
@@ -49,7 +50,14 @@ func CreatePixTokenHandler(storeDDB *dynamo.Store) http.HandlerFunc {
 
 		var req PixChargeRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Erro ao decodificar JSON: "+err.Error(), http.StatusBadRequest)
+			writeChargeError(w, newChargeError(http.StatusBadRequest, codeJSONInvalido, "Erro ao decodificar JSON: "+err.Error()))
+			return
+		}
+
+		// Valida pedido e campanha antes de criar a cobranca real na EFI.
+		valor, cerr := validateCharge(r.Context(), storeDDB, &req, time.Now())
+		if cerr != nil {
+			writeChargeError(w, cerr)
 			return
 		}
 
@@ -61,86 +69,72 @@ func CreatePixTokenHandler(storeDDB *dynamo.Store) http.HandlerFunc {
 				"cpf":  req.CPF,
 				"nome": req.Nome,
 			},
-			"valor":              map[string]interface{}{"original": req.Valor},
+			"valor":              map[string]interface{}{"original": valor.String()},
 			"chave":              req.Chave,
 			"solicitacaoPagador": "pagamento de doacao",
 		}
 
 		resStr, err := efi.CreateImmediateCharge(body)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Erro ao criar cobranca PIX: %v", err), http.StatusInternalServerError)
+			writeChargeError(w, newChargeError(http.StatusBadGateway, codeErroEFI, fmt.Sprintf("Erro ao criar cobranca PIX: %v", err)))
 			return
 		}
 
 		var resMap map[string]interface{}
 		if err := json.Unmarshal([]byte(resStr), &resMap); err != nil {
-			http.Error(w, "Erro ao decodificar resposta do PIX: "+err.Error(), http.StatusInternalServerError)
+			writeChargeError(w, newChargeError(http.StatusBadGateway, codeErroEFI, "Erro ao decodificar resposta do PIX: "+err.Error()))
 			return
 		}
 
 		txid, ok := resMap["txid"].(string)
 		if !ok || txid == "" {
-			http.Error(w, "Resposta invalida da API (txid ausente)", http.StatusInternalServerError)
+			writeChargeError(w, newChargeError(http.StatusBadGateway, codeErroEFI, "Resposta invalida da API (txid ausente)"))
 			return
 		}
 
-		idPixQRCode := uuid.NewString()
+		loc, _ := resMap["loc"].(map[string]interface{})
+		calendario, _ := resMap["calendario"].(map[string]interface{})
+		expiracao, _ := strconv.ParseInt(fmt.Sprint(calendario["expiracao"]), 10, 64)
+		locID, _ := strconv.ParseInt(fmt.Sprint(loc["id"]), 10, 64)
+		status := fmt.Sprint(resMap["status"])
 		now := time.Now().Format(time.RFC3339)
-		pixSK := store.PrefixPix + now + "#" + idPixQRCode
-
-		pixItem := map[string]types.AttributeValue{
-			"PK":           dynamo.S(store.DonationPK(req.IdDoacao)),
-			"SK":           dynamo.S(pixSK),
-			"id":           dynamo.S(idPixQRCode),
-			"id_doacao":    dynamo.S(req.IdDoacao),
-			"valor":        dynamo.N(req.Valor),
-			"cpf":          dynamo.S(req.CPF),
-			"nome":         dynamo.S(req.Nome),
-			"mensagem":     dynamo.S(req.Mensagem),
-			"anonimo":      dynamo.B(req.Anonimo),
-			"visivel":      dynamo.B(false),
-			"data_criacao": dynamo.S(now),
-			"status":       dynamo.S(fmt.Sprint(resMap["status"])),
-			"txid":         dynamo.S(txid),
-		}
-
-		statusItem := map[string]types.AttributeValue{
-			"PK":               dynamo.S(store.TxPK(txid)),
-			"SK":               dynamo.S("STATUS"),
-			"id_pix_qrcode":    dynamo.S(idPixQRCode),
-			"id_doacao":        dynamo.S(req.IdDoacao),
-			"pix_sk":           dynamo.S(pixSK),
-			"status":           dynamo.S(fmt.Sprint(resMap["status"])),
-			"buscar":           dynamo.B(true),
-			"finalizado":       dynamo.B(false),
-			"data_pago":        dynamo.S(""),
-			"expiracao":        dynamo.N(fmt.Sprint(resMap["calendario"].(map[string]interface{})["expiracao"])),
-			"tipo_pagamento":   dynamo.S("v1"),
-			"loc_id":           dynamo.N(fmt.Sprint(resMap["loc"].(map[string]interface{})["id"])),
-			"loc_tipo_cob":     dynamo.S(fmt.Sprint(resMap["loc"].(map[string]interface{})["tipoCob"])),
-			"loc_criacao":      dynamo.S(parseTimeISO(resMap["loc"].(map[string]interface{})["criacao"]).Format(time.RFC3339)),
-			"location":         dynamo.S(fmt.Sprint(resMap["loc"].(map[string]interface{})["location"])),
-			"pix_copia_e_cola": dynamo.S(fmt.Sprint(resMap["loc"].(map[string]interface{})["location"])),
-			"chave":            dynamo.S(req.Chave),
-			"id_pix":           dynamo.S(txid),
-			"valor":            dynamo.N(req.Valor),
-			"data_criacao":     dynamo.S(parseTimeISO(resMap["calendario"].(map[string]interface{})["criacao"]).Format(time.RFC3339)),
-		}
 
-		ctx := r.Context()
-		err = storeDDB.TransactWrite(ctx, []types.TransactWriteItem{
-			{Put: &types.Put{TableName: &storeDDB.Table, Item: pixItem}},
-			{Put: &types.Put{TableName: &storeDDB.Table, Item: statusItem}},
+		charge := repo.Pix{
+			ID:          uuid.NewString(),
+			IDDoacao:    req.IdDoacao,
+			Valor:       valor,
+			CPF:         req.CPF,
+			Nome:        req.Nome,
+			Mensagem:    req.Mensagem,
+			Anonimo:     req.Anonimo,
+			Novidades:   req.Novidades,
+			Email:       req.Email,
+			DataCriacao: now,
+			Status:      status,
+			TxID:        txid,
+		}
+		err = repo.NewPixRepo(storeDDB).CreateCharge(r.Context(), charge, repo.PixStatus{
+			IDPixQRCode:   charge.ID,
+			IDDoacao:      req.IdDoacao,
+			Status:        status,
+			Buscar:        true,
+			Expiracao:     expiracao,
+			TipoPagamento: "v1",
+			LocID:         locID,
+			LocTipoCob:    fmt.Sprint(loc["tipoCob"]),
+			LocCriacao:    parseTimeISO(loc["criacao"]).Format(time.RFC3339),
+			Location:      fmt.Sprint(loc["location"]),
+			PixCopiaECola: copiaECola(resMap, fmt.Sprint(loc["location"])),
+			Chave:         req.Chave,
+			IDPix:         txid,
+			Valor:         valor,
+			DataCriacao:   parseTimeISO(calendario["criacao"]).Format(time.RFC3339),
 		})
 		if err != nil {
-			http.Error(w, "Erro ao salvar pix: "+err.Error(), http.StatusInternalServerError)
+			writeChargeError(w, newChargeError(http.StatusInternalServerError, codeErroInterno, "Erro ao salvar pix: "+err.Error()))
 			return
 		}
 
-		go func(txid string) {
-			_ = IniciarMonitoramentoStatusPagamento(storeDDB, txid)
-		}(txid)
-
 		w.Header().Set("Content-Type", "application/json")
 		w.Write([]byte(resStr))
 	}
@@ -188,30 +182,9 @@ func consultarStatusPix(txid string) (string, error) {
 	return status, nil
 }
 
-func IniciarMonitoramentoStatusPagamento(storeDDB *dynamo.Store, txid string) error {
-	checkInterval := []time.Duration{30 * time.Second, 1 * time.Minute}
-	attempts := []int{10, 21}
-
-	for phase := 0; phase < 2; phase++ {
-		for i := 0; i < attempts[phase]; i++ {
-			status, err := consultarStatusPix(txid)
-			if err != nil {
-				return err
-			}
-
-			// MOCK: atualiza sempre
-			_ = atualizarStatusPagamento(storeDDB, txid, status)
-			return nil
-
-			time.Sleep(checkInterval[phase])
-		}
-	}
-
-	_ = marcarPagamentoVencido(storeDDB, txid)
-	return nil
-}
-
-func MonitorarStatusPagamentoHandler(storeDDB *dynamo.Store) http.HandlerFunc {
+// MonitorarStatusPagamentoHandler concilia uma cobranca com a EFI na hora (a
+// mesma transicao do job agendado) e devolve o status aplicado.
+func MonitorarStatusPagamentoHandler(storeDDB dynamo.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		txid := vars["txid"]
@@ -220,130 +193,42 @@ func MonitorarStatusPagamentoHandler(storeDDB *dynamo.Store) http.HandlerFunc {
 			return
 		}
 
-		go func() {
-			_ = IniciarMonitoramentoStatusPagamento(storeDDB, txid)
-		}()
-
-		w.WriteHeader(http.StatusAccepted)
-		w.Write([]byte("Monitoramento iniciado"))
-	}
-}
-
-func atualizarStatusPagamento(storeDDB *dynamo.Store, txid, status string) error {
-	now := time.Now().Format(time.RFC3339)
-
-	err := storeDDB.UpdateItem(context.Background(), map[string]types.AttributeValue{
-		"PK": dynamo.S(store.TxPK(txid)),
-		"SK": dynamo.S("STATUS"),
-	}, "SET #s = :s, buscar = :b, finalizado = :f, data_pago = :d", map[string]string{
-		"#s": "status",
-	}, map[string]types.AttributeValue{
-		":s": dynamo.S("CONCLUIDA"),
-		":b": dynamo.B(false),
-		":f": dynamo.B(true),
-		":d": dynamo.S(now),
-	})
-	if err != nil {
-		return err
-	}
-
-	item, err := storeDDB.GetItem(context.Background(), store.TxPK(txid), "STATUS")
-	if err != nil || len(item) == 0 {
-		return err
-	}
-
-	idDoacao := ""
-	pixSK := ""
-	valor := "0"
-	if v, ok := item["id_doacao"].(*types.AttributeValueMemberS); ok {
-		idDoacao = v.Value
-	}
-	if v, ok := item["pix_sk"].(*types.AttributeValueMemberS); ok {
-		pixSK = v.Value
-	}
-	if v, ok := item["valor"].(*types.AttributeValueMemberN); ok {
-		valor = v.Value
-	}
-
-	if idDoacao == "" || pixSK == "" {
-		return nil
-	}
-
-	_ = storeDDB.UpdateItem(context.Background(), map[string]types.AttributeValue{
-		"PK": dynamo.S(store.DonationPK(idDoacao)),
-		"SK": dynamo.S(pixSK),
-	}, "SET visivel = :v, #s = :s", map[string]string{"#s": "status"}, map[string]types.AttributeValue{
-		":v": dynamo.B(true),
-		":s": dynamo.S("CONCLUIDA"),
-	})
-
-	valorFloat, _ := strconv.ParseFloat(valor, 64)
-	valorLiquido := valorFloat * 0.90
-
-	_ = storeDDB.UpdateItem(context.Background(), map[string]types.AttributeValue{
-		"PK": dynamo.S(store.DonationPK(idDoacao)),
-		"SK": dynamo.S("PAYMENT"),
-	}, "SET valor_disponivel = if_not_exists(valor_disponivel, :z) + :v, data_update = :d", nil, map[string]types.AttributeValue{
-		":z": dynamo.N("0"),
-		":v": dynamo.N(fmt.Sprintf("%.2f", valorLiquido)),
-		":d": dynamo.S(now),
-	})
-
-	return nil
-}
-
-func marcarPagamentoVencido(storeDDB *dynamo.Store, txid string) error {
-	return storeDDB.UpdateItem(context.Background(), map[string]types.AttributeValue{
-		"PK": dynamo.S(store.TxPK(txid)),
-		"SK": dynamo.S("STATUS"),
-	}, "SET #s = :s, buscar = :b", map[string]string{"#s": "status"}, map[string]types.AttributeValue{
-		":s": dynamo.S("VENCIDO"),
-		":b": dynamo.B(false),
-	})
-}
-
-func MonitorarStatusAllPagamentosHandler(storeDDB *dynamo.Store) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		authKey := r.Header.Get("KEY")
-		if authKey != "MINHAKEY_123" {
-			http.Error(w, "Chave de acesso invalida", http.StatusUnauthorized)
+		charges := repo.NewPixRepo(storeDDB)
+		st, err := charges.GetStatus(r.Context(), txid)
+		if errors.Is(err, repo.ErrNotFound) {
+			http.Error(w, "Cobranca nao encontrada", http.StatusNotFound)
 			return
 		}
-
-		out, err := storeDDB.Scan(r.Context(), &dynamodb.ScanInput{
-			FilterExpression: aws.String("begins_with(PK, :tx) AND #s = :st AND buscar = :b AND finalizado = :f"),
-			ExpressionAttributeNames: map[string]string{
-				"#s": "status",
-			},
-			ExpressionAttributeValues: map[string]types.AttributeValue{
-				":tx": dynamo.S(store.PrefixTx),
-				":st": dynamo.S("ATIVA"),
-				":b":  dynamo.B(true),
-				":f":  dynamo.B(false),
-			},
-		})
 		if err != nil {
-			http.Error(w, "Erro ao buscar cobrancas ativas: "+err.Error(), http.StatusInternalServerError)
+			http.Error(w, "Erro ao buscar cobranca: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
-
-		var txids []string
-		for _, item := range out.Items {
-			if v, ok := item["id_pix"].(*types.AttributeValueMemberS); ok {
-				txids = append(txids, v.Value)
+		status := st.Status
+		if st.Buscar && !st.Finalizado {
+			status, err = reconcileCharge(r.Context(), charges, st, time.Now())
+			if err != nil {
+				http.Error(w, "Erro ao conciliar cobranca: "+err.Error(), http.StatusBadGateway)
+				return
 			}
 		}
 
-		for _, txid := range txids {
-			go func(id string) {
-				_ = IniciarMonitoramentoStatusPagamento(storeDDB, id)
-			}(txid)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"txid": txid, "status": status})
+	}
+}
+
+// MonitorarStatusAllPagamentosHandler roda a conciliacao na hora e devolve o
+// relatorio. E chamado por jobs autenticados como cliente de maquina
+// (client_credentials com escopo ScopeMonitor) ou por usuarios ADMIN.
+func MonitorarStatusAllPagamentosHandler(storeDDB dynamo.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		run, err := ReconcileCharges(r.Context(), storeDDB, "manual", time.Now())
+		if err != nil {
+			http.Error(w, "Erro na conciliacao: "+err.Error(), http.StatusInternalServerError)
+			return
 		}
 
-		w.WriteHeader(http.StatusAccepted)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"message":         "Monitoramento iniciado",
-			"total_monitorar": len(txids),
-		})
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(run)
 	}
 }
@@ -0,0 +1,46 @@
+package pix
+
+import (
+	"BACK_SORTE_GO/common/repo"
+	"BACK_SORTE_GO/common/store/dynamo"
+	"context"
+	"errors"
+	"fmt"
+)
+
+// migrations sao as migracoes pontuais de dados, rodadas por invocacao direta
+// da lambda com {"migracao": "<nome>"} (ver main.go). Cada uma pode ser
+// repetida sem efeito colateral e devolve quantos itens tratou.
+var migrations = map[string]func(context.Context, dynamo.Store) (int, error){
+	"acompanhamento": indexLegacyOpen,
+}
+
+// RunMigration roda a migracao pelo nome.
+func RunMigration(ctx context.Context, storeDDB dynamo.Store, name string) (int, error) {
+	run, ok := migrations[name]
+	if !ok {
+		return 0, fmt.Errorf("migracao desconhecida: %q", name)
+	}
+	return run(ctx, storeDDB)
+}
+
+// indexLegacyOpen poe no GSI5 as cobrancas com buscar ligado criadas antes do
+// indice, que a conciliacao nao enxergaria e ficariam abertas para sempre.
+func indexLegacyOpen(ctx context.Context, storeDDB dynamo.Store) (int, error) {
+	charges := repo.NewPixRepo(storeDDB)
+	indexed := 0
+	var errs []error
+	err := charges.ScanLegacyOpen(ctx, func(txid string, st repo.PixStatus) error {
+		err := charges.IndexOpen(ctx, txid, st)
+		if errors.Is(err, repo.ErrConflict) {
+			return nil
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cobranca %s: %w", txid, err))
+			return nil
+		}
+		indexed++
+		return nil
+	})
+	return indexed, errors.Join(append(errs, err)...)
+}
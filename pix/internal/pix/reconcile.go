@@ -0,0 +1,132 @@
+package pix
+
+import (
+	"BACK_SORTE_GO/common/repo"
+	"BACK_SORTE_GO/common/store/dynamo"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// reconcileWorkers limita as consultas simultaneas a EFI.
+	reconcileWorkers  = 5
+	reconcilePageSize = 50
+	// reconcileMaxFalhas limita os erros detalhados no relatorio.
+	reconcileMaxFalhas = 20
+	// defaultExpiracao e o prazo da cobranca quando o TX# nao o tem gravado.
+	defaultExpiracao = 3600
+)
+
+// consultarStatus busca o status da cobranca na EFI; nos testes e trocada por
+// um stub.
+var consultarStatus = consultarStatusPix
+
+// ReconcileCharges percorre as cobrancas em acompanhamento (GSI5), consulta o
+// status real na EFI com concorrencia limitada e aplica a transicao de cada
+// uma. Cobre as notificacoes do webhook que se perderam. O relatorio da
+// execucao fica em RECONCILE#PIX.
+func ReconcileCharges(ctx context.Context, storeDDB dynamo.Store, origem string, at time.Time) (repo.PixReconcileRun, error) {
+	charges := repo.NewPixRepo(storeDDB)
+	run := repo.PixReconcileRun{
+		ID:         uuid.NewString(),
+		Origem:     origem,
+		DataInicio: at.UTC().Format(time.RFC3339),
+	}
+
+	var mu sync.Mutex
+	var listErr error
+	cursor := ""
+	for {
+		page, next, err := charges.ListOpenPage(ctx, cursor, reconcilePageSize)
+		if err != nil {
+			listErr = fmt.Errorf("listar cobrancas: %w", err)
+			break
+		}
+
+		sem := make(chan struct{}, reconcileWorkers)
+		var wg sync.WaitGroup
+		for _, st := range page {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(st repo.PixStatus) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				status, err := reconcileCharge(ctx, charges, st, at)
+				mu.Lock()
+				defer mu.Unlock()
+				tally(&run, st.IDPix, status, err)
+			}(st)
+		}
+		wg.Wait()
+
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	run.DataFim = time.Now().UTC().Format(time.RFC3339)
+	return run, errors.Join(listErr, charges.SaveReconcileRun(ctx, run))
+}
+
+// reconcileCharge aplica na cobranca o status real da EFI: CONCLUIDA confirma
+// o pagamento (uma unica vez, como o webhook), REMOVIDA_* grava o status e
+// encerra o acompanhamento e ATIVA fora do prazo vira VENCIDO. Devolve o
+// status aplicado.
+func reconcileCharge(ctx context.Context, charges repo.PixRepo, st repo.PixStatus, at time.Time) (string, error) {
+	txid := st.IDPix
+	status, err := consultarStatus(txid)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case status == "CONCLUIDA":
+		_, _, err := charges.Confirm(ctx, txid)
+		return status, err
+	case strings.HasPrefix(status, "REMOVIDA"):
+		return status, charges.StopTracking(ctx, txid, status)
+	case status == "ATIVA" && chargeExpired(st, at):
+		return "VENCIDO", charges.MarkExpired(ctx, txid)
+	}
+	return status, nil
+}
+
+// chargeExpired diz se o prazo de pagamento da cobranca ja passou.
+func chargeExpired(st repo.PixStatus, at time.Time) bool {
+	created, err := time.Parse(time.RFC3339, st.DataCriacao)
+	if err != nil {
+		return false
+	}
+	expiracao := st.Expiracao
+	if expiracao <= 0 {
+		expiracao = defaultExpiracao
+	}
+	return at.After(created.Add(time.Duration(expiracao) * time.Second))
+}
+
+func tally(run *repo.PixReconcileRun, txid, status string, err error) {
+	run.Verificadas++
+	switch {
+	case err != nil:
+		run.Erros++
+		if len(run.Falhas) < reconcileMaxFalhas {
+			run.Falhas = append(run.Falhas, txid+": "+err.Error())
+		}
+	case status == "CONCLUIDA":
+		run.Concluidas++
+	case status == "VENCIDO":
+		run.Vencidas++
+	case strings.HasPrefix(status, "REMOVIDA"):
+		run.Removidas++
+	default:
+		run.Pendentes++
+	}
+}
@@ -0,0 +1,114 @@
+package pix
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"BACK_SORTE_GO/common/money"
+	"BACK_SORTE_GO/common/repo"
+	"BACK_SORTE_GO/common/store"
+	"BACK_SORTE_GO/common/store/dynamo"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestReconcileCharges(t *testing.T) {
+	ctx := context.Background()
+	storeDDB := dynamo.NewMemory("core")
+	createDonation(t, storeDDB, repo.Donation{ID: "d-1", Active: true})
+
+	now := time.Now().UTC()
+	efi := map[string]string{
+		"tx-paga":     "CONCLUIDA",
+		"tx-removida": "REMOVIDA_PELO_USUARIO_RECEBEDOR",
+		"tx-vencida":  "ATIVA",
+		"tx-aberta":   "ATIVA",
+	}
+	created := map[string]time.Time{"tx-vencida": now.Add(-2 * time.Hour)}
+	charges := repo.NewPixRepo(storeDDB)
+	for _, txid := range []string{"tx-paga", "tx-removida", "tx-vencida", "tx-aberta", "tx-erro"} {
+		at, ok := created[txid]
+		if !ok {
+			at = now.Add(-time.Minute)
+		}
+		date := at.Format(time.RFC3339)
+		err := charges.CreateCharge(ctx,
+			repo.Pix{ID: "p-" + txid, IDDoacao: "d-1", Valor: money.Cents(1000), DataCriacao: date, TxID: txid},
+			repo.PixStatus{IDDoacao: "d-1", Status: "ATIVA", Buscar: true, IDPix: txid, Valor: money.Cents(1000), Expiracao: 3600, DataCriacao: date})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	orig := consultarStatus
+	t.Cleanup(func() { consultarStatus = orig })
+	consultarStatus = func(txid string) (string, error) {
+		if status, ok := efi[txid]; ok {
+			return status, nil
+		}
+		return "", errors.New("efi fora do ar")
+	}
+
+	run, err := ReconcileCharges(ctx, storeDDB, "teste", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Verificadas != 5 || run.Concluidas != 1 || run.Removidas != 1 || run.Vencidas != 1 || run.Pendentes != 1 || run.Erros != 1 || len(run.Falhas) != 1 {
+		t.Fatalf("relatorio = %+v", run)
+	}
+	if item, _ := storeDDB.GetItem(ctx, store.PrefixReconcile+"PIX", run.DataInicio+"#"+run.ID); len(item) == 0 {
+		t.Fatal("relatorio nao gravado")
+	}
+
+	for txid, want := range map[string]string{"tx-paga": "CONCLUIDA", "tx-removida": "REMOVIDA_PELO_USUARIO_RECEBEDOR", "tx-vencida": "VENCIDO", "tx-aberta": "ATIVA"} {
+		if st, err := charges.GetStatus(ctx, txid); err != nil || st.Status != want {
+			t.Errorf("%s: %+v, %v", txid, st, err)
+		}
+	}
+	if p, err := repo.NewDonationRepo(storeDDB).GetProgress(ctx, "d-1"); err != nil || p.TotalDoacoes != 1 {
+		t.Fatalf("GetProgress = %+v, %v", p, err)
+	}
+
+	// So as cobrancas ainda abertas voltam na proxima execucao.
+	run, err = ReconcileCharges(ctx, storeDDB, "teste", now)
+	if err != nil || run.Verificadas != 2 {
+		t.Fatalf("segunda execucao = %+v, %v", run, err)
+	}
+}
+
+func TestIndexLegacyOpen(t *testing.T) {
+	ctx := context.Background()
+	storeDDB := dynamo.NewMemory("core")
+	createDonation(t, storeDDB, repo.Donation{ID: "d-1", Active: true})
+	charges := repo.NewPixRepo(storeDDB)
+	// Cobrancas anteriores ao GSI5: buscar ligado, sem as chaves do indice.
+	for _, txid := range []string{"tx-antiga", "tx-paga"} {
+		err := charges.CreateCharge(ctx,
+			repo.Pix{ID: "p-" + txid, IDDoacao: "d-1", Valor: money.Cents(1000), DataCriacao: "2024-01-02T00:00:00Z", TxID: txid},
+			repo.PixStatus{IDDoacao: "d-1", Status: "ATIVA", IDPix: txid, Valor: money.Cents(1000), DataCriacao: "2024-01-02T00:00:00Z"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = storeDDB.UpdateItem(ctx, map[string]types.AttributeValue{"PK": dynamo.S(store.TxPK(txid)), "SK": dynamo.S("STATUS")},
+			"SET buscar = :t, finalizado = :f", nil, map[string]types.AttributeValue{":t": dynamo.B(true), ":f": dynamo.B(txid == "tx-paga")})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for run := 0; run < 2; run++ {
+		n, err := RunMigration(ctx, storeDDB, "acompanhamento")
+		if err != nil || n != 1-run {
+			t.Fatalf("execucao %d: %d, %v", run, n, err)
+		}
+	}
+	open, _, err := charges.ListOpenPage(ctx, "", 10)
+	if err != nil || len(open) != 1 || open[0].IDPix != "tx-antiga" {
+		t.Fatalf("ListOpenPage = %+v, %v", open, err)
+	}
+	if _, err := RunMigration(ctx, storeDDB, "outra"); err == nil {
+		t.Fatal("migracao desconhecida sem erro")
+	}
+}
@@ -0,0 +1,284 @@
+package pix
+
+import (
+	"BACK_SORTE_GO/common/config"
+	"BACK_SORTE_GO/common/middleware"
+	"BACK_SORTE_GO/common/money"
+	"BACK_SORTE_GO/common/repo"
+	"BACK_SORTE_GO/common/store/dynamo"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/efipay/sdk-go-apis-efi/src/efipay/pix"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// refundIDPattern e o formato do id de devolucao aceito pela EFI.
+var refundIDPattern = regexp.MustCompile(`^[a-zA-Z0-9]{1,35}$`)
+
+// PixRefundRequest pede a devolucao de um Pix pago. Sem valor devolve o que
+// falta; o id e opcional e serve para repetir o pedido sem devolver de novo.
+type PixRefundRequest struct {
+	ID     string `json:"id"`
+	Valor  string `json:"valor"`
+	Motivo string `json:"motivo"`
+}
+
+// errDevolucaoRecusada marca a devolucao que a EFI recusou com uma resposta de
+// erro (4xx): repetir nao resolve, entao a reserva e liberada.
+var errDevolucaoRecusada = errors.New("devolucao recusada pela EFI")
+
+// Chamadas a EFI da devolucao; nos testes sao trocadas por stubs.
+var (
+	buscarE2EID        = efiE2EID
+	devolverPix        = efiDevolucao
+	consultarDevolucao = efiConsultaDevolucao
+)
+
+// efiE2EID devolve o endToEndId do Pix que pagou a cobranca.
+func efiE2EID(txid string) (string, error) {
+	res, err := pix.NewEfiPay(config.GetCredentials()).DetailCharge(txid)
+	if err != nil {
+		return "", err
+	}
+	var charge struct {
+		Pix []struct {
+			EndToEndID string `json:"endToEndId"`
+		} `json:"pix"`
+	}
+	if err := json.Unmarshal([]byte(res), &charge); err != nil {
+		return "", err
+	}
+	if len(charge.Pix) == 0 || charge.Pix[0].EndToEndID == "" {
+		return "", fmt.Errorf("cobranca %s sem pix recebido", txid)
+	}
+	return charge.Pix[0].EndToEndID, nil
+}
+
+// efiDevolucao pede a devolucao na EFI. Repetir com o mesmo id nao devolve de
+// novo: a EFI responde a devolucao ja criada.
+func efiDevolucao(e2eid, id string, valor money.Money) (status, rtrID string, err error) {
+	res, err := pix.NewEfiPay(config.GetCredentials()).PixDevolution(e2eid, id, map[string]interface{}{
+		"valor": valor.String(),
+	})
+	if err != nil {
+		// O SDK devolve o corpo da resposta de erro; falha de rede ou de
+		// autenticacao nao e JSON e pode ser repetida.
+		var body struct {
+			Nome   string `json:"nome"`
+			Status int    `json:"status"`
+		}
+		if json.Unmarshal([]byte(err.Error()), &body) == nil && (body.Nome != "" || (body.Status >= 400 && body.Status < 500)) {
+			return "", "", fmt.Errorf("%w: %v", errDevolucaoRecusada, err)
+		}
+		return "", "", err
+	}
+	return parseDevolucao(res)
+}
+
+// efiConsultaDevolucao consulta na EFI uma devolucao ja pedida. Erro aqui nao
+// desfaz a reserva: a devolucao pode ter sido feita.
+func efiConsultaDevolucao(e2eid, id string) (status, rtrID string, err error) {
+	res, err := pix.NewEfiPay(config.GetCredentials()).PixDetailDevolution(e2eid, id)
+	if err != nil {
+		return "", "", err
+	}
+	return parseDevolucao(res)
+}
+
+func parseDevolucao(res string) (status, rtrID string, err error) {
+	var out struct {
+		RtrID  string `json:"rtrId"`
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal([]byte(res), &out); err != nil {
+		return "", "", err
+	}
+	return out.Status, out.RtrID, nil
+}
+
+// applyRefundStatus aplica o status da EFI a devolucao aberta: DEVOLVIDO
+// conclui, NAO_REALIZADO desfaz a reserva e EM_PROCESSAMENTO so grava o rtrId,
+// mantendo a reserva ate a confirmacao (webhook ou pedido repetido).
+func applyRefundStatus(ctx context.Context, charges repo.PixRepo, rf repo.PixRefund) error {
+	switch rf.Status {
+	case "DEVOLVIDO":
+		return charges.FinishRefund(ctx, rf)
+	case "NAO_REALIZADO":
+		return charges.ReleaseRefund(ctx, rf)
+	case "EM_PROCESSAMENTO":
+		return charges.MarkRefundProcessing(ctx, rf)
+	}
+	return fmt.Errorf("status de devolucao %q desconhecido", rf.Status)
+}
+
+// PixRefundHandler devolve um Pix pago ao doador, total ou parcialmente. So o
+// dono da campanha ou a equipe de suporte (ADMIN) podem pedir. A devolucao e
+// reservada antes da chamada a EFI (REFUND# PENDENTE, mensagem escondida e
+// credito estornado); se a EFI falhar, repetir com o mesmo id conclui o pedido.
+// Enquanto a EFI processa (EM_PROCESSAMENTO) a reserva continua e o pedido
+// repetido consulta a EFI de novo; o webhook tambem traz o status final. Se a
+// EFI recusar ou responder NAO_REALIZADO, a reserva e desfeita.
+func PixRefundHandler(storeDDB dynamo.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, _ := middleware.PrincipalFromContext(r.Context())
+		if principal.UserID == "" {
+			http.Error(w, "ID do usuario invalido", http.StatusUnauthorized)
+			return
+		}
+		txid := mux.Vars(r)["txid"]
+
+		var req PixRefundRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Erro ao decodificar JSON: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.ID = strings.TrimSpace(req.ID)
+		if req.ID != "" && !refundIDPattern.MatchString(req.ID) {
+			http.Error(w, "Campo 'id' deve ter ate 35 letras ou numeros", http.StatusBadRequest)
+			return
+		}
+
+		ctx := r.Context()
+		charges := repo.NewPixRepo(storeDDB)
+		st, err := charges.GetStatus(ctx, txid)
+		if errors.Is(err, repo.ErrNotFound) {
+			http.Error(w, "Cobranca nao encontrada", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Erro ao buscar cobranca: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !st.Finalizado || st.IDDoacao == "" {
+			http.Error(w, "Cobranca nao foi paga", http.StatusConflict)
+			return
+		}
+		donation, err := repo.NewDonationRepo(storeDDB).Get(ctx, st.IDDoacao)
+		if err != nil {
+			http.Error(w, "Doacao nao encontrada", http.StatusNotFound)
+			return
+		}
+		if !principal.HasRole(middleware.RoleAdmin) && donation.IDUser != principal.UserID {
+			http.Error(w, "Usuario nao autorizado a devolver este pagamento", http.StatusForbidden)
+			return
+		}
+
+		// Pedido repetido: devolucao ja finalizada volta como esta; pendente
+		// segue para a EFI de novo e em processamento e consultada.
+		var rf repo.PixRefund
+		created := false
+		if req.ID != "" {
+			rf, err = charges.GetRefund(ctx, st.IDDoacao, txid, req.ID)
+			if err != nil && !errors.Is(err, repo.ErrNotFound) {
+				http.Error(w, "Erro ao buscar devolucao: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		if rf.ID == "" {
+			charge, err := charges.GetCharge(ctx, st.IDDoacao, st.PixSK)
+			if err != nil {
+				http.Error(w, "Erro ao buscar cobranca: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			restante, err := charge.Valor.Sub(charge.ValorDevolvido)
+			if err != nil {
+				http.Error(w, "Erro ao calcular valor devolvido: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if restante.Centavos <= 0 {
+				http.Error(w, "Cobranca ja devolvida", http.StatusConflict)
+				return
+			}
+			valor := restante
+			if req.Valor != "" {
+				valor, err = money.Parse(req.Valor)
+				if err != nil || valor.Centavos <= 0 {
+					http.Error(w, "Valor invalido", http.StatusBadRequest)
+					return
+				}
+				if cmp, err := valor.Cmp(restante); err != nil || cmp > 0 {
+					http.Error(w, fmt.Sprintf("Valor acima do disponivel para devolucao (%s)", restante), http.StatusBadRequest)
+					return
+				}
+			}
+			e2eid, err := buscarE2EID(txid)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Erro ao consultar pagamento na EFI: %v", err), http.StatusBadGateway)
+				return
+			}
+
+			rf = repo.PixRefund{
+				ID:          req.ID,
+				IDDoacao:    st.IDDoacao,
+				TxID:        txid,
+				E2EID:       e2eid,
+				PixSK:       st.PixSK,
+				Valor:       valor,
+				Motivo:      strings.TrimSpace(req.Motivo),
+				IDUser:      principal.UserID,
+				Status:      "PENDENTE",
+				DataCriacao: time.Now().Format(time.RFC3339),
+			}
+			if rf.ID == "" {
+				rf.ID = strings.ReplaceAll(uuid.NewString(), "-", "")
+			}
+			err = charges.ReserveRefund(ctx, rf, charge)
+			if errors.Is(err, repo.ErrInsufficientBalance) {
+				http.Error(w, "Saldo disponivel da campanha insuficiente para a devolucao", http.StatusConflict)
+				return
+			}
+			if errors.Is(err, repo.ErrConflict) {
+				http.Error(w, "Devolucao concorrente na mesma cobranca, tente novamente", http.StatusConflict)
+				return
+			}
+			if err != nil {
+				http.Error(w, "Erro ao registrar devolucao: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			created = true
+		}
+
+		if rf.Open() {
+			var status, rtrID string
+			var efiErr error
+			if rf.Status == "PENDENTE" {
+				status, rtrID, efiErr = devolverPix(rf.E2EID, rf.ID, rf.Valor)
+			} else {
+				status, rtrID, efiErr = consultarDevolucao(rf.E2EID, rf.ID)
+			}
+			if efiErr != nil && !errors.Is(efiErr, errDevolucaoRecusada) {
+				http.Error(w, fmt.Sprintf("Erro ao solicitar devolucao na EFI (repita com id %s): %v", rf.ID, efiErr), http.StatusBadGateway)
+				return
+			}
+			if efiErr != nil {
+				status = "NAO_REALIZADO"
+			}
+			if rtrID == "" {
+				rtrID = rf.RtrID
+			}
+			rf.Status, rf.RtrID, rf.DataUpdate = status, rtrID, time.Now().Format(time.RFC3339)
+			if err := applyRefundStatus(ctx, charges, rf); err != nil {
+				http.Error(w, "Erro ao gravar devolucao: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if efiErr != nil {
+				http.Error(w, fmt.Sprintf("Devolucao recusada pela EFI e desfeita: %v", efiErr), http.StatusUnprocessableEntity)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if created {
+			w.WriteHeader(http.StatusCreated)
+		}
+		json.NewEncoder(w).Encode(rf)
+	}
+}
@@ -0,0 +1,339 @@
+package pix
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"BACK_SORTE_GO/common/middleware"
+	"BACK_SORTE_GO/common/money"
+	"BACK_SORTE_GO/common/repo"
+	"BACK_SORTE_GO/common/store"
+	"BACK_SORTE_GO/common/store/dynamo"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/gorilla/mux"
+)
+
+// stubEFI troca as chamadas de devolucao a EFI e as restaura no fim do teste.
+func stubEFI(t *testing.T) {
+	origE2E, origDev, origConsulta := buscarE2EID, devolverPix, consultarDevolucao
+	t.Cleanup(func() { buscarE2EID, devolverPix, consultarDevolucao = origE2E, origDev, origConsulta })
+	buscarE2EID = func(string) (string, error) { return "E123", nil }
+	consultarDevolucao = func(string, string) (string, string, error) { return "", "", errors.New("consulta nao esperada") }
+}
+
+func TestPixRefund(t *testing.T) {
+	ctx := context.Background()
+	storeDDB := dynamo.NewMemory("core")
+	createDonation(t, storeDDB, repo.Donation{ID: "d-1", Active: true})
+	charges := repo.NewPixRepo(storeDDB)
+	charge := repo.Pix{ID: "p-1", IDDoacao: "d-1", Valor: money.Cents(2000), CPF: "52998224725", Mensagem: "Forca", DataCriacao: "2024-01-02T00:00:00Z", TxID: "tx-1"}
+	if err := charges.CreateCharge(ctx, charge, repo.PixStatus{IDDoacao: "d-1", Status: "ATIVA", Buscar: true, IDPix: "tx-1", Valor: money.Cents(2000)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := charges.Confirm(ctx, "tx-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	stubEFI(t)
+	efiCalls := 0
+	efiDown := true
+	devolverPix = func(e2eid, id string, valor money.Money) (string, string, error) {
+		efiCalls++
+		if efiDown {
+			return "", "", errors.New("timeout")
+		}
+		return "EM_PROCESSAMENTO", "D" + id, nil
+	}
+	consultarDevolucao = func(e2eid, id string) (string, string, error) {
+		efiCalls++
+		return "DEVOLVIDO", "D" + id, nil
+	}
+
+	refund := func(userID string, roles []string, body string) (int, repo.PixRefund) {
+		r := httptest.NewRequest(http.MethodPost, "/pix/devolucao/tx-1", strings.NewReader(body))
+		r = mux.SetURLVars(r, map[string]string{"txid": "tx-1"})
+		r = r.WithContext(middleware.WithPrincipal(r.Context(), middleware.Principal{UserID: userID, Roles: roles}))
+		w := httptest.NewRecorder()
+		PixRefundHandler(storeDDB)(w, r)
+		var rf repo.PixRefund
+		json.NewDecoder(w.Body).Decode(&rf)
+		return w.Code, rf
+	}
+	available := func() money.Money {
+		_, payments, err := repo.NewDonationRepo(storeDDB).DetailsAndPayments(ctx, []string{"d-1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return payments["d-1"].ValorDisponivel
+	}
+
+	if code, _ := refund("u-2", nil, `{}`); code != http.StatusForbidden {
+		t.Fatalf("outro usuario: status %d", code)
+	}
+	if code, _ := refund("u-1", nil, `{"valor":"20.01"}`); code != http.StatusBadRequest {
+		t.Fatalf("acima do pago: status %d", code)
+	}
+
+	// EFI fora do ar: a devolucao fica reservada e o mesmo id conclui depois.
+	if code, _ := refund("u-1", nil, `{"id":"dev1","valor":"5.00","motivo":"campanha errada"}`); code != http.StatusBadGateway {
+		t.Fatalf("efi fora: status %d", code)
+	}
+	if got := available(); got != money.Cents(1350) {
+		t.Fatalf("valor_disponivel apos reserva = %v", got)
+	}
+	efiDown = false
+	code, rf := refund("u-1", nil, `{"id":"dev1","valor":"5.00"}`)
+	if code != http.StatusOK || rf.Status != "EM_PROCESSAMENTO" || rf.RtrID != "Ddev1" || rf.E2EID != "E123" {
+		t.Fatalf("repeticao: status %d, %+v", code, rf)
+	}
+	// Em processamento o pedido repetido consulta a EFI; depois de DEVOLVIDO
+	// nao chama mais.
+	if code, rf := refund("u-1", nil, `{"id":"dev1","valor":"5.00"}`); code != http.StatusOK || rf.Status != "DEVOLVIDO" || rf.RtrID != "Ddev1" {
+		t.Fatalf("consulta: status %d, %+v", code, rf)
+	}
+	calls := efiCalls
+	if code, _ := refund("u-1", nil, `{"id":"dev1","valor":"5.00"}`); code != http.StatusOK || efiCalls != calls {
+		t.Fatalf("repeticao concluida: status %d, chamadas %d", code, efiCalls)
+	}
+	if got := available(); got != money.Cents(1350) {
+		t.Fatalf("valor_disponivel apos repeticao = %v", got)
+	}
+
+	msgs, err := charges.ListByDonation(ctx, "d-1", false)
+	if err != nil || len(msgs) != 1 || msgs[0].Visivel || msgs[0].Status != "DEVOLVIDA_PARCIAL" || msgs[0].ValorDevolvido != money.Cents(500) {
+		t.Fatalf("PIX# = %+v, %v", msgs, err)
+	}
+
+	// Sem valor o admin devolve o restante.
+	code, rf = refund("admin", []string{middleware.RoleAdmin}, `{}`)
+	if code != http.StatusCreated || rf.Valor != money.Cents(1500) {
+		t.Fatalf("restante: status %d, %+v", code, rf)
+	}
+	if code, _ := refund("u-1", nil, `{}`); code != http.StatusConflict {
+		t.Fatalf("ja devolvida: status %d", code)
+	}
+	if got := available(); !got.IsZero() {
+		t.Fatalf("valor_disponivel final = %v", got)
+	}
+	p, err := repo.NewDonationRepo(storeDDB).GetProgress(ctx, "d-1")
+	if err != nil || !p.TotalArrecadado.IsZero() || p.TotalDoacoes != 0 {
+		t.Fatalf("GetProgress = %+v, %v", p, err)
+	}
+}
+
+func TestPixRefundReleaseAndBalance(t *testing.T) {
+	ctx := context.Background()
+	storeDDB := dynamo.NewMemory("core")
+	createDonation(t, storeDDB, repo.Donation{ID: "d-1", Active: true})
+	charges := repo.NewPixRepo(storeDDB)
+	donations := repo.NewDonationRepo(storeDDB)
+	charge := repo.Pix{ID: "p-1", IDDoacao: "d-1", Valor: money.Cents(2000), CPF: "52998224725", DataCriacao: "2024-01-02T00:00:00Z", TxID: "tx-1"}
+	if err := charges.CreateCharge(ctx, charge, repo.PixStatus{IDDoacao: "d-1", Status: "ATIVA", Buscar: true, IDPix: "tx-1", Valor: money.Cents(2000)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := charges.Confirm(ctx, "tx-1"); err != nil {
+		t.Fatal(err)
+	}
+	before, err := donations.GetProgress(ctx, "d-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stubEFI(t)
+	efiStatus, efiErr := "", error(nil)
+	devolverPix = func(e2eid, id string, valor money.Money) (string, string, error) {
+		return efiStatus, "D" + id, efiErr
+	}
+	refund := func(body string) int {
+		r := httptest.NewRequest(http.MethodPost, "/pix/devolucao/tx-1", strings.NewReader(body))
+		r = mux.SetURLVars(r, map[string]string{"txid": "tx-1"})
+		r = r.WithContext(middleware.WithPrincipal(r.Context(), middleware.Principal{UserID: "u-1"}))
+		w := httptest.NewRecorder()
+		PixRefundHandler(storeDDB)(w, r)
+		return w.Code
+	}
+	released := func(step string) {
+		t.Helper()
+		msgs, err := charges.ListByDonation(ctx, "d-1", false)
+		if err != nil || len(msgs) != 1 || !msgs[0].Visivel || msgs[0].Status != "CONCLUIDA" || !msgs[0].ValorDevolvido.IsZero() {
+			t.Fatalf("%s: PIX# = %+v, %v", step, msgs, err)
+		}
+		if p, err := donations.GetProgress(ctx, "d-1"); err != nil || p != before {
+			t.Fatalf("%s: GetProgress = %+v, %v; want %+v", step, p, err, before)
+		}
+		check, err := repo.NewLedgerRepo(storeDDB).Check(ctx, "d-1")
+		if err != nil || !check.OK() || check.Saldos[repo.AccountCampanha] != money.Cents(1800) {
+			t.Fatalf("%s: conferencia = %+v, %v", step, check, err)
+		}
+	}
+
+	// Recusa da EFI e NAO_REALIZADO desfazem a reserva.
+	efiErr = fmt.Errorf("%w: valor_invalido", errDevolucaoRecusada)
+	if code := refund(`{"id":"dev1"}`); code != http.StatusUnprocessableEntity {
+		t.Fatalf("recusada: status %d", code)
+	}
+	released("recusada")
+	efiStatus, efiErr = "NAO_REALIZADO", nil
+	if code := refund(`{"id":"dev2","valor":"5.00"}`); code != http.StatusCreated {
+		t.Fatalf("nao realizada: status %d", code)
+	}
+	released("nao realizada")
+	if rf, err := charges.GetRefund(ctx, "d-1", "tx-1", "dev2"); err != nil || rf.Status != "NAO_REALIZADO" {
+		t.Fatalf("REFUND# = %+v, %v", rf, err)
+	}
+
+	// Devolucao parcial em andamento: a reconstrucao do AGG desconta o devolvido.
+	efiStatus = "EM_PROCESSAMENTO"
+	if code := refund(`{"id":"dev3","valor":"5.00"}`); code != http.StatusCreated {
+		t.Fatalf("parcial: status %d", code)
+	}
+	stored, _ := donations.GetProgress(ctx, "d-1")
+	if err := storeDDB.TransactWrite(ctx, []types.TransactWriteItem{{Delete: &types.Delete{
+		TableName: aws.String(storeDDB.TableName()),
+		Key:       map[string]types.AttributeValue{"PK": dynamo.S(store.DonationPK("d-1")), "SK": dynamo.S("AGG")},
+	}}}); err != nil {
+		t.Fatal(err)
+	}
+	if rebuilt, err := donations.GetProgress(ctx, "d-1"); err != nil || rebuilt.TotalArrecadado != money.Cents(1500) || rebuilt.TotalArrecadado != stored.TotalArrecadado || rebuilt.TotalDoacoes != 1 {
+		t.Fatalf("reconstrucao = %+v, %v; gravado %+v", rebuilt, err, stored)
+	}
+
+	// Saldo ja resgatado: a devolucao nao deixa o disponivel negativo.
+	if _, err := donations.RequestRescue(ctx, "d-1", money.Cents(1350), "u-1"); err != nil {
+		t.Fatal(err)
+	}
+	if code := refund(`{"valor":"1.00"}`); code != http.StatusConflict {
+		t.Fatalf("sem saldo: status %d", code)
+	}
+	if payment, _ := donations.GetPayment(ctx, "d-1"); !payment.ValorDisponivel.IsZero() {
+		t.Fatalf("valor_disponivel = %v", payment.ValorDisponivel)
+	}
+}
+
+func TestPixRefundAsyncResult(t *testing.T) {
+	t.Setenv("PIX_WEBHOOK_SECRET", "segredo")
+	ctx := context.Background()
+	storeDDB := dynamo.NewMemory("core")
+	createDonation(t, storeDDB, repo.Donation{ID: "d-1", Active: true})
+	charges := repo.NewPixRepo(storeDDB)
+	donations := repo.NewDonationRepo(storeDDB)
+	charge := repo.Pix{ID: "p-1", IDDoacao: "d-1", Valor: money.Cents(2000), CPF: "52998224725", DataCriacao: "2024-01-02T00:00:00Z", TxID: "tx-1"}
+	if err := charges.CreateCharge(ctx, charge, repo.PixStatus{IDDoacao: "d-1", Status: "ATIVA", Buscar: true, IDPix: "tx-1", Valor: money.Cents(2000)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := charges.Confirm(ctx, "tx-1"); err != nil {
+		t.Fatal(err)
+	}
+	before, err := donations.GetProgress(ctx, "d-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stubEFI(t)
+	devolverPix = func(e2eid, id string, valor money.Money) (string, string, error) {
+		return "EM_PROCESSAMENTO", "D" + id, nil
+	}
+	refund := func(body string) (int, repo.PixRefund) {
+		r := httptest.NewRequest(http.MethodPost, "/pix/devolucao/tx-1", strings.NewReader(body))
+		r = mux.SetURLVars(r, map[string]string{"txid": "tx-1"})
+		r = r.WithContext(middleware.WithPrincipal(r.Context(), middleware.Principal{UserID: "u-1"}))
+		w := httptest.NewRecorder()
+		PixRefundHandler(storeDDB)(w, r)
+		var rf repo.PixRefund
+		json.NewDecoder(w.Body).Decode(&rf)
+		return w.Code, rf
+	}
+	notify := func(id, status string) map[string]int {
+		t.Helper()
+		body := fmt.Sprintf(`{"pix":[{"endToEndId":"E123","txid":"tx-1","valor":"20.00","devolucoes":[{"id":%q,"rtrId":"D%s","valor":"5.00","status":%q}]}]}`, id, id, status)
+		r := httptest.NewRequest(http.MethodPost, "/pix/webhook?hmac=segredo", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		PixWebhookHandler(storeDDB)(w, r)
+		var resp map[string]int
+		json.NewDecoder(w.Body).Decode(&resp)
+		if w.Code != http.StatusOK {
+			t.Fatalf("webhook %s %s: status %d", id, status, w.Code)
+		}
+		return resp
+	}
+	reserved := func(step, id string) {
+		t.Helper()
+		if rf, err := charges.GetRefund(ctx, "d-1", "tx-1", id); err != nil || rf.Status != "EM_PROCESSAMENTO" || rf.RtrID != "D"+id {
+			t.Fatalf("%s: REFUND# = %+v, %v", step, rf, err)
+		}
+		if payment, _ := donations.GetPayment(ctx, "d-1"); payment.ValorDisponivel != money.Cents(1350) {
+			t.Fatalf("%s: valor_disponivel = %v", step, payment.ValorDisponivel)
+		}
+		if msgs, _ := charges.ListByDonation(ctx, "d-1", false); len(msgs) != 1 || msgs[0].Visivel {
+			t.Fatalf("%s: PIX# = %+v", step, msgs)
+		}
+	}
+	released := func(step, id string) {
+		t.Helper()
+		if rf, err := charges.GetRefund(ctx, "d-1", "tx-1", id); err != nil || rf.Status != "NAO_REALIZADO" {
+			t.Fatalf("%s: REFUND# = %+v, %v", step, rf, err)
+		}
+		msgs, err := charges.ListByDonation(ctx, "d-1", false)
+		if err != nil || len(msgs) != 1 || !msgs[0].Visivel || msgs[0].Status != "CONCLUIDA" || !msgs[0].ValorDevolvido.IsZero() {
+			t.Fatalf("%s: PIX# = %+v, %v", step, msgs, err)
+		}
+		if p, err := donations.GetProgress(ctx, "d-1"); err != nil || p != before {
+			t.Fatalf("%s: GetProgress = %+v, %v; want %+v", step, p, err, before)
+		}
+		check, err := repo.NewLedgerRepo(storeDDB).Check(ctx, "d-1")
+		if err != nil || !check.OK() || check.Saldos[repo.AccountCampanha] != money.Cents(1800) {
+			t.Fatalf("%s: conferencia = %+v, %v", step, check, err)
+		}
+	}
+
+	// EM_PROCESSAMENTO mantem a reserva; a consulta no pedido repetido traz o
+	// NAO_REALIZADO e desfaz.
+	if code, rf := refund(`{"id":"dev1","valor":"5.00"}`); code != http.StatusCreated || rf.Status != "EM_PROCESSAMENTO" {
+		t.Fatalf("dev1: status %d, %+v", code, rf)
+	}
+	reserved("dev1 em processamento", "dev1")
+	consultarDevolucao = func(e2eid, id string) (string, string, error) { return "NAO_REALIZADO", "D" + id, nil }
+	if code, rf := refund(`{"id":"dev1","valor":"5.00"}`); code != http.StatusOK || rf.Status != "NAO_REALIZADO" {
+		t.Fatalf("dev1 consulta: status %d, %+v", code, rf)
+	}
+	released("dev1 consulta", "dev1")
+
+	// O webhook da devolucao tambem desfaz, uma unica vez.
+	if code, _ := refund(`{"id":"dev2","valor":"5.00"}`); code != http.StatusCreated {
+		t.Fatalf("dev2: status %d", code)
+	}
+	reserved("dev2 em processamento", "dev2")
+	if resp := notify("dev2", "NAO_REALIZADO"); resp["devolucoes"] != 1 {
+		t.Fatalf("webhook dev2: %v", resp)
+	}
+	released("webhook dev2", "dev2")
+	if resp := notify("dev2", "NAO_REALIZADO"); resp["devolucoes"] != 0 {
+		t.Fatalf("reenvio dev2: %v", resp)
+	}
+	released("reenvio dev2", "dev2")
+
+	// Confirmada pelo webhook, a reserva fica e um status posterior nao muda.
+	if code, _ := refund(`{"id":"dev3","valor":"5.00"}`); code != http.StatusCreated {
+		t.Fatalf("dev3: status %d", code)
+	}
+	if resp := notify("dev3", "DEVOLVIDO"); resp["devolucoes"] != 1 {
+		t.Fatalf("webhook dev3: %v", resp)
+	}
+	if resp := notify("dev3", "NAO_REALIZADO"); resp["devolucoes"] != 0 {
+		t.Fatalf("webhook dev3 apos DEVOLVIDO: %v", resp)
+	}
+	if rf, err := charges.GetRefund(ctx, "d-1", "tx-1", "dev3"); err != nil || rf.Status != "DEVOLVIDO" || rf.RtrID != "Ddev3" {
+		t.Fatalf("dev3: REFUND# = %+v, %v", rf, err)
+	}
+	if payment, _ := donations.GetPayment(ctx, "d-1"); payment.ValorDisponivel != money.Cents(1350) {
+		t.Fatalf("dev3: valor_disponivel = %v", payment.ValorDisponivel)
+	}
+}
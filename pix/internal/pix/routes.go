@@ -1,16 +1,30 @@
 package pix
 
 import (
+	"BACK_SORTE_GO/common/middleware"
 	"BACK_SORTE_GO/internal/app"
 	"BACK_SORTE_GO/internal/donation"
 
 	"github.com/gorilla/mux"
 )
 
+// ScopeMonitor libera o monitoramento em lote das cobrancas para clientes de maquina;
+// usuarios ADMIN tambem podem disparar o monitoramento.
+const ScopeMonitor = "pix:monitor"
+
 func RegisterRoutes(router *mux.Router, a *app.App) {
+	auth := middleware.RequireAuth(a.Auth)
+	monitor := middleware.RequireRoleOrScope(middleware.RoleAdmin, ScopeMonitor)
+	recentMFA := middleware.RequireRecentMFA(a.Store)
+
 	router.HandleFunc("/pix/create", CreatePixTokenHandler(a.Store)).Methods("POST")
 	router.HandleFunc("/pix/status/{txid}", PixChargeStatusHandler()).Methods("GET")
 	router.HandleFunc("/pix/monitora/{txid}", MonitorarStatusPagamentoHandler(a.Store)).Methods("POST")
+	// A EFI acrescenta /pix na URL cadastrada; as duas formas sao aceitas.
+	router.HandleFunc("/pix/webhook", PixWebhookHandler(a.Store)).Methods("POST")
+	router.HandleFunc("/pix/webhook/pix", PixWebhookHandler(a.Store)).Methods("POST")
+	router.Handle("/pix/devolucao/{txid}", auth(recentMFA(PixRefundHandler(a.Store)))).Methods("POST")
+	router.HandleFunc("/pix/static/{donationId}", PixStaticHandler(a.Store)).Methods("GET")
 	router.HandleFunc("/pix/total/{id}", donation.DonationSummaryByIDHandler(a.Store)).Methods("GET")
-	router.HandleFunc("/pix/monitora/all", MonitorarStatusAllPagamentosHandler(a.Store)).Methods("GET")
+	router.Handle("/pix/monitora/all", auth(monitor(MonitorarStatusAllPagamentosHandler(a.Store)))).Methods("GET")
 }
@@ -0,0 +1,136 @@
+package pix
+
+import (
+	"BACK_SORTE_GO/common/config"
+	"BACK_SORTE_GO/common/money"
+	"BACK_SORTE_GO/common/repo"
+	"BACK_SORTE_GO/common/store/dynamo"
+	"BACK_SORTE_GO/internal/brcode"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// staticQRSize e o lado, em pixels, do PNG do QR estatico.
+const staticQRSize = 512
+
+// PixStaticHandler gera o BR Code estatico da campanha com a chave Pix da
+// conta bancaria do dono, sem passar pela EFI. O pagamento cai direto na
+// conta do dono e nao e contabilizado no progresso. ?valor= fixa o valor e
+// ?formato=png devolve a imagem do QR em vez do JSON.
+func PixStaticHandler(storeDDB dynamo.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		donation, cerr := payableDonation(ctx, storeDDB, mux.Vars(r)["donationId"], time.Now())
+		if cerr != nil {
+			writeChargeError(w, cerr)
+			return
+		}
+		var valor money.Money
+		if raw := r.URL.Query().Get("valor"); raw != "" {
+			if valor, cerr = parseValor(raw); cerr != nil {
+				writeChargeError(w, cerr)
+				return
+			}
+		}
+
+		chave, err := ownerPixKey(ctx, storeDDB, donation.IDUser)
+		if err != nil {
+			writeChargeError(w, newChargeError(http.StatusInternalServerError, codeErroInterno, "Erro ao buscar conta do dono: "+err.Error()))
+			return
+		}
+		if chave == "" {
+			writeChargeError(w, newChargeError(http.StatusConflict, codeChavePixAusente, "Dono da campanha sem chave Pix cadastrada"))
+			return
+		}
+		nome := donation.Name
+		if owner, err := repo.NewUserRepo(storeDDB).Get(ctx, donation.IDUser); err == nil && owner.Name != "" {
+			nome = owner.Name
+		}
+
+		txid := staticTxID(donation.ID)
+		payload, err := brcode.Payload{
+			Chave:  chave,
+			Valor:  valor,
+			Nome:   nome,
+			Cidade: config.GetPixMerchantCity(),
+			TxID:   txid,
+		}.String()
+		if err != nil {
+			writeChargeError(w, newChargeError(http.StatusInternalServerError, codeErroInterno, "Erro ao gerar BR Code: "+err.Error()))
+			return
+		}
+
+		if r.URL.Query().Get("formato") == "png" {
+			png, err := qrcode.Encode(payload, qrcode.Medium, staticQRSize)
+			if err != nil {
+				writeChargeError(w, newChargeError(http.StatusInternalServerError, codeErroInterno, "Erro ao gerar QR Code: "+err.Error()))
+				return
+			}
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(png)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id_doacao":        donation.ID,
+			"chave":            chave,
+			"valor":            valor,
+			"txid":             txid,
+			"pix_copia_e_cola": payload,
+		})
+	}
+}
+
+// ownerPixKey devolve a chave Pix da conta ativa mais recente do usuario.
+func ownerPixKey(ctx context.Context, storeDDB dynamo.Store, userID string) (string, error) {
+	accounts, err := repo.NewBankRepo(storeDDB).ListByUser(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	var chave, latest string
+	for _, acc := range accounts {
+		if !acc.Active || acc.Dell || strings.TrimSpace(acc.Pix) == "" || acc.DateCreate < latest {
+			continue
+		}
+		chave, latest = strings.TrimSpace(acc.Pix), acc.DateCreate
+	}
+	return chave, nil
+}
+
+// staticTxID identifica a campanha no QR estatico (ate 25 letras ou numeros).
+func staticTxID(donationID string) string {
+	id := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, donationID)
+	if len(id) > 25 {
+		id = id[:25]
+	}
+	return id
+}
+
+// copiaECola e o payload do QR da cobranca: o pixCopiaECola devolvido pela EFI
+// ou, sem ele, o BR Code dinamico montado com o location.
+func copiaECola(resMap map[string]interface{}, location string) string {
+	if payload, _ := resMap["pixCopiaECola"].(string); payload != "" {
+		return payload
+	}
+	payload, err := brcode.Payload{
+		URL:    location,
+		Nome:   config.GetPixMerchantName(),
+		Cidade: config.GetPixMerchantCity(),
+	}.String()
+	if err != nil {
+		return ""
+	}
+	return payload
+}
@@ -0,0 +1,80 @@
+package pix
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"BACK_SORTE_GO/common/repo"
+	"BACK_SORTE_GO/common/store/dynamo"
+	"BACK_SORTE_GO/internal/brcode"
+
+	"github.com/gorilla/mux"
+)
+
+func TestPixStatic(t *testing.T) {
+	ctx := context.Background()
+	storeDDB := dynamo.NewMemory("core")
+	createDonation(t, storeDDB, repo.Donation{ID: "d-1", Active: true})
+	createDonation(t, storeDDB, repo.Donation{ID: "d-2", Closed: true})
+
+	get := func(id, query string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/pix/static/"+id+query, nil)
+		w := httptest.NewRecorder()
+		PixStaticHandler(storeDDB)(w, mux.SetURLVars(r, map[string]string{"donationId": id}))
+		return w
+	}
+	code := func(w *httptest.ResponseRecorder) string {
+		var e chargeError
+		json.NewDecoder(w.Body).Decode(&e)
+		return e.Code
+	}
+
+	if w := get("d-1", ""); w.Code != http.StatusConflict || code(w) != codeChavePixAusente {
+		t.Fatalf("sem chave: status %d", w.Code)
+	}
+	if w := get("d-2", ""); code(w) != codeDoacaoEncerrada {
+		t.Fatalf("encerrada: status %d", w.Code)
+	}
+
+	banks := repo.NewBankRepo(storeDDB)
+	for _, acc := range []repo.BankAccount{
+		{ID: "b-1", Pix: "antiga@example.com", Active: true, DateCreate: "2024-01-01T00:00:00Z"},
+		{ID: "b-2", Pix: "dona@example.com", Active: true, DateCreate: "2024-02-01T00:00:00Z"},
+		{ID: "b-3", Pix: "desativada@example.com", DateCreate: "2024-03-01T00:00:00Z"},
+	} {
+		acc.IDUser = "u-1"
+		if err := banks.Create(ctx, acc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if w := get("d-1", "?valor=0.50"); code(w) != codeValorAbaixoMinimo {
+		t.Fatalf("valor baixo: status %d", w.Code)
+	}
+	w := get("d-1", "?valor=25")
+	var resp struct {
+		Chave         string  `json:"chave"`
+		Valor         float64 `json:"valor"`
+		PixCopiaECola string  `json:"pix_copia_e_cola"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("status %d, %v", w.Code, err)
+	}
+	payload := resp.PixCopiaECola
+	if resp.Chave != "dona@example.com" || resp.Valor != 25 || !strings.Contains(payload, "0116dona@example.com") || !strings.Contains(payload, "540525.00") {
+		t.Fatalf("resposta = %+v", resp)
+	}
+	if crc := payload[len(payload)-4:]; crc != brcode.CRC16(payload[:len(payload)-4]) {
+		t.Fatalf("CRC %s nao confere", crc)
+	}
+
+	w = get("d-1", "?formato=png")
+	if w.Header().Get("Content-Type") != "image/png" || !bytes.HasPrefix(w.Body.Bytes(), []byte("\x89PNG")) {
+		t.Fatalf("png: status %d, %s", w.Code, w.Header().Get("Content-Type"))
+	}
+}
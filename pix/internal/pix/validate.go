@@ -0,0 +1,164 @@
+package pix
+
+import (
+	"BACK_SORTE_GO/common/money"
+	"BACK_SORTE_GO/common/repo"
+	"BACK_SORTE_GO/common/store/dynamo"
+	"BACK_SORTE_GO/common/text"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Limites da cobranca. Nome e mensagem seguem os tamanhos aceitos pela EFI
+// (devedor.nome e solicitacaoPagador).
+const (
+	pixNomeMaxLen  = 200
+	pixMensagemMax = 140
+)
+
+var (
+	pixMinValor = money.Cents(100)
+	pixMaxValor = money.Cents(5000000)
+)
+
+// Codigos de erro da criacao de cobranca, devolvidos em {"code", "message"}.
+const (
+	codeJSONInvalido        = "JSON_INVALIDO"
+	codeDoacaoObrigatoria   = "DOACAO_OBRIGATORIA"
+	codeDoacaoNaoEncontrada = "DOACAO_NAO_ENCONTRADA"
+	codeDoacaoExcluida      = "DOACAO_EXCLUIDA"
+	codeDoacaoEncerrada     = "DOACAO_ENCERRADA"
+	codeDoacaoInativa       = "DOACAO_INATIVA"
+	codeValorInvalido       = "VALOR_INVALIDO"
+	codeValorAbaixoMinimo   = "VALOR_ABAIXO_MINIMO"
+	codeValorAcimaMaximo    = "VALOR_ACIMA_MAXIMO"
+	codeCPFInvalido         = "CPF_INVALIDO"
+	codeNomeObrigatorio     = "NOME_OBRIGATORIO"
+	codeNomeLongo           = "NOME_LONGO"
+	codeMensagemLonga       = "MENSAGEM_LONGA"
+	codeEmailInvalido       = "EMAIL_INVALIDO"
+	codeChavePixAusente     = "CHAVE_PIX_AUSENTE"
+	codeErroEFI             = "ERRO_EFI"
+	codeErroInterno         = "ERRO_INTERNO"
+)
+
+// chargeError e uma falha da criacao de cobranca com codigo estavel para o
+// front tratar.
+type chargeError struct {
+	status  int
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func newChargeError(status int, code, message string) *chargeError {
+	return &chargeError{status: status, Code: code, Message: message}
+}
+
+func writeChargeError(w http.ResponseWriter, e *chargeError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(e.status)
+	json.NewEncoder(w).Encode(e)
+}
+
+// validateCharge confere o pedido e a campanha antes de criar a cobranca na
+// EFI. Normaliza CPF (so digitos), nome, mensagem e e-mail em req e devolve o
+// valor.
+func validateCharge(ctx context.Context, storeDDB dynamo.Store, req *PixChargeRequest, at time.Time) (money.Money, *chargeError) {
+	req.IdDoacao = strings.TrimSpace(req.IdDoacao)
+	req.Nome = strings.TrimSpace(req.Nome)
+	req.Mensagem = strings.TrimSpace(req.Mensagem)
+	req.CPF = text.Digits(req.CPF)
+	req.Email = strings.ToLower(strings.TrimSpace(req.Email))
+
+	if req.IdDoacao == "" {
+		return money.Money{}, newChargeError(http.StatusBadRequest, codeDoacaoObrigatoria, "Campo 'id' obrigatorio")
+	}
+	valor, cerr := parseValor(req.Valor)
+	if cerr != nil {
+		return money.Money{}, cerr
+	}
+	if !validCPF(req.CPF) {
+		return money.Money{}, newChargeError(http.StatusBadRequest, codeCPFInvalido, "CPF invalido")
+	}
+	if req.Nome == "" {
+		return money.Money{}, newChargeError(http.StatusBadRequest, codeNomeObrigatorio, "Campo 'nome' obrigatorio")
+	}
+	if utf8.RuneCountInString(req.Nome) > pixNomeMaxLen {
+		return money.Money{}, newChargeError(http.StatusBadRequest, codeNomeLongo, fmt.Sprintf("Campo 'nome' deve ter no maximo %d caracteres", pixNomeMaxLen))
+	}
+	if utf8.RuneCountInString(req.Mensagem) > pixMensagemMax {
+		return money.Money{}, newChargeError(http.StatusBadRequest, codeMensagemLonga, fmt.Sprintf("Campo 'mensagem' deve ter no maximo %d caracteres", pixMensagemMax))
+	}
+	if req.Novidades || req.Email != "" {
+		if addr, err := mail.ParseAddress(req.Email); err != nil || addr.Address != req.Email {
+			return money.Money{}, newChargeError(http.StatusBadRequest, codeEmailInvalido, "Campo 'email' invalido para receber novidades")
+		}
+	}
+
+	if _, cerr := payableDonation(ctx, storeDDB, req.IdDoacao, at); cerr != nil {
+		return money.Money{}, cerr
+	}
+	return valor, nil
+}
+
+// parseValor le o valor em reais ("10,50" ou "10.50", ate duas casas) e
+// confere os limites da cobranca.
+func parseValor(raw string) (money.Money, *chargeError) {
+	valor, err := money.Parse(raw)
+	if err != nil || valor.IsNegative() {
+		return money.Money{}, newChargeError(http.StatusBadRequest, codeValorInvalido, "Valor invalido")
+	}
+	if cmp, err := valor.Cmp(pixMinValor); err != nil || cmp < 0 {
+		return money.Money{}, newChargeError(http.StatusBadRequest, codeValorAbaixoMinimo, fmt.Sprintf("Valor minimo e %s", pixMinValor))
+	}
+	if cmp, err := valor.Cmp(pixMaxValor); err != nil || cmp > 0 {
+		return money.Money{}, newChargeError(http.StatusBadRequest, codeValorAcimaMaximo, fmt.Sprintf("Valor maximo e %s", pixMaxValor))
+	}
+	return valor, nil
+}
+
+// payableDonation le a campanha e confere se ela ainda recebe pagamentos.
+func payableDonation(ctx context.Context, storeDDB dynamo.Store, id string, at time.Time) (repo.Donation, *chargeError) {
+	donation, err := repo.NewDonationRepo(storeDDB).Get(ctx, id)
+	if errors.Is(err, repo.ErrNotFound) {
+		return repo.Donation{}, newChargeError(http.StatusNotFound, codeDoacaoNaoEncontrada, "Doacao nao encontrada")
+	}
+	if err != nil {
+		return repo.Donation{}, newChargeError(http.StatusInternalServerError, codeErroInterno, "Erro ao buscar doacao: "+err.Error())
+	}
+	switch {
+	case donation.Dell:
+		return repo.Donation{}, newChargeError(http.StatusGone, codeDoacaoExcluida, "Doacao excluida")
+	case donation.Closed || donation.Ended(at):
+		return repo.Donation{}, newChargeError(http.StatusConflict, codeDoacaoEncerrada, "Doacao encerrada")
+	case !donation.Active:
+		return repo.Donation{}, newChargeError(http.StatusConflict, codeDoacaoInativa, "Doacao inativa")
+	}
+	return donation, nil
+}
+
+// validCPF confere os dois digitos verificadores; sequencias repetidas
+// ("11111111111") passam na conta mas nao sao CPFs validos.
+func validCPF(cpf string) bool {
+	if len(cpf) != 11 || strings.Count(cpf, cpf[:1]) == 11 {
+		return false
+	}
+	for n := 9; n <= 10; n++ {
+		sum := 0
+		for i := 0; i < n; i++ {
+			sum += int(cpf[i]-'0') * (n + 1 - i)
+		}
+		digit := sum * 10 % 11 % 10
+		if digit != int(cpf[n]-'0') {
+			return false
+		}
+	}
+	return true
+}
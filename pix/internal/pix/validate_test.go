@@ -0,0 +1,94 @@
+package pix
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"BACK_SORTE_GO/common/money"
+	"BACK_SORTE_GO/common/repo"
+	"BACK_SORTE_GO/common/store/dynamo"
+)
+
+func createDonation(t *testing.T, storeDDB dynamo.Store, d repo.Donation) {
+	t.Helper()
+	d.IDUser, d.Name, d.Valor, d.DateCreate, d.NomeLink = "u-1", "Campanha "+d.ID, money.Cents(10000), "2024-01-01T00:00:00Z", "@"+d.ID
+	err := repo.NewDonationRepo(storeDDB).Create(context.Background(), repo.NewDonation{
+		Profile: d,
+		Details: repo.DonationDetails{ID: "dd-" + d.ID, IDDoacao: d.ID},
+		Link:    repo.DonationLink{ID: "l-" + d.ID, IDDoacao: d.ID, NomeLink: d.NomeLink},
+		Payment: repo.DonationPayment{ID: "p-" + d.ID, IDDoacao: d.ID, Status: "START"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidCPF(t *testing.T) {
+	for cpf, want := range map[string]bool{
+		"52998224725": true,
+		"11144477735": true,
+		"52998224724": false,
+		"11111111111": false,
+		"1234567890":  false,
+		"":            false,
+	} {
+		if got := validCPF(cpf); got != want {
+			t.Errorf("validCPF(%q) = %v", cpf, got)
+		}
+	}
+}
+
+func TestValidateCharge(t *testing.T) {
+	ctx := context.Background()
+	storeDDB := dynamo.NewMemory("core")
+	for _, d := range []repo.Donation{
+		{ID: "aberta", Active: true},
+		{ID: "excluida", Active: false, Dell: true},
+		{ID: "encerrada", Active: false, Closed: true},
+		{ID: "vencida", Active: true, DateEnd: "2020-01-01T00:00:00Z"},
+		{ID: "inativa"},
+	} {
+		createDonation(t, storeDDB, d)
+	}
+
+	ok := PixChargeRequest{Valor: "10.5", CPF: "529.982.247-25", Nome: " Joao ", Mensagem: "Forca!", IdDoacao: "aberta"}
+	cases := []struct {
+		name string
+		edit func(*PixChargeRequest)
+		code string
+	}{
+		{"valido", func(*PixChargeRequest) {}, ""},
+		{"sem id", func(r *PixChargeRequest) { r.IdDoacao = " " }, codeDoacaoObrigatoria},
+		{"valor texto", func(r *PixChargeRequest) { r.Valor = "dez" }, codeValorInvalido},
+		{"tres casas", func(r *PixChargeRequest) { r.Valor = "10.555" }, codeValorInvalido},
+		{"abaixo", func(r *PixChargeRequest) { r.Valor = "0.99" }, codeValorAbaixoMinimo},
+		{"acima", func(r *PixChargeRequest) { r.Valor = "50000.01" }, codeValorAcimaMaximo},
+		{"cpf", func(r *PixChargeRequest) { r.CPF = "529.982.247-24" }, codeCPFInvalido},
+		{"sem nome", func(r *PixChargeRequest) { r.Nome = "" }, codeNomeObrigatorio},
+		{"mensagem", func(r *PixChargeRequest) { r.Mensagem = strings.Repeat("a", pixMensagemMax+1) }, codeMensagemLonga},
+		{"novidades", func(r *PixChargeRequest) { r.Novidades, r.Email = true, " Joao@Exemplo.com " }, ""},
+		{"novidades sem email", func(r *PixChargeRequest) { r.Novidades = true }, codeEmailInvalido},
+		{"email invalido", func(r *PixChargeRequest) { r.Email = "joao@" }, codeEmailInvalido},
+		{"inexistente", func(r *PixChargeRequest) { r.IdDoacao = "nenhuma" }, codeDoacaoNaoEncontrada},
+		{"excluida", func(r *PixChargeRequest) { r.IdDoacao = "excluida" }, codeDoacaoExcluida},
+		{"encerrada", func(r *PixChargeRequest) { r.IdDoacao = "encerrada" }, codeDoacaoEncerrada},
+		{"date_end", func(r *PixChargeRequest) { r.IdDoacao = "vencida" }, codeDoacaoEncerrada},
+		{"inativa", func(r *PixChargeRequest) { r.IdDoacao = "inativa" }, codeDoacaoInativa},
+	}
+	for _, c := range cases {
+		req := ok
+		c.edit(&req)
+		valor, cerr := validateCharge(ctx, storeDDB, &req, time.Now())
+		if c.code == "" {
+			if cerr != nil || valor != money.Cents(1050) || req.CPF != "52998224725" || req.Nome != "Joao" {
+				t.Fatalf("%s: valor %v, req %+v, erro %+v", c.name, valor, req, cerr)
+			}
+			continue
+		}
+		if cerr == nil || cerr.Code != c.code {
+			t.Errorf("%s: erro %+v, esperado %s", c.name, cerr, c.code)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package pix
+
+import (
+	"BACK_SORTE_GO/common/config"
+	"BACK_SORTE_GO/common/money"
+	"BACK_SORTE_GO/common/repo"
+	"BACK_SORTE_GO/common/store/dynamo"
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// pixWebhookPayload e a notificacao da EFI com os Pix recebidos.
+type pixWebhookPayload struct {
+	Pix []pixRecebido `json:"pix"`
+}
+
+type pixRecebido struct {
+	EndToEndID string              `json:"endToEndId"`
+	TxID       string              `json:"txid"`
+	Valor      string              `json:"valor"`
+	Horario    string              `json:"horario"`
+	Devolucoes []devolucaoRecebida `json:"devolucoes"`
+}
+
+// devolucaoRecebida e o status de uma devolucao do Pix, enviado pela EFI no
+// mesmo webhook quando a devolucao muda de status.
+type devolucaoRecebida struct {
+	ID     string `json:"id"`
+	RtrID  string `json:"rtrId"`
+	Valor  string `json:"valor"`
+	Status string `json:"status"`
+}
+
+// webhookAuthorized exige o segredo compartilhado (?hmac= na URL cadastrada
+// na EFI ou header X-Webhook-Secret). Sem PIX_WEBHOOK_SECRET o webhook recusa
+// tudo.
+func webhookAuthorized(r *http.Request) bool {
+	secret := config.GetPixWebhookSecret()
+	if secret == "" {
+		return false
+	}
+	got := r.URL.Query().Get("hmac")
+	if got == "" {
+		got = r.Header.Get("X-Webhook-Secret")
+	}
+	return subtle.ConstantTimeCompare([]byte(got), []byte(secret)) == 1
+}
+
+// PixWebhookHandler recebe as notificacoes de pagamento da EFI e confirma cada
+// cobranca uma unica vez (Confirm e condicional em finalizado). As devolucoes
+// que vem junto com o Pix concluem ou desfazem as reservas ainda abertas. Erro
+// de gravacao devolve 500 para a EFI reenviar a notificacao.
+func PixWebhookHandler(storeDDB dynamo.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !webhookAuthorized(r) {
+			http.Error(w, "Webhook nao autorizado", http.StatusUnauthorized)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+		if err != nil {
+			http.Error(w, "Erro ao ler notificacao", http.StatusBadRequest)
+			return
+		}
+		// No cadastro do webhook a EFI envia uma notificacao sem Pix.
+		var payload pixWebhookPayload
+		if len(bytes.TrimSpace(body)) > 0 {
+			if err := json.Unmarshal(body, &payload); err != nil {
+				http.Error(w, "Erro ao decodificar JSON: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		confirmados, divergentes, devolucoes := 0, 0, 0
+		for _, p := range payload.Pix {
+			if len(p.Devolucoes) > 0 {
+				n, err := atualizarDevolucoes(r.Context(), storeDDB, p)
+				if err != nil {
+					http.Error(w, "Erro ao atualizar devolucao: "+err.Error(), http.StatusInternalServerError)
+					return
+				}
+				devolucoes += n
+				continue
+			}
+			result, err := confirmarPixRecebido(r.Context(), storeDDB, p)
+			if err != nil {
+				http.Error(w, "Erro ao confirmar pagamento: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			switch result {
+			case pixConfirmado:
+				confirmados++
+			case pixDivergente:
+				divergentes++
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]int{
+			"recebidos":   len(payload.Pix),
+			"confirmados": confirmados,
+			"divergentes": divergentes,
+			"devolucoes":  devolucoes,
+		})
+	}
+}
+
+// Resultado de cada Pix da notificacao.
+const (
+	pixIgnorado = iota
+	pixConfirmado
+	pixDivergente
+)
+
+// confirmarPixRecebido casa o txid com o TX# e confirma a cobranca. Pix sem
+// cobranca nossa so gera aviso; valor diferente do cobrado gera aviso, nao
+// confirma a cobranca e entra em "divergentes" na resposta.
+func confirmarPixRecebido(ctx context.Context, storeDDB dynamo.Store, p pixRecebido) (int, error) {
+	if p.TxID == "" {
+		fmt.Printf("aviso: pix %s recebido sem txid\n", p.EndToEndID)
+		return pixIgnorado, nil
+	}
+	charges := repo.NewPixRepo(storeDDB)
+	st, err := charges.GetStatus(ctx, p.TxID)
+	if errors.Is(err, repo.ErrNotFound) {
+		fmt.Printf("aviso: pix %s com txid %s desconhecido\n", p.EndToEndID, p.TxID)
+		return pixIgnorado, nil
+	}
+	if err != nil {
+		return pixIgnorado, err
+	}
+	valor, err := money.Parse(p.Valor)
+	cmp := 1
+	if err == nil {
+		cmp, err = valor.Cmp(st.Valor)
+	}
+	if err != nil || cmp != 0 {
+		fmt.Printf("aviso: pix %s pagou %q na cobranca %s de %s; cobranca nao confirmada\n", p.EndToEndID, p.Valor, p.TxID, st.Valor)
+		return pixDivergente, nil
+	}
+	_, applied, err := charges.Confirm(ctx, p.TxID)
+	if err != nil || !applied {
+		return pixIgnorado, err
+	}
+	return pixConfirmado, nil
+}
+
+// atualizarDevolucoes aplica o status final das devolucoes do Pix aos REFUND#
+// ainda abertos e devolve quantos mudaram. Devolucao que nao saiu daqui (feita
+// no painel da EFI, por exemplo) so gera aviso.
+func atualizarDevolucoes(ctx context.Context, storeDDB dynamo.Store, p pixRecebido) (int, error) {
+	charges := repo.NewPixRepo(storeDDB)
+	st, err := charges.GetStatus(ctx, p.TxID)
+	if errors.Is(err, repo.ErrNotFound) {
+		fmt.Printf("aviso: devolucao do pix %s com txid %q desconhecido\n", p.EndToEndID, p.TxID)
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	atualizadas := 0
+	for _, d := range p.Devolucoes {
+		rf, err := charges.GetRefund(ctx, st.IDDoacao, p.TxID, d.ID)
+		if errors.Is(err, repo.ErrNotFound) {
+			fmt.Printf("aviso: devolucao %s do pix %s desconhecida\n", d.ID, p.EndToEndID)
+			continue
+		}
+		if err != nil {
+			return atualizadas, err
+		}
+		if !rf.Open() || rf.Status == d.Status {
+			continue
+		}
+		rf.Status, rf.DataUpdate = d.Status, time.Now().Format(time.RFC3339)
+		if d.RtrID != "" {
+			rf.RtrID = d.RtrID
+		}
+		if err := applyRefundStatus(ctx, charges, rf); err != nil {
+			return atualizadas, err
+		}
+		atualizadas++
+	}
+	return atualizadas, nil
+}
@@ -0,0 +1,81 @@
+package pix
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"BACK_SORTE_GO/common/money"
+	"BACK_SORTE_GO/common/repo"
+	"BACK_SORTE_GO/common/store/dynamo"
+)
+
+func TestPixWebhook(t *testing.T) {
+	t.Setenv("PIX_WEBHOOK_SECRET", "segredo")
+	ctx := context.Background()
+	storeDDB := dynamo.NewMemory("core")
+	createDonation(t, storeDDB, repo.Donation{ID: "d-1", Active: true})
+	err := repo.NewPixRepo(storeDDB).CreateCharge(ctx,
+		repo.Pix{ID: "p-1", IDDoacao: "d-1", Valor: money.Cents(2000), CPF: "52998224725", DataCriacao: "2024-01-02T00:00:00Z", TxID: "tx-1"},
+		repo.PixStatus{IDPixQRCode: "p-1", IDDoacao: "d-1", Status: "ATIVA", Buscar: true, IDPix: "tx-1", Valor: money.Cents(2000)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notify := func(query string, header map[string]string, body string) (int, map[string]int) {
+		r := httptest.NewRequest(http.MethodPost, "/pix/webhook/pix"+query, strings.NewReader(body))
+		for k, v := range header {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		PixWebhookHandler(storeDDB)(w, r)
+		var resp map[string]int
+		json.NewDecoder(w.Body).Decode(&resp)
+		return w.Code, resp
+	}
+	body := `{"pix":[{"endToEndId":"E1","txid":"tx-1","valor":"20.00","horario":"2024-01-02T00:01:00Z"},{"endToEndId":"E2","txid":"outro","valor":"5.00"}]}`
+
+	if code, _ := notify("?hmac=errado", nil, body); code != http.StatusUnauthorized {
+		t.Fatalf("segredo errado: status %d", code)
+	}
+	if code, _ := notify("", map[string]string{"X-Amzn-Mtls-Clientcert-Subject": "CN=efi"}, body); code != http.StatusUnauthorized {
+		t.Fatalf("so certificado, sem segredo: status %d", code)
+	}
+	if code, _ := notify("?hmac=segredo", nil, ""); code != http.StatusOK {
+		t.Fatalf("cadastro do webhook: status %d", code)
+	}
+	if code, resp := notify("?hmac=segredo", nil, body); code != http.StatusOK || resp["recebidos"] != 2 || resp["confirmados"] != 1 {
+		t.Fatalf("primeira notificacao: status %d, %v", code, resp)
+	}
+	// Reenvio da EFI nao conta o pagamento de novo.
+	if code, resp := notify("", map[string]string{"X-Webhook-Secret": "segredo"}, body); code != http.StatusOK || resp["confirmados"] != 0 {
+		t.Fatalf("reenvio: status %d, %v", code, resp)
+	}
+
+	// Valor diferente do cobrado nao confirma e aparece em divergentes.
+	err = repo.NewPixRepo(storeDDB).CreateCharge(ctx,
+		repo.Pix{ID: "p-2", IDDoacao: "d-1", Valor: money.Cents(3000), CPF: "52998224725", DataCriacao: "2024-01-02T00:00:00Z", TxID: "tx-2"},
+		repo.PixStatus{IDPixQRCode: "p-2", IDDoacao: "d-1", Status: "ATIVA", Buscar: true, IDPix: "tx-2", Valor: money.Cents(3000)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	partial := `{"pix":[{"endToEndId":"E3","txid":"tx-2","valor":"10.00"}]}`
+	if code, resp := notify("?hmac=segredo", nil, partial); code != http.StatusOK || resp["confirmados"] != 0 || resp["divergentes"] != 1 {
+		t.Fatalf("valor divergente: status %d, %v", code, resp)
+	}
+	if st, err := repo.NewPixRepo(storeDDB).GetStatus(ctx, "tx-2"); err != nil || st.Finalizado {
+		t.Fatalf("divergente confirmou: %+v, %v", st, err)
+	}
+
+	st, err := repo.NewPixRepo(storeDDB).GetStatus(ctx, "tx-1")
+	if err != nil || !st.Finalizado || st.Status != "CONCLUIDA" {
+		t.Fatalf("GetStatus = %+v, %v", st, err)
+	}
+	p, err := repo.NewDonationRepo(storeDDB).GetProgress(ctx, "d-1")
+	if err != nil || p.TotalArrecadado != money.Cents(2000) || p.TotalDoacoes != 1 {
+		t.Fatalf("GetProgress = %+v, %v", p, err)
+	}
+}
@@ -2,13 +2,16 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"log"
 	"net/http"
+	"time"
 
+	"BACK_SORTE_GO/common/middleware"
 	"BACK_SORTE_GO/internal/app"
-	"BACK_SORTE_GO/internal/middleware"
 	"BACK_SORTE_GO/internal/pix"
 
+	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/awslabs/aws-lambda-go-api-proxy/httpadapter"
 	"github.com/gorilla/mux"
@@ -29,5 +32,30 @@ func main() {
 	pix.RegisterRoutes(router, a)
 
 	adapter := httpadapter.NewV2(router)
-	lambda.Start(adapter.ProxyWithContext)
+	lambda.Start(func(ctx context.Context, raw json.RawMessage) (interface{}, error) {
+		// O agendamento do EventBridge concilia as cobrancas abertas; o resto e HTTP.
+		var schedule events.CloudWatchEvent
+		if err := json.Unmarshal(raw, &schedule); err == nil && schedule.Source == "aws.events" {
+			run, err := pix.ReconcileCharges(ctx, a.Store, "agendamento", time.Now())
+			log.Printf("conciliacao pix: %d verificadas, %d concluidas, %d vencidas, %d removidas, %d erros",
+				run.Verificadas, run.Concluidas, run.Vencidas, run.Removidas, run.Erros)
+			return run, err
+		}
+
+		// Migracoes pontuais chegam por invocacao direta: {"migracao": "acompanhamento"}.
+		var migration struct {
+			Migracao string `json:"migracao"`
+		}
+		if err := json.Unmarshal(raw, &migration); err == nil && migration.Migracao != "" {
+			done, err := pix.RunMigration(ctx, a.Store, migration.Migracao)
+			log.Printf("migracao %s: %d itens", migration.Migracao, done)
+			return map[string]int{"itens": done}, err
+		}
+
+		var req events.APIGatewayV2HTTPRequest
+		if err := json.Unmarshal(raw, &req); err != nil {
+			return nil, err
+		}
+		return adapter.ProxyWithContext(ctx, req)
+	})
 }
@@ -42,16 +42,22 @@ resource "aws_lambda_function" "pix" {
   runtime       = "provided.al2"
   filename      = var.lambda_zip
   source_code_hash = filebase64sha256(var.lambda_zip)
+  # A conciliacao agendada consulta a EFI cobranca por cobranca.
+  timeout       = 60
 
   environment {
     variables = {
-      DYNAMODB_TABLE = var.dynamodb_table
-      CLIENT_ID      = var.efi_client_id
-      CLIENT_SECRET  = var.efi_client_secret
-      SANDBOX        = var.efi_sandbox
-      TIMEOUT        = var.efi_timeout
-      CA_PEM         = var.efi_ca_pem
-      KEY_PEM        = var.efi_key_pem
+      DYNAMODB_TABLE     = var.dynamodb_table
+      CLIENT_ID          = var.efi_client_id
+      CLIENT_SECRET      = var.efi_client_secret
+      SANDBOX            = var.efi_sandbox
+      TIMEOUT            = var.efi_timeout
+      CA_PEM             = var.efi_ca_pem
+      KEY_PEM            = var.efi_key_pem
+      JWT_SECRET         = var.jwt_secret
+      PIX_WEBHOOK_SECRET = var.pix_webhook_secret
+      PIX_MERCHANT_NAME  = var.pix_merchant_name
+      PIX_MERCHANT_CITY  = var.pix_merchant_city
     }
   }
 }
@@ -87,3 +93,23 @@ resource "aws_lambda_permission" "pix" {
   principal     = "apigateway.amazonaws.com"
   source_arn    = "${aws_apigatewayv2_api.http.execution_arn}/*/*/pix/*"
 }
+
+resource "aws_cloudwatch_event_rule" "reconcile" {
+  name                = "${var.project_name}-pix-reconcile"
+  description         = "Concilia com a EFI as cobrancas Pix ainda abertas."
+  schedule_expression = var.reconcile_schedule_expression
+}
+
+resource "aws_cloudwatch_event_target" "reconcile_target" {
+  rule      = aws_cloudwatch_event_rule.reconcile.name
+  target_id = "pix-reconcile"
+  arn       = aws_lambda_function.pix.arn
+}
+
+resource "aws_lambda_permission" "allow_eventbridge_reconcile" {
+  statement_id  = "AllowExecutionFromEventBridgeReconcile"
+  action        = "lambda:InvokeFunction"
+  function_name = aws_lambda_function.pix.function_name
+  principal     = "events.amazonaws.com"
+  source_arn    = aws_cloudwatch_event_rule.reconcile.arn
+}
@@ -1,3 +1,7 @@
 output "api_endpoint" {
   value = aws_apigatewayv2_api.http.api_endpoint
 }
+
+output "reconcile_schedule_rule" {
+  value = aws_cloudwatch_event_rule.reconcile.name
+}
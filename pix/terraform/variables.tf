@@ -42,6 +42,32 @@ variable "efi_key_pem" {
   default = ""
 }
 
+variable "jwt_secret" {
+  type      = string
+  sensitive = true
+}
+
+variable "pix_webhook_secret" {
+  type      = string
+  default   = ""
+  sensitive = true
+}
+
+variable "pix_merchant_name" {
+  type    = string
+  default = "BACK SORTE"
+}
+
+variable "pix_merchant_city" {
+  type    = string
+  default = "SAO PAULO"
+}
+
+variable "reconcile_schedule_expression" {
+  type    = string
+  default = "rate(10 minutes)"
+}
+
 variable "lambda_zip" {
   type = string
 }
@@ -53,7 +53,6 @@ resource "aws_lambda_function" "users" {
       DYNAMODB_TABLE  = var.dynamodb_table
       AWS_BUCKET_NAME = var.aws_bucket_name
       AWS_BUCKET_NAME_IMG_DOACAO = var.aws_bucket_name_img_doacao
-      PASSWORD_RESET_KEY = var.password_reset_key
       JWT_SECRET = var.jwt_secret
     }
   }
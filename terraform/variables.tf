@@ -30,13 +30,7 @@ variable "aws_bucket_name_img_doacao" {
 variable "jwt_secret" {
   type        = string
   description = "JWT secret"
-  default     = ""
-}
-
-variable "password_reset_key" {
-  type        = string
-  description = "Password reset key"
-  default     = ""
+  sensitive   = true
 }
 
 variable "efi_client_id" {
@@ -1,7 +1,7 @@
 package users
 
 import (
-	"BACK_SORTE_GO/config"
+	"BACK_SORTE_GO/common/config"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -0,0 +1,78 @@
+package users
+
+import (
+	"BACK_SORTE_GO/common/middleware"
+	"BACK_SORTE_GO/common/repo"
+	"BACK_SORTE_GO/common/store/dynamo"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// validRoles sao os papeis que podem ser gravados no PROFILE.
+var validRoles = map[string]bool{
+	middleware.RoleAdmin: true,
+}
+
+// UserRolesUpdateHandler substitui os papeis de um usuario. Restrito a ADMIN; os
+// novos papeis valem a partir do proximo login ou refresh do usuario.
+func UserRolesUpdateHandler(storeDDB dynamo.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		adminID := middleware.UserIDFromContext(r.Context())
+		if adminID == "" {
+			http.Error(w, "ID do usuario invalido", http.StatusUnauthorized)
+			return
+		}
+
+		userID := mux.Vars(r)["id"]
+		if userID == "" {
+			http.Error(w, "ID do usuario nao fornecido", http.StatusBadRequest)
+			return
+		}
+
+		var req struct {
+			Roles []string `json:"roles"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Erro ao processar o JSON", http.StatusBadRequest)
+			return
+		}
+
+		roles := []string{}
+		seen := map[string]bool{}
+		for _, role := range req.Roles {
+			if !validRoles[role] {
+				http.Error(w, "Papel invalido: "+role, http.StatusBadRequest)
+				return
+			}
+			if !seen[role] {
+				seen[role] = true
+				roles = append(roles, role)
+			}
+		}
+
+		if userID == adminID && !seen[middleware.RoleAdmin] {
+			http.Error(w, "Nao e permitido remover o proprio papel ADMIN", http.StatusBadRequest)
+			return
+		}
+
+		ctx := r.Context()
+		users := repo.NewUserRepo(storeDDB)
+		if _, err := users.Get(ctx, userID); err != nil {
+			http.Error(w, "Usuario nao encontrado", http.StatusNotFound)
+			return
+		}
+
+		if err := users.UpdateRoles(ctx, userID, roles, adminID); err != nil {
+			http.Error(w, "Erro ao atualizar papeis", http.StatusInternalServerError)
+			return
+		}
+
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"message": "Papeis atualizados com sucesso",
+			"id":      userID,
+			"roles":   roles,
+		})
+	}
+}
@@ -1,8 +1,8 @@
 package users
 
 import (
-	"BACK_SORTE_GO/internal/store"
-	"BACK_SORTE_GO/internal/store/dynamo"
+	"BACK_SORTE_GO/common/repo"
+	"BACK_SORTE_GO/common/store/dynamo"
 	"net/http"
 	"strconv"
 	"strings"
@@ -15,7 +15,7 @@ import (
 
 const emailConfirmMaxAttempts = 5
 
-func UserConfirmEmailHandler(storeDDB *dynamo.Store) http.HandlerFunc {
+func UserConfirmEmailHandler(storeDDB dynamo.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		email := strings.TrimSpace(r.URL.Query().Get("email"))
 		token := strings.TrimSpace(r.URL.Query().Get("token"))
@@ -120,14 +120,7 @@ func UserConfirmEmailHandler(storeDDB *dynamo.Store) http.HandlerFunc {
 		}
 
 		now := time.Now().UTC().Format(time.RFC3339)
-		err = storeDDB.UpdateItem(ctx, map[string]types.AttributeValue{
-			"PK": dynamo.S(store.UserPK(userID)),
-			"SK": dynamo.S("PROFILE"),
-		}, "SET email_valid = :v, date_update = :d", nil, map[string]types.AttributeValue{
-			":v": dynamo.B(true),
-			":d": dynamo.S(now),
-		})
-		if err != nil {
+		if err := repo.NewUserRepo(storeDDB).SetEmailValid(ctx, userID); err != nil {
 			http.Error(w, "Erro ao atualizar validacao de email do usuario", http.StatusInternalServerError)
 			return
 		}
@@ -0,0 +1,78 @@
+package users
+
+import (
+	"BACK_SORTE_GO/common/middleware"
+	"BACK_SORTE_GO/common/repo"
+	"BACK_SORTE_GO/common/store/dynamo"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// FeePolicyListHandler devolve a politica de taxas em vigor e as versoes ja
+// publicadas, da mais recente para a mais antiga. Restrito a ADMIN.
+func FeePolicyListHandler(storeDDB dynamo.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fees := repo.NewFeeRepo(storeDDB)
+		current, err := fees.Current(r.Context())
+		if err != nil {
+			http.Error(w, "Erro ao buscar politica de taxas", http.StatusInternalServerError)
+			return
+		}
+		versions, err := fees.List(r.Context())
+		if err != nil {
+			http.Error(w, "Erro ao listar politicas de taxas", http.StatusInternalServerError)
+			return
+		}
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"atual":   current,
+			"versoes": versions,
+		})
+	}
+}
+
+// FeePolicyPublishHandler publica uma nova versao da politica de taxas. A
+// versao anterior continua gravada; pagamentos ja creditados guardam a versao
+// que usaram.
+func FeePolicyPublishHandler(storeDDB dynamo.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		adminID := middleware.UserIDFromContext(r.Context())
+		if adminID == "" {
+			http.Error(w, "ID do usuario invalido", http.StatusUnauthorized)
+			return
+		}
+
+		var req struct {
+			Descricao string          `json:"descricao"`
+			Regras    []repo.FeeRule  `json:"regras"`
+			Promocoes []repo.FeePromo `json:"promocoes"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Erro ao processar o JSON", http.StatusBadRequest)
+			return
+		}
+
+		policy := repo.FeePolicy{
+			Descricao: req.Descricao,
+			Regras:    req.Regras,
+			Promocoes: req.Promocoes,
+			IDUser:    adminID,
+		}
+		if err := policy.Validate(); err != nil {
+			http.Error(w, "Politica invalida: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		policy, err := repo.NewFeeRepo(storeDDB).Publish(r.Context(), policy)
+		if errors.Is(err, repo.ErrConflict) {
+			http.Error(w, "Outra versao foi publicada ao mesmo tempo, tente novamente", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Erro ao publicar politica de taxas", http.StatusInternalServerError)
+			return
+		}
+
+		jsonResponse(w, http.StatusCreated, policy)
+	}
+}
@@ -0,0 +1,270 @@
+package users
+
+import (
+	"BACK_SORTE_GO/common/middleware"
+	"BACK_SORTE_GO/common/repo"
+	"BACK_SORTE_GO/common/store"
+	"BACK_SORTE_GO/common/store/dynamo"
+	"BACK_SORTE_GO/common/totp"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const totpIssuer = "The Pure Grace"
+
+// mfaItem e o segundo fator do usuario (USER#{id} / MFA#TOTP). Os codigos de
+// recuperacao ficam apenas como sha256.
+type mfaItem struct {
+	Secret        string   `dynamodbav:"secret"`
+	Enabled       bool     `dynamodbav:"enabled"`
+	LastStep      int64    `dynamodbav:"last_step"`
+	RecoveryCodes []string `dynamodbav:"recovery_codes"`
+	DateEnabled   string   `dynamodbav:"date_enabled"`
+}
+
+func mfaKey(userID string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"PK": dynamo.S(store.UserPK(userID)),
+		"SK": dynamo.S(middleware.MFASK),
+	}
+}
+
+func loadMFA(r *http.Request, storeDDB dynamo.Store, userID string) (mfaItem, error) {
+	item, err := storeDDB.GetItem(r.Context(), store.UserPK(userID), middleware.MFASK)
+	if err != nil || len(item) == 0 {
+		return mfaItem{}, err
+	}
+	var mfa mfaItem
+	err = attributevalue.UnmarshalMap(item, &mfa)
+	return mfa, err
+}
+
+func newRecoveryCodes() ([]string, types.AttributeValue, error) {
+	codes, err := totp.GenerateRecoveryCodes(totp.RecoveryCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+	hashes := make([]string, 0, len(codes))
+	for _, c := range codes {
+		hashes = append(hashes, totp.HashRecoveryCode(c))
+	}
+	return codes, &types.AttributeValueMemberSS{Value: hashes}, nil
+}
+
+// UserMFAStatusHandler informa se o segundo fator esta ativo e quantos codigos de recuperacao restam.
+func UserMFAStatusHandler(storeDDB dynamo.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idUser := middleware.UserIDFromContext(r.Context())
+		if idUser == "" {
+			http.Error(w, "ID do usuario invalido", http.StatusUnauthorized)
+			return
+		}
+
+		mfa, err := loadMFA(r, storeDDB, idUser)
+		if err != nil {
+			http.Error(w, "Erro ao buscar segundo fator", http.StatusInternalServerError)
+			return
+		}
+
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"enabled":             mfa.Enabled,
+			"recovery_codes_left": len(mfa.RecoveryCodes),
+		})
+	}
+}
+
+// UserMFASetupHandler gera um novo segredo TOTP (ainda inativo) e a URI para o QR code.
+func UserMFASetupHandler(storeDDB dynamo.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idUser := middleware.UserIDFromContext(r.Context())
+		if idUser == "" {
+			http.Error(w, "ID do usuario invalido", http.StatusUnauthorized)
+			return
+		}
+
+		ctx := r.Context()
+		mfa, err := loadMFA(r, storeDDB, idUser)
+		if err != nil {
+			http.Error(w, "Erro ao buscar segundo fator", http.StatusInternalServerError)
+			return
+		}
+		if mfa.Enabled {
+			http.Error(w, "Segundo fator ja esta ativo", http.StatusConflict)
+			return
+		}
+
+		user, err := repo.NewUserRepo(storeDDB).Get(ctx, idUser)
+		if errors.Is(err, repo.ErrNotFound) {
+			http.Error(w, "Usuario nao encontrado", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Erro ao buscar usuario", http.StatusInternalServerError)
+			return
+		}
+
+		secret, err := totp.GenerateSecret()
+		if err != nil {
+			http.Error(w, "Erro ao gerar segredo", http.StatusInternalServerError)
+			return
+		}
+
+		now := time.Now().Format(time.RFC3339)
+		err = storeDDB.PutItem(ctx, map[string]types.AttributeValue{
+			"PK":          dynamo.S(store.UserPK(idUser)),
+			"SK":          dynamo.S(middleware.MFASK),
+			"id_user":     dynamo.S(idUser),
+			"secret":      dynamo.S(secret),
+			"enabled":     dynamo.B(false),
+			"date_create": dynamo.S(now),
+			"date_update": dynamo.S(now),
+		})
+		if err != nil {
+			http.Error(w, "Erro ao salvar segundo fator", http.StatusInternalServerError)
+			return
+		}
+
+		jsonResponse(w, http.StatusOK, map[string]string{
+			"secret":      secret,
+			"otpauth_uri": totp.ProvisioningURI(totpIssuer, user.Email, secret),
+		})
+	}
+}
+
+// UserMFAEnableHandler ativa o segundo fator apos conferir um codigo do app e
+// devolve os codigos de recuperacao (exibidos uma unica vez).
+func UserMFAEnableHandler(storeDDB dynamo.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idUser := middleware.UserIDFromContext(r.Context())
+		if idUser == "" {
+			http.Error(w, "ID do usuario invalido", http.StatusUnauthorized)
+			return
+		}
+
+		var req struct {
+			Code string `json:"code"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+			http.Error(w, "Erro ao processar o JSON", http.StatusBadRequest)
+			return
+		}
+
+		mfa, err := loadMFA(r, storeDDB, idUser)
+		if err != nil {
+			http.Error(w, "Erro ao buscar segundo fator", http.StatusInternalServerError)
+			return
+		}
+		if mfa.Enabled {
+			http.Error(w, "Segundo fator ja esta ativo", http.StatusConflict)
+			return
+		}
+		if mfa.Secret == "" {
+			http.Error(w, "Configuracao do segundo fator nao iniciada", http.StatusBadRequest)
+			return
+		}
+
+		step, ok := totp.Validate(mfa.Secret, req.Code, time.Now())
+		if !ok {
+			http.Error(w, "Codigo invalido", http.StatusBadRequest)
+			return
+		}
+
+		codes, hashes, err := newRecoveryCodes()
+		if err != nil {
+			http.Error(w, "Erro ao gerar codigos de recuperacao", http.StatusInternalServerError)
+			return
+		}
+
+		now := time.Now().Format(time.RFC3339)
+		err = storeDDB.UpdateItem(r.Context(), mfaKey(idUser),
+			"SET enabled = :e, last_step = :s, recovery_codes = :rc, date_enabled = :d, date_update = :d", nil,
+			map[string]types.AttributeValue{
+				":e":  dynamo.B(true),
+				":s":  dynamo.N(strconv.FormatInt(step, 10)),
+				":rc": hashes,
+				":d":  dynamo.S(now),
+			})
+		if err != nil {
+			http.Error(w, "Erro ao ativar segundo fator", http.StatusInternalServerError)
+			return
+		}
+
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"message":        "Segundo fator ativado com sucesso",
+			"recovery_codes": codes,
+		})
+	}
+}
+
+// UserMFARecoveryCodesHandler substitui os codigos de recuperacao. Exige segundo fator recente.
+func UserMFARecoveryCodesHandler(storeDDB dynamo.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idUser := middleware.UserIDFromContext(r.Context())
+		if idUser == "" {
+			http.Error(w, "ID do usuario invalido", http.StatusUnauthorized)
+			return
+		}
+
+		mfa, err := loadMFA(r, storeDDB, idUser)
+		if err != nil {
+			http.Error(w, "Erro ao buscar segundo fator", http.StatusInternalServerError)
+			return
+		}
+		if !mfa.Enabled {
+			http.Error(w, "Segundo fator nao esta ativo", http.StatusBadRequest)
+			return
+		}
+
+		codes, hashes, err := newRecoveryCodes()
+		if err != nil {
+			http.Error(w, "Erro ao gerar codigos de recuperacao", http.StatusInternalServerError)
+			return
+		}
+
+		err = storeDDB.UpdateItem(r.Context(), mfaKey(idUser), "SET recovery_codes = :rc, date_update = :d", nil,
+			map[string]types.AttributeValue{
+				":rc": hashes,
+				":d":  dynamo.S(time.Now().Format(time.RFC3339)),
+			})
+		if err != nil {
+			http.Error(w, "Erro ao salvar codigos de recuperacao", http.StatusInternalServerError)
+			return
+		}
+
+		jsonResponse(w, http.StatusOK, map[string]interface{}{
+			"recovery_codes": codes,
+		})
+	}
+}
+
+// UserMFADisableHandler desativa o segundo fator. Exige segundo fator recente.
+func UserMFADisableHandler(storeDDB dynamo.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idUser := middleware.UserIDFromContext(r.Context())
+		if idUser == "" {
+			http.Error(w, "ID do usuario invalido", http.StatusUnauthorized)
+			return
+		}
+
+		err := storeDDB.UpdateItem(r.Context(), mfaKey(idUser),
+			"SET enabled = :e, date_update = :d REMOVE secret, recovery_codes, last_step", nil,
+			map[string]types.AttributeValue{
+				":e": dynamo.B(false),
+				":d": dynamo.S(time.Now().Format(time.RFC3339)),
+			})
+		if err != nil {
+			http.Error(w, "Erro ao desativar segundo fator", http.StatusInternalServerError)
+			return
+		}
+
+		jsonResponse(w, http.StatusOK, map[string]string{
+			"message": "Segundo fator desativado com sucesso",
+		})
+	}
+}
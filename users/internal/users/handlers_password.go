@@ -1,21 +1,20 @@
 package users
 
 import (
-	"BACK_SORTE_GO/config"
-	"BACK_SORTE_GO/internal/store"
-	"BACK_SORTE_GO/internal/store/dynamo"
+	"BACK_SORTE_GO/common/middleware"
+	"BACK_SORTE_GO/common/repo"
+	"BACK_SORTE_GO/common/store"
+	"BACK_SORTE_GO/common/store/dynamo"
 	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
-	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
-	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -34,7 +33,7 @@ func generateRandomToken(length int) (string, error) {
 	return string(b), nil
 }
 
-func UserPasswordRecoverStartHandler(storeDDB *dynamo.Store) http.HandlerFunc {
+func UserPasswordRecoverStartHandler(storeDDB dynamo.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req struct {
 			Email string `json:"email"`
@@ -50,20 +49,12 @@ func UserPasswordRecoverStartHandler(storeDDB *dynamo.Store) http.HandlerFunc {
 		}
 
 		ctx := r.Context()
-		userOut, err := storeDDB.Query(ctx, &dynamodb.QueryInput{
-			IndexName:              aws.String("GSI2"),
-			KeyConditionExpression: aws.String("GSI2PK = :pk"),
-			ExpressionAttributeValues: map[string]types.AttributeValue{
-				":pk": dynamo.S("EMAIL#" + strings.ToLower(req.Email)),
-			},
-			Limit: aws.Int32(1),
-		})
-		if err != nil || len(userOut.Items) == 0 {
+		u, err := repo.NewUserRepo(storeDDB).FindByEmail(ctx, req.Email)
+		if errors.Is(err, repo.ErrNotFound) {
 			http.Error(w, "Email inexistente", http.StatusNotFound)
 			return
 		}
-		var u userItem
-		if err := attributevalue.UnmarshalMap(userOut.Items[0], &u); err != nil {
+		if err != nil {
 			http.Error(w, "Erro ao buscar usuario", http.StatusInternalServerError)
 			return
 		}
@@ -131,7 +122,7 @@ func UserPasswordRecoverStartHandler(storeDDB *dynamo.Store) http.HandlerFunc {
 	}
 }
 
-func UserPasswordRecoverConfirmHandler(storeDDB *dynamo.Store) http.HandlerFunc {
+func UserPasswordRecoverConfirmHandler(storeDDB dynamo.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req struct {
 			Email    string `json:"email"`
@@ -202,14 +193,7 @@ func UserPasswordRecoverConfirmHandler(storeDDB *dynamo.Store) http.HandlerFunc
 			return
 		}
 
-		err = storeDDB.UpdateItem(ctx, map[string]types.AttributeValue{
-			"PK": dynamo.S(store.UserPK(userID)),
-			"SK": dynamo.S("PROFILE"),
-		}, "SET password = :p, date_update = :d", nil, map[string]types.AttributeValue{
-			":p": dynamo.S(string(newHashedPassword)),
-			":d": dynamo.S(time.Now().Format(time.RFC3339)),
-		})
-		if err != nil {
+		if err := repo.NewUserRepo(storeDDB).UpdatePassword(ctx, userID, string(newHashedPassword)); err != nil {
 			http.Error(w, "Erro ao atualizar a senha", http.StatusInternalServerError)
 			return
 		}
@@ -228,26 +212,10 @@ func UserPasswordRecoverConfirmHandler(storeDDB *dynamo.Store) http.HandlerFunc
 	}
 }
 
-func UserPasswordChangeHandler(storeDDB *dynamo.Store) http.HandlerFunc {
+func UserPasswordChangeHandler(storeDDB dynamo.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			http.Error(w, "Token nao fornecido", http.StatusUnauthorized)
-			return
-		}
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-
-		claims := jwt.MapClaims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return jwtSecretKey, nil
-		})
-		if err != nil || !token.Valid {
-			http.Error(w, "Token invalido", http.StatusUnauthorized)
-			return
-		}
-
-		userID, ok := claims["sub"].(string)
-		if !ok || userID == "" {
+		userID := middleware.UserIDFromContext(r.Context())
+		if userID == "" {
 			http.Error(w, "ID do usuario invalido no token", http.StatusUnauthorized)
 			return
 		}
@@ -267,18 +235,14 @@ func UserPasswordChangeHandler(storeDDB *dynamo.Store) http.HandlerFunc {
 		}
 
 		ctx := r.Context()
-		item, err := storeDDB.GetItem(ctx, store.UserPK(userID), "PROFILE")
-		if err != nil || len(item) == 0 {
-			http.Error(w, "Usuario nao encontrado", http.StatusNotFound)
-			return
-		}
-		var u userItem
-		if err := attributevalue.UnmarshalMap(item, &u); err != nil {
+		users := repo.NewUserRepo(storeDDB)
+		u, err := users.Get(ctx, userID)
+		if err != nil {
 			http.Error(w, "Usuario nao encontrado", http.StatusNotFound)
 			return
 		}
 
-		if err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(req.OldPassword)); err != nil {
+		if err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(req.OldPassword)); err != nil {
 			http.Error(w, "Senha antiga incorreta", http.StatusUnauthorized)
 			return
 		}
@@ -289,14 +253,7 @@ func UserPasswordChangeHandler(storeDDB *dynamo.Store) http.HandlerFunc {
 			return
 		}
 
-		err = storeDDB.UpdateItem(ctx, map[string]types.AttributeValue{
-			"PK": dynamo.S(store.UserPK(userID)),
-			"SK": dynamo.S("PROFILE"),
-		}, "SET password = :p, date_update = :d", nil, map[string]types.AttributeValue{
-			":p": dynamo.S(string(newHashedPassword)),
-			":d": dynamo.S(time.Now().Format(time.RFC3339)),
-		})
-		if err != nil {
+		if err := repo.NewUserRepo(storeDDB).UpdatePassword(ctx, userID, string(newHashedPassword)); err != nil {
 			http.Error(w, "Erro ao atualizar a senha", http.StatusInternalServerError)
 			return
 		}
@@ -307,18 +264,13 @@ func UserPasswordChangeHandler(storeDDB *dynamo.Store) http.HandlerFunc {
 	}
 }
 
-func UserPasswordRecoverLinkHandler(storeDDB *dynamo.Store) http.HandlerFunc {
+// UserPasswordRecoverLinkHandler e uma ferramenta do suporte (papel ADMIN) para
+// obter o link de recuperacao pendente de um email.
+func UserPasswordRecoverLinkHandler(storeDDB dynamo.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		email := r.URL.Query().Get("email")
-		key := r.URL.Query().Get("key")
-
-		if email == "" || key == "" {
-			http.Error(w, "Email e key sao obrigatorios", http.StatusBadRequest)
-			return
-		}
-
-		if key != config.GetPasswordResetKey() {
-			http.Error(w, "Key invalida", http.StatusUnauthorized)
+		if email == "" {
+			http.Error(w, "Email e obrigatorio", http.StatusBadRequest)
 			return
 		}
 
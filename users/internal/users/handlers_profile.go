@@ -1,48 +1,30 @@
 package users
 
 import (
-	"BACK_SORTE_GO/config"
-	"BACK_SORTE_GO/internal/store"
-	"BACK_SORTE_GO/internal/store/dynamo"
+	"BACK_SORTE_GO/common/config"
+	"BACK_SORTE_GO/common/middleware"
+	"BACK_SORTE_GO/common/repo"
+	"BACK_SORTE_GO/common/store/dynamo"
 	"BACK_SORTE_GO/utils"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"path/filepath"
 	"strings"
-	"time"
 
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
-	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 )
 
-func UploadUserProfileImageHandler(storeDDB *dynamo.Store) http.HandlerFunc {
+func UploadUserProfileImageHandler(storeDDB dynamo.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			http.Error(w, "Token ausente", http.StatusUnauthorized)
-			return
-		}
-
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		claims := jwt.MapClaims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return jwtSecretKey, nil
-		})
-		if err != nil || !token.Valid {
-			http.Error(w, "Token invalido", http.StatusUnauthorized)
-			return
-		}
-
-		idFromToken, ok := claims["sub"].(string)
-		if !ok || idFromToken == "" {
+		idFromToken := middleware.UserIDFromContext(r.Context())
+		if idFromToken == "" {
 			http.Error(w, "ID do usuario invalido", http.StatusUnauthorized)
 			return
 		}
 
-		err = r.ParseMultipartForm(10 << 20)
+		err := r.ParseMultipartForm(10 << 20)
 		if err != nil {
 			http.Error(w, "Erro ao parsear o formulario: "+err.Error(), http.StatusBadRequest)
 			return
@@ -74,17 +56,7 @@ func UploadUserProfileImageHandler(storeDDB *dynamo.Store) http.HandlerFunc {
 			return
 		}
 
-		ctx := r.Context()
-		item := map[string]types.AttributeValue{
-			"PK":          dynamo.S(store.UserPK(idFromToken)),
-			"SK":          dynamo.S("DETAILS"),
-			"id":          dynamo.S(uuid.NewString()),
-			"id_user":     dynamo.S(idFromToken),
-			"img_perfil":  dynamo.S(fileName),
-			"date_update": dynamo.S(time.Now().Format(time.RFC3339)),
-		}
-
-		_ = storeDDB.PutItem(ctx, item)
+		_ = repo.NewUserRepo(storeDDB).SetProfileImage(r.Context(), idFromToken, uuid.NewString(), fileName)
 
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
@@ -93,7 +65,7 @@ func UploadUserProfileImageHandler(storeDDB *dynamo.Store) http.HandlerFunc {
 	}
 }
 
-func UserProfileImageHandler(storeDDB *dynamo.Store) http.HandlerFunc {
+func UserProfileImageHandler(storeDDB dynamo.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		userID := vars["id"]
@@ -103,14 +75,12 @@ func UserProfileImageHandler(storeDDB *dynamo.Store) http.HandlerFunc {
 			return
 		}
 
-		ctx := r.Context()
-		item, err := storeDDB.GetItem(ctx, store.UserPK(userID), "DETAILS")
-		if err != nil || len(item) == 0 {
+		details, err := repo.NewUserRepo(storeDDB).GetDetails(r.Context(), userID)
+		if err != nil {
 			http.Error(w, "Usuario nao encontrado ou sem imagem", http.StatusNotFound)
 			return
 		}
-		imgAttr, ok := item["img_perfil"].(*types.AttributeValueMemberS)
-		if !ok || imgAttr.Value == "" {
+		if details.ImgPerfil == "" {
 			http.Error(w, "Imagem de perfil nao cadastrada", http.StatusNotFound)
 			return
 		}
@@ -122,7 +92,7 @@ func UserProfileImageHandler(storeDDB *dynamo.Store) http.HandlerFunc {
 			return
 		}
 
-		url := fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", bucket, region, imgAttr.Value)
+		url := fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", bucket, region, details.ImgPerfil)
 
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
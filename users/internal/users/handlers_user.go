@@ -1,27 +1,22 @@
 package users
 
 import (
-	"BACK_SORTE_GO/internal/store"
-	"BACK_SORTE_GO/internal/store/dynamo"
+	"BACK_SORTE_GO/common/middleware"
+	"BACK_SORTE_GO/common/money"
+	"BACK_SORTE_GO/common/repo"
+	"BACK_SORTE_GO/common/store/dynamo"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
-	"strings"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
-	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"golang.org/x/crypto/bcrypt"
 )
 
-var jwtSecretKey = []byte("SUA_CHAVE_SECRETA")
-
-func CreateUserHandler(storeDDB *dynamo.Store) http.HandlerFunc {
+func CreateUserHandler(storeDDB dynamo.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req struct {
 			Name     string `json:"name"`
@@ -41,21 +36,13 @@ func CreateUserHandler(storeDDB *dynamo.Store) http.HandlerFunc {
 		}
 
 		ctx := r.Context()
-		existsOut, err := storeDDB.Query(ctx, &dynamodb.QueryInput{
-			IndexName:              aws.String("GSI2"),
-			KeyConditionExpression: aws.String("GSI2PK = :pk"),
-			ExpressionAttributeValues: map[string]types.AttributeValue{
-				":pk": dynamo.S("EMAIL#" + strings.ToLower(req.Email)),
-			},
-			Limit: aws.Int32(1),
-		})
-		if err != nil {
-			http.Error(w, "Erro ao verificar duplicacao de email: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		if len(existsOut.Items) > 0 {
+		users := repo.NewUserRepo(storeDDB)
+		if _, err := users.FindByEmail(ctx, req.Email); err == nil {
 			http.Error(w, "O email ja esta em uso", http.StatusBadRequest)
 			return
+		} else if !errors.Is(err, repo.ErrNotFound) {
+			http.Error(w, "Erro ao verificar duplicacao de email: "+err.Error(), http.StatusInternalServerError)
+			return
 		}
 
 		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
@@ -67,63 +54,31 @@ func CreateUserHandler(storeDDB *dynamo.Store) http.HandlerFunc {
 		userID := uuid.NewString()
 		now := time.Now().Format(time.RFC3339)
 
-		userItem := map[string]types.AttributeValue{
-			"PK":          dynamo.S(store.UserPK(userID)),
-			"SK":          dynamo.S("PROFILE"),
-			"GSI2PK":      dynamo.S("EMAIL#" + strings.ToLower(req.Email)),
-			"GSI2SK":      dynamo.S(store.UserPK(userID)),
-			"id":          dynamo.S(userID),
-			"name":        dynamo.S(req.Name),
-			"email":       dynamo.S(req.Email),
-			"password":    dynamo.S(string(hashedPassword)),
-			"cpf":         dynamo.S(req.CPF),
-			"active":      dynamo.B(true),
-			"inicial":     dynamo.B(false),
-			"dell":        dynamo.B(false),
-			"date_create": dynamo.S(now),
-			"date_update": dynamo.S(""),
-		}
-
-		contaNivelID := uuid.NewString()
-		contaNivelItem := map[string]types.AttributeValue{
-			"PK":             dynamo.S(store.UserPK(userID)),
-			"SK":             dynamo.S("ACCOUNT#LEVEL"),
-			"id":             dynamo.S(contaNivelID),
-			"id_user":        dynamo.S(userID),
-			"nivel":          dynamo.S("BASICO"),
-			"ativo":          dynamo.B(false),
-			"status":         dynamo.S("INATIVO"),
-			"data_pagamento": dynamo.S(""),
-			"tipo_pagamento": dynamo.S("INATIVO"),
-			"data_update":    dynamo.S(now),
-		}
-
-		contaNivelPagID := uuid.NewString()
-		contaNivelPagItem := map[string]types.AttributeValue{
-			"PK":            dynamo.S(store.UserPK(userID)),
-			"SK":            dynamo.S("ACCOUNT#PAYMENT#" + contaNivelPagID),
-			"id":            dynamo.S(contaNivelPagID),
-			"id_user":       dynamo.S(userID),
-			"pago_data":     dynamo.S(""),
-			"pago":          dynamo.B(false),
-			"valor":         dynamo.S("0"),
-			"status":        dynamo.S("INATIVO"),
-			"codigo":        dynamo.S("111"),
-			"data_create":   dynamo.S(now),
-			"referente":     dynamo.S("01"),
-			"valido":        dynamo.B(true),
-			"txid":          dynamo.S(""),
-			"pg_status":     dynamo.S("INATIVO"),
-			"cpf":           dynamo.S(""),
-			"chave":         dynamo.S(""),
-			"pixCopiaECola": dynamo.S(""),
-			"expiracao":     dynamo.S(""),
-		}
-
-		err = storeDDB.TransactWrite(ctx, []types.TransactWriteItem{
-			{Put: &types.Put{TableName: &storeDDB.Table, Item: userItem}},
-			{Put: &types.Put{TableName: &storeDDB.Table, Item: contaNivelItem}},
-			{Put: &types.Put{TableName: &storeDDB.Table, Item: contaNivelPagItem}},
+		err = users.Create(ctx, repo.User{
+			ID:           userID,
+			Name:         req.Name,
+			Email:        req.Email,
+			PasswordHash: string(hashedPassword),
+			CPF:          req.CPF,
+			Active:       true,
+			DateCreate:   now,
+		}, repo.AccountLevel{
+			ID:            uuid.NewString(),
+			IDUser:        userID,
+			Nivel:         repo.AccountLevelBasico,
+			Status:        "INATIVO",
+			TipoPagamento: "INATIVO",
+			DataUpdate:    now,
+		}, &repo.AccountPayment{
+			ID:         uuid.NewString(),
+			IDUser:     userID,
+			Valor:      money.Text{Money: money.Cents(0)},
+			Status:     "INATIVO",
+			Codigo:     "111",
+			DataCreate: now,
+			Referente:  "01",
+			Valido:     true,
+			PgStatus:   "INATIVO",
 		})
 		if err != nil {
 			http.Error(w, "Erro ao criar o usuario: "+err.Error(), http.StatusInternalServerError)
@@ -141,7 +96,7 @@ func CreateUserHandler(storeDDB *dynamo.Store) http.HandlerFunc {
 	}
 }
 
-func UserShowHandler(storeDDB *dynamo.Store) http.HandlerFunc {
+func UserShowHandler(storeDDB dynamo.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		id := vars["id"]
@@ -151,13 +106,12 @@ func UserShowHandler(storeDDB *dynamo.Store) http.HandlerFunc {
 		}
 
 		ctx := r.Context()
-		item, err := storeDDB.GetItem(ctx, store.UserPK(id), "PROFILE")
-		if err != nil || len(item) == 0 {
+		u, err := repo.NewUserRepo(storeDDB).Get(ctx, id)
+		if errors.Is(err, repo.ErrNotFound) {
 			http.Error(w, "Usuario nao encontrado", http.StatusNotFound)
 			return
 		}
-		var u userItem
-		if err := attributevalue.UnmarshalMap(item, &u); err != nil {
+		if err != nil {
 			http.Error(w, "Erro ao buscar usuario", http.StatusInternalServerError)
 			return
 		}
@@ -179,26 +133,10 @@ type UserNameChangeRequest struct {
 	NewName string `json:"new_name"`
 }
 
-func UserNameChangeHandler(storeDDB *dynamo.Store) http.HandlerFunc {
+func UserNameChangeHandler(storeDDB dynamo.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			http.Error(w, "Token nao fornecido", http.StatusUnauthorized)
-			return
-		}
-
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		claims := jwt.MapClaims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return jwtSecretKey, nil
-		})
-		if err != nil || !token.Valid {
-			http.Error(w, "Token invalido", http.StatusUnauthorized)
-			return
-		}
-
-		idFromToken, ok := claims["sub"].(string)
-		if !ok || idFromToken == "" {
+		idFromToken := middleware.UserIDFromContext(r.Context())
+		if idFromToken == "" {
 			http.Error(w, "ID do usuario invalido", http.StatusUnauthorized)
 			return
 		}
@@ -215,13 +153,13 @@ func UserNameChangeHandler(storeDDB *dynamo.Store) http.HandlerFunc {
 		}
 
 		ctx := r.Context()
-		item, err := storeDDB.GetItem(ctx, store.UserPK(req.IDUser), "PROFILE")
-		if err != nil || len(item) == 0 {
+		users := repo.NewUserRepo(storeDDB)
+		u, err := users.Get(ctx, req.IDUser)
+		if errors.Is(err, repo.ErrNotFound) {
 			http.Error(w, "Usuario nao encontrado", http.StatusNotFound)
 			return
 		}
-		var u userItem
-		if err := attributevalue.UnmarshalMap(item, &u); err != nil {
+		if err != nil {
 			http.Error(w, "Erro ao buscar usuario", http.StatusInternalServerError)
 			return
 		}
@@ -230,14 +168,7 @@ func UserNameChangeHandler(storeDDB *dynamo.Store) http.HandlerFunc {
 			return
 		}
 
-		err = storeDDB.UpdateItem(ctx, map[string]types.AttributeValue{
-			"PK": dynamo.S(store.UserPK(req.IDUser)),
-			"SK": dynamo.S("PROFILE"),
-		}, "SET name = :n, date_update = :d", nil, map[string]types.AttributeValue{
-			":n": dynamo.S(req.NewName),
-			":d": dynamo.S(time.Now().Format(time.RFC3339)),
-		})
-		if err != nil {
+		if err := users.UpdateName(ctx, req.IDUser, req.NewName); err != nil {
 			http.Error(w, "Erro ao atualizar nome", http.StatusInternalServerError)
 			return
 		}
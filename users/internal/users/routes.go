@@ -1,23 +1,36 @@
 package users
 
 import (
+	"BACK_SORTE_GO/common/middleware"
 	"BACK_SORTE_GO/internal/app"
 
 	"github.com/gorilla/mux"
 )
 
 func RegisterRoutes(router *mux.Router, a *app.App) {
+	auth := middleware.RequireAuth(a.Auth)
+	recentMFA := middleware.RequireRecentMFA(a.Store)
+	admin := middleware.RequireRole(middleware.RoleAdmin)
+
 	router.HandleFunc("/users", CreateUserHandler(a.Store)).Methods("POST")
-	router.HandleFunc("/users/passwordChange", UserPasswordChangeHandler(a.Store)).Methods("POST")
+	router.Handle("/users/passwordChange", auth(UserPasswordChangeHandler(a.Store))).Methods("POST")
 	router.HandleFunc("/users/passwordRecover", UserPasswordRecoverStartHandler(a.Store)).Methods("POST")
 	router.HandleFunc("/users/passwordConfirmToken", UserPasswordRecoverConfirmHandler(a.Store)).Methods("POST")
-	router.HandleFunc("/users/passwordRecoverLink", UserPasswordRecoverLinkHandler(a.Store)).Methods("GET")
+	router.Handle("/users/passwordRecoverLink", auth(admin(UserPasswordRecoverLinkHandler(a.Store)))).Methods("GET")
 	router.HandleFunc("/users/confirmEmail", UserConfirmEmailHandler(a.Store)).Methods("GET")
-	router.HandleFunc("/users/bankAccount", UserBankAccountHandler(a.Store)).Methods("POST")
-	router.HandleFunc("/users/bankAccount", UserBankAccountUpdateHandler(a.Store)).Methods("PATCH")
-	router.HandleFunc("/users/bankAccount", UserBankAccountGetHandler(a.Store)).Methods("GET")
-	router.HandleFunc("/users/uploadProfileImage", UploadUserProfileImageHandler(a.Store)).Methods("POST")
+	router.Handle("/users/bankAccount", auth(recentMFA(UserBankAccountHandler(a.Store)))).Methods("POST")
+	router.Handle("/users/bankAccount", auth(recentMFA(UserBankAccountUpdateHandler(a.Store)))).Methods("PATCH")
+	router.Handle("/users/bankAccount", auth(UserBankAccountGetHandler(a.Store))).Methods("GET")
+	router.Handle("/users/uploadProfileImage", auth(UploadUserProfileImageHandler(a.Store))).Methods("POST")
 	router.HandleFunc("/users/ProfileImage/{id}", UserProfileImageHandler(a.Store)).Methods("GET")
 	router.HandleFunc("/users/show/{id}", UserShowHandler(a.Store)).Methods("GET")
-	router.HandleFunc("/users/nameChange", UserNameChangeHandler(a.Store)).Methods("POST")
+	router.Handle("/users/nameChange", auth(UserNameChangeHandler(a.Store))).Methods("POST")
+	router.Handle("/users/roles/{id}", auth(recentMFA(admin(UserRolesUpdateHandler(a.Store))))).Methods("PUT")
+	router.Handle("/users/feePolicy", auth(admin(FeePolicyListHandler(a.Store)))).Methods("GET")
+	router.Handle("/users/feePolicy", auth(recentMFA(admin(FeePolicyPublishHandler(a.Store))))).Methods("POST")
+	router.Handle("/users/twoFactor", auth(UserMFAStatusHandler(a.Store))).Methods("GET")
+	router.Handle("/users/twoFactor/setup", auth(UserMFASetupHandler(a.Store))).Methods("POST")
+	router.Handle("/users/twoFactor/enable", auth(UserMFAEnableHandler(a.Store))).Methods("POST")
+	router.Handle("/users/twoFactor/recoveryCodes", auth(recentMFA(UserMFARecoveryCodesHandler(a.Store)))).Methods("POST")
+	router.Handle("/users/twoFactor/disable", auth(recentMFA(UserMFADisableHandler(a.Store)))).Methods("POST")
 }
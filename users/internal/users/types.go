@@ -6,18 +6,6 @@ import (
 	"time"
 )
 
-type userItem struct {
-	ID         string `dynamodbav:"id"`
-	Name       string `dynamodbav:"name"`
-	Email      string `dynamodbav:"email"`
-	Password   string `dynamodbav:"password"`
-	CPF        string `dynamodbav:"cpf"`
-	Active     bool   `dynamodbav:"active"`
-	Inicial    bool   `dynamodbav:"inicial"`
-	Dell       bool   `dynamodbav:"dell"`
-	DateCreate string `dynamodbav:"date_create"`
-}
-
 func jsonResponse(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -5,8 +5,8 @@ import (
 	"log"
 	"net/http"
 
+	"BACK_SORTE_GO/common/middleware"
 	"BACK_SORTE_GO/internal/app"
-	"BACK_SORTE_GO/internal/middleware"
 	"BACK_SORTE_GO/internal/users"
 
 	"github.com/aws/aws-lambda-go/lambda"
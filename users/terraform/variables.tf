@@ -23,13 +23,8 @@ variable "aws_bucket_name_img_doacao" {
 }
 
 variable "jwt_secret" {
-  type    = string
-  default = ""
-}
-
-variable "password_reset_key" {
-  type    = string
-  default = ""
+  type      = string
+  sensitive = true
 }
 
 variable "lambda_zip" {